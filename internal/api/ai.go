@@ -319,11 +319,14 @@ func (s *Server) answerWeeklyTaskStatusQuestion(question string) (string, []AICh
 	incomplete := make([]datedTask, 0)
 	completed := make([]datedTask, 0)
 
+	logged := s.taskCompletionDates()
 	modDateCache := make(map[string]time.Time)
 	modDateKnown := make(map[string]bool)
 	for _, task := range tasks {
 		var activityDate time.Time
-		if dailyDate, ok := parseDailyNoteDate(task.Path); ok {
+		if doneDate, ok := taskCompletionDate(task, logged); ok && task.Completed {
+			activityDate = doneDate
+		} else if dailyDate, ok := parseDailyNoteDate(task.Path); ok {
 			activityDate = dailyDate
 		} else if task.DueDateISO != "" {
 			if parsed, parseErr := time.ParseInLocation("2006-01-02", task.DueDateISO, time.Local); parseErr == nil {
@@ -348,9 +351,10 @@ func (s *Server) answerWeeklyTaskStatusQuestion(question string) (string, []AICh
 			continue
 		}
 		entry := datedTask{task: task, date: activityDate}
-		if task.Completed {
+		switch {
+		case task.Completed:
 			completed = append(completed, entry)
-		} else {
+		case !isClosedTaskStatus(task.Status):
 			incomplete = append(incomplete, entry)
 		}
 	}
@@ -408,6 +412,11 @@ func (s *Server) answerWeeklyTaskStatusQuestion(question string) (string, []AICh
 			builder.WriteString(item.date.Format("2006-01-02"))
 			builder.WriteString("] ")
 			builder.WriteString(item.task.Text)
+			if item.task.Status != taskStatusOpen && !item.task.Completed {
+				builder.WriteString(" [")
+				builder.WriteString(item.task.Status)
+				builder.WriteString("]")
+			}
 			builder.WriteString(" (")
 			builder.WriteString(item.task.Path)
 			builder.WriteString(":")
@@ -494,12 +503,22 @@ func (s *Server) answerDirectTaskQuestion(question string) (string, []AIChatSour
 	}
 
 	results := make([]completedTaskResult, 0, len(tasks))
+	logged := s.taskCompletionDates()
 	modDateCache := make(map[string]time.Time)
 	modDateKnown := make(map[string]bool)
 	for _, task := range tasks {
 		if !task.Completed {
 			continue
 		}
+		if doneDate, ok := taskCompletionDate(task, logged); ok {
+			if !doneDate.Before(start) && !doneDate.After(now) {
+				results = append(results, completedTaskResult{
+					task:         task,
+					activityDate: doneDate,
+				})
+			}
+			continue
+		}
 		if dailyDate, ok := parseDailyNoteDate(task.Path); ok {
 			if !dailyDate.Before(start) && !dailyDate.After(now) {
 				results = append(results, completedTaskResult{
@@ -605,7 +624,7 @@ func (s *Server) listArchivedTasksForWeek(start, end time.Time) ([]archivedTaskR
 			return err
 		}
 		if d.IsDir() {
-			if isAiDir(d.Name()) {
+			if isHiddenDir(d.Name()) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -751,9 +770,20 @@ func (s *Server) buildAIStructuredContext(now time.Time) string {
 		date time.Time
 	}
 	completedThisWeek := make([]completedRecord, 0)
+	logged := s.taskCompletionDates()
 	overdueOpen := 0
 	dueThisWeekOpen := 0
+	inProgress := 0
+	blocked := 0
 	for _, task := range tasks {
+		switch task.Status {
+		case taskStatusCancelled, taskStatusMigrated:
+			continue
+		case taskStatusInProgress:
+			inProgress++
+		case taskStatusBlocked:
+			blocked++
+		}
 		dueDate := time.Time{}
 		if task.DueDateISO != "" {
 			if parsed, parseErr := time.ParseInLocation("2006-01-02", task.DueDateISO, time.Local); parseErr == nil {
@@ -762,10 +792,12 @@ func (s *Server) buildAIStructuredContext(now time.Time) string {
 		}
 
 		if task.Completed {
-			if dailyDate, ok := parseDailyNoteDate(task.Path); ok {
-				if !dailyDate.Before(weekStart) && !dailyDate.After(today) {
-					completedThisWeek = append(completedThisWeek, completedRecord{task: task, date: dailyDate})
-				}
+			doneDate, ok := taskCompletionDate(task, logged)
+			if !ok {
+				doneDate, ok = parseDailyNoteDate(task.Path)
+			}
+			if ok && !doneDate.Before(weekStart) && !doneDate.After(today) {
+				completedThisWeek = append(completedThisWeek, completedRecord{task: task, date: doneDate})
 			}
 			continue
 		}
@@ -811,6 +843,10 @@ func (s *Server) buildAIStructuredContext(now time.Time) string {
 	builder.WriteString(strconv.Itoa(overdueOpen))
 	builder.WriteString("\n- Open tasks due this week: ")
 	builder.WriteString(strconv.Itoa(dueThisWeekOpen))
+	builder.WriteString("\n- Tasks in progress: ")
+	builder.WriteString(strconv.Itoa(inProgress))
+	builder.WriteString("\n- Blocked tasks: ")
+	builder.WriteString(strconv.Itoa(blocked))
 	if maxExamples > 0 {
 		builder.WriteString("\n\nCompleted this week examples:\n")
 		for i := 0; i < maxExamples; i++ {
@@ -137,6 +137,8 @@ func (idx *AIIndex) ensureIndex(ctx context.Context, settings AISettings, notesD
 type noteInfo struct {
 	Path     string
 	Modified time.Time
+	Size     int64
+	Aliases  []string
 }
 
 func listMarkdownNotes(notesDir string) ([]noteInfo, error) {
@@ -146,7 +148,7 @@ func listMarkdownNotes(notesDir string) ([]noteInfo, error) {
 			return err
 		}
 		if d.IsDir() {
-			if isAiDir(d.Name()) {
+			if isHiddenDir(d.Name()) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -165,6 +167,7 @@ func listMarkdownNotes(notesDir string) ([]noteInfo, error) {
 		notes = append(notes, noteInfo{
 			Path:     filepath.ToSlash(rel),
 			Modified: info.ModTime(),
+			Size:     info.Size(),
 		})
 		return nil
 	})
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	taskBlockIDPattern = regexp.MustCompile(`(^|\s)\^(t-[0-9a-z]+)\b`)
+	taskBlockIDFormat  = regexp.MustCompile(`^t-[0-9a-z]+$`)
+)
+
+// taskRef addresses a task either by its block ID or by path, line number and
+// line hash. A block ID is looked up in Path first and then across the vault,
+// so it keeps working after the task moves.
+type taskRef struct {
+	Path       string
+	LineNumber int
+	LineHash   string
+	ID         string
+}
+
+// taskLocation is a task line found by findTask. Line has its carriage
+// return removed; Ending holds it.
+type taskLocation struct {
+	AbsPath string
+	RelPath string
+	Lines   []string
+	Index   int
+	Line    string
+	Ending  string
+}
+
+func (ref taskRef) validate() error {
+	if ref.ID != "" {
+		if !taskBlockIDFormat.MatchString(ref.ID) {
+			return errors.New("id must be a task block id such as t-3f9a")
+		}
+		return nil
+	}
+	if strings.TrimSpace(ref.Path) == "" {
+		return errors.New("path is required")
+	}
+	if ref.LineNumber <= 0 {
+		return errors.New("lineNumber must be positive")
+	}
+	return nil
+}
+
+// findTask reads the note holding the task. On failure it returns the HTTP
+// status and message to report.
+func (s *Server) findTask(ref taskRef) (*taskLocation, int, string) {
+	pathParam := ref.Path
+	if ref.ID != "" && strings.TrimSpace(pathParam) == "" {
+		pathParam = s.findTaskBlockPath(ref.ID)
+		if pathParam == "" {
+			return nil, http.StatusBadRequest, "task not found"
+		}
+	}
+
+	loc, status, msg := s.readTaskLocation(pathParam, ref)
+	if status != 0 && ref.ID != "" {
+		// The task may have moved to another note since it was listed.
+		if moved := s.findTaskBlockPath(ref.ID); moved != "" && moved != loc.RelPath {
+			loc, status, msg = s.readTaskLocation(moved, ref)
+		}
+	}
+	if status != 0 {
+		return nil, status, msg
+	}
+	return loc, 0, ""
+}
+
+func (s *Server) readTaskLocation(pathParam string, ref taskRef) (*taskLocation, int, string) {
+	absPath, relPath, err := s.resolvePath(pathParam)
+	if err != nil {
+		return &taskLocation{}, http.StatusBadRequest, err.Error()
+	}
+	loc := &taskLocation{AbsPath: absPath, RelPath: relPath}
+	if !isMarkdown(absPath) {
+		return loc, http.StatusBadRequest, "not a note file"
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return loc, http.StatusNotFound, "note not found"
+		}
+		return loc, http.StatusInternalServerError, "unable to read note"
+	}
+
+	loc.Lines = strings.Split(string(data), "\n")
+	found := false
+	if ref.ID != "" {
+		loc.Index, found = findTaskBlockLine(string(data), ref.ID)
+	} else {
+		loc.Index, found = findTaskLine(loc.Lines, ref.LineNumber, ref.LineHash)
+	}
+	if !found {
+		return loc, http.StatusBadRequest, "task not found"
+	}
+	loc.Line = loc.Lines[loc.Index]
+	if strings.HasSuffix(loc.Line, "\r") {
+		loc.Ending = "\r"
+		loc.Line = strings.TrimSuffix(loc.Line, "\r")
+	}
+	return loc, 0, ""
+}
+
+// findTaskBlockLine returns the index of the task line carrying id, skipping
+// front matter and code blocks.
+func findTaskBlockLine(content, id string) (int, bool) {
+	for _, todo := range parseTodoLines(maskFrontMatter(content)) {
+		if todo.BlockID == id {
+			return todo.LineNumber - 1, true
+		}
+	}
+	return 0, false
+}
+
+// findTaskBlockPath returns the first note, in walk order, with a task
+// carrying id.
+func (s *Server) findTaskBlockPath(id string) string {
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return ""
+	}
+	for _, note := range notes {
+		for _, todo := range note.Todos {
+			if todo.BlockID == id {
+				return note.Path
+			}
+		}
+	}
+	return ""
+}
+
+// setTaskLineBlockID replaces the block ID of a task line, or removes it when
+// id is empty. New IDs go at the end of the line.
+func setTaskLineBlockID(line string, id string) (string, bool) {
+	if id == "" {
+		return replaceTaskLineToken(line, taskBlockIDPattern, "")
+	}
+	return replaceTaskLineToken(line, taskBlockIDPattern, "^"+id)
+}
+
+// newTaskBlockID returns a random ID not in used and records it there. IDs
+// start at four hex digits and grow when the short ones keep colliding.
+func newTaskBlockID(used map[string]bool) string {
+	for size := 2; ; size++ {
+		buf := make([]byte, size)
+		for attempt := 0; attempt < 16; attempt++ {
+			if _, err := rand.Read(buf); err != nil {
+				continue
+			}
+			id := "t-" + hex.EncodeToString(buf)
+			if !used[id] {
+				used[id] = true
+				return id
+			}
+		}
+	}
+}
+
+// assignTaskBlockIDs gives every task without a block ID a new one, as does
+// a task repeating an ID used earlier in the same note. used holds the IDs
+// taken in other notes and is updated. It returns the number of IDs added.
+func assignTaskBlockIDs(content string, used map[string]bool) (string, int) {
+	lines := strings.Split(content, "\n")
+	seen := make(map[string]bool)
+	assigned := 0
+	for _, todo := range parseTodoLines(maskFrontMatter(content)) {
+		if todo.BlockID != "" && !seen[todo.BlockID] {
+			seen[todo.BlockID] = true
+			used[todo.BlockID] = true
+			continue
+		}
+		line := lines[todo.LineNumber-1]
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+			line = strings.TrimSuffix(line, "\r")
+		}
+		id := newTaskBlockID(used)
+		seen[id] = true
+		if updated, ok := setTaskLineBlockID(line, id); ok {
+			lines[todo.LineNumber-1] = updated + ending
+			assigned++
+		}
+	}
+	if assigned == 0 {
+		return content, 0
+	}
+	return strings.Join(lines, "\n"), assigned
+}
+
+// assignNoteBlockIDs applies assignTaskBlockIDs to a note that is being saved
+// when the taskBlockIds setting is on. Templates are left alone so each note
+// created from them gets its own IDs.
+func (s *Server) assignNoteBlockIDs(relPath, content string) (string, bool) {
+	if isTemplate(relPath) {
+		return content, false
+	}
+	settings, _, err := s.loadSettings()
+	if err != nil || !settings.TaskBlockIDs {
+		return content, false
+	}
+	updated, assigned := assignTaskBlockIDs(content, s.usedTaskBlockIDs(relPath))
+	return updated, assigned > 0
+}
+
+// usedTaskBlockIDs collects the block IDs of every note except skipPath.
+func (s *Server) usedTaskBlockIDs(skipPath string) map[string]bool {
+	used := make(map[string]bool)
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return used
+	}
+	for _, note := range notes {
+		if note.Path == skipPath {
+			continue
+		}
+		for _, todo := range note.Todos {
+			if todo.BlockID != "" {
+				used[todo.BlockID] = true
+			}
+		}
+	}
+	return used
+}
+
+func (s *Server) handleTasksAssignIDs(w http.ResponseWriter, r *http.Request) {
+	assigned, files, err := s.assignVaultTaskBlockIDs()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to assign task ids")
+		return
+	}
+	writeJSON(w, http.StatusOK, TaskAssignIDsResponse{Assigned: assigned, Files: files})
+}
+
+// assignVaultTaskBlockIDs backfills block IDs for every task in the vault,
+// whatever the taskBlockIds setting says.
+func (s *Server) assignVaultTaskBlockIDs() (int, int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	assigned := 0
+	filesUpdated := 0
+
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return 0, 0, err
+	}
+	used := s.usedTaskBlockIDs("")
+	for _, note := range notes {
+		path := filepath.Join(s.notesDir, filepath.FromSlash(note.Path))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		output, count := assignTaskBlockIDs(string(data), used)
+		if count == 0 {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			return 0, 0, err
+		}
+		assigned += count
+		filesUpdated += 1
+	}
+	if assigned > 0 {
+		s.logger.Info("assigned task ids", "count", assigned, "files", filesUpdated)
+	}
+	return assigned, filesUpdated, nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetTaskLineBlockID(t *testing.T) {
+	cases := []struct {
+		line string
+		id   string
+		want string
+	}{
+		{line: "- [ ] Call Bob ^2", id: "t-3f9a", want: "- [ ] Call Bob ^2 ^t-3f9a"},
+		{line: "- [ ] Call Bob ^t-0001 >2026-01-02", id: "t-3f9a", want: "- [ ] Call Bob >2026-01-02 ^t-3f9a"},
+		{line: "- [x] Ship `^t-code` ^t-0001", id: "", want: "- [x] Ship `^t-code`"},
+	}
+	for _, tc := range cases {
+		got, ok := setTaskLineBlockID(tc.line, tc.id)
+		if !ok || got != tc.want {
+			t.Fatalf("%q: expected %q, got %q", tc.line, tc.want, got)
+		}
+	}
+	if _, ok := setTaskLineBlockID("plain text", "t-3f9a"); ok {
+		t.Fatalf("expected non-task line to be rejected")
+	}
+}
+
+func TestAssignTaskBlockIDs(t *testing.T) {
+	content := strings.Join([]string{
+		"---",
+		"title: - [ ] not a task",
+		"---",
+		"- [ ] First ^t-0001",
+		"- [ ] Copy ^t-0001\r",
+		"- [ ] New",
+		"```",
+		"- [ ] In code",
+		"```",
+	}, "\n")
+	used := map[string]bool{"t-beef": true}
+	updated, assigned := assignTaskBlockIDs(content, used)
+	if assigned != 2 {
+		t.Fatalf("expected 2 ids assigned, got %d in %q", assigned, updated)
+	}
+
+	lines := strings.Split(updated, "\n")
+	if lines[1] != "title: - [ ] not a task" || lines[7] != "- [ ] In code" {
+		t.Fatalf("expected front matter and code untouched, got %q", updated)
+	}
+	if lines[3] != "- [ ] First ^t-0001" {
+		t.Fatalf("expected first id kept, got %q", lines[3])
+	}
+	if !strings.HasSuffix(lines[4], "\r") {
+		t.Fatalf("expected line ending kept, got %q", lines[4])
+	}
+	ids := make(map[string]bool)
+	for _, todo := range parseTodoLines(maskFrontMatter(updated)) {
+		if todo.BlockID == "" || ids[todo.BlockID] || todo.BlockID == "t-beef" {
+			t.Fatalf("expected unique new ids, got %#v", todo)
+		}
+		if strings.Contains(todo.Text, "^t-") {
+			t.Fatalf("expected id stripped from text, got %q", todo.Text)
+		}
+		ids[todo.BlockID] = true
+	}
+	if !used["t-0001"] || len(used) != 4 {
+		t.Fatalf("expected used ids updated, got %v", used)
+	}
+
+	if again, count := assignTaskBlockIDs(updated, used); count != 0 || again != updated {
+		t.Fatalf("expected no changes on second pass, got %d", count)
+	}
+}
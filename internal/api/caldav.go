@@ -0,0 +1,645 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// caldavInboxCollection holds the tasks without a project. Tasks created in
+// it get no +project.
+const caldavInboxCollection = "inbox"
+
+const caldavMaxBody = 1 << 20
+
+// caldavCollectionPattern matches the calendar names a +project token can
+// carry, so a task created in the calendar is listed in it again.
+var caldavCollectionPattern = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// caldavResourcesFileName maps the block IDs of tasks created over CalDAV to
+// the name and UID the client gave them.
+const caldavResourcesFileName = "caldav-resources.json"
+
+func init() {
+	chi.RegisterMethod("PROPFIND")
+	chi.RegisterMethod("REPORT")
+}
+
+// caldavTask is a task exposed as a CalDAV resource. Tasks created by a
+// client keep the name and UID it gave them, other tasks with a block ID are
+// named after it, and the rest get a name derived from their line, which
+// changes whenever the line does.
+type caldavTask struct {
+	Task       TaskItem
+	Collection string
+	Name       string
+	UID        string
+	ETag       string
+}
+
+// caldavResource is the name and UID a client gave a task it created.
+type caldavResource struct {
+	Name string `json:"name"`
+	UID  string `json:"uid,omitempty"`
+}
+
+func (s *Server) caldavTasks() ([]caldavTask, error) {
+	tasks, _, err := s.listTasks()
+	if err != nil {
+		return nil, err
+	}
+	created, err := s.loadCalDAVResources()
+	if err != nil {
+		s.logger.Warn("caldav resources load failed", "error", err)
+	}
+	resources := make([]caldavTask, 0, len(tasks))
+	for _, task := range tasks {
+		name, uid := task.ID, ""
+		if resource, ok := created[task.ID]; ok {
+			name, uid = resource.Name, resource.UID
+		} else if !taskBlockIDFormat.MatchString(name) {
+			name = "l-" + hashLine(task.ID + "\n" + task.LineHash)[:20]
+		}
+		collection := task.Project
+		if collection == "" {
+			collection = caldavInboxCollection
+		}
+		resources = append(resources, caldavTask{
+			Task:       task,
+			Collection: collection,
+			Name:       name,
+			UID:        uid,
+			ETag:       `"` + task.LineHash[:16] + `"`,
+		})
+	}
+	return resources, nil
+}
+
+func (resource caldavTask) ref() taskRef {
+	if taskBlockIDFormat.MatchString(resource.Task.ID) {
+		return taskRef{Path: resource.Task.Path, ID: resource.Task.ID}
+	}
+	return taskRef{Path: resource.Task.Path, LineNumber: resource.Task.LineNumber, LineHash: resource.Task.LineHash}
+}
+
+// handleCalDAV serves the tasks as CalDAV VTODO calendars, one per project,
+// under /caldav/<project>/<task>.ics. Clients can complete tasks, change due
+// dates and create tasks, which are appended to Inbox.md.
+func (s *Server) handleCalDAV(w http.ResponseWriter, r *http.Request) {
+	index := strings.Index(r.URL.Path, "/caldav")
+	if index < 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	base := r.URL.Path[:index+len("/caldav")]
+	rest := strings.Trim(r.URL.Path[index+len("/caldav"):], "/")
+	collection, resource := "", ""
+	if rest != "" {
+		parts := strings.Split(rest, "/")
+		if len(parts) > 2 {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		collection = parts[0]
+		if len(parts) == 2 {
+			if !strings.HasSuffix(parts[1], ".ics") {
+				writeError(w, http.StatusNotFound, "not found")
+				return
+			}
+			resource = strings.TrimSuffix(parts[1], ".ics")
+		}
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, calendar-access")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, PROPFIND, REPORT")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		s.caldavPropfind(w, r, base, collection, resource)
+	case "REPORT":
+		if collection == "" || resource != "" {
+			writeError(w, http.StatusBadRequest, "reports are supported on calendars only")
+			return
+		}
+		s.caldavReport(w, r, base, collection)
+	case http.MethodGet, http.MethodHead:
+		if resource == "" {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.caldavGet(w, r, resource)
+	case http.MethodPut:
+		if resource == "" {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.caldavPut(w, r, base, collection, resource)
+	case http.MethodDelete:
+		writeError(w, http.StatusForbidden, "tasks cannot be deleted over caldav")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) caldavPropfind(w http.ResponseWriter, r *http.Request, base, collection, resource string) {
+	resources, err := s.caldavTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load tasks")
+		return
+	}
+	// Depth infinity is answered as depth 1.
+	deep := strings.TrimSpace(r.Header.Get("Depth")) != "0"
+
+	responses := make([]davResponse, 0)
+	switch {
+	case collection == "":
+		responses = append(responses, caldavHomeResponse(base))
+		if deep {
+			for _, name := range caldavCollections(resources) {
+				responses = append(responses, caldavCollectionResponse(base, name, resources))
+			}
+		}
+	case resource == "":
+		if !caldavCollectionExists(resources, collection) {
+			writeError(w, http.StatusNotFound, "calendar not found")
+			return
+		}
+		responses = append(responses, caldavCollectionResponse(base, collection, resources))
+		if deep {
+			for _, item := range resources {
+				if item.Collection == collection {
+					responses = append(responses, caldavResourceResponse(base, item, ""))
+				}
+			}
+		}
+	default:
+		item, ok := findCalDAVTask(resources, resource)
+		if !ok {
+			writeError(w, http.StatusNotFound, "task not found")
+			return
+		}
+		responses = append(responses, caldavResourceResponse(base, item, ""))
+	}
+	writeMultistatus(w, responses)
+}
+
+type caldavReportRequest struct {
+	XMLName xml.Name
+	Hrefs   []string `xml:"DAV: href"`
+}
+
+// caldavReport answers calendar-multiget and calendar-query. Query filters
+// are not applied; every task in the calendar is returned.
+func (s *Server) caldavReport(w http.ResponseWriter, r *http.Request, base, collection string) {
+	var report caldavReportRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, caldavMaxBody)).Decode(&report); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid report")
+		return
+	}
+	resources, err := s.caldavTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load tasks")
+		return
+	}
+	if !caldavCollectionExists(resources, collection) {
+		writeError(w, http.StatusNotFound, "calendar not found")
+		return
+	}
+	baseURL := requestBaseURL(r)
+
+	responses := make([]davResponse, 0)
+	switch report.XMLName.Local {
+	case "calendar-multiget":
+		for _, href := range report.Hrefs {
+			href = strings.TrimSpace(href)
+			name, err := url.PathUnescape(path.Base(href))
+			if err != nil {
+				name = path.Base(href)
+			}
+			item, ok := findCalDAVTask(resources, strings.TrimSuffix(name, ".ics"))
+			if !ok {
+				responses = append(responses, davResponse{Href: href, Status: davStatus(http.StatusNotFound)})
+				continue
+			}
+			response := caldavResourceResponse(base, item, caldavCalendarData(item, baseURL))
+			// Answer at the address asked for, which differs when the
+			// task's project changed.
+			response.Href = href
+			responses = append(responses, response)
+		}
+	case "calendar-query":
+		for _, item := range resources {
+			if item.Collection == collection {
+				responses = append(responses, caldavResourceResponse(base, item, caldavCalendarData(item, baseURL)))
+			}
+		}
+	default:
+		writeError(w, http.StatusNotImplemented, "unsupported report")
+		return
+	}
+	writeMultistatus(w, responses)
+}
+
+func (s *Server) caldavGet(w http.ResponseWriter, r *http.Request, resource string) {
+	resources, err := s.caldavTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load tasks")
+		return
+	}
+	item, ok := findCalDAVTask(resources, resource)
+	if !ok {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", item.ETag)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(caldavCalendarData(item, requestBaseURL(r))))
+}
+
+// caldavPut writes a client's VTODO back to its task line. Only the status and
+// the due date are applied, and a VTODO without DUE clears the task's due
+// date; other edits are ignored. A VTODO for an unknown
+// resource becomes a new task in Inbox.md.
+func (s *Server) caldavPut(w http.ResponseWriter, r *http.Request, base, collection, resource string) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, caldavMaxBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unable to read body")
+		return
+	}
+	todo, err := parseICSTodo(string(data))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := ""
+	if todo.Status != "" {
+		var ok bool
+		status, ok = caldavTaskStatus(todo.Status)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "unsupported STATUS")
+			return
+		}
+	}
+
+	resources, err := s.caldavTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load tasks")
+		return
+	}
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	item, ok := findCalDAVTask(resources, resource)
+	if !ok {
+		// A name this server handed out that no longer resolves means the
+		// task line changed since the client synced.
+		if ifMatch != "" || taskBlockIDFormat.MatchString(resource) || strings.HasPrefix(resource, "l-") {
+			writeError(w, http.StatusPreconditionFailed, "task changed")
+			return
+		}
+		s.caldavCreate(w, base, collection, resource, todo, status)
+		return
+	}
+	if strings.TrimSpace(r.Header.Get("If-None-Match")) == "*" {
+		writeError(w, http.StatusPreconditionFailed, "task exists")
+		return
+	}
+	if ifMatch != "" && ifMatch != "*" && ifMatch != item.ETag {
+		writeError(w, http.StatusPreconditionFailed, "task changed")
+		return
+	}
+
+	// A task whose due token does not parse is sent without DUE, so only a
+	// date the client was given can be cleared.
+	var due *string
+	if todo.Due != item.Task.DueDateISO {
+		due = &todo.Due
+	}
+	current, _ := caldavTaskStatus(caldavCurrentStatus(item.Task))
+	code, msg := 0, ""
+	switch {
+	case status != "" && status != current:
+		_, code, msg = s.updateTaskStatusDue(item.ref(), status, due)
+	case due != nil:
+		code, msg = s.updateTaskDue(item.ref(), *due)
+	}
+	if code != 0 {
+		writeError(w, code, msg)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// caldavCreate appends a client's new VTODO to Inbox.md. The task keeps the
+// resource name and UID the client chose, so its next sync finds it there.
+func (s *Server) caldavCreate(w http.ResponseWriter, base, collection, resource string, todo icsTodo, status string) {
+	summary := strings.Join(strings.Fields(todo.Summary), " ")
+	if summary == "" {
+		writeError(w, http.StatusBadRequest, "SUMMARY is required")
+		return
+	}
+	if collection != caldavInboxCollection && !caldavCollectionPattern.MatchString(collection) {
+		writeError(w, http.StatusForbidden, "calendar name must be a project name of letters only")
+		return
+	}
+	line := "- [ ] " + summary
+	if collection != caldavInboxCollection && !strings.Contains(strings.ToLower(summary), "+"+strings.ToLower(collection)) {
+		line += " +" + collection
+	}
+	if todo.Due != "" {
+		line += " >" + todo.Due
+	}
+	if todo.Priority > 0 {
+		line += " ^" + strconv.Itoa((todo.Priority+1)/2)
+	}
+	if status != "" && status != taskStatusOpen {
+		line, _ = setTaskLineStatus(line, status)
+	}
+
+	if err := s.ensureInboxNote(); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to create inbox note")
+		return
+	}
+	absPath, _, err := s.resolvePath(inboxNotePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to resolve inbox note")
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	used := s.usedTaskBlockIDs("")
+	id := newTaskBlockID(used)
+	line, _ = setTaskLineBlockID(line, id)
+	created, err := s.loadCalDAVResources()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load caldav resources")
+		return
+	}
+	// Drop the names of tasks that have since been deleted.
+	for blockID := range created {
+		if !used[blockID] {
+			delete(created, blockID)
+		}
+	}
+	created[id] = caldavResource{Name: resource, UID: todo.UID}
+	if err := s.saveCalDAVResources(created); err != nil {
+		s.logger.Error("unable to save caldav resources", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to save caldav resources")
+		return
+	}
+
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read inbox note")
+		return
+	}
+	updated := string(content)
+	if updated != "" && !strings.HasSuffix(updated, "\n") {
+		updated += "\n"
+	}
+	updated += line + "\n"
+	if err := os.WriteFile(absPath, []byte(updated), 0o644); err != nil {
+		s.logger.Error("unable to add caldav task", "path", inboxNotePath, "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to update note")
+		return
+	}
+
+	s.logger.Info("caldav task created", "path", inboxNotePath, "id", id)
+	w.Header().Set("Location", base+"/"+url.PathEscape(collection)+"/"+url.PathEscape(resource)+".ics")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) caldavResourcesFilePath() string {
+	return filepath.Join(s.notesDir, caldavResourcesFileName)
+}
+
+func (s *Server) loadCalDAVResources() (map[string]caldavResource, error) {
+	data, err := os.ReadFile(s.caldavResourcesFilePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]caldavResource{}, nil
+		}
+		return nil, err
+	}
+	var resources map[string]caldavResource
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, err
+	}
+	if resources == nil {
+		resources = map[string]caldavResource{}
+	}
+	return resources, nil
+}
+
+func (s *Server) saveCalDAVResources(resources map[string]caldavResource) error {
+	data, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.caldavResourcesFilePath(), data)
+}
+
+// caldavCurrentStatus is the VTODO status a task is shown with. Blocked and
+// deferred tasks show as NEEDS-ACTION, so only a change made by the client
+// moves them.
+func caldavCurrentStatus(task TaskItem) string {
+	return taskICSComponent(task, "", false).Status
+}
+
+func caldavTaskStatus(status string) (string, bool) {
+	switch status {
+	case "NEEDS-ACTION":
+		return taskStatusOpen, true
+	case "IN-PROCESS":
+		return taskStatusInProgress, true
+	case "COMPLETED":
+		return taskStatusDone, true
+	case "CANCELLED":
+		return taskStatusCancelled, true
+	}
+	return "", false
+}
+
+func caldavCalendarData(item caldavTask, baseURL string) string {
+	entry := taskICSComponent(item.Task, baseURL, false)
+	if item.UID != "" {
+		entry.UID = item.UID
+	}
+	return renderICS("Scoli: "+item.Collection, []icsEntry{entry}, timeNow())
+}
+
+func caldavCollections(resources []caldavTask) []string {
+	seen := map[string]bool{caldavInboxCollection: true}
+	names := []string{caldavInboxCollection}
+	for _, item := range resources {
+		if !seen[item.Collection] {
+			seen[item.Collection] = true
+			names = append(names, item.Collection)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+func caldavCollectionExists(resources []caldavTask, collection string) bool {
+	if collection == caldavInboxCollection {
+		return true
+	}
+	for _, item := range resources {
+		if item.Collection == collection {
+			return true
+		}
+	}
+	return false
+}
+
+// findCalDAVTask looks a resource up by name in any calendar, so a task whose
+// project changed is still found at its old address.
+func findCalDAVTask(resources []caldavTask, name string) (caldavTask, bool) {
+	for _, item := range resources {
+		if item.Name == name {
+			return item, true
+		}
+	}
+	return caldavTask{}, false
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	DAV       string        `xml:"xmlns:D,attr"`
+	CalDAV    string        `xml:"xmlns:C,attr"`
+	CalServer string        `xml:"xmlns:CS,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string       `xml:"D:href"`
+	Propstat *davPropstat `xml:"D:propstat,omitempty"`
+	Status   string       `xml:"D:status,omitempty"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	ResourceType         *davResourceType `xml:"D:resourcetype,omitempty"`
+	DisplayName          string           `xml:"D:displayname,omitempty"`
+	CurrentUserPrincipal *davHref         `xml:"D:current-user-principal,omitempty"`
+	PrincipalURL         *davHref         `xml:"D:principal-URL,omitempty"`
+	CalendarHomeSet      *davHref         `xml:"C:calendar-home-set,omitempty"`
+	SupportedComponents  *davCompSet      `xml:"C:supported-calendar-component-set,omitempty"`
+	CTag                 string           `xml:"CS:getctag,omitempty"`
+	ETag                 string           `xml:"D:getetag,omitempty"`
+	ContentType          string           `xml:"D:getcontenttype,omitempty"`
+	CalendarData         string           `xml:"C:calendar-data,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+	Calendar   *struct{} `xml:"C:calendar,omitempty"`
+}
+
+type davHref struct {
+	Href string `xml:"D:href"`
+}
+
+type davCompSet struct {
+	Comps []davComp `xml:"C:comp"`
+}
+
+type davComp struct {
+	Name string `xml:"name,attr"`
+}
+
+func davStatus(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(davMultistatus{
+		DAV:       "DAV:",
+		CalDAV:    "urn:ietf:params:xml:ns:caldav",
+		CalServer: "http://calendarserver.org/ns/",
+		Responses: responses,
+	})
+}
+
+func caldavHomeResponse(base string) davResponse {
+	home := &davHref{Href: base + "/"}
+	return davResponse{
+		Href: base + "/",
+		Propstat: &davPropstat{
+			Prop: davProp{
+				ResourceType:         &davResourceType{Collection: &struct{}{}},
+				DisplayName:          "Scoli",
+				CurrentUserPrincipal: home,
+				PrincipalURL:         home,
+				CalendarHomeSet:      home,
+			},
+			Status: davStatus(http.StatusOK),
+		},
+	}
+}
+
+// caldavCollectionResponse describes a project calendar. Its ctag changes
+// whenever one of its tasks does.
+func caldavCollectionResponse(base, collection string, resources []caldavTask) davResponse {
+	var tag strings.Builder
+	for _, item := range resources {
+		if item.Collection == collection {
+			tag.WriteString(item.Name)
+			tag.WriteString(item.ETag)
+		}
+	}
+	displayName := collection
+	if collection == caldavInboxCollection {
+		displayName = "Inbox"
+	}
+	return davResponse{
+		Href: base + "/" + url.PathEscape(collection) + "/",
+		Propstat: &davPropstat{
+			Prop: davProp{
+				ResourceType:         &davResourceType{Collection: &struct{}{}, Calendar: &struct{}{}},
+				DisplayName:          displayName,
+				CurrentUserPrincipal: &davHref{Href: base + "/"},
+				SupportedComponents:  &davCompSet{Comps: []davComp{{Name: "VTODO"}}},
+				CTag:                 `"` + hashLine(tag.String())[:16] + `"`,
+			},
+			Status: davStatus(http.StatusOK),
+		},
+	}
+}
+
+func caldavResourceResponse(base string, item caldavTask, calendarData string) davResponse {
+	return davResponse{
+		Href: base + "/" + url.PathEscape(item.Collection) + "/" + url.PathEscape(item.Name) + ".ics",
+		Propstat: &davPropstat{
+			Prop: davProp{
+				ResourceType: &davResourceType{},
+				ETag:         item.ETag,
+				ContentType:  "text/calendar; charset=utf-8; component=VTODO",
+				CalendarData: calendarData,
+			},
+			Status: davStatus(http.StatusOK),
+		},
+	}
+}
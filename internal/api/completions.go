@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const taskLogFileName = "task-log.jsonl"
+
+// Task log events. A reopened entry cancels the done entry before it for the
+// same task.
+const (
+	taskLogDone     = "done"
+	taskLogReopened = "reopened"
+)
+
+// TaskLogEntry is one line of the append-only completion log.
+type TaskLogEntry struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Text    string    `json:"text"`
+	Project string    `json:"project,omitempty"`
+}
+
+type TaskLogResponse struct {
+	Entries []TaskLogEntry `json:"entries"`
+}
+
+func (s *Server) taskLogPath() string {
+	return filepath.Join(s.notesDir, taskLogFileName)
+}
+
+func newTaskLogEntry(event string, task TaskItem) TaskLogEntry {
+	return TaskLogEntry{
+		Time:    timeNow(),
+		Event:   event,
+		ID:      task.ID,
+		Path:    task.Path,
+		Text:    task.Text,
+		Project: task.Project,
+	}
+}
+
+// taskLogKey identifies a task across log entries and listings: by block ID
+// when it has one, otherwise by note and text.
+func taskLogKey(id, path, text string) string {
+	if taskBlockIDFormat.MatchString(id) {
+		return id
+	}
+	return path + "\x00" + strings.ToLower(text)
+}
+
+func (s *Server) appendTaskLog(entries ...TaskLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	s.taskLogMu.Lock()
+	defer s.taskLogMu.Unlock()
+	file, err := os.OpenFile(s.taskLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readTaskLog returns the log in file order. Lines that do not parse are
+// skipped.
+func (s *Server) readTaskLog() ([]TaskLogEntry, error) {
+	s.taskLogMu.Lock()
+	defer s.taskLogMu.Unlock()
+	file, err := os.Open(s.taskLogPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TaskLogEntry{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]TaskLogEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry TaskLogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			s.logger.Warn("skipping task log line", "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// completedTasks returns the latest done entry of every task that has not been
+// reopened since, oldest first.
+func completedTasks(entries []TaskLogEntry) []TaskLogEntry {
+	latest := make(map[string]TaskLogEntry)
+	for _, entry := range entries {
+		key := taskLogKey(entry.ID, entry.Path, entry.Text)
+		switch entry.Event {
+		case taskLogDone:
+			latest[key] = entry
+		case taskLogReopened:
+			delete(latest, key)
+		}
+	}
+	completed := make([]TaskLogEntry, 0, len(latest))
+	for _, entry := range latest {
+		completed = append(completed, entry)
+	}
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[i].Time.Before(completed[j].Time)
+	})
+	return completed
+}
+
+// completedBetween returns the completions logged on the days from start to
+// end, inclusive.
+func (s *Server) completedBetween(start, end time.Time) ([]TaskLogEntry, error) {
+	entries, err := s.readTaskLog()
+	if err != nil {
+		return nil, err
+	}
+	start = dateOnly(start)
+	end = dateOnly(end)
+	result := make([]TaskLogEntry, 0)
+	for _, entry := range completedTasks(entries) {
+		day := dateOnly(entry.Time.In(start.Location()))
+		if day.Before(start) || day.After(end) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// taskCompletionDates maps taskLogKey to the day each task still counted as
+// done was completed.
+func (s *Server) taskCompletionDates() map[string]time.Time {
+	dates := make(map[string]time.Time)
+	entries, err := s.readTaskLog()
+	if err != nil {
+		s.logger.Warn("unable to read task log", "error", err)
+		return dates
+	}
+	for _, entry := range completedTasks(entries) {
+		dates[taskLogKey(entry.ID, entry.Path, entry.Text)] = dateOnly(entry.Time.In(time.Local))
+	}
+	return dates
+}
+
+// taskCompletionDate is the day a completed task was finished, from its
+// ✓date token or else from the log.
+func taskCompletionDate(task TaskItem, logged map[string]time.Time) (time.Time, bool) {
+	if task.CompletedDate != "" {
+		if parsed, err := time.ParseInLocation(dailyDateLayout, task.CompletedDate, time.Local); err == nil {
+			return parsed, true
+		}
+	}
+	date, ok := logged[taskLogKey(task.ID, task.Path, task.Text)]
+	return date, ok
+}
+
+func (s *Server) handleTasksLog(w http.ResponseWriter, r *http.Request) {
+	from := strings.TrimSpace(r.URL.Query().Get("from"))
+	to := strings.TrimSpace(r.URL.Query().Get("to"))
+	var start, end time.Time
+	if from != "" {
+		iso, ok := resolveDueDate(from, dateOnly(timeNow()))
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid from date")
+			return
+		}
+		start, _ = time.ParseInLocation(dailyDateLayout, iso, time.Local)
+	}
+	if to != "" {
+		iso, ok := resolveDueDate(to, dateOnly(timeNow()))
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid to date")
+			return
+		}
+		end, _ = time.ParseInLocation(dailyDateLayout, iso, time.Local)
+	}
+
+	entries, err := s.readTaskLog()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read task log")
+		return
+	}
+	filtered := make([]TaskLogEntry, 0, len(entries))
+	for _, entry := range entries {
+		day := dateOnly(entry.Time.In(time.Local))
+		if !start.IsZero() && day.Before(start) {
+			continue
+		}
+		if !end.IsZero() && day.After(end) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	writeJSON(w, http.StatusOK, TaskLogResponse{Entries: filtered})
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompletedTasks(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 9, 0, 0, 0, time.Local)
+	}
+	entries := []TaskLogEntry{
+		{Time: day(12), Event: taskLogDone, ID: "t-0001", Path: "a.md", Text: "Ship"},
+		{Time: day(13), Event: taskLogDone, ID: "a.md:4", Path: "a.md", Text: "Call Bob"},
+		{Time: day(14), Event: taskLogReopened, ID: "a.md:4", Path: "a.md", Text: "call bob"},
+		// The block ID follows the task to another note.
+		{Time: day(15), Event: taskLogReopened, ID: "t-0001", Path: "b.md", Text: "Ship"},
+		{Time: day(16), Event: taskLogDone, ID: "t-0001", Path: "b.md", Text: "Ship v2"},
+		{Time: day(11), Event: taskLogDone, ID: "c.md:1", Path: "c.md", Text: "Old"},
+	}
+	completed := completedTasks(entries)
+	if len(completed) != 2 || completed[0].Text != "Old" || completed[1].Text != "Ship v2" {
+		t.Fatalf("unexpected completions %#v", completed)
+	}
+}
+
+func TestSetTaskLineCompletedDate(t *testing.T) {
+	line, ok := setTaskLineCompletedDate("- [x] Ship `✓2020-01-01` ^t-0001", "2026-10-17")
+	if !ok || line != "- [x] Ship `✓2020-01-01` ^t-0001 ✓2026-10-17" {
+		t.Fatalf("unexpected line %q", line)
+	}
+	line, _ = setTaskLineCompletedDate(line, "")
+	if line != "- [x] Ship `✓2020-01-01` ^t-0001" {
+		t.Fatalf("expected date removed, got %q", line)
+	}
+
+	todos := parseTodoLines("- [x] Ship ✓2026-10-17 >2026-10-20")
+	if len(todos) != 1 || todos[0].CompletedDate != "2026-10-17" || todos[0].Text != "Ship" {
+		t.Fatalf("unexpected parse %#v", todos)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	diffMaxEdits     = 2000
+)
+
+type diffOp struct {
+	kind byte
+	a    int
+	b    int
+}
+
+// unifiedDiff renders a line-based unified diff between two texts, using the
+// same header and hunk format as `diff -u`. Identical inputs yield "".
+func unifiedDiff(fromName, toName, from, to string) string {
+	a := splitDiffLines(from)
+	b := splitDiffLines(to)
+	ops := diffLines(a, b)
+
+	changes := make([]int, 0)
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(changes); {
+		start := changes[i] - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContextLines {
+			j++
+		}
+		end := changes[j] + diffContextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+		writeDiffHunk(&out, ops[start:end], a, b)
+		i = j + 1
+	}
+	return out.String()
+}
+
+func writeDiffHunk(out *strings.Builder, ops []diffOp, a, b []string) {
+	aStart, bStart := -1, -1
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if aStart == -1 {
+				aStart = op.a
+			}
+			aCount++
+		}
+		if op.kind != '-' {
+			if bStart == -1 {
+				bStart = op.b
+			}
+			bCount++
+		}
+	}
+	if aStart == -1 {
+		aStart = ops[0].a
+	}
+	if bStart == -1 {
+		bStart = ops[0].b
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", diffRange(aStart, aCount), diffRange(bStart, bCount))
+	for _, op := range ops {
+		switch op.kind {
+		case '-':
+			out.WriteString("-" + a[op.a] + "\n")
+		case '+':
+			out.WriteString("+" + b[op.b] + "\n")
+		default:
+			out.WriteString(" " + a[op.a] + "\n")
+		}
+	}
+}
+
+func diffRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script with Myers' algorithm. Inserted
+// lines carry the position in a where they apply, deleted lines the position
+// in b, so hunk ranges can be derived from any op. Very large edit distances
+// fall back to replacing everything.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := n + m
+	if limit > diffMaxEdits {
+		limit = diffMaxEdits
+	}
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	trace := make([][]int, 0)
+	found := false
+	for d := 0; d <= limit && !found; d++ {
+		// Keep only the diagonals this round can read to bound memory.
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		ops := make([]diffOp, 0, n+m)
+		for i := 0; i < n; i++ {
+			ops = append(ops, diffOp{kind: '-', a: i, b: 0})
+		}
+		for j := 0; j < m; j++ {
+			ops = append(ops, diffOp{kind: '+', a: n, b: j})
+		}
+		return ops
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		window := trace[d]
+		base := d + 1
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && window[base+k-1] < window[base+k+1]) {
+			prevK = k + 1
+		}
+		prevX := window[base+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{kind: ' ', a: x, b: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{kind: '+', a: x, b: y})
+		} else {
+			x--
+			ops = append(ops, diffOp{kind: '-', a: x, b: y})
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,23 @@
+package api
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	to := "one\ntwo\nthree\nFOUR\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,7 +1,7 @@\n one\n two\n three\n-four\n+FOUR\n five\n six\n seven\n" +
+		"@@ -8,3 +8,4 @@\n eight\n nine\n ten\n+eleven\n"
+	if diff := unifiedDiff("a", "b", from, to); diff != expected {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	if diff := unifiedDiff("a", "b", "same\n", "same\n"); diff != "" {
+		t.Fatalf("expected empty diff, got %q", diff)
+	}
+
+	expected = "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+new\n+lines\n"
+	if diff := unifiedDiff("a", "b", "", "new\nlines"); diff != expected {
+		t.Fatalf("unexpected insert diff:\n%s", diff)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var relativeWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// resolveDueDate accepts the fixed layouts of normalizeDueDate and the
+// relative forms of parseRelativeDate, which count from anchor.
+func resolveDueDate(raw string, anchor time.Time) (string, bool) {
+	if iso, ok := normalizeDueDate(raw); ok {
+		return iso, true
+	}
+	if date, ok := parseRelativeDate(raw, anchor); ok {
+		return date.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+// parseRelativeDate understands today, tomorrow, yesterday, weekday names
+// (the next such day, counting the anchor itself), next-week, next-month,
+// next-year, eow, eom, eoy and offsets such as +3d, -1w, +2m or +1y.
+func parseRelativeDate(raw string, anchor time.Time) (time.Time, bool) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	day := dateOnly(anchor)
+	switch value {
+	case "today", "tod":
+		return day, true
+	case "tomorrow", "tom":
+		return day.AddDate(0, 0, 1), true
+	case "yesterday":
+		return day.AddDate(0, 0, -1), true
+	case "next-week":
+		return day.AddDate(0, 0, daysUntilWeekday(day, time.Monday, true)), true
+	case "next-month":
+		return addMonthsClamped(day, 1, 1), true
+	case "next-year":
+		return time.Date(day.Year()+1, time.January, 1, 0, 0, 0, 0, day.Location()), true
+	case "eow":
+		return day.AddDate(0, 0, daysUntilWeekday(day, time.Sunday, false)), true
+	case "eom":
+		return addMonthsClamped(day, 0, 31), true
+	case "eoy":
+		return time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, day.Location()), true
+	}
+	if weekday, ok := relativeWeekdays[value]; ok {
+		return day.AddDate(0, 0, daysUntilWeekday(day, weekday, false)), true
+	}
+	if len(value) < 3 || (value[0] != '+' && value[0] != '-') {
+		return time.Time{}, false
+	}
+	amount, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || amount < 0 {
+		return time.Time{}, false
+	}
+	if value[0] == '-' {
+		amount = -amount
+	}
+	switch value[len(value)-1] {
+	case 'd':
+		return day.AddDate(0, 0, amount), true
+	case 'w':
+		return day.AddDate(0, 0, 7*amount), true
+	case 'm':
+		return addMonthsClamped(day, amount, day.Day()), true
+	case 'y':
+		return addMonthsClamped(day, 12*amount, day.Day()), true
+	}
+	return time.Time{}, false
+}
+
+// daysUntilWeekday counts the days from day to the next weekday. With strict,
+// a day that already is that weekday moves on a week.
+func daysUntilWeekday(day time.Time, weekday time.Weekday, strict bool) int {
+	days := (int(weekday) - int(day.Weekday()) + 7) % 7
+	if days == 0 && strict {
+		days = 7
+	}
+	return days
+}
+
+// dueDateAnchor is the date relative due dates count from: the date of a
+// daily note, or today for every other note.
+func dueDateAnchor(relPath string) time.Time {
+	if noteDate, ok := parseDailyNoteDate(relPath); ok {
+		return noteDate
+	}
+	return dateOnly(timeNow())
+}
+
+// normalizeNoteDueDates applies normalizeRelativeDueDates to a note that is
+// being saved when the normalizeDueDates setting is on. Templates are left
+// alone so their relative dates resolve for each new note.
+func (s *Server) normalizeNoteDueDates(relPath, content string) (string, bool) {
+	if isTemplate(relPath) {
+		return content, false
+	}
+	settings, _, err := s.loadSettings()
+	if err != nil || !settings.NormalizeDueDates {
+		return content, false
+	}
+	return normalizeRelativeDueDates(content, relPath)
+}
+
+// normalizeRelativeDueDates rewrites relative due dates in the note's task
+// lines to ISO dates, and reports whether anything changed.
+func normalizeRelativeDueDates(content, relPath string) (string, bool) {
+	todos := parseTodoLines(maskFrontMatter(content))
+	lines := strings.Split(content, "\n")
+	anchor := dueDateAnchor(relPath)
+	changed := false
+	for _, todo := range todos {
+		if !todo.DueDateRelative {
+			continue
+		}
+		iso, ok := resolveDueDate(todo.DueDateRaw, anchor)
+		if !ok {
+			continue
+		}
+		line := lines[todo.LineNumber-1]
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+			line = strings.TrimSuffix(line, "\r")
+		}
+		if updated, ok := setTaskLineDueDate(line, iso); ok {
+			lines[todo.LineNumber-1] = updated + ending
+			changed = true
+		}
+	}
+	if !changed {
+		return content, false
+	}
+	return strings.Join(lines, "\n"), true
+}
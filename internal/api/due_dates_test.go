@@ -0,0 +1,55 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRelativeDate(t *testing.T) {
+	// 2026-01-07 is a Wednesday.
+	anchor := time.Date(2026, 1, 7, 15, 30, 0, 0, time.Local)
+	cases := map[string]string{
+		"today":      "2026-01-07",
+		"Tomorrow":   "2026-01-08",
+		"yesterday":  "2026-01-06",
+		"wed":        "2026-01-07",
+		"fri":        "2026-01-09",
+		"monday":     "2026-01-12",
+		"next-week":  "2026-01-12",
+		"next-month": "2026-02-01",
+		"next-year":  "2027-01-01",
+		"eow":        "2026-01-11",
+		"eom":        "2026-01-31",
+		"eoy":        "2026-12-31",
+		"+3d":        "2026-01-10",
+		"-1w":        "2025-12-31",
+		"+1m":        "2026-02-07",
+		"+1y":        "2027-01-07",
+	}
+	for raw, want := range cases {
+		got, ok := parseRelativeDate(raw, anchor)
+		if !ok {
+			t.Fatalf("%s: expected relative date", raw)
+		}
+		if got.Format("2006-01-02") != want {
+			t.Fatalf("%s: expected %s, got %s", raw, want, got.Format("2006-01-02"))
+		}
+	}
+	for _, raw := range []string{"", "later", "3d", "+d", "+3x", "next-fri"} {
+		if _, ok := parseRelativeDate(raw, anchor); ok {
+			t.Fatalf("%q: expected invalid relative date", raw)
+		}
+	}
+}
+
+func TestNormalizeRelativeDueDates(t *testing.T) {
+	content := "---\ndue: >tomorrow\n---\n- [ ] Call >tomorrow #home\n- [ ] Fixed >2026-03-01\n```\n- [ ] In code >tomorrow\n```\n"
+	got, changed := normalizeRelativeDueDates(content, "Daily/2026-01-07.md")
+	want := "---\ndue: >tomorrow\n---\n- [ ] Call #home >2026-01-08\n- [ ] Fixed >2026-03-01\n```\n- [ ] In code >tomorrow\n```\n"
+	if !changed || got != want {
+		t.Fatalf("unexpected normalized content %q", got)
+	}
+	if _, changed := normalizeRelativeDueDates(want, "Daily/2026-01-07.md"); changed {
+		t.Fatalf("expected no changes for absolute dates")
+	}
+}
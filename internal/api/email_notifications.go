@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -184,7 +185,7 @@ func (s *Server) buildEmailTokens(settings EmailSettings) (map[string]string, er
 	openTasks := make([]TaskItem, 0, len(tasks))
 
 	for _, task := range tasks {
-		if task.Completed {
+		if isClosedTaskStatus(task.Status) {
 			continue
 		}
 		openTasks = append(openTasks, task)
@@ -215,7 +216,7 @@ func (s *Server) buildEmailTokens(settings EmailSettings) (map[string]string, er
 	tasksByProject := formatTasksByProject(openTasks)
 	yesterdaySummary, completedYesterday := s.buildYesterdaySummary()
 
-	return map[string]string{
+	tokens := map[string]string{
 		"date":                today.Format(dailyDateLayout),
 		"tasks_overdue":       formatTaskList(overdue),
 		"tasks_today":         formatTaskList(dueToday),
@@ -223,7 +224,45 @@ func (s *Server) buildEmailTokens(settings EmailSettings) (map[string]string, er
 		"tasks_by_project":    tasksByProject,
 		"notes_summary":       yesterdaySummary,
 		"completed_yesterday": completedYesterday,
-	}, nil
+	}
+	s.addFilterEmailTokens(tokens, tasks, today)
+	return tokens, nil
+}
+
+// addFilterEmailTokens adds a {{filter:<id>}} token for each saved task
+// filter, listing its matches grouped and sorted as the filter says.
+func (s *Server) addFilterEmailTokens(tokens map[string]string, tasks []TaskItem, today time.Time) {
+	if _, err := os.Stat(s.taskFiltersFilePath()); err != nil {
+		return
+	}
+	filters, _, err := s.loadTaskFilters()
+	if err != nil {
+		s.logger.Warn("unable to load task filters", "error", err)
+		return
+	}
+	for _, filter := range filters.Filters {
+		query := taskQuery{Filters: []TaskFilter{filter}, Sort: filter.Sort, GroupBy: filter.GroupBy}
+		matched, groups := query.apply(slices.Clone(tasks), today)
+		tokens["filter:"+filter.ID] = formatTaskGroups(matched, groups)
+	}
+}
+
+func formatTaskGroups(tasks []TaskItem, groups []TaskGroup) string {
+	if len(groups) == 0 {
+		return formatTaskList(tasks)
+	}
+	var lines []string
+	for _, group := range groups {
+		label := group.Key
+		if label == "" {
+			label = "None"
+		}
+		lines = append(lines, label+":")
+		for _, task := range group.Tasks {
+			lines = append(lines, "  "+formatTaskLine(task))
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
 func sortTasksByDue(tasks []TaskItem) {
@@ -296,6 +335,9 @@ func formatTasksByProject(tasks []TaskItem) string {
 
 func formatTaskLine(task TaskItem) string {
 	parts := []string{task.Text}
+	if task.Status != "" && task.Status != taskStatusOpen {
+		parts = append(parts, task.Status)
+	}
 	if task.Project != "" {
 		parts = append(parts, "+"+task.Project)
 	}
@@ -308,18 +350,36 @@ func formatTaskLine(task TaskItem) string {
 	return "- " + strings.Join(parts, " · ")
 }
 
+// buildYesterdaySummary summarizes yesterday's daily note and lists the tasks
+// completed yesterday: those in the completion log, then done tasks in the
+// daily note that the log does not cover.
 func (s *Server) buildYesterdaySummary() (string, string) {
 	yesterday := dateOnly(timeNow()).AddDate(0, 0, -1)
+	completed := make([]string, 0)
+	seen := make(map[string]bool)
+	logged, err := s.completedBetween(yesterday, yesterday)
+	if err != nil {
+		s.logger.Warn("unable to read task log", "error", err)
+	}
+	for _, entry := range logged {
+		completed = append(completed, "- "+entry.Text+" · "+entry.Path)
+		seen[strings.ToLower(entry.Text)] = true
+	}
+
 	relPath := dailyNotePathForDate(yesterday)
 	absPath := filepath.Join(s.notesDir, filepath.FromSlash(relPath))
 	data, err := os.ReadFile(absPath)
 	if err != nil {
-		return fmt.Sprintf("No daily note found for %s.", yesterday.Format(dailyDateLayout)), "None"
+		return fmt.Sprintf("No daily note found for %s.", yesterday.Format(dailyDateLayout)), formatCompletedTasks(completed)
 	}
 	content := string(data)
 	noteSummary := summarizeNoteContent(content, 5)
-	completed := summarizeCompletedTasks(content)
-	return noteSummary, completed
+	for _, text := range completedTaskTexts(content, yesterday) {
+		if !seen[strings.ToLower(text)] {
+			completed = append(completed, "- "+text)
+		}
+	}
+	return noteSummary, formatCompletedTasks(completed)
 }
 
 func dailyNotePathForDate(value time.Time) string {
@@ -348,16 +408,24 @@ func summarizeNoteContent(content string, maxLines int) string {
 	return strings.Join(summary, "\n")
 }
 
-func summarizeCompletedTasks(content string) string {
+// completedTaskTexts lists the done tasks in content, leaving out those whose
+// ✓date is not day.
+func completedTaskTexts(content string, day time.Time) []string {
 	parsed := parseTodoLines(content)
-	var lines []string
+	var texts []string
 	for _, todo := range parsed {
-		if todo.Completed {
-			if todo.Text != "" {
-				lines = append(lines, "- "+todo.Text)
-			}
+		if !todo.Completed || todo.Text == "" {
+			continue
 		}
+		if todo.CompletedDate != "" && todo.CompletedDate != day.Format(dailyDateLayout) {
+			continue
+		}
+		texts = append(texts, todo.Text)
 	}
+	return texts
+}
+
+func formatCompletedTasks(lines []string) string {
 	if len(lines) == 0 {
 		return "None"
 	}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// WriteConflictResponse is returned with 409 when an If-Match precondition
+// fails. Current holds the resource as it is on the server, including its
+// ETag, so clients can merge and retry.
+type WriteConflictResponse struct {
+	Error   string `json:"error"`
+	Current any    `json:"current"`
+}
+
+// contentETag returns a strong ETag derived from the stored bytes.
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ifMatchSatisfied reports whether the request's If-Match header allows a
+// write to a resource whose current ETag is etag. Requests without the header
+// are unconditional.
+func ifMatchSatisfied(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		// If-Match uses strong comparison, so a weak tag never matches.
+		if strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if !strings.HasPrefix(candidate, `"`) {
+			candidate = `"` + candidate + `"`
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func writeConflict(w http.ResponseWriter, message, etag string, current any) {
+	w.Header().Set("ETag", etag)
+	writeJSON(w, http.StatusConflict, WriteConflictResponse{Error: message, Current: current})
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventNoteCreated   = "note.created"
+	EventNoteUpdated   = "note.updated"
+	EventNoteRenamed   = "note.renamed"
+	EventNoteDeleted   = "note.deleted"
+	EventFolderCreated = "folder.created"
+	EventFolderRenamed = "folder.renamed"
+	EventFolderDeleted = "folder.deleted"
+	EventTasksChanged  = "tasks.changed"
+	EventSheetChanged  = "sheet.changed"
+
+	eventBufferSize    = 64
+	eventKeepAliveTime = 30 * time.Second
+)
+
+// ChangeEvent describes a change to the notes tree, whether it was made
+// through the API or directly on disk. Note and folder paths are relative to
+// the notes root; sheet paths are relative to the Sheets root.
+type ChangeEvent struct {
+	Type    string    `json:"type"`
+	Path    string    `json:"path"`
+	OldPath string    `json:"oldPath,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// eventBus fans change events out to subscribers. Slow subscribers drop
+// events rather than stalling the watcher.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan ChangeEvent]struct{}
+}
+
+func (b *eventBus) subscribe() (<-chan ChangeEvent, func()) {
+	ch := make(chan ChangeEvent, eventBufferSize)
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan ChangeEvent]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *eventBus) publish(events ...ChangeEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		for _, event := range events {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+	types := make(map[string]bool)
+	for _, value := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			types[value] = true
+		}
+	}
+
+	events, cancel := s.events.subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := controller.Flush(); err != nil {
+		s.logger.Warn("event stream unsupported", "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAliveTime)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			if len(types) > 0 && !types[event.Type] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
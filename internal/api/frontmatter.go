@@ -0,0 +1,199 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NoteMeta is the YAML front matter of a note. Fields holds every key as
+// written; the well-known keys are also normalised into the typed fields.
+// Tags keep their case like inline #tags, projects are lowercased and dates
+// are YYYY-MM-DD.
+type NoteMeta struct {
+	Fields  map[string]any `json:"fields"`
+	Tags    []string       `json:"tags,omitempty"`
+	Aliases []string       `json:"aliases,omitempty"`
+	Created string         `json:"created,omitempty"`
+	Updated string         `json:"updated,omitempty"`
+	Project string         `json:"project,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// frontMatterLineCount returns how many lines the front matter block spans,
+// including both --- delimiters, or 0 when the note has none. The block must
+// start on the first line and may be closed by --- or ....
+func frontMatterLineCount(lines []string) int {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t\r") != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		switch strings.TrimRight(lines[i], " \t\r") {
+		case "---", "...":
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// maskFrontMatter blanks the front matter lines so inline parsers skip them
+// while line numbers stay the same.
+func maskFrontMatter(content string) string {
+	lines := strings.Split(content, "\n")
+	count := frontMatterLineCount(lines)
+	if count == 0 {
+		return content
+	}
+	for i := 0; i < count; i++ {
+		lines[i] = ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseNoteMeta returns nil when the note has no front matter. Invalid YAML
+// yields a NoteMeta with only Error set, so callers can surface it.
+func parseNoteMeta(content string) *NoteMeta {
+	lines := strings.Split(content, "\n")
+	count := frontMatterLineCount(lines)
+	if count == 0 {
+		return nil
+	}
+	raw := strings.Join(lines[1:count-1], "\n")
+	fields := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(raw), &fields); err != nil {
+		return &NoteMeta{Fields: map[string]any{}, Error: "invalid front matter: " + err.Error()}
+	}
+	meta := &NoteMeta{Fields: make(map[string]any, len(fields))}
+	for key, value := range fields {
+		meta.Fields[key] = normalizeMetaValue(value)
+	}
+
+	for _, key := range []string{"tags", "tag"} {
+		for _, tag := range metaStrings(meta.Fields[key], true) {
+			if tag = strings.TrimPrefix(tag, "#"); tag != "" {
+				meta.Tags = append(meta.Tags, tag)
+			}
+		}
+	}
+	for _, key := range []string{"aliases", "alias"} {
+		meta.Aliases = append(meta.Aliases, metaStrings(meta.Fields[key], true)...)
+	}
+	meta.Created = metaDate(meta.Fields, "created", "date")
+	meta.Updated = metaDate(meta.Fields, "updated", "modified")
+	if projects := metaStrings(meta.Fields["project"], false); len(projects) > 0 {
+		meta.Project = strings.ToLower(strings.TrimPrefix(projects[0], "+"))
+	}
+	return meta
+}
+
+// normalizeMetaValue converts decoded YAML into values that encode cleanly as
+// JSON: dates become strings and maps get string keys.
+func normalizeMetaValue(value any) any {
+	switch typed := value.(type) {
+	case time.Time:
+		if typed.Hour() == 0 && typed.Minute() == 0 && typed.Second() == 0 && typed.Nanosecond() == 0 {
+			return typed.Format("2006-01-02")
+		}
+		return typed.Format(time.RFC3339)
+	case map[string]any:
+		out := make(map[string]any, len(typed))
+		for key, item := range typed {
+			out[key] = normalizeMetaValue(item)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(typed))
+		for key, item := range typed {
+			out[fmt.Sprint(key)] = normalizeMetaValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(typed))
+		for i, item := range typed {
+			out[i] = normalizeMetaValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// metaStrings reads a scalar or list value as strings. With split, scalar
+// strings are split on commas so "tags: a, b" works like "tags: [a, b]".
+func metaStrings(value any, split bool) []string {
+	values := make([]string, 0)
+	switch typed := value.(type) {
+	case nil:
+	case []any:
+		for _, item := range typed {
+			values = append(values, metaStrings(item, split)...)
+		}
+	case string:
+		if !split {
+			values = append(values, typed)
+			break
+		}
+		for _, part := range strings.Split(typed, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	case map[string]any:
+	default:
+		values = append(values, fmt.Sprint(typed))
+	}
+	return values
+}
+
+func metaDate(fields map[string]any, keys ...string) string {
+	for _, key := range keys {
+		text, ok := fields[key].(string)
+		if !ok {
+			continue
+		}
+		if len(text) >= len("2006-01-02") {
+			if parsed, err := time.Parse("2006-01-02", text[:len("2006-01-02")]); err == nil {
+				return parsed.Format("2006-01-02")
+			}
+		}
+	}
+	return ""
+}
+
+// metaValues flattens scalar and list fields into lowercase strings for
+// matching. Nested maps are skipped.
+func (m *NoteMeta) metaValues() map[string][]string {
+	if m == nil || len(m.Fields) == 0 {
+		return nil
+	}
+	values := make(map[string][]string, len(m.Fields))
+	for key, value := range m.Fields {
+		if _, nested := value.(map[string]any); nested {
+			continue
+		}
+		items := make([]string, 0)
+		for _, item := range metaStrings(value, false) {
+			items = append(items, strings.ToLower(item))
+		}
+		values[strings.ToLower(key)] = items
+	}
+	return values
+}
+
+// templateValues renders each scalar or list field as text for {{meta:key}}
+// placeholders. Lists are joined with ", ".
+func (m *NoteMeta) templateValues() map[string]string {
+	if m == nil {
+		return nil
+	}
+	values := make(map[string]string, len(m.Fields))
+	for key, value := range m.Fields {
+		if _, nested := value.(map[string]any); nested {
+			continue
+		}
+		values[key] = strings.Join(metaStrings(value, false), ", ")
+	}
+	return values
+}
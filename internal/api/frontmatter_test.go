@@ -0,0 +1,52 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNoteMeta(t *testing.T) {
+	content := "---\ntags: [Work, \"#planning\"]\naliases: Q3 Plan, Roadmap\ncreated: 2026-03-01\nupdated: 2026-04-02T10:00:00Z\nproject: +Launch\nowner: alice\n---\n# Plan\n"
+	meta := parseNoteMeta(content)
+	if meta == nil || meta.Error != "" {
+		t.Fatalf("expected front matter, got %#v", meta)
+	}
+	if strings.Join(meta.Tags, ",") != "Work,planning" {
+		t.Fatalf("unexpected tags %#v", meta.Tags)
+	}
+	if strings.Join(meta.Aliases, ",") != "Q3 Plan,Roadmap" {
+		t.Fatalf("unexpected aliases %#v", meta.Aliases)
+	}
+	if meta.Created != "2026-03-01" || meta.Updated != "2026-04-02" || meta.Project != "launch" {
+		t.Fatalf("unexpected meta %#v", meta)
+	}
+	if meta.Fields["owner"] != "alice" || meta.Fields["created"] != "2026-03-01" {
+		t.Fatalf("unexpected fields %#v", meta.Fields)
+	}
+	if values := meta.metaValues(); strings.Join(values["tags"], ",") != "work,#planning" {
+		t.Fatalf("unexpected meta values %#v", values)
+	}
+
+	if parseNoteMeta("# Plan\n---\nowner: bob\n---\n") != nil {
+		t.Fatalf("expected front matter only at the start of a note")
+	}
+	if meta := parseNoteMeta("---\nowner: [bob\n---\n"); meta == nil || meta.Error == "" {
+		t.Fatalf("expected invalid front matter error, got %#v", meta)
+	}
+
+	masked := maskFrontMatter(content)
+	if !strings.HasPrefix(masked, "\n\n\n\n\n\n\n\n# Plan") {
+		t.Fatalf("expected front matter lines to be blanked, got %q", masked)
+	}
+}
+
+func TestTemplateMetaPlaceholders(t *testing.T) {
+	now := time.Date(2026, 5, 6, 9, 0, 0, 0, time.Local)
+	input := "---\nproject: launch\ncreated: {{date:YYYY-MM-DD}}\n---\n# {{title}} ({{meta:project}}, {{meta:created}}){{meta:missing}}\n"
+	got := applyTemplatePlaceholders(input, now, templateContext("Work/Plan.md"))
+	expected := "---\nproject: launch\ncreated: 2026-05-06\n---\n# Plan (launch, 2026-05-06)\n"
+	if got != expected {
+		t.Fatalf("unexpected template output %q", got)
+	}
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	graphNodeNote    = "note"
+	graphNodeTag     = "tag"
+	graphNodeMention = "mention"
+	graphNodeProject = "project"
+
+	graphEdgeLink = "link"
+
+	graphDefaultDepth = 1
+	graphMaxDepth     = 5
+)
+
+type GraphResponse struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	Path  string `json:"path,omitempty"`
+}
+
+// GraphEdge connects two nodes. Link edges point from the linking note to the
+// linked note; tag, mention and project edges point from a note to the shared
+// node. Weight counts repeated links or tokens.
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+	Weight int    `json:"weight"`
+}
+
+// graphNoteEntry counts the links and shared tokens of one note.
+type graphNoteEntry struct {
+	aliases  []string
+	links    []string
+	tags     map[string]int
+	mentions map[string]int
+	projects map[string]int
+}
+
+type graphFilter struct {
+	folder string
+	tag    string
+	focus  string
+	depth  int
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := graphFilter{
+		tag:   strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query.Get("tag")), "#")),
+		depth: graphDefaultDepth,
+	}
+	if folder := strings.TrimSpace(query.Get("folder")); folder != "" {
+		_, relFolder, err := s.resolvePath(folder)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.folder = relFolder
+	}
+	if rawDepth := strings.TrimSpace(query.Get("depth")); rawDepth != "" {
+		depth, err := strconv.Atoi(rawDepth)
+		if err != nil || depth < 1 {
+			writeError(w, http.StatusBadRequest, "depth must be a positive integer")
+			return
+		}
+		if depth > graphMaxDepth {
+			depth = graphMaxDepth
+		}
+		filter.depth = depth
+	}
+	if focus := strings.TrimSpace(query.Get("focus")); focus != "" {
+		_, relFocus, err := s.resolvePath(focus)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter.focus = relFocus
+	}
+
+	entries, err := s.graphEntries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to build graph")
+		return
+	}
+	if filter.focus != "" {
+		if _, ok := entries[filter.focus]; !ok {
+			writeError(w, http.StatusNotFound, "note not found")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, buildGraph(entries, filter))
+}
+
+// graphEntries returns the graph facts of every note keyed by note path.
+func (s *Server) graphEntries() (map[string]graphNoteEntry, error) {
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]graphNoteEntry, len(notes))
+	for _, note := range notes {
+		entries[note.Path] = newGraphNoteEntry(note)
+	}
+	return entries, nil
+}
+
+func newGraphNoteEntry(note *vaultNote) graphNoteEntry {
+	entry := graphNoteEntry{
+		links:    make([]string, 0),
+		tags:     make(map[string]int),
+		mentions: make(map[string]int),
+		projects: make(map[string]int),
+	}
+	for _, link := range note.Links {
+		if link.Target != "" {
+			entry.links = append(entry.links, link.Target)
+		}
+	}
+	for _, tag := range note.Tags {
+		entry.tags[strings.ToLower(tag)]++
+	}
+	for _, mention := range note.Mentions {
+		entry.mentions[mention]++
+	}
+	for _, todo := range note.Todos {
+		if todo.Project != "" {
+			entry.projects[todo.Project]++
+		} else if note.Meta != nil && note.Meta.Project != "" {
+			entry.projects[note.Meta.Project]++
+		}
+	}
+	if note.Meta != nil {
+		entry.aliases = note.Meta.Aliases
+		if note.Meta.Project != "" {
+			entry.projects[note.Meta.Project]++
+		}
+	}
+	return entry
+}
+
+func buildGraph(entries map[string]graphNoteEntry, filter graphFilter) GraphResponse {
+	notes := make([]noteInfo, 0, len(entries))
+	for notePath, entry := range entries {
+		notes = append(notes, noteInfo{Path: notePath, Aliases: entry.aliases})
+	}
+	resolver := newWikiLinkResolver(notes)
+
+	included := func(notePath string) bool {
+		if notePath == filter.focus {
+			return true
+		}
+		if filter.folder != "" && !strings.HasPrefix(notePath, filter.folder+"/") {
+			return false
+		}
+		if filter.tag != "" && entries[notePath].tags[filter.tag] == 0 {
+			return false
+		}
+		return true
+	}
+
+	nodes := make(map[string]GraphNode)
+	edges := make(map[[3]string]int)
+	addShared := func(source, nodeType string, values map[string]int) {
+		for value, count := range values {
+			id := nodeType + ":" + value
+			if _, ok := nodes[id]; !ok {
+				nodes[id] = GraphNode{ID: id, Type: nodeType, Label: graphSharedLabel(nodeType, value)}
+			}
+			edges[[3]string{source, id, nodeType}] += count
+		}
+	}
+
+	for notePath, entry := range entries {
+		if !included(notePath) {
+			continue
+		}
+		id := graphNoteID(notePath)
+		nodes[id] = GraphNode{ID: id, Type: graphNodeNote, Label: noteTitle(notePath), Path: notePath}
+		for _, target := range entry.links {
+			resolved, ok := resolver.resolve(notePath, target)
+			if !ok || resolved == notePath || !included(resolved) {
+				continue
+			}
+			edges[[3]string{id, graphNoteID(resolved), graphEdgeLink}]++
+		}
+		addShared(id, graphNodeTag, entry.tags)
+		addShared(id, graphNodeMention, entry.mentions)
+		addShared(id, graphNodeProject, entry.projects)
+	}
+
+	if filter.focus != "" {
+		keep := graphNeighborhood(graphNoteID(filter.focus), edges, filter.depth)
+		for id := range nodes {
+			if !keep[id] {
+				delete(nodes, id)
+			}
+		}
+		for key := range edges {
+			if !keep[key[0]] || !keep[key[1]] {
+				delete(edges, key)
+			}
+		}
+	}
+
+	response := GraphResponse{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Edges: make([]GraphEdge, 0, len(edges)),
+	}
+	for _, node := range nodes {
+		response.Nodes = append(response.Nodes, node)
+	}
+	for key, weight := range edges {
+		if _, ok := nodes[key[1]]; !ok {
+			continue
+		}
+		response.Edges = append(response.Edges, GraphEdge{Source: key[0], Target: key[1], Type: key[2], Weight: weight})
+	}
+	sort.Slice(response.Nodes, func(i, j int) bool {
+		return response.Nodes[i].ID < response.Nodes[j].ID
+	})
+	sort.Slice(response.Edges, func(i, j int) bool {
+		a, b := response.Edges[i], response.Edges[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+	return response
+}
+
+// graphNeighborhood walks edges in both directions from start and returns the
+// node IDs reachable within depth hops.
+func graphNeighborhood(start string, edges map[[3]string]int, depth int) map[string]bool {
+	adjacent := make(map[string][]string)
+	for key := range edges {
+		adjacent[key[0]] = append(adjacent[key[0]], key[1])
+		adjacent[key[1]] = append(adjacent[key[1]], key[0])
+	}
+	seen := map[string]bool{start: true}
+	frontier := []string{start}
+	for step := 0; step < depth && len(frontier) > 0; step++ {
+		next := make([]string, 0)
+		for _, id := range frontier {
+			for _, neighbor := range adjacent[id] {
+				if seen[neighbor] {
+					continue
+				}
+				seen[neighbor] = true
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+func graphNoteID(notePath string) string {
+	return graphNodeNote + ":" + notePath
+}
+
+func graphSharedLabel(nodeType, value string) string {
+	switch nodeType {
+	case graphNodeTag:
+		return "#" + value
+	case graphNodeMention:
+		return "@" + value
+	case graphNodeProject:
+		return "+" + value
+	}
+	return value
+}
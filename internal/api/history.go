@@ -0,0 +1,337 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	historyFolderName        = ".history"
+	historyVersionLayout     = "20060102T150405.000000000Z"
+	historyCurrentVersion    = "current"
+	defaultHistoryVersions   = 50
+	defaultHistoryMaxAgeDays = 30
+)
+
+var errInvalidVersion = errors.New("invalid version")
+
+type NoteVersion struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+type NoteHistoryResponse struct {
+	Path     string        `json:"path"`
+	Versions []NoteVersion `json:"versions"`
+}
+
+type NoteVersionResponse struct {
+	Path    string    `json:"path"`
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Content string    `json:"content"`
+}
+
+type NoteDiffResponse struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Diff string `json:"diff"`
+}
+
+type NoteRestorePayload struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+func isHistoryDir(name string) bool {
+	return strings.EqualFold(name, historyFolderName)
+}
+
+// isHiddenDir reports whether a directory holds server data rather than
+// notes. Every walker over the notes tree skips these.
+func isHiddenDir(name string) bool {
+	return isAiDir(name) || isHistoryDir(name)
+}
+
+func (s *Server) historyDirPath(relPath string) string {
+	return filepath.Join(s.notesDir, historyFolderName, filepath.FromSlash(relPath))
+}
+
+// snapshotNote stores content as a new version of relPath unless it matches
+// the latest version, then prunes versions outside the retention settings.
+func (s *Server) snapshotNote(relPath, content string) error {
+	settings, _, err := s.loadSettings()
+	if err != nil {
+		return err
+	}
+	if settings.HistoryMaxVersions <= 0 {
+		return nil
+	}
+
+	versions, err := s.listNoteVersions(relPath)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		latest, err := os.ReadFile(s.noteVersionPath(relPath, versions[0].ID))
+		if err == nil && string(latest) == content {
+			return nil
+		}
+	}
+
+	dir := s.historyDirPath(relPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	id := timeNow().UTC().Format(historyVersionLayout)
+	if len(versions) > 0 && versions[0].ID >= id {
+		// Keep IDs strictly increasing even if the clock stalls or steps back.
+		latest, _ := time.Parse(historyVersionLayout, versions[0].ID)
+		id = latest.Add(time.Nanosecond).UTC().Format(historyVersionLayout)
+	}
+	if err := os.WriteFile(s.noteVersionPath(relPath, id), []byte(content), 0o644); err != nil {
+		return err
+	}
+	return s.pruneNoteVersions(relPath, settings)
+}
+
+func (s *Server) pruneNoteVersions(relPath string, settings Settings) error {
+	versions, err := s.listNoteVersions(relPath)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Time{}
+	if settings.HistoryMaxAgeDays > 0 {
+		cutoff = timeNow().Add(-time.Duration(settings.HistoryMaxAgeDays) * 24 * time.Hour)
+	}
+	for i, version := range versions {
+		expired := !cutoff.IsZero() && version.Created.Before(cutoff)
+		// Always keep the newest version so a note never loses all history.
+		if i == 0 || (i < settings.HistoryMaxVersions && !expired) {
+			continue
+		}
+		if err := os.Remove(s.noteVersionPath(relPath, version.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// listNoteVersions returns the stored versions of a note, newest first.
+func (s *Server) listNoteVersions(relPath string) ([]NoteVersion, error) {
+	entries, err := os.ReadDir(s.historyDirPath(relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []NoteVersion{}, nil
+		}
+		return nil, err
+	}
+	versions := make([]NoteVersion, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isMarkdown(entry.Name()) {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".md")
+		created, err := time.Parse(historyVersionLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, NoteVersion{ID: id, Created: created, Size: info.Size()})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ID > versions[j].ID
+	})
+	return versions, nil
+}
+
+func (s *Server) noteVersionPath(relPath, id string) string {
+	return filepath.Join(s.historyDirPath(relPath), id+".md")
+}
+
+func (s *Server) readNoteVersion(absPath, relPath, id string) (string, error) {
+	if id == "" || id == historyCurrentVersion {
+		data, err := os.ReadFile(absPath)
+		return string(data), err
+	}
+	if _, err := time.Parse(historyVersionLayout, id); err != nil {
+		return "", errInvalidVersion
+	}
+	data, err := os.ReadFile(s.noteVersionPath(relPath, id))
+	return string(data), err
+}
+
+// moveNoteHistory keeps history attached to a note or folder across renames.
+func (s *Server) moveNoteHistory(from, to string) error {
+	source := s.historyDirPath(from)
+	if _, err := os.Stat(source); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	target := s.historyDirPath(to)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	return os.Rename(source, target)
+}
+
+// resolveHistoryNote validates the path parameter shared by the history
+// endpoints. Notes that were deleted may still have history, so a missing
+// file is only an error when the history is missing too.
+func (s *Server) resolveHistoryNote(w http.ResponseWriter, pathParam string) (string, string, bool) {
+	if strings.TrimSpace(pathParam) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return "", "", false
+	}
+	absPath, relPath, err := s.resolvePath(pathParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	if !isNoteFile(absPath) {
+		writeError(w, http.StatusBadRequest, "not a note file")
+		return "", "", false
+	}
+	if _, err := os.Stat(absPath); err != nil {
+		if !os.IsNotExist(err) {
+			writeError(w, http.StatusInternalServerError, "unable to read note")
+			return "", "", false
+		}
+		if _, err := os.Stat(s.historyDirPath(relPath)); err != nil {
+			writeError(w, http.StatusNotFound, "note not found")
+			return "", "", false
+		}
+	}
+	return absPath, relPath, true
+}
+
+func (s *Server) handleNoteHistoryList(w http.ResponseWriter, r *http.Request) {
+	_, relPath, ok := s.resolveHistoryNote(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+	versions, err := s.listNoteVersions(relPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list versions")
+		return
+	}
+	writeJSON(w, http.StatusOK, NoteHistoryResponse{Path: relPath, Versions: versions})
+}
+
+func (s *Server) handleNoteHistoryVersion(w http.ResponseWriter, r *http.Request) {
+	absPath, relPath, ok := s.resolveHistoryNote(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" || id == historyCurrentVersion {
+		writeError(w, http.StatusBadRequest, "id is required")
+		return
+	}
+	content, err := s.readNoteVersion(absPath, relPath, id)
+	if err != nil {
+		writeHistoryReadError(w, err)
+		return
+	}
+	created, _ := time.Parse(historyVersionLayout, id)
+	writeJSON(w, http.StatusOK, NoteVersionResponse{Path: relPath, ID: id, Created: created, Content: content})
+}
+
+func (s *Server) handleNoteHistoryDiff(w http.ResponseWriter, r *http.Request) {
+	absPath, relPath, ok := s.resolveHistoryNote(w, r.URL.Query().Get("path"))
+	if !ok {
+		return
+	}
+	from := strings.TrimSpace(r.URL.Query().Get("from"))
+	to := strings.TrimSpace(r.URL.Query().Get("to"))
+	if from == "" {
+		writeError(w, http.StatusBadRequest, "from is required")
+		return
+	}
+	if to == "" {
+		to = historyCurrentVersion
+	}
+
+	fromContent, err := s.readNoteVersion(absPath, relPath, from)
+	if err != nil {
+		writeHistoryReadError(w, err)
+		return
+	}
+	toContent, err := s.readNoteVersion(absPath, relPath, to)
+	if err != nil {
+		writeHistoryReadError(w, err)
+		return
+	}
+	diff := unifiedDiff(relPath+"@"+from, relPath+"@"+to, fromContent, toContent)
+	writeJSON(w, http.StatusOK, NoteDiffResponse{Path: relPath, From: from, To: to, Diff: diff})
+}
+
+func (s *Server) handleNoteHistoryRestore(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[NoteRestorePayload](r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	absPath, relPath, ok := s.resolveHistoryNote(w, payload.Path)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(payload.Version)
+	if id == "" || id == historyCurrentVersion {
+		writeError(w, http.StatusBadRequest, "version is required")
+		return
+	}
+	content, err := s.readNoteVersion(absPath, relPath, id)
+	if err != nil {
+		writeHistoryReadError(w, err)
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if current, err := os.ReadFile(absPath); err == nil {
+		if err := s.snapshotNote(relPath, string(current)); err != nil {
+			s.logger.Warn("note snapshot failed", "path", relPath, "error", err)
+		}
+	} else if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to prepare destination")
+			return
+		}
+	}
+	if err := writeFileAtomic(absPath, []byte(content)); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to restore note")
+		return
+	}
+
+	s.logger.Info("note restored", "path", relPath, "version", id)
+	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "version": id})
+}
+
+func writeHistoryReadError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "version not found")
+		return
+	}
+	if errors.Is(err, errInvalidVersion) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "unable to read version")
+}
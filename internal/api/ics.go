@@ -0,0 +1,372 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const icsDateLayout = "20060102"
+
+// icsEntry is one VTODO or VEVENT in a calendar feed. Dates are all-day.
+type icsEntry struct {
+	Component   string
+	UID         string
+	Summary     string
+	Description string
+	Date        time.Time
+	Status      string
+	Priority    int
+	Categories  []string
+	URL         string
+	Completed   time.Time
+}
+
+// handleTasksICS serves dated tasks, and the daily notes, as an iCalendar
+// feed. ?filter=<id> limits the feed to the tasks matching a saved filter and
+// leaves out daily notes. Tasks are VTODOs unless ?as=event asks for
+// VEVENTs, which more calendar apps display.
+func (s *Server) handleTasksICS(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	asEvents := false
+	switch strings.ToLower(strings.TrimSpace(query.Get("as"))) {
+	case "", "todo":
+	case "event":
+		asEvents = true
+	default:
+		writeError(w, http.StatusBadRequest, "as must be todo or event")
+		return
+	}
+
+	var filter *TaskFilter
+	if id := strings.TrimSpace(query.Get("filter")); id != "" {
+		filters, _, err := s.loadTaskFilters()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to load task filters")
+			return
+		}
+		found, ok := filters.findTaskFilter(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "task filter not found")
+			return
+		}
+		filter = &found
+	}
+
+	tasks, _, err := s.listTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load tasks")
+		return
+	}
+
+	base := requestBaseURL(r)
+	today := dateOnly(timeNow())
+	entries := make([]icsEntry, 0, len(tasks))
+	for _, task := range tasks {
+		if task.DueDateISO == "" {
+			continue
+		}
+		if filter != nil && !taskMatchesFilter(task, *filter, today) {
+			continue
+		}
+		entry, ok := taskICSEntry(task, base, asEvents)
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	name := "Scoli tasks"
+	if filter != nil {
+		name = "Scoli: " + filter.Name
+	} else {
+		notes, err := s.vaultNotes()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to load notes")
+			return
+		}
+		for _, note := range notes {
+			date, ok := parseDailyNoteDate(note.Path)
+			if !ok {
+				continue
+			}
+			entries = append(entries, icsEntry{
+				Component: "VEVENT",
+				UID:       "daily-" + date.Format(dailyDateLayout) + "@scoli",
+				Summary:   "Daily note " + date.Format(dailyDateLayout),
+				Date:      date,
+				URL:       noteURL(base, note.Path),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(renderICS(name, entries, timeNow())))
+}
+
+// taskICSEntry converts a dated task.
+func taskICSEntry(task TaskItem, base string, asEvent bool) (icsEntry, bool) {
+	entry := taskICSComponent(task, base, asEvent)
+	if entry.Date.IsZero() {
+		return icsEntry{}, false
+	}
+	return entry, true
+}
+
+// taskICSComponent converts a task, leaving Date zero when it has no due
+// date. Priorities ^1 to ^5 map onto the iCalendar scale of 1 (highest) to 9.
+func taskICSComponent(task TaskItem, base string, asEvent bool) icsEntry {
+	entry := icsEntry{
+		Component:   "VTODO",
+		UID:         task.ID + "@scoli",
+		Summary:     task.Text,
+		Description: task.Path,
+		URL:         noteURL(base, task.Path),
+	}
+	if due, err := time.ParseInLocation(dailyDateLayout, task.DueDateISO, time.Local); err == nil {
+		entry.Date = due
+	}
+	if task.Priority > 0 {
+		entry.Priority = task.Priority*2 - 1
+	}
+	if task.Project != "" {
+		entry.Categories = []string{task.Project}
+	}
+	if asEvent {
+		entry.Component = "VEVENT"
+		if task.Status == taskStatusCancelled || task.Status == taskStatusMigrated {
+			entry.Status = "CANCELLED"
+		}
+		return entry
+	}
+	switch task.Status {
+	case taskStatusDone:
+		entry.Status = "COMPLETED"
+		if completed, err := time.ParseInLocation(dailyDateLayout, task.CompletedDate, time.Local); err == nil {
+			entry.Completed = completed
+		}
+	case taskStatusCancelled, taskStatusMigrated:
+		// A migrated task carries on in a later note under its block ID.
+		entry.Status = "CANCELLED"
+	case taskStatusInProgress:
+		entry.Status = "IN-PROCESS"
+	default:
+		entry.Status = "NEEDS-ACTION"
+	}
+	return entry
+}
+
+func renderICS(name string, entries []icsEntry, now time.Time) string {
+	var b strings.Builder
+	line := func(value string) {
+		b.WriteString(foldICSLine(value))
+		b.WriteString("\r\n")
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Scoli//Tasks//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + escapeICSText(name))
+	for _, entry := range entries {
+		line("BEGIN:" + entry.Component)
+		line("UID:" + escapeICSText(entry.UID))
+		line("DTSTAMP:" + stamp)
+		line("SUMMARY:" + escapeICSText(entry.Summary))
+		if entry.Component == "VTODO" {
+			// Undated tasks, listed over CalDAV, have no DUE.
+			if !entry.Date.IsZero() {
+				line("DUE;VALUE=DATE:" + entry.Date.Format(icsDateLayout))
+			}
+		} else {
+			line("DTSTART;VALUE=DATE:" + entry.Date.Format(icsDateLayout))
+			line("DTEND;VALUE=DATE:" + entry.Date.AddDate(0, 0, 1).Format(icsDateLayout))
+			line("TRANSP:TRANSPARENT")
+		}
+		if entry.Description != "" {
+			line("DESCRIPTION:" + escapeICSText(entry.Description))
+		}
+		if entry.Status != "" {
+			line("STATUS:" + entry.Status)
+		}
+		if !entry.Completed.IsZero() {
+			line("COMPLETED:" + entry.Completed.UTC().Format("20060102T150405Z"))
+		}
+		if entry.Priority > 0 {
+			line("PRIORITY:" + strconv.Itoa(entry.Priority))
+		}
+		if len(entry.Categories) > 0 {
+			escaped := make([]string, 0, len(entry.Categories))
+			for _, category := range entry.Categories {
+				escaped = append(escaped, escapeICSText(category))
+			}
+			line("CATEGORIES:" + strings.Join(escaped, ","))
+		}
+		if entry.URL != "" {
+			line("URL:" + entry.URL)
+		}
+		line("END:" + entry.Component)
+	}
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escapeICSText(value string) string {
+	return icsTextEscaper.Replace(value)
+}
+
+// foldICSLine splits a content line into 75-octet pieces, as RFC 5545
+// requires, without breaking a UTF-8 sequence.
+func foldICSLine(value string) string {
+	const limit = 75
+	if len(value) <= limit {
+		return value
+	}
+	var b strings.Builder
+	width := limit
+	for len(value) > width {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(value[cut]) {
+			cut--
+		}
+		b.WriteString(value[:cut])
+		b.WriteString("\r\n ")
+		value = value[cut:]
+		// Continuation lines start with a space, which counts.
+		width = limit - 1
+	}
+	b.WriteString(value)
+	return b.String()
+}
+
+// requestBaseURL is the scheme and host the request was made to, honouring
+// a reverse proxy's X-Forwarded-Proto and X-Forwarded-Host.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.TrimSpace(r.Header.Get("X-Forwarded-Proto")); proto != "" {
+		scheme = strings.ToLower(strings.Split(proto, ",")[0])
+	}
+	host := r.Host
+	if forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-Host")); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// noteURL links to a note in the web UI, which opens ?note=<path> on load.
+func noteURL(base, relPath string) string {
+	return base + "/?note=" + url.QueryEscape(relPath)
+}
+
+// icsTodo is the part of a client's VTODO that is written back to a task.
+// Due is YYYY-MM-DD, or empty when the VTODO has no DUE.
+type icsTodo struct {
+	UID      string
+	Summary  string
+	Status   string
+	Due      string
+	Priority int
+}
+
+var icsTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// parseICSTodo reads the first VTODO of an iCalendar object.
+func parseICSTodo(data string) (icsTodo, error) {
+	unfolded := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(data)
+	var todo icsTodo
+	inside := false
+	for _, raw := range strings.Split(unfolded, "\n") {
+		line := strings.TrimSuffix(raw, "\r")
+		name, value, ok := splitICSLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			inside = true
+			continue
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			if !inside {
+				continue
+			}
+			return todo, nil
+		case !inside:
+			continue
+		}
+		switch name {
+		case "UID":
+			todo.UID = strings.TrimSpace(icsTextUnescaper.Replace(value))
+		case "SUMMARY":
+			todo.Summary = strings.TrimSpace(icsTextUnescaper.Replace(value))
+		case "STATUS":
+			todo.Status = strings.ToUpper(strings.TrimSpace(value))
+		case "DUE":
+			due, ok := parseICSDate(value)
+			if !ok {
+				return icsTodo{}, errors.New("invalid DUE")
+			}
+			todo.Due = due
+		case "PRIORITY":
+			priority, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || priority < 0 || priority > 9 {
+				return icsTodo{}, errors.New("invalid PRIORITY")
+			}
+			todo.Priority = priority
+		}
+	}
+	return icsTodo{}, errors.New("no VTODO in calendar data")
+}
+
+// splitICSLine splits a content line into its upper-cased name, without
+// parameters, and its value. Colons inside quoted parameter values do not end
+// the name.
+func splitICSLine(line string) (string, string, bool) {
+	quoted := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if quoted {
+				continue
+			}
+			head := line[:i]
+			if semi := strings.IndexByte(head, ';'); semi >= 0 {
+				head = head[:semi]
+			}
+			return strings.ToUpper(strings.TrimSpace(head)), line[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// parseICSDate reads a DATE or DATE-TIME value as a local day. UTC times are
+// converted to local time; floating and TZID times keep their date.
+func parseICSDate(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if strings.HasSuffix(value, "Z") {
+		parsed, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return "", false
+		}
+		return parsed.In(time.Local).Format(dailyDateLayout), true
+	}
+	if len(value) < len(icsDateLayout) {
+		return "", false
+	}
+	parsed, err := time.ParseInLocation(icsDateLayout, value[:len(icsDateLayout)], time.Local)
+	if err != nil {
+		return "", false
+	}
+	return parsed.Format(dailyDateLayout), true
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFoldICSLine(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 80)
+	folded := foldICSLine(line)
+	parts := strings.Split(folded, "\r\n")
+	if len(parts) < 3 {
+		t.Fatalf("expected line to be folded, got %q", folded)
+	}
+	for i, part := range parts {
+		if len(part) > 75 {
+			t.Fatalf("part %d is %d octets", i, len(part))
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Fatalf("continuation %d does not start with a space", i)
+		}
+	}
+	if strings.ReplaceAll(folded, "\r\n ", "") != line {
+		t.Fatalf("unfolding did not restore the line")
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	got := escapeICSText("a,b;c\\d\ne")
+	if got != `a\,b\;c\\d\ne` {
+		t.Fatalf("unexpected escape %q", got)
+	}
+}
+
+func TestParseICSTodo(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Ignored\r\nEND:VEVENT\r\n" +
+		"BEGIN:VTODO\r\nSUMMARY;LANGUAGE=en:Call Bob\\, then\r\n  write notes\r\n" +
+		"DUE;TZID=\"Europe/Paris: CET\":20261020T230000\r\nSTATUS:completed\r\nPRIORITY:5\r\n" +
+		"END:VTODO\r\nEND:VCALENDAR\r\n"
+	todo, err := parseICSTodo(data)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if todo.Summary != "Call Bob, then write notes" || todo.Due != "2026-10-20" || todo.Status != "COMPLETED" || todo.Priority != 5 {
+		t.Fatalf("unexpected todo %#v", todo)
+	}
+	if _, err := parseICSTodo("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"); err == nil {
+		t.Fatalf("expected an error without a VTODO")
+	}
+}
@@ -23,6 +23,7 @@ type JournalEntry struct {
 
 type JournalListResponse struct {
 	Entries []JournalEntry `json:"entries"`
+	ETag    string         `json:"etag,omitempty"`
 }
 
 type JournalCreatePayload struct {
@@ -52,12 +53,16 @@ func (s *Server) journalFilePath() string {
 }
 
 func (s *Server) handleJournalList(w http.ResponseWriter, r *http.Request) {
+	s.journalMu.Lock()
 	entries, err := s.loadJournalEntries()
+	etag := s.journalETag()
+	s.journalMu.Unlock()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to load journal")
 		return
 	}
-	writeJSON(w, http.StatusOK, JournalListResponse{Entries: entries})
+	w.Header().Set("ETag", etag)
+	writeJSON(w, http.StatusOK, JournalListResponse{Entries: entries, ETag: etag})
 }
 
 func (s *Server) handleJournalCreate(w http.ResponseWriter, r *http.Request) {
@@ -71,9 +76,11 @@ func (s *Server) handleJournalCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := s.loadJournalEntries()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to load journal")
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+
+	entries, ok := s.loadJournalForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -91,6 +98,7 @@ func (s *Server) handleJournalCreate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to save journal")
 		return
 	}
+	w.Header().Set("ETag", s.journalETag())
 
 	writeJSON(w, http.StatusCreated, entry)
 }
@@ -106,9 +114,11 @@ func (s *Server) handleJournalUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := s.loadJournalEntries()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to load journal")
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+
+	entries, ok := s.loadJournalForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -131,6 +141,7 @@ func (s *Server) handleJournalUpdate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to save journal")
 		return
 	}
+	w.Header().Set("ETag", s.journalETag())
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
@@ -142,9 +153,11 @@ func (s *Server) handleJournalDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := s.loadJournalEntries()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to load journal")
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+
+	entries, ok := s.loadJournalForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -166,6 +179,7 @@ func (s *Server) handleJournalDelete(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to save journal")
 		return
 	}
+	w.Header().Set("ETag", s.journalETag())
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -181,9 +195,11 @@ func (s *Server) handleJournalArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := s.loadJournalEntries()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to load journal")
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+
+	entries, ok := s.loadJournalForWrite(w, r)
+	if !ok {
 		return
 	}
 
@@ -223,6 +239,7 @@ func (s *Server) handleJournalArchive(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "unable to save journal")
 		return
 	}
+	w.Header().Set("ETag", s.journalETag())
 
 	writeJSON(w, http.StatusOK, JournalArchiveResponse{
 		Status:      "archived",
@@ -231,9 +248,11 @@ func (s *Server) handleJournalArchive(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleJournalArchiveAll(w http.ResponseWriter, r *http.Request) {
-	entries, err := s.loadJournalEntries()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to load journal")
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+
+	entries, ok := s.loadJournalForWrite(w, r)
+	if !ok {
 		return
 	}
 	if len(entries) == 0 {
@@ -262,6 +281,7 @@ func (s *Server) handleJournalArchiveAll(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, "unable to save journal")
 		return
 	}
+	w.Header().Set("ETag", s.journalETag())
 
 	writeJSON(w, http.StatusOK, JournalArchiveResponse{
 		Status:      "archived",
@@ -350,6 +370,29 @@ func (s *Server) handleJournalArchiveGet(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, JournalListResponse{Entries: entries})
 }
 
+// loadJournalForWrite loads the active journal for a handler holding
+// journalMu. A failed If-Match precondition is answered with 409 and the
+// current entries, since every write rewrites the whole file.
+func (s *Server) loadJournalForWrite(w http.ResponseWriter, r *http.Request) ([]JournalEntry, bool) {
+	entries, err := s.loadJournalEntries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to load journal")
+		return nil, false
+	}
+	etag := s.journalETag()
+	if !ifMatchSatisfied(r, etag) {
+		writeConflict(w, "journal changed on server", etag, JournalListResponse{Entries: entries, ETag: etag})
+		return nil, false
+	}
+	return entries, true
+}
+
+// journalETag hashes journal.json as stored. A missing file hashes as empty.
+func (s *Server) journalETag() string {
+	data, _ := os.ReadFile(s.journalFilePath())
+	return contentETag(data)
+}
+
 func (s *Server) loadJournalEntries() ([]JournalEntry, error) {
 	return loadJournalEntriesFromPath(s.journalFilePath())
 }
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+type WikiLink struct {
+	Target  string `json:"target"`
+	Path    string `json:"path,omitempty"`
+	Heading string `json:"heading,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+	Line    int    `json:"line"`
+	Exists  bool   `json:"exists"`
+}
+
+type LinkReference struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+	Line    int    `json:"line"`
+	Context string `json:"context"`
+}
+
+type LinksResponse struct {
+	Path             string          `json:"path"`
+	Title            string          `json:"title"`
+	Outgoing         []WikiLink      `json:"outgoing"`
+	Backlinks        []LinkReference `json:"backlinks"`
+	UnlinkedMentions []LinkReference `json:"unlinkedMentions"`
+}
+
+type parsedWikiLink struct {
+	Target  string
+	Heading string
+	Alias   string
+	Line    int
+}
+
+// wikiLinkResolver maps [[link]] targets to note paths. Targets containing a
+// slash are treated as paths (relative to the linking note, then the notes
+// root); bare names match a note title anywhere in the vault.
+type wikiLinkResolver struct {
+	byPath  map[string]string
+	byName  map[string][]string
+	byAlias map[string][]string
+}
+
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	pathParam := r.URL.Query().Get("path")
+	if strings.TrimSpace(pathParam) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	absPath, relPath, err := s.resolvePath(pathParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "note not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "path is a folder")
+		return
+	}
+	if !isMarkdown(absPath) {
+		writeError(w, http.StatusBadRequest, "not a note file")
+		return
+	}
+
+	vaultNotes, err := s.vaultNotes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list notes")
+		return
+	}
+	notes := noteInfos(vaultNotes)
+	resolver := newWikiLinkResolver(notes)
+	title := noteTitle(relPath)
+	mentionPattern := unlinkedMentionPattern(title)
+
+	response := LinksResponse{
+		Path:             relPath,
+		Title:            title,
+		Outgoing:         make([]WikiLink, 0),
+		Backlinks:        make([]LinkReference, 0),
+		UnlinkedMentions: make([]LinkReference, 0),
+	}
+
+	for _, note := range notes {
+		data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(note.Path)))
+		if err != nil {
+			continue
+		}
+		content := maskFrontMatter(string(data))
+		if note.Path == relPath {
+			for _, link := range parseWikiLinks(content) {
+				resolved, ok := resolver.resolve(relPath, link.Target)
+				response.Outgoing = append(response.Outgoing, WikiLink{
+					Target:  link.Target,
+					Path:    resolved,
+					Heading: link.Heading,
+					Alias:   link.Alias,
+					Line:    link.Line,
+					Exists:  ok,
+				})
+			}
+			continue
+		}
+
+		lines := strings.Split(content, "\n")
+		linkedLines := make(map[int]bool)
+		for _, link := range parseWikiLinks(content) {
+			if linkedLines[link.Line] {
+				continue
+			}
+			resolved, ok := resolver.resolve(note.Path, link.Target)
+			if !ok || resolved != relPath {
+				continue
+			}
+			linkedLines[link.Line] = true
+			response.Backlinks = append(response.Backlinks, newLinkReference(note.Path, link.Line, lines))
+		}
+
+		if mentionPattern == nil {
+			continue
+		}
+		tracker := &codeBlockTracker{}
+		for i, line := range lines {
+			if tracker.isCodeLine(line) {
+				continue
+			}
+			plain := wikiLinkPattern.ReplaceAllString(stripInlineCode(line), " ")
+			if mentionPattern.MatchString(plain) {
+				response.UnlinkedMentions = append(response.UnlinkedMentions, newLinkReference(note.Path, i+1, lines))
+			}
+		}
+	}
+
+	sortLinkReferences(response.Backlinks)
+	sortLinkReferences(response.UnlinkedMentions)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseWikiLinks returns [[target#heading|alias]] links outside code blocks
+// and inline code, with 1-based line numbers.
+func parseWikiLinks(content string) []parsedWikiLink {
+	links := make([]parsedWikiLink, 0)
+	tracker := &codeBlockTracker{}
+	for i, line := range strings.Split(content, "\n") {
+		if tracker.isCodeLine(line) {
+			continue
+		}
+		masked, _ := maskInlineCode(line)
+		for _, match := range wikiLinkPattern.FindAllStringSubmatch(masked, -1) {
+			target, heading, alias := splitWikiLink(match[1])
+			if target == "" && heading == "" {
+				continue
+			}
+			links = append(links, parsedWikiLink{
+				Target:  target,
+				Heading: heading,
+				Alias:   alias,
+				Line:    i + 1,
+			})
+		}
+	}
+	return links
+}
+
+func splitWikiLink(inner string) (string, string, string) {
+	target, alias, _ := strings.Cut(inner, "|")
+	target, heading, _ := strings.Cut(target, "#")
+	return strings.TrimSpace(target), strings.TrimSpace(heading), strings.TrimSpace(alias)
+}
+
+func newWikiLinkResolver(notes []noteInfo) *wikiLinkResolver {
+	resolver := &wikiLinkResolver{
+		byPath:  make(map[string]string, len(notes)),
+		byName:  make(map[string][]string),
+		byAlias: make(map[string][]string),
+	}
+	for _, note := range notes {
+		resolver.byPath[strings.ToLower(note.Path)] = note.Path
+		name := strings.ToLower(noteTitle(note.Path))
+		resolver.byName[name] = append(resolver.byName[name], note.Path)
+		for _, alias := range note.Aliases {
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			if alias != "" {
+				resolver.byAlias[alias] = append(resolver.byAlias[alias], note.Path)
+			}
+		}
+	}
+	for _, index := range []map[string][]string{resolver.byName, resolver.byAlias} {
+		for name, paths := range index {
+			sort.Slice(paths, func(i, j int) bool {
+				depthA := strings.Count(paths[i], "/")
+				depthB := strings.Count(paths[j], "/")
+				if depthA != depthB {
+					return depthA < depthB
+				}
+				return paths[i] < paths[j]
+			})
+			index[name] = paths
+		}
+	}
+	return resolver
+}
+
+// resolve returns the note path a link from source points at. A link with an
+// empty target (a bare #heading) refers to the source note itself.
+func (r *wikiLinkResolver) resolve(source, target string) (string, bool) {
+	if target == "" {
+		return source, true
+	}
+	if isMarkdown(target) {
+		target = target[:len(target)-len(".md")]
+	}
+	if strings.Contains(target, "/") {
+		candidates := []string{
+			path.Join(path.Dir(source), target),
+			strings.TrimPrefix(target, "/"),
+		}
+		for _, candidate := range candidates {
+			clean, err := cleanRelPath(candidate + ".md")
+			if err != nil {
+				continue
+			}
+			if resolved, ok := r.byPath[strings.ToLower(filepath.ToSlash(clean))]; ok {
+				return resolved, true
+			}
+		}
+		return "", false
+	}
+
+	// Front matter aliases only apply when no note has the title itself.
+	paths := r.byName[strings.ToLower(target)]
+	if len(paths) == 0 {
+		paths = r.byAlias[strings.ToLower(target)]
+	}
+	if len(paths) == 0 {
+		return "", false
+	}
+	sourceDir := path.Dir(source)
+	for _, candidate := range paths {
+		if path.Dir(candidate) == sourceDir {
+			return candidate, true
+		}
+	}
+	return paths[0], true
+}
+
+func noteTitle(relPath string) string {
+	base := path.Base(relPath)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+func unlinkedMentionPattern(title string) *regexp.Regexp {
+	if len([]rune(strings.TrimSpace(title))) < 3 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(title) + `($|[^\pL\pN])`)
+}
+
+func newLinkReference(notePath string, line int, lines []string) LinkReference {
+	context := ""
+	if line > 0 && line <= len(lines) {
+		context = strings.TrimSpace(strings.TrimSuffix(lines[line-1], "\r"))
+	}
+	return LinkReference{
+		Path:    notePath,
+		Name:    path.Base(notePath),
+		Line:    line,
+		Context: context,
+	}
+}
+
+func sortLinkReferences(refs []LinkReference) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Path == refs[j].Path {
+			return refs[i].Line < refs[j].Line
+		}
+		return refs[i].Path < refs[j].Path
+	})
+}
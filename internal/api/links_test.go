@@ -0,0 +1,49 @@
+package api
+
+import "testing"
+
+func TestParseWikiLinks(t *testing.T) {
+	content := "See [[Roadmap]] and [[Work/Plan#Goals|the plan]].\n`[[Ignored]]`\n```\n[[Fenced]]\n```\n[[#Local heading]]"
+	links := parseWikiLinks(content)
+	expected := []parsedWikiLink{
+		{Target: "Roadmap", Line: 1},
+		{Target: "Work/Plan", Heading: "Goals", Alias: "the plan", Line: 1},
+		{Heading: "Local heading", Line: 6},
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("expected %d links, got %#v", len(expected), links)
+	}
+	for i, link := range expected {
+		if links[i] != link {
+			t.Fatalf("link %d: expected %#v, got %#v", i, link, links[i])
+		}
+	}
+}
+
+func TestWikiLinkResolver(t *testing.T) {
+	resolver := newWikiLinkResolver([]noteInfo{
+		{Path: "Plan.md"},
+		{Path: "Work/Plan.md"},
+		{Path: "Work/Sub/Notes.md"},
+	})
+	cases := []struct {
+		source string
+		target string
+		path   string
+		ok     bool
+	}{
+		{source: "Home.md", target: "plan", path: "Plan.md", ok: true},
+		{source: "Work/Other.md", target: "Plan", path: "Work/Plan.md", ok: true},
+		{source: "Home.md", target: "Work/Plan.md", path: "Work/Plan.md", ok: true},
+		{source: "Work/Other.md", target: "Sub/Notes", path: "Work/Sub/Notes.md", ok: true},
+		{source: "Work/Other.md", target: "../Plan", path: "Plan.md", ok: true},
+		{source: "Home.md", target: "../../etc/passwd", ok: false},
+		{source: "Home.md", target: "Missing", ok: false},
+	}
+	for _, tc := range cases {
+		resolved, ok := resolver.resolve(tc.source, tc.target)
+		if resolved != tc.path || ok != tc.ok {
+			t.Fatalf("resolve %q from %q: expected %q/%v, got %q/%v", tc.target, tc.source, tc.path, tc.ok, resolved, ok)
+		}
+	}
+}
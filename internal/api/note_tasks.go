@@ -9,41 +9,118 @@ import (
 )
 
 var (
-	todoLinePattern      = regexp.MustCompile(`^\s*-\s+\[( |x|X|✓)\]\s+`)
-	todoTogglePattern    = regexp.MustCompile(`^(\s*-\s+\[)( |x|X|✓)(\]\s+)`)
-	todoCompletedPattern = regexp.MustCompile(`^\s*-\s+\[(x|X|✓)\]\s+`)
-	taskProjectPattern   = regexp.MustCompile(`(^|\s)\+([A-Za-z]+)\b`)
-	taskTagPattern       = regexp.MustCompile(`(^|\s)#([A-Za-z]+)\b`)
-	taskMentionPattern   = regexp.MustCompile(`(^|\s)@([A-Za-z]+)\b`)
-	taskDuePattern       = regexp.MustCompile(`(^|\s)>(\S+)`)
-	taskPriorityPattern  = regexp.MustCompile(`(^|\s)\^([1-5])\b`)
-	taskTokenPattern     = regexp.MustCompile(`(^|\s)(#[A-Za-z]+|@[A-Za-z]+|\+[A-Za-z]+|\^[1-5]|>\S+)`)
-	taskSomedayPattern   = regexp.MustCompile(`(?i)(^|\s)#someday\b`)
+	todoLinePattern       = regexp.MustCompile(`^\s*-\s+\[( |x|X|✓|/|-|>|<|\?)\]\s+`)
+	todoTogglePattern     = regexp.MustCompile(`^(\s*-\s+\[)( |x|X|✓|/|-|>|<|\?)(\]\s+)`)
+	todoCompletedPattern  = regexp.MustCompile(`^\s*-\s+\[(x|X|✓)\]\s+`)
+	todoClosedPattern     = regexp.MustCompile(`^\s*-\s+\[(x|X|✓|-|<)\]\s+`)
+	taskProjectPattern    = regexp.MustCompile(`(^|\s)\+([A-Za-z]+)\b`)
+	taskTagPattern        = regexp.MustCompile(`(^|\s)#([A-Za-z]+)\b`)
+	taskMentionPattern    = regexp.MustCompile(`(^|\s)@([A-Za-z]+)\b`)
+	taskDuePattern        = regexp.MustCompile(`(^|\s)>(\S+)`)
+	taskPriorityPattern   = regexp.MustCompile(`(^|\s)\^([1-5])\b`)
+	taskRecurrencePattern = regexp.MustCompile(`(?i)(^|\s)\*every:(\S+)`)
+	taskDoneDatePattern   = regexp.MustCompile(`(^|\s)✓(\d{4}-\d{2}-\d{2})\b`)
+	taskTokenPattern      = regexp.MustCompile(`(^|\s)(#[A-Za-z]+|@[A-Za-z]+|\+[A-Za-z]+|\^[1-5]|>\S+|(?i:\*every:)\S+|\^t-[0-9a-z]+\b|✓\d{4}-\d{2}-\d{2}\b)`)
+	taskSomedayPattern    = regexp.MustCompile(`(?i)(^|\s)#someday\b`)
 )
 
+// Task statuses and the checkbox markers that write them. Done, cancelled and
+// migrated tasks are closed; the others still count as open work. Migrated
+// marks a task that daily rollover copied into a later note.
+const (
+	taskStatusOpen       = "open"
+	taskStatusDone       = "done"
+	taskStatusInProgress = "in-progress"
+	taskStatusCancelled  = "cancelled"
+	taskStatusDeferred   = "deferred"
+	taskStatusBlocked    = "blocked"
+	taskStatusMigrated   = "migrated"
+)
+
+var taskStatusMarkers = map[string]string{
+	taskStatusOpen:       " ",
+	taskStatusDone:       "x",
+	taskStatusInProgress: "/",
+	taskStatusCancelled:  "-",
+	taskStatusDeferred:   ">",
+	taskStatusBlocked:    "?",
+	taskStatusMigrated:   "<",
+}
+
+func taskStatusFromMarker(marker string) string {
+	switch marker {
+	case "x", "X", "✓":
+		return taskStatusDone
+	case "/":
+		return taskStatusInProgress
+	case "-":
+		return taskStatusCancelled
+	case ">":
+		return taskStatusDeferred
+	case "?":
+		return taskStatusBlocked
+	case "<":
+		return taskStatusMigrated
+	default:
+		return taskStatusOpen
+	}
+}
+
+func isClosedTaskStatus(status string) bool {
+	return status == taskStatusDone || status == taskStatusCancelled || status == taskStatusMigrated
+}
+
 type ParsedTodo struct {
 	LineNumber   int
 	LineHash     string
 	Text         string
 	Completed    bool
+	Status       string
 	Project      string
 	Tags         []string
 	Mentions     []string
 	DueDateRaw   string
 	DueDateISO   string
 	DueDateValid bool
-	Priority     int
+	// DueDateRelative marks due dates such as >tomorrow or >+3d. They are
+	// resolved against dueDateAnchor when a task is listed, so DueDateISO
+	// stays empty here.
+	DueDateRelative bool
+	Priority        int
+	// Recurrence is the normalised *every: spec, empty when the task does
+	// not repeat or the spec is not understood.
+	Recurrence string
+	// BlockID is the task's ^t-… token without the caret, empty when the
+	// line has none.
+	BlockID string
+	// CompletedDate is the YYYY-MM-DD of a ✓date token, written when the
+	// task is completed through the API.
+	CompletedDate string
+	// Indent is the width of the line's leading whitespace, counting a tab
+	// as four columns. Parent is the line number of the nearest less
+	// indented task above it in the same list, 0 for top-level tasks.
+	Indent int
+	Parent int
 }
 
 func parseTodoLines(content string) []ParsedTodo {
 	lines := strings.Split(content, "\n")
 	todos := make([]ParsedTodo, 0)
 	tracker := &codeBlockTracker{}
+	// parents holds the open task lines that later, deeper tasks nest under.
+	parents := make([]ParsedTodo, 0)
 	for i, line := range lines {
 		raw := strings.TrimSuffix(line, "\r")
 		if tracker.isCodeLine(raw) {
 			continue
 		}
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		indent := indentWidth(raw)
+		for len(parents) > 0 && parents[len(parents)-1].Indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
 		loc := todoLinePattern.FindStringIndex(raw)
 		if loc == nil {
 			continue
@@ -56,8 +133,12 @@ func parseTodoLines(content string) []ParsedTodo {
 		if strings.TrimSpace(rest) == "" {
 			continue
 		}
+		parent := 0
+		if len(parents) > 0 {
+			parent = parents[len(parents)-1].LineNumber
+		}
 
-		completed := match[1] != " "
+		status := taskStatusFromMarker(match[1])
 		restForMeta := stripInlineCode(rest)
 		project := extractFirstMatch(taskProjectPattern, restForMeta)
 		tags := extractMatches(taskTagPattern, restForMeta)
@@ -65,42 +146,92 @@ func parseTodoLines(content string) []ParsedTodo {
 		priority := extractPriority(restForMeta)
 		dueRaw := extractDueDate(restForMeta)
 		dueISO, dueValid := normalizeDueDate(dueRaw)
+		dueRelative := false
+		if dueRaw != "" && !dueValid {
+			_, dueRelative = parseRelativeDate(dueRaw, time.Time{})
+			dueValid = dueRelative
+		}
+		recurrence, _ := parseRecurrence(extractFirstMatch(taskRecurrencePattern, restForMeta))
+		blockID := extractFirstMatch(taskBlockIDPattern, restForMeta)
+		completedDate := extractFirstMatch(taskDoneDatePattern, restForMeta)
 
 		text := cleanTaskText(rest)
 		if text == "" {
 			text = strings.TrimSpace(rest)
 		}
 
-		todos = append(todos, ParsedTodo{
-			LineNumber:   i + 1,
-			LineHash:     hashLine(raw),
-			Text:         text,
-			Completed:    completed,
-			Project:      strings.ToLower(project),
-			Tags:         tags,
-			Mentions:     mentions,
-			DueDateRaw:   dueRaw,
-			DueDateISO:   dueISO,
-			DueDateValid: dueValid,
-			Priority:     priority,
-		})
+		todo := ParsedTodo{
+			LineNumber:      i + 1,
+			LineHash:        hashLine(raw),
+			Text:            text,
+			Completed:       status == taskStatusDone,
+			Status:          status,
+			Project:         strings.ToLower(project),
+			Tags:            tags,
+			Mentions:        mentions,
+			DueDateRaw:      dueRaw,
+			DueDateISO:      dueISO,
+			DueDateValid:    dueValid,
+			DueDateRelative: dueRelative,
+			Priority:        priority,
+			Recurrence:      recurrence.String(),
+			BlockID:         blockID,
+			CompletedDate:   completedDate,
+			Indent:          indent,
+			Parent:          parent,
+		}
+		todos = append(todos, todo)
+		parents = append(parents, todo)
 	}
 	return todos
 }
 
 func setTaskLineCompletion(line string, completed bool) (string, bool) {
+	if completed {
+		return setTaskLineStatus(line, taskStatusDone)
+	}
+	return setTaskLineStatus(line, taskStatusOpen)
+}
+
+func setTaskLineStatus(line string, status string) (string, bool) {
+	marker, ok := taskStatusMarkers[status]
+	if !ok {
+		return "", false
+	}
 	match := todoTogglePattern.FindStringSubmatchIndex(line)
 	if match == nil || len(match) < 6 {
 		return "", false
 	}
-	marker := " "
-	if completed {
-		marker = "x"
-	}
 	updated := line[:match[4]] + marker + line[match[5]:]
 	return updated, true
 }
 
+// setTaskLineCompletedDate replaces the ✓date token of a task line, or removes
+// it when date is empty.
+func setTaskLineCompletedDate(line string, date string) (string, bool) {
+	if date == "" {
+		return replaceTaskLineToken(line, taskDoneDatePattern, "")
+	}
+	return replaceTaskLineToken(line, taskDoneDatePattern, "✓"+date)
+}
+
+// replaceTaskLineToken removes every match of pattern from the task text and
+// appends token when it is not empty. Inline code is left alone.
+func replaceTaskLineToken(line string, pattern *regexp.Regexp, token string) (string, bool) {
+	loc := todoLinePattern.FindStringIndex(line)
+	if loc == nil {
+		return "", false
+	}
+	prefix := line[:loc[1]]
+	masked, replacements := maskInlineCode(line[loc[1]:])
+	masked = pattern.ReplaceAllString(masked, " ")
+	trimmed := strings.TrimSpace(strings.Join(strings.Fields(masked), " "))
+	if token != "" {
+		trimmed = strings.TrimSpace(trimmed + " " + token)
+	}
+	return prefix + restoreInlineCode(trimmed, replacements), true
+}
+
 func setTaskLineDueDate(line string, dueISO string) (string, bool) {
 	loc := todoLinePattern.FindStringIndex(line)
 	if loc == nil {
@@ -122,8 +253,9 @@ func setTaskLineDueDate(line string, dueISO string) (string, bool) {
 	return updated, true
 }
 
+// archiveCompletedTaskLine archives done, cancelled and migrated tasks.
 func archiveCompletedTaskLine(line string) (string, bool) {
-	if !todoCompletedPattern.MatchString(line) {
+	if !todoClosedPattern.MatchString(line) {
 		return "", false
 	}
 	trimmed := strings.TrimSuffix(line, "\r")
@@ -137,6 +269,18 @@ func archiveCompletedTaskLine(line string) (string, bool) {
 	return updated, true
 }
 
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range leadingWhitespace(line) {
+		if r == '\t' {
+			width += 4
+			continue
+		}
+		width++
+	}
+	return width
+}
+
 func leadingWhitespace(text string) string {
 	for i, r := range text {
 		if r != ' ' && r != '\t' {
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// taskRecurrence is a parsed *every: spec. Interval specs repeat every N
+// days, weeks, months or years; anchored specs land on a weekday
+// (weekly:mon) or a day of the month (monthly:15).
+type taskRecurrence struct {
+	unit     byte
+	interval int
+	weekday  time.Weekday
+	monthDay int
+	anchored bool
+}
+
+var recurrenceUnits = map[string]byte{
+	"daily":    'd',
+	"weekly":   'w',
+	"monthly":  'm',
+	"yearly":   'y',
+	"annually": 'y',
+}
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseRecurrence accepts daily, weekly, monthly and yearly, an interval such
+// as 3d, 2w, 6m or 1y, and the anchored forms weekly:<day> and
+// monthly:<1-31>.
+func parseRecurrence(raw string) (taskRecurrence, bool) {
+	spec := strings.ToLower(strings.TrimRight(raw, ".,;:)]}"))
+	if spec == "" {
+		return taskRecurrence{}, false
+	}
+	name, anchor, hasAnchor := strings.Cut(spec, ":")
+	if unit, ok := recurrenceUnits[name]; ok {
+		rule := taskRecurrence{unit: unit, interval: 1}
+		if !hasAnchor {
+			return rule, true
+		}
+		switch unit {
+		case 'w':
+			weekday, ok := recurrenceWeekdays[anchor]
+			if !ok {
+				return taskRecurrence{}, false
+			}
+			rule.weekday = weekday
+		case 'm':
+			day, err := strconv.Atoi(anchor)
+			if err != nil || day < 1 || day > 31 {
+				return taskRecurrence{}, false
+			}
+			rule.monthDay = day
+		default:
+			return taskRecurrence{}, false
+		}
+		rule.anchored = true
+		return rule, true
+	}
+	if hasAnchor || len(spec) < 2 {
+		return taskRecurrence{}, false
+	}
+	unit := spec[len(spec)-1]
+	interval, err := strconv.Atoi(spec[:len(spec)-1])
+	if err != nil || interval < 1 || strings.IndexByte("dwmy", unit) == -1 {
+		return taskRecurrence{}, false
+	}
+	return taskRecurrence{unit: unit, interval: interval}, true
+}
+
+// String returns the canonical spec, or "" for the zero value.
+func (r taskRecurrence) String() string {
+	if r.interval == 0 {
+		return ""
+	}
+	if r.anchored {
+		if r.unit == 'w' {
+			return "weekly:" + strings.ToLower(r.weekday.String()[:3])
+		}
+		return "monthly:" + strconv.Itoa(r.monthDay)
+	}
+	if r.interval == 1 {
+		for name, unit := range recurrenceUnits {
+			if unit == r.unit && name != "annually" {
+				return name
+			}
+		}
+	}
+	return strconv.Itoa(r.interval) + string(r.unit)
+}
+
+// next returns the first occurrence after from. Month and year steps keep the
+// day of the month, clamped to the length of the target month.
+func (r taskRecurrence) next(from time.Time) time.Time {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	switch {
+	case r.anchored && r.unit == 'w':
+		days := (int(r.weekday) - int(from.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return from.AddDate(0, 0, days)
+	case r.anchored:
+		candidate := addMonthsClamped(from, 0, r.monthDay)
+		if !candidate.After(from) {
+			candidate = addMonthsClamped(from, 1, r.monthDay)
+		}
+		return candidate
+	}
+	switch r.unit {
+	case 'd':
+		return from.AddDate(0, 0, r.interval)
+	case 'w':
+		return from.AddDate(0, 0, 7*r.interval)
+	case 'm':
+		return addMonthsClamped(from, r.interval, from.Day())
+	default:
+		return addMonthsClamped(from, 12*r.interval, from.Day())
+	}
+}
+
+// addMonthsClamped moves months forward from t and picks day, or the last day
+// of the month when it is shorter.
+func addMonthsClamped(t time.Time, months, day int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// nextRecurringTaskLine builds the open task that follows a recurring task
+// line. The next due date counts from the current due date, or from anchor
+// when the task has none. Relative due dates are also resolved from anchor.
+// Any block ID is dropped so the two tasks do not share it, as is any
+// completion date.
+func nextRecurringTaskLine(line string, anchor time.Time) (string, bool) {
+	todos := parseTodoLines(line)
+	if len(todos) != 1 || todos[0].Recurrence == "" {
+		return "", false
+	}
+	rule, _ := parseRecurrence(todos[0].Recurrence)
+	from := anchor
+	if iso, ok := resolveDueDate(todos[0].DueDateRaw, anchor); ok {
+		if due, err := time.ParseInLocation("2006-01-02", iso, time.Local); err == nil {
+			from = due
+		}
+	}
+	open, ok := setTaskLineCompletion(line, false)
+	if !ok {
+		return "", false
+	}
+	// The completed line keeps its block ID; the next occurrence is a new task.
+	if todos[0].BlockID != "" {
+		open, _ = setTaskLineBlockID(open, "")
+	}
+	if todos[0].CompletedDate != "" {
+		open, _ = setTaskLineCompletedDate(open, "")
+	}
+	return setTaskLineDueDate(open, rule.next(from).Format("2006-01-02"))
+}
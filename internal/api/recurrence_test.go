@@ -0,0 +1,65 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	cases := []struct {
+		spec string
+		from string
+		want string
+		norm string
+	}{
+		{spec: "daily", from: "2026-01-31", want: "2026-02-01", norm: "daily"},
+		{spec: "2w", from: "2026-01-05", want: "2026-01-19", norm: "2w"},
+		{spec: "Monthly", from: "2026-01-31", want: "2026-02-28", norm: "monthly"},
+		{spec: "3m", from: "2026-11-15", want: "2027-02-15", norm: "3m"},
+		{spec: "annually", from: "2028-02-29", want: "2029-02-28", norm: "yearly"},
+		{spec: "monthly:15", from: "2026-01-10", want: "2026-01-15", norm: "monthly:15"},
+		{spec: "monthly:15", from: "2026-01-15", want: "2026-02-15", norm: "monthly:15"},
+		{spec: "monthly:31", from: "2026-02-01", want: "2026-02-28", norm: "monthly:31"},
+		{spec: "weekly:mon", from: "2026-01-05", want: "2026-01-12", norm: "weekly:mon"},
+		{spec: "weekly:fri", from: "2026-01-05", want: "2026-01-09", norm: "weekly:fri"},
+	}
+	for _, tc := range cases {
+		rule, ok := parseRecurrence(tc.spec)
+		if !ok {
+			t.Fatalf("%s: expected valid spec", tc.spec)
+		}
+		if rule.String() != tc.norm {
+			t.Fatalf("%s: expected %q, got %q", tc.spec, tc.norm, rule.String())
+		}
+		from, _ := time.ParseInLocation("2006-01-02", tc.from, time.Local)
+		if got := rule.next(from).Format("2006-01-02"); got != tc.want {
+			t.Fatalf("%s from %s: expected %s, got %s", tc.spec, tc.from, tc.want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "often", "0d", "weekly:someday", "monthly:32", "yearly:1", "2w:mon"} {
+		if _, ok := parseRecurrence(spec); ok {
+			t.Fatalf("%q: expected invalid spec", spec)
+		}
+	}
+}
+
+func TestNextRecurringTaskLine(t *testing.T) {
+	today := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+	next, ok := nextRecurringTaskLine("  - [ ] Pay rent +home *every:monthly:1 >2026-03-01", today)
+	if !ok || next != "  - [ ] Pay rent +home *every:monthly:1 >2026-04-01" {
+		t.Fatalf("unexpected next line %q", next)
+	}
+	next, ok = nextRecurringTaskLine("- [ ] Water plants *every:3d", today)
+	if !ok || next != "- [ ] Water plants *every:3d >2026-03-13" {
+		t.Fatalf("unexpected next line %q", next)
+	}
+	if _, ok := nextRecurringTaskLine("- [ ] One off >2026-03-01", today); ok {
+		t.Fatalf("expected no next line for a task without recurrence")
+	}
+
+	todos := parseTodoLines("- [ ] Stretch *every:Daily #health")
+	if len(todos) != 1 || todos[0].Recurrence != "daily" || todos[0].Text != "Stretch" {
+		t.Fatalf("unexpected parsed todo %#v", todos)
+	}
+}
@@ -0,0 +1,386 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownLinkPattern = regexp.MustCompile(`(!?\[[^\]\n]*\]\()([^)\s]+)((?:\s+"[^"\n]*")?\))`)
+	externalHrefPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*:|/|#)`)
+)
+
+var errRenameConflict = errors.New("notes changed during rename")
+
+type RenameResponse struct {
+	Path    string         `json:"path"`
+	NewPath string         `json:"newPath"`
+	DryRun  bool           `json:"dryRun,omitempty"`
+	Changes []RenameChange `json:"changes"`
+	Filters []string       `json:"filters,omitempty"`
+}
+
+// RenameChange describes one rewritten line. Path is the file's location
+// before the rename.
+type RenameChange struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// renameMove describes a note or folder rename in notes-relative slash paths.
+type renameMove struct {
+	from   string
+	to     string
+	folder bool
+}
+
+type renameFileEdit struct {
+	oldPath  string
+	newPath  string
+	original string
+	updated  string
+}
+
+type renamePlan struct {
+	move      renameMove
+	edits     []renameFileEdit
+	changes   []RenameChange
+	filters   TaskFilters
+	filterIDs []string
+}
+
+func (m renameMove) mapPath(p string) (string, bool) {
+	if p == m.from {
+		return m.to, true
+	}
+	if m.folder && strings.HasPrefix(p, m.from+"/") {
+		return m.to + p[len(m.from):], true
+	}
+	return p, false
+}
+
+// planRename computes every reference rewrite a rename needs without touching
+// the disk: wiki-links, relative markdown links and images, and saved task
+// filter path prefixes.
+func (s *Server) planRename(move renameMove) (renamePlan, error) {
+	plan := renamePlan{
+		move:    move,
+		edits:   make([]renameFileEdit, 0),
+		changes: make([]RenameChange, 0),
+	}
+
+	notes, err := listMarkdownNotes(s.notesDir)
+	if err != nil {
+		return plan, err
+	}
+	movedNotes := make([]noteInfo, 0, len(notes))
+	for _, note := range notes {
+		mapped, _ := move.mapPath(note.Path)
+		movedNotes = append(movedNotes, noteInfo{Path: mapped})
+	}
+	rewriter := &renameRewriter{
+		notesDir:    s.notesDir,
+		move:        move,
+		oldResolver: newWikiLinkResolver(notes),
+		newResolver: newWikiLinkResolver(movedNotes),
+	}
+
+	for _, note := range notes {
+		data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(note.Path)))
+		if err != nil {
+			return plan, err
+		}
+		original := string(data)
+		newPath, _ := move.mapPath(note.Path)
+		updated, changes := rewriter.rewriteNote(original, note.Path, newPath)
+		if len(changes) == 0 {
+			continue
+		}
+		plan.edits = append(plan.edits, renameFileEdit{
+			oldPath:  note.Path,
+			newPath:  newPath,
+			original: original,
+			updated:  updated,
+		})
+		plan.changes = append(plan.changes, changes...)
+	}
+
+	if _, err := os.Stat(s.taskFiltersFilePath()); err != nil {
+		if os.IsNotExist(err) {
+			return plan, nil
+		}
+		return plan, err
+	}
+	filters, _, err := s.loadTaskFilters()
+	if err != nil {
+		return plan, err
+	}
+	for i, filter := range filters.Filters {
+		if filter.PathPrefix == "" {
+			continue
+		}
+		if updated, ok := renamePathPrefix(filter.PathPrefix, move); ok {
+			filters.Filters[i].PathPrefix = updated
+			plan.filterIDs = append(plan.filterIDs, filter.ID)
+		}
+	}
+	if len(plan.filterIDs) > 0 {
+		plan.filters = filters
+	}
+	return plan, nil
+}
+
+// applyRename moves the path and writes the planned edits. Any failure rolls
+// back what was already written so clients never see a half-applied rename.
+func (s *Server) applyRename(plan renamePlan) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	for _, edit := range plan.edits {
+		data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(edit.oldPath)))
+		if err != nil || string(data) != edit.original {
+			return errRenameConflict
+		}
+	}
+
+	for _, edit := range plan.edits {
+		if err := s.snapshotNote(edit.oldPath, edit.original); err != nil {
+			s.logger.Warn("note snapshot failed", "path", edit.oldPath, "error", err)
+		}
+	}
+
+	absFrom := filepath.Join(s.notesDir, filepath.FromSlash(plan.move.from))
+	absTo := filepath.Join(s.notesDir, filepath.FromSlash(plan.move.to))
+	if err := os.Rename(absFrom, absTo); err != nil {
+		return err
+	}
+	if err := s.moveNoteHistory(plan.move.from, plan.move.to); err != nil {
+		s.logger.Warn("note history move failed", "path", plan.move.from, "error", err)
+	}
+
+	written := make([]renameFileEdit, 0, len(plan.edits))
+	rollback := func() {
+		for i := len(written) - 1; i >= 0; i-- {
+			edit := written[i]
+			absPath := filepath.Join(s.notesDir, filepath.FromSlash(edit.newPath))
+			if err := writeFileAtomic(absPath, []byte(edit.original)); err != nil {
+				s.logger.Error("rename rollback failed", "path", edit.newPath, "error", err)
+			}
+		}
+		if err := os.Rename(absTo, absFrom); err != nil {
+			s.logger.Error("rename rollback failed", "path", plan.move.to, "error", err)
+		}
+		if err := s.moveNoteHistory(plan.move.to, plan.move.from); err != nil {
+			s.logger.Error("rename rollback failed", "path", plan.move.to, "error", err)
+		}
+	}
+
+	for _, edit := range plan.edits {
+		absPath := filepath.Join(s.notesDir, filepath.FromSlash(edit.newPath))
+		if err := writeFileAtomic(absPath, []byte(edit.updated)); err != nil {
+			rollback()
+			return err
+		}
+		written = append(written, edit)
+	}
+	if len(plan.filterIDs) > 0 {
+		if err := s.saveTaskFilters(plan.filters); err != nil {
+			rollback()
+			return err
+		}
+	}
+	return nil
+}
+
+func (plan renamePlan) response(dryRun bool) RenameResponse {
+	return RenameResponse{
+		Path:    plan.move.from,
+		NewPath: plan.move.to,
+		DryRun:  dryRun,
+		Changes: plan.changes,
+		Filters: plan.filterIDs,
+	}
+}
+
+type renameRewriter struct {
+	notesDir    string
+	move        renameMove
+	oldResolver *wikiLinkResolver
+	newResolver *wikiLinkResolver
+}
+
+func (rw *renameRewriter) rewriteNote(content, oldSource, newSource string) (string, []RenameChange) {
+	lines := strings.Split(content, "\n")
+	changes := make([]RenameChange, 0)
+	tracker := &codeBlockTracker{}
+	for i, line := range lines {
+		if tracker.isCodeLine(line) {
+			continue
+		}
+		masked, replacements := maskInlineCode(line)
+		masked = wikiLinkPattern.ReplaceAllStringFunc(masked, func(match string) string {
+			return rw.rewriteWikiLink(match, oldSource, newSource)
+		})
+		masked = markdownLinkPattern.ReplaceAllStringFunc(masked, func(match string) string {
+			return rw.rewriteMarkdownLink(match, oldSource, newSource)
+		})
+		updated := restoreInlineCode(masked, replacements)
+		if updated == line {
+			continue
+		}
+		changes = append(changes, RenameChange{
+			Path:   oldSource,
+			Line:   i + 1,
+			Before: strings.TrimSuffix(line, "\r"),
+			After:  strings.TrimSuffix(updated, "\r"),
+		})
+		lines[i] = updated
+	}
+	return strings.Join(lines, "\n"), changes
+}
+
+// rewriteWikiLink updates a [[link]] whose target no longer resolves to the
+// same note after the rename, keeping any heading and alias untouched.
+func (rw *renameRewriter) rewriteWikiLink(match, oldSource, newSource string) string {
+	inner := match[2 : len(match)-2]
+	end := strings.IndexAny(inner, "#|")
+	if end == -1 {
+		end = len(inner)
+	}
+	target := strings.TrimSpace(inner[:end])
+	if target == "" {
+		return match
+	}
+	previous, ok := rw.oldResolver.resolve(oldSource, target)
+	if !ok {
+		return match
+	}
+	expected, _ := rw.move.mapPath(previous)
+	if current, ok := rw.newResolver.resolve(newSource, target); ok && current == expected {
+		return match
+	}
+	return "[[" + rw.wikiLinkTarget(newSource, expected, target) + inner[end:] + "]]"
+}
+
+// wikiLinkTarget picks the shortest link text that resolves to dest from
+// source: the bare title when the original link used one, otherwise a path.
+func (rw *renameRewriter) wikiLinkTarget(source, dest, original string) string {
+	withExt := isMarkdown(original)
+	relative := relativeSlashPath(path.Dir(source), dest)
+	candidates := make([]string, 0, 3)
+	if !strings.Contains(original, "/") {
+		candidates = append(candidates, noteTitle(dest))
+	}
+	candidates = append(candidates, strings.TrimSuffix(dest, ".md"), strings.TrimSuffix(relative, ".md"))
+	chosen := strings.TrimSuffix(relative, ".md")
+	for _, candidate := range candidates {
+		if resolved, ok := rw.newResolver.resolve(source, candidate); ok && resolved == dest {
+			chosen = candidate
+			break
+		}
+	}
+	if withExt {
+		chosen += ".md"
+	}
+	return chosen
+}
+
+// rewriteMarkdownLink updates relative [text](href) links and ![image](href)
+// embeds when either the target or the linking note moved.
+func (rw *renameRewriter) rewriteMarkdownLink(match, oldSource, newSource string) string {
+	parts := markdownLinkPattern.FindStringSubmatch(match)
+	href := parts[2]
+	if externalHrefPattern.MatchString(href) {
+		return match
+	}
+	hrefPath, fragment, hasFragment := strings.Cut(href, "#")
+	if hrefPath == "" {
+		return match
+	}
+	decoded, err := url.PathUnescape(hrefPath)
+	if err != nil {
+		decoded = hrefPath
+	}
+	clean, err := cleanRelPath(path.Join(path.Dir(oldSource), decoded))
+	if err != nil || clean == "" {
+		return match
+	}
+	oldTarget := filepath.ToSlash(clean)
+	newTarget, moved := rw.move.mapPath(oldTarget)
+	if !moved && path.Dir(oldSource) == path.Dir(newSource) {
+		return match
+	}
+	if _, err := os.Stat(filepath.Join(rw.notesDir, filepath.FromSlash(oldTarget))); err != nil {
+		return match
+	}
+
+	updated := strings.ReplaceAll(relativeSlashPath(path.Dir(newSource), newTarget), " ", "%20")
+	if hasFragment {
+		updated += "#" + fragment
+	}
+	if updated == href {
+		return match
+	}
+	return parts[1] + updated + parts[3]
+}
+
+// renamePathPrefix maps a saved filter prefix onto the renamed path. Prefixes
+// may name the path itself, with or without a trailing slash or .md suffix.
+func renamePathPrefix(prefix string, move renameMove) (string, bool) {
+	trimmed := strings.TrimSuffix(prefix, "/")
+	slash := prefix[len(trimmed):]
+	if mapped, ok := move.mapPath(trimmed); ok {
+		return mapped + slash, true
+	}
+	if !move.folder && trimmed == strings.TrimSuffix(move.from, ".md") {
+		return strings.TrimSuffix(move.to, ".md") + slash, true
+	}
+	return prefix, false
+}
+
+func relativeSlashPath(fromDir, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeFileAtomic replaces a file by writing a sibling temp file and renaming
+// it into place, keeping the original permissions.
+func writeFileAtomic(absPath string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(absPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(absPath), "."+filepath.Base(absPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, absPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
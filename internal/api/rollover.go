@@ -0,0 +1,109 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dailyRollover holds the tasks carried from the latest earlier daily note
+// into a new one, and that note with the carried tasks marked as migrated.
+type dailyRollover struct {
+	SourcePath    string
+	SourceContent string
+	Lines         []string
+}
+
+// planDailyRollover finds the latest daily note before today and collects its
+// unfinished tasks. It returns nil when there is nothing to carry.
+func (s *Server) planDailyRollover(today time.Time) (*dailyRollover, error) {
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return nil, err
+	}
+	sourcePath := ""
+	var sourceDate time.Time
+	for _, note := range notes {
+		noteDate, ok := parseDailyNoteDate(note.Path)
+		if !ok || !noteDate.Before(today) {
+			continue
+		}
+		if sourcePath == "" || noteDate.After(sourceDate) {
+			sourcePath = note.Path
+			sourceDate = noteDate
+		}
+	}
+	if sourcePath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(sourcePath)))
+	if err != nil {
+		return nil, err
+	}
+	migrated, carried := migrateOpenTasks(string(data), sourcePath)
+	if len(carried) == 0 {
+		return nil, nil
+	}
+	return &dailyRollover{SourcePath: sourcePath, SourceContent: migrated, Lines: carried}, nil
+}
+
+// migrateOpenTasks marks the open, in-progress and blocked tasks of a daily
+// note as migrated ([<]) and returns the note along with the lines to carry.
+// Carried lines keep their tokens and their block ID, which is removed from
+// the migrated line. Relative due dates are pinned to the source note's date.
+// Subtasks stay nested under a carried parent and move to the top level
+// otherwise.
+func migrateOpenTasks(content, relPath string) (string, []string) {
+	lines := strings.Split(content, "\n")
+	carried := make(map[int]bool)
+	result := make([]string, 0)
+	for _, todo := range parseTodoLines(maskFrontMatter(content)) {
+		if isClosedTaskStatus(todo.Status) || todo.Status == taskStatusDeferred {
+			continue
+		}
+		carried[todo.LineNumber] = true
+
+		line := lines[todo.LineNumber-1]
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+			line = strings.TrimSuffix(line, "\r")
+		}
+
+		next := line
+		if todo.Parent == 0 || !carried[todo.Parent] {
+			next = strings.TrimLeft(next, " \t")
+		}
+		if todo.DueDateRelative {
+			if iso, ok := resolveDueDate(todo.DueDateRaw, dueDateAnchor(relPath)); ok {
+				next, _ = setTaskLineDueDate(next, iso)
+			}
+		}
+		result = append(result, next)
+
+		marked, ok := setTaskLineStatus(line, taskStatusMigrated)
+		if !ok {
+			continue
+		}
+		if todo.BlockID != "" {
+			marked, _ = setTaskLineBlockID(marked, "")
+		}
+		lines[todo.LineNumber-1] = marked + ending
+	}
+	if len(result) == 0 {
+		return content, nil
+	}
+	return strings.Join(lines, "\n"), result
+}
+
+// appendRolloverTasks adds the carried task lines to the end of a new daily
+// note.
+func appendRolloverTasks(content string, lines []string) string {
+	if strings.TrimSpace(content) == "" {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	content = strings.TrimRight(content, "\n")
+	return content + "\n\n" + strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,50 @@
+package api
+
+import "testing"
+
+func TestMigrateOpenTasks(t *testing.T) {
+	content := "# 2026-10-16\n" +
+		"- [ ] Call Bob >tomorrow +work !high ^t-abc1\n" +
+		"- [x] Shipped\n" +
+		"- [x] Parent done\n" +
+		"  - [ ] Orphan step\n" +
+		"- [/] Draft spec\n" +
+		"  - [ ] Outline\n" +
+		"  - [-] Dropped\n" +
+		"- [>] Deferred idea\n" +
+		"- [<] Already moved\n"
+
+	migrated, carried := migrateOpenTasks(content, "Daily/2026-10-16.md")
+	expectedCarried := []string{
+		"- [ ] Call Bob +work !high ^t-abc1 >2026-10-17",
+		"- [ ] Orphan step",
+		"- [/] Draft spec",
+		"  - [ ] Outline",
+	}
+	if len(carried) != len(expectedCarried) {
+		t.Fatalf("expected %d carried lines, got %#v", len(expectedCarried), carried)
+	}
+	for i, line := range expectedCarried {
+		if carried[i] != line {
+			t.Fatalf("carried line %d: expected %q, got %q", i, line, carried[i])
+		}
+	}
+
+	expected := "# 2026-10-16\n" +
+		"- [<] Call Bob >tomorrow +work !high\n" +
+		"- [x] Shipped\n" +
+		"- [x] Parent done\n" +
+		"  - [<] Orphan step\n" +
+		"- [<] Draft spec\n" +
+		"  - [<] Outline\n" +
+		"  - [-] Dropped\n" +
+		"- [>] Deferred idea\n" +
+		"- [<] Already moved\n"
+	if migrated != expected {
+		t.Fatalf("unexpected migrated note %q", migrated)
+	}
+
+	if same, none := migrateOpenTasks("- [x] Done\n", "Daily/2026-10-16.md"); same != "- [x] Done\n" || none != nil {
+		t.Fatalf("expected note without open tasks to be unchanged")
+	}
+}
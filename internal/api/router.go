@@ -1,12 +1,20 @@
 package api
 
 import (
+	"context"
 	"log/slog"
+	"net/http"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
+	return NewRouterContext(context.Background(), notesDir, logger...)
+}
+
+// NewRouterContext is NewRouter with the notes watcher stopped, and its
+// inotify watches released, once ctx is done.
+func NewRouterContext(ctx context.Context, notesDir string, logger ...*slog.Logger) chi.Router {
 	var baseLogger *slog.Logger
 	if len(logger) > 0 && logger[0] != nil {
 		baseLogger = logger[0]
@@ -16,19 +24,26 @@ func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
 	s := &Server{
 		notesDir: notesDir,
 		logger:   baseLogger.With("component", "api"),
+		done:     ctx.Done(),
 	}
 	if err := s.ensureAIStorage(); err != nil {
 		s.logger.Error("ai storage init failed", "error", err)
 	}
 	s.startEmailSchedulers()
+	s.startWatcher(ctx)
 
 	r := chi.NewRouter()
 	r.Get("/health", s.handleHealth)
+	r.Get("/events", s.handleEvents)
 	r.Get("/tree", s.handleTree)
 	r.Get("/notes", s.handleGetNote)
 	r.Post("/notes", s.handleCreateNote)
 	r.Patch("/notes", s.handleUpdateNote)
 	r.Patch("/notes/rename", s.handleRenameNote)
+	r.Get("/notes/history", s.handleNoteHistoryList)
+	r.Get("/notes/history/version", s.handleNoteHistoryVersion)
+	r.Get("/notes/history/diff", s.handleNoteHistoryDiff)
+	r.Post("/notes/history/restore", s.handleNoteHistoryRestore)
 	r.Delete("/notes", s.handleDeleteNote)
 	r.Get("/files", s.handleGetFile)
 	r.Get("/search", s.handleSearch)
@@ -42,6 +57,8 @@ func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
 	r.Get("/journal/archive", s.handleJournalArchiveGet)
 	r.Get("/tags", s.handleTags)
 	r.Get("/mentions", s.handleMentions)
+	r.Get("/links", s.handleLinks)
+	r.Get("/graph", s.handleGraph)
 	r.Get("/settings", s.handleSettingsGet)
 	r.Patch("/settings", s.handleSettingsUpdate)
 	r.Get("/email/settings", s.handleEmailSettingsGet)
@@ -57,16 +74,25 @@ func NewRouter(notesDir string, logger ...*slog.Logger) chi.Router {
 	r.Get("/tasks/filters", s.handleTaskFiltersGet)
 	r.Put("/tasks/filters", s.handleTaskFiltersUpdate)
 	r.Patch("/tasks/toggle", s.handleTasksToggle)
+	r.Patch("/tasks/status", s.handleTasksStatus)
 	r.Patch("/tasks/due", s.handleTasksDue)
 	r.Patch("/tasks/archive", s.handleTasksArchive)
+	r.Patch("/tasks/ids", s.handleTasksAssignIDs)
+	r.Get("/tasks/log", s.handleTasksLog)
+	r.Get("/tasks.ics", s.handleTasksICS)
+	r.Handle("/caldav", http.HandlerFunc(s.handleCalDAV))
+	r.Handle("/caldav/*", http.HandlerFunc(s.handleCalDAV))
 	r.Get("/sheets/tree", s.handleSheetsTree)
 	r.Get("/sheets", s.handleSheetsGet)
 	r.Post("/sheets", s.handleSheetsCreate)
 	r.Patch("/sheets", s.handleSheetsUpdate)
+	r.Post("/sheets/operations", s.handleSheetsOperations)
 	r.Patch("/sheets/rename", s.handleSheetsRename)
 	r.Delete("/sheets", s.handleSheetsDelete)
 	r.Post("/sheets/import", s.handleSheetsImport)
+	r.Post("/sheets/import/worksheets", s.handleSheetsWorksheets)
 	r.Get("/sheets/export", s.handleSheetsExport)
+	r.Get("/sheets/query", s.handleSheetsQuery)
 	r.Route("/ai", func(r chi.Router) {
 		r.Get("/settings", s.handleAISettingsGet)
 		r.Get("/chats", s.handleAIChatsList)
@@ -0,0 +1,606 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	searchDefaultLimit   = 50
+	searchMaxLimit       = 200
+	searchSnippetRunes   = 160
+	searchSnippetLeading = 40
+)
+
+type SearchHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// searchTerm is a positive query term used to locate and highlight matches
+// inside note lines. Words holds the lowercased tokens; more than one token
+// means the term is a phrase.
+type searchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+type searchQuery struct {
+	Match   string
+	Terms   []searchTerm
+	Filters []searchFilter
+	// Groups holds filter expressions that are not a plain AND of filters,
+	// such as "tag:a OR tag:b" or "NOT (tag:a path:b/)".
+	Groups []searchFilterExpr
+}
+
+// addFilters splits top-level filters into plain filters and groups.
+func (q *searchQuery) addFilters(filters []searchFilterExpr) {
+	for _, expr := range filters {
+		switch {
+		case expr.Any == nil && expr.All == nil:
+			q.Filters = append(q.Filters, expr.Filter)
+		case expr.All != nil && !expr.Negated:
+			q.addFilters(expr.All)
+		default:
+			q.Groups = append(q.Groups, expr)
+		}
+	}
+}
+
+type searchToken struct {
+	kind    string
+	text    string
+	negated bool
+	prefix  bool
+	filter  searchFilter
+}
+
+const (
+	searchTokenWord   = "word"
+	searchTokenPhrase = "phrase"
+	searchTokenOpen   = "("
+	searchTokenClose  = ")"
+	searchTokenAnd    = "AND"
+	searchTokenOr     = "OR"
+	searchTokenNot    = "NOT"
+	searchTokenFilter = "filter"
+)
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+	limit := searchDefaultLimit
+	if rawLimit := strings.TrimSpace(r.URL.Query().Get("limit")); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		if parsed > searchMaxLimit {
+			parsed = searchMaxLimit
+		}
+		limit = parsed
+	}
+
+	parsed, err := parseSearchQuery(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	queryLimit := limit
+	if len(parsed.Filters) > 0 || len(parsed.Groups) > 0 {
+		// Filters are applied after ranking, so fetch every match first.
+		queryLimit = -1
+	}
+
+	idx, err := s.getSearchIndex()
+	if err != nil {
+		s.logger.Error("search index open failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to open search index")
+		return
+	}
+	notes, err := s.vaultNoteMap()
+	if err != nil {
+		s.logger.Error("vault refresh failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to search notes")
+		return
+	}
+	if err := idx.refresh(s.notesDir, notes); err != nil {
+		s.logger.Error("search index refresh failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "unable to search notes")
+		return
+	}
+	matches, err := idx.query(parsed.Match, queryLimit)
+	if err != nil {
+		s.logger.Warn("search query failed", "query", query, "match", parsed.Match, "error", err)
+		writeError(w, http.StatusBadRequest, "invalid search query")
+		return
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for _, match := range matches {
+		if len(results) >= limit {
+			break
+		}
+		if !matchesSearchFilters(parsed, match, notes[match.Path]) {
+			continue
+		}
+		result := SearchResult{
+			Path:  match.Path,
+			Name:  path.Base(match.Path),
+			Type:  "note",
+			Score: -match.Rank,
+		}
+		line, snippet, highlights := findSearchLine(match.Content, parsed.Terms)
+		if line > 0 {
+			result.Line = line
+			result.Snippet = snippet
+			result.Highlights = highlights
+		}
+		results = append(results, result)
+	}
+
+	// Task search is intentionally disabled for now.
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// parseSearchQuery turns user input into a safe FTS5 match expression.
+// Supported syntax: bare words (implicit AND), "quoted phrases", word*
+// prefixes, AND/OR/NOT operators, parentheses, and -term exclusions. The last
+// bare word is matched as a prefix so results keep up with typing. Field
+// filters (tag:, path:, ...) are matched against note metadata after ranking
+// and follow the same AND/OR/NOT rules as search terms.
+func parseSearchQuery(input string) (searchQuery, error) {
+	tokens, err := lexSearchQuery(input)
+	if err != nil {
+		return searchQuery{}, err
+	}
+	if len(tokens) > 0 {
+		last := &tokens[len(tokens)-1]
+		afterNot := len(tokens) > 1 && tokens[len(tokens)-2].kind == searchTokenNot
+		if last.kind == searchTokenWord && !last.negated && !afterNot {
+			last.prefix = true
+		}
+	}
+
+	parser := &searchQueryParser{tokens: tokens}
+	group, err := parser.parseGroup(false)
+	if err != nil {
+		return searchQuery{}, err
+	}
+	if parser.pos < len(parser.tokens) {
+		return searchQuery{}, errors.New("unbalanced parentheses in query")
+	}
+	if group.match == "" && len(group.filters) == 0 {
+		return searchQuery{}, errors.New("query must include at least one search term")
+	}
+	query := searchQuery{Match: group.match, Terms: parser.terms}
+	query.addFilters(group.filters)
+	return query, nil
+}
+
+func lexSearchQuery(input string) ([]searchToken, error) {
+	tokens := make([]searchToken, 0)
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+		if r == '(' || r == ')' {
+			tokens = append(tokens, searchToken{kind: string(r)})
+			i++
+			continue
+		}
+		negated := false
+		if r == '-' && i+1 < len(runes) && runes[i+1] == '(' {
+			tokens = append(tokens, searchToken{kind: searchTokenNot})
+			i++
+			continue
+		}
+		if r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negated = true
+			i++
+			r = runes[i]
+		}
+		if r == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			tokens = append(tokens, searchToken{
+				kind:    searchTokenPhrase,
+				text:    string(runes[i+1 : end]),
+				negated: negated,
+			})
+			i = end + 1
+			continue
+		}
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '(' && runes[end] != ')' && runes[end] != '"' {
+			end++
+		}
+		text := string(runes[i:end])
+		i = end
+		if !negated {
+			switch text {
+			case searchTokenAnd, searchTokenOr, searchTokenNot:
+				tokens = append(tokens, searchToken{kind: text})
+				continue
+			}
+		}
+		filter, ok, err := parseSearchFilter(text)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			tokens = append(tokens, searchToken{kind: searchTokenFilter, negated: negated, filter: filter})
+			continue
+		}
+		prefix := false
+		if strings.HasSuffix(text, "*") {
+			text = strings.TrimRight(text, "*")
+			prefix = true
+		}
+		tokens = append(tokens, searchToken{
+			kind:    searchTokenWord,
+			text:    text,
+			negated: negated,
+			prefix:  prefix,
+		})
+	}
+	return tokens, nil
+}
+
+type searchQueryParser struct {
+	tokens []searchToken
+	pos    int
+	terms  []searchTerm
+}
+
+// searchGroup is one parsed level of the query: the FTS5 match for its search
+// terms and the filters that must hold alongside it.
+type searchGroup struct {
+	match   string
+	filters []searchFilterExpr
+}
+
+type searchGroupItem struct {
+	operator string
+	group    searchGroup
+}
+
+// parseGroup renders one level of the query. Exclusions are collected and
+// applied to the whole group as "(positives) NOT a NOT b", since FTS5 only
+// supports NOT as a binary operator. Filters keep their place in the boolean
+// expression, but can only be combined with search terms through AND: the
+// index cannot answer "term OR tag:x" or "NOT (term tag:x)".
+func (p *searchQueryParser) parseGroup(nested bool) (searchGroup, error) {
+	positives := make([]searchGroupItem, 0)
+	negatives := make([]searchGroup, 0)
+	operator := ""
+	negateNext := false
+
+	for p.pos < len(p.tokens) {
+		token := p.tokens[p.pos]
+		p.pos++
+		switch token.kind {
+		case searchTokenClose:
+			if !nested {
+				return searchGroup{}, errors.New("unbalanced parentheses in query")
+			}
+			return buildSearchGroup(positives, negatives)
+		case searchTokenAnd:
+			operator = searchTokenAnd
+			continue
+		case searchTokenOr:
+			operator = searchTokenOr
+			continue
+		case searchTokenNot:
+			negateNext = true
+			continue
+		}
+
+		var group searchGroup
+		negated := negateNext || token.negated
+		negateNext = false
+		switch token.kind {
+		case searchTokenFilter:
+			group.filters = []searchFilterExpr{{Filter: token.filter}}
+		case searchTokenOpen:
+			termCount := len(p.terms)
+			inner, err := p.parseGroup(true)
+			if err != nil {
+				return searchGroup{}, err
+			}
+			if negated {
+				p.terms = p.terms[:termCount]
+			}
+			group = inner
+		case searchTokenWord, searchTokenPhrase:
+			words := searchWords(token.text)
+			if len(words) == 0 {
+				continue
+			}
+			prefix := token.prefix && token.kind == searchTokenWord
+			group.match = renderSearchTerm(words, prefix)
+			if !negated {
+				p.terms = append(p.terms, searchTerm{Words: words, Prefix: prefix})
+			}
+		}
+		if group.match == "" && len(group.filters) == 0 {
+			continue
+		}
+		if negated && group.match == "" {
+			// Excluded filters stay in place so they can take part in OR.
+			group.filters = []searchFilterExpr{allSearchFilters(group.filters).negate()}
+			negated = false
+		}
+		if negated {
+			negatives = append(negatives, group)
+			operator = ""
+			continue
+		}
+		if len(positives) > 0 && operator == "" {
+			operator = searchTokenAnd
+		}
+		positives = append(positives, searchGroupItem{operator: operator, group: group})
+		operator = ""
+	}
+	if nested {
+		return searchGroup{}, errors.New("unbalanced parentheses in query")
+	}
+	return buildSearchGroup(positives, negatives)
+}
+
+// buildSearchGroup combines the items of one level. Items joined by OR must be
+// all search terms or all filters, and excluded search terms must not carry
+// filters; excluded filters arrive here already negated.
+func buildSearchGroup(positives []searchGroupItem, negatives []searchGroup) (searchGroup, error) {
+	hasOr := false
+	hasTerms := false
+	hasFilters := false
+	for i, item := range positives {
+		if i > 0 && item.operator == searchTokenOr {
+			hasOr = true
+		}
+		hasTerms = hasTerms || item.group.match != ""
+		hasFilters = hasFilters || len(item.group.filters) > 0
+	}
+	if hasOr && hasTerms && hasFilters {
+		return searchGroup{}, errors.New("filters cannot be combined with search terms using OR")
+	}
+
+	var group searchGroup
+	matches := make([]string, 0)
+	if hasOr && hasFilters {
+		alternatives := make([]searchFilterExpr, 0)
+		for i, item := range positives {
+			if i > 0 && item.operator == searchTokenOr {
+				alternatives = append(alternatives, allSearchFilters(group.filters))
+				group.filters = nil
+			}
+			group.filters = append(group.filters, item.group.filters...)
+		}
+		alternatives = append(alternatives, allSearchFilters(group.filters))
+		group.filters = []searchFilterExpr{{Any: alternatives}}
+	} else {
+		for _, item := range positives {
+			if item.group.match != "" {
+				if len(matches) > 0 {
+					matches = append(matches, item.operator)
+				}
+				matches = append(matches, item.group.match)
+			}
+			group.filters = append(group.filters, item.group.filters...)
+		}
+	}
+
+	excluded := make([]string, 0)
+	for _, negative := range negatives {
+		if len(negative.filters) > 0 {
+			return searchGroup{}, errors.New("NOT cannot apply to a group that mixes filters and search terms")
+		}
+		excluded = append(excluded, negative.match)
+	}
+	group.match = renderSearchGroup(matches, excluded)
+	return group, nil
+}
+
+func renderSearchGroup(positives, negatives []string) string {
+	if len(positives) == 0 {
+		return ""
+	}
+	expr := strings.Join(positives, " ")
+	if len(positives) > 1 {
+		expr = "(" + expr + ")"
+	}
+	for _, negative := range negatives {
+		expr += " NOT " + negative
+	}
+	if len(negatives) > 0 {
+		expr = "(" + expr + ")"
+	}
+	return expr
+}
+
+func renderSearchTerm(words []string, prefix bool) string {
+	quoted := `"` + strings.ReplaceAll(strings.Join(words, " "), `"`, `""`) + `"`
+	if prefix {
+		quoted += "*"
+	}
+	return quoted
+}
+
+// searchWords splits text the same way the unicode61 tokenizer does, so
+// highlighting lines up with what the index matched.
+func searchWords(text string) []string {
+	words := make([]string, 0)
+	for _, span := range searchWordSpans([]rune(text)) {
+		words = append(words, span.word)
+	}
+	return words
+}
+
+type searchWordSpan struct {
+	word  string
+	start int
+	end   int
+}
+
+func searchWordSpans(runes []rune) []searchWordSpan {
+	spans := make([]searchWordSpan, 0)
+	start := -1
+	for i, r := range runes {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			spans = append(spans, searchWordSpan{word: strings.ToLower(string(runes[start:i])), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		spans = append(spans, searchWordSpan{word: strings.ToLower(string(runes[start:])), start: start, end: len(runes)})
+	}
+	return spans
+}
+
+// findSearchLine picks the line matching the most distinct query terms and
+// returns its 1-based number with a trimmed snippet. Highlight offsets are
+// rune positions within the snippet.
+func findSearchLine(content string, terms []searchTerm) (int, string, []SearchHighlight) {
+	if len(terms) == 0 {
+		return 0, "", nil
+	}
+	bestLine := 0
+	bestScore := 0
+	var bestRunes []rune
+	var bestRanges []SearchHighlight
+
+	for i, line := range strings.Split(content, "\n") {
+		runes := []rune(strings.TrimRight(line, "\r"))
+		spans := searchWordSpans(runes)
+		if len(spans) == 0 {
+			continue
+		}
+		ranges := make([]SearchHighlight, 0)
+		score := 0
+		for _, term := range terms {
+			found := matchSearchTerm(spans, term)
+			if len(found) == 0 {
+				continue
+			}
+			score++
+			ranges = append(ranges, found...)
+		}
+		if score > bestScore {
+			bestScore = score
+			bestLine = i + 1
+			bestRunes = runes
+			bestRanges = ranges
+			if score == len(terms) {
+				break
+			}
+		}
+	}
+	if bestLine == 0 {
+		return 0, "", nil
+	}
+	snippet, highlights := buildSearchSnippet(bestRunes, bestRanges)
+	return bestLine, snippet, highlights
+}
+
+func matchSearchTerm(spans []searchWordSpan, term searchTerm) []SearchHighlight {
+	found := make([]SearchHighlight, 0)
+	count := len(term.Words)
+	for i := 0; i+count <= len(spans); i++ {
+		matched := true
+		for j, word := range term.Words {
+			candidate := spans[i+j].word
+			last := j == count-1
+			if last && term.Prefix {
+				if !strings.HasPrefix(candidate, word) {
+					matched = false
+					break
+				}
+				continue
+			}
+			if candidate != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found = append(found, SearchHighlight{Start: spans[i].start, End: spans[i+count-1].end})
+		}
+	}
+	return found
+}
+
+func buildSearchSnippet(runes []rune, ranges []SearchHighlight) (string, []SearchHighlight) {
+	start := 0
+	for start < len(runes) && unicode.IsSpace(runes[start]) {
+		start++
+	}
+	end := len(runes)
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	if end-start > searchSnippetRunes {
+		first := end
+		for _, item := range ranges {
+			if item.Start < first {
+				first = item.Start
+			}
+		}
+		windowStart := first - searchSnippetLeading
+		if windowStart < start {
+			windowStart = start
+		}
+		windowEnd := windowStart + searchSnippetRunes
+		if windowEnd > end {
+			windowEnd = end
+			windowStart = end - searchSnippetRunes
+		}
+		start = windowStart
+		end = windowEnd
+	}
+
+	prefix := ""
+	if start > 0 && strings.TrimSpace(string(runes[:start])) != "" {
+		prefix = "…"
+	}
+	suffix := ""
+	if end < len(runes) && strings.TrimSpace(string(runes[end:])) != "" {
+		suffix = "…"
+	}
+	offset := len([]rune(prefix)) - start
+
+	highlights := make([]SearchHighlight, 0, len(ranges))
+	for _, item := range ranges {
+		if item.Start < start || item.End > end {
+			continue
+		}
+		highlights = append(highlights, SearchHighlight{Start: item.Start + offset, End: item.End + offset})
+	}
+	sort.Slice(highlights, func(i, j int) bool {
+		return highlights[i].Start < highlights[j].Start
+	})
+	return prefix + string(runes[start:end]) + suffix, highlights
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	searchFilterTag      = "tag"
+	searchFilterMention  = "mention"
+	searchFilterProject  = "project"
+	searchFilterPath     = "path"
+	searchFilterModified = "modified"
+	searchFilterCreated  = "created"
+	searchFilterMeta     = "meta"
+)
+
+// searchFilter narrows search results by note metadata. Op is only used by
+// modified: and created: filters and is one of "=", ">", ">=", "<", "<=". Key
+// is the front matter key of a meta: filter; an empty Value matches any note
+// that has the key.
+type searchFilter struct {
+	Field   string
+	Op      string
+	Key     string
+	Value   string
+	Negated bool
+}
+
+// searchNoteFacets holds the metadata of a single note that filters match
+// against. Tags, mentions, projects and front matter are lowercased.
+type searchNoteFacets struct {
+	Tags     map[string]bool
+	Mentions map[string]bool
+	Projects map[string]bool
+	Modified string
+	Created  string
+	Meta     map[string][]string
+}
+
+// parseSearchFilter recognises field:value filters and the #tag, @mention and
+// +project shorthands. Words that look like neither are left to full-text
+// matching.
+func parseSearchFilter(text string) (searchFilter, bool, error) {
+	switch {
+	case len(text) > 1 && text[0] == '#' && isSearchFilterName(text[1:]):
+		return searchFilter{Field: searchFilterTag, Value: strings.ToLower(text[1:])}, true, nil
+	case len(text) > 1 && text[0] == '@' && isSearchFilterName(text[1:]):
+		return searchFilter{Field: searchFilterMention, Value: strings.ToLower(text[1:])}, true, nil
+	case len(text) > 1 && text[0] == '+' && isSearchFilterName(text[1:]):
+		return searchFilter{Field: searchFilterProject, Value: strings.ToLower(text[1:])}, true, nil
+	}
+
+	field, value, ok := strings.Cut(text, ":")
+	if !ok {
+		return searchFilter{}, false, nil
+	}
+	field = strings.ToLower(field)
+	switch field {
+	case searchFilterTag, searchFilterMention, searchFilterProject:
+		value = strings.ToLower(strings.TrimLeft(value, "#@+"))
+		if value == "" {
+			return searchFilter{}, false, fmt.Errorf("%s: filter requires a value", field)
+		}
+		return searchFilter{Field: field, Value: value}, true, nil
+	case searchFilterPath:
+		value = strings.TrimPrefix(strings.ReplaceAll(value, "\\", "/"), "/")
+		if value == "" {
+			return searchFilter{}, false, fmt.Errorf("%s: filter requires a value", field)
+		}
+		return searchFilter{Field: field, Value: strings.ToLower(value)}, true, nil
+	case searchFilterMeta:
+		key, metaValue, _ := strings.Cut(value, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			return searchFilter{}, false, fmt.Errorf("%s: filter requires a key", field)
+		}
+		return searchFilter{Field: field, Key: key, Value: strings.ToLower(strings.TrimSpace(metaValue))}, true, nil
+	case searchFilterModified, searchFilterCreated:
+		op := "="
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(value, candidate) {
+				op = candidate
+				value = strings.TrimPrefix(value, candidate)
+				break
+			}
+		}
+		iso, valid := resolveDueDate(value, timeNow())
+		if !valid {
+			return searchFilter{}, false, fmt.Errorf("invalid %s date: %s", field, value)
+		}
+		return searchFilter{Field: field, Op: op, Value: iso}, true, nil
+	}
+	return searchFilter{}, false, nil
+}
+
+func isSearchFilterName(value string) bool {
+	for _, r := range value {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// searchFilterExpr is a boolean expression over filters. A leaf holds Filter
+// and carries its negation in Filter.Negated; otherwise the expression matches
+// when any of Any, or all of All, match.
+type searchFilterExpr struct {
+	Filter  searchFilter
+	Any     []searchFilterExpr
+	All     []searchFilterExpr
+	Negated bool
+}
+
+func allSearchFilters(filters []searchFilterExpr) searchFilterExpr {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return searchFilterExpr{All: filters}
+}
+
+func (expr searchFilterExpr) negate() searchFilterExpr {
+	if expr.Any == nil && expr.All == nil {
+		expr.Filter.Negated = !expr.Filter.Negated
+		return expr
+	}
+	expr.Negated = !expr.Negated
+	return expr
+}
+
+func matchesSearchFilters(query searchQuery, match searchIndexMatch, note *vaultNote) bool {
+	if len(query.Filters) == 0 && len(query.Groups) == 0 {
+		return true
+	}
+	facets := buildSearchNoteFacets(match, note)
+	notePath := strings.ToLower(match.Path)
+	for _, filter := range query.Filters {
+		if !matchSearchFilter(filter, facets, notePath) {
+			return false
+		}
+	}
+	for _, expr := range query.Groups {
+		if !matchSearchFilterExpr(expr, facets, notePath) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchSearchFilterExpr(expr searchFilterExpr, facets searchNoteFacets, notePath string) bool {
+	if expr.Any == nil && expr.All == nil {
+		return matchSearchFilter(expr.Filter, facets, notePath)
+	}
+	matched := expr.All != nil
+	for _, child := range expr.All {
+		if !matchSearchFilterExpr(child, facets, notePath) {
+			matched = false
+			break
+		}
+	}
+	for _, child := range expr.Any {
+		if matchSearchFilterExpr(child, facets, notePath) {
+			matched = true
+			break
+		}
+	}
+	return matched != expr.Negated
+}
+
+func matchSearchFilter(filter searchFilter, facets searchNoteFacets, notePath string) bool {
+	matched := false
+	switch filter.Field {
+	case searchFilterTag:
+		matched = facets.Tags[filter.Value]
+	case searchFilterMention:
+		matched = facets.Mentions[filter.Value]
+	case searchFilterProject:
+		matched = facets.Projects[filter.Value]
+	case searchFilterPath:
+		matched = strings.HasPrefix(notePath, filter.Value)
+	case searchFilterModified:
+		matched = compareSearchDate(facets.Modified, filter.Op, filter.Value)
+	case searchFilterCreated:
+		matched = facets.Created != "" && compareSearchDate(facets.Created, filter.Op, filter.Value)
+	case searchFilterMeta:
+		values, ok := facets.Meta[filter.Key]
+		matched = ok && (filter.Value == "" || slices.Contains(values, filter.Value))
+	}
+	return matched != filter.Negated
+}
+
+// buildSearchNoteFacets takes note metadata from the vault, so it follows the
+// same rules as the tags and mentions listings; projects come from the note's
+// tasks and front matter. Front matter created and updated dates take the
+// place of the file times. A note missing from the vault matches no facets.
+func buildSearchNoteFacets(match searchIndexMatch, note *vaultNote) searchNoteFacets {
+	facets := searchNoteFacets{
+		Tags:     make(map[string]bool),
+		Mentions: make(map[string]bool),
+		Projects: make(map[string]bool),
+		Modified: time.Unix(0, match.Modified).In(time.Local).Format("2006-01-02"),
+	}
+	if note == nil {
+		return facets
+	}
+	for _, tag := range note.Tags {
+		facets.Tags[strings.ToLower(tag)] = true
+	}
+	for _, mention := range note.Mentions {
+		facets.Mentions[mention] = true
+	}
+	for _, todo := range note.Todos {
+		if todo.Project != "" {
+			facets.Projects[todo.Project] = true
+		}
+	}
+	if note.Meta != nil {
+		if note.Meta.Project != "" {
+			facets.Projects[note.Meta.Project] = true
+		}
+		if note.Meta.Updated != "" {
+			facets.Modified = note.Meta.Updated
+		}
+		facets.Created = note.Meta.Created
+		facets.Meta = note.Meta.metaValues()
+	}
+	return facets
+}
+
+func compareSearchDate(value, op, target string) bool {
+	switch op {
+	case ">":
+		return value > target
+	case ">=":
+		return value >= target
+	case "<":
+		return value < target
+	case "<=":
+		return value <= target
+	default:
+		return value == target
+	}
+}
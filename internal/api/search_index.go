@@ -0,0 +1,224 @@
+package api
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const searchIndexFileName = "search.sqlite"
+
+type SearchIndex struct {
+	db     *sql.DB
+	logger *slog.Logger
+	mu     sync.Mutex
+}
+
+type searchIndexMatch struct {
+	Path     string
+	Title    string
+	Content  string
+	Rank     float64
+	Modified int64
+}
+
+type searchIndexState struct {
+	modified int64
+	size     int64
+}
+
+func (s *Server) searchIndexPath() string {
+	return filepath.Join(s.aiDirPath(), searchIndexFileName)
+}
+
+// getSearchIndex opens the index on first use. A failed open is not cached,
+// so a later call retries and reports its own cause.
+func (s *Server) getSearchIndex() (*SearchIndex, error) {
+	s.searchIndexMu.Lock()
+	defer s.searchIndexMu.Unlock()
+	if s.searchIndexStore != nil {
+		return s.searchIndexStore, nil
+	}
+	if err := os.MkdirAll(s.aiDirPath(), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", s.searchIndexPath())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	idx := &SearchIndex{
+		db:     db,
+		logger: s.logger.With("component", "search-index"),
+	}
+	if err := idx.ensureSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.searchIndexStore = idx
+	return idx, nil
+}
+
+func (idx *SearchIndex) ensureSchema() error {
+	const notesTable = `
+CREATE TABLE IF NOT EXISTS search_notes (
+	note_path TEXT PRIMARY KEY,
+	note_modified INTEGER NOT NULL,
+	note_size INTEGER NOT NULL
+);`
+	const ftsTable = `
+CREATE VIRTUAL TABLE IF NOT EXISTS search_fts USING fts5(
+	note_path UNINDEXED,
+	title,
+	content,
+	tokenize = 'unicode61 remove_diacritics 2'
+);`
+	if _, err := idx.db.Exec(notesTable); err != nil {
+		return err
+	}
+	if _, err := idx.db.Exec(ftsTable); err != nil {
+		return err
+	}
+	return nil
+}
+
+// refresh brings the index in line with the vault, re-reading only files
+// whose modification time or size changed since they were indexed.
+func (idx *SearchIndex) refresh(notesDir string, notes map[string]*vaultNote) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	existing, err := idx.fetchIndexedNotes()
+	if err != nil {
+		return err
+	}
+
+	changed := make([]noteInfo, 0)
+	for _, note := range notes {
+		state, ok := existing[note.Path]
+		delete(existing, note.Path)
+		if ok && state.modified == note.Modified.UnixNano() && state.size == note.Size {
+			continue
+		}
+		changed = append(changed, noteInfo{Path: note.Path, Modified: note.Modified, Size: note.Size})
+	}
+	if len(changed) == 0 && len(existing) == 0 {
+		return nil
+	}
+
+	tx, err := idx.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for notePath := range existing {
+		if err := deleteSearchNote(tx, notePath); err != nil {
+			return err
+		}
+	}
+	for _, note := range changed {
+		data, err := os.ReadFile(filepath.Join(notesDir, filepath.FromSlash(note.Path)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				if err := deleteSearchNote(tx, note.Path); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if err := deleteSearchNote(tx, note.Path); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO search_notes (note_path, note_modified, note_size) VALUES (?, ?, ?)",
+			note.Path, note.Modified.UnixNano(), note.Size,
+		); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO search_fts (note_path, title, content) VALUES (?, ?, ?)",
+			note.Path, noteTitle(note.Path), string(data),
+		); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	idx.logger.Debug("search index refreshed", "updated", len(changed), "removed", len(existing))
+	return nil
+}
+
+func (idx *SearchIndex) fetchIndexedNotes() (map[string]searchIndexState, error) {
+	rows, err := idx.db.Query("SELECT note_path, note_modified, note_size FROM search_notes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]searchIndexState)
+	for rows.Next() {
+		var notePath string
+		var state searchIndexState
+		if err := rows.Scan(&notePath, &state.modified, &state.size); err != nil {
+			return nil, err
+		}
+		result[notePath] = state
+	}
+	return result, rows.Err()
+}
+
+func deleteSearchNote(tx *sql.Tx, notePath string) error {
+	if _, err := tx.Exec("DELETE FROM search_fts WHERE note_path = ?", notePath); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM search_notes WHERE note_path = ?", notePath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// query runs an FTS5 match expression and returns notes ordered by BM25,
+// weighting title hits well above body hits. An empty expression returns
+// every indexed note ordered by path. A negative limit means no limit.
+func (idx *SearchIndex) query(match string, limit int) ([]searchIndexMatch, error) {
+	var rows *sql.Rows
+	var err error
+	if match == "" {
+		rows, err = idx.db.Query(
+			`SELECT f.note_path, f.title, f.content, 0.0 AS rank, n.note_modified
+FROM search_fts f
+JOIN search_notes n ON n.note_path = f.note_path
+ORDER BY f.note_path
+LIMIT ?`,
+			limit,
+		)
+	} else {
+		rows, err = idx.db.Query(
+			`SELECT f.note_path, f.title, f.content, bm25(search_fts, 0.0, 10.0, 1.0) AS rank, n.note_modified
+FROM search_fts f
+JOIN search_notes n ON n.note_path = f.note_path
+WHERE search_fts MATCH ?
+ORDER BY rank, f.note_path
+LIMIT ?`,
+			match, limit,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]searchIndexMatch, 0)
+	for rows.Next() {
+		var match searchIndexMatch
+		if err := rows.Scan(&match.Path, &match.Title, &match.Content, &match.Rank, &match.Modified); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	cases := []struct {
+		input string
+		match string
+	}{
+		{input: "alpha", match: `"alpha"*`},
+		{input: "alpha beta", match: `("alpha" AND "beta"*)`},
+		{input: `"exact phrase" next`, match: `("exact phrase" AND "next"*)`},
+		{input: "pre* OR other", match: `("pre"* OR "other"*)`},
+		{input: "-draft report", match: `("report"* NOT "draft")`},
+		{input: "a (b OR c) NOT d", match: `(("a" AND ("b" OR "c")) NOT "d")`},
+		{input: `c++ "say ""hi"""`, match: `("c" AND "say" AND "hi")`},
+	}
+	for _, tc := range cases {
+		parsed, err := parseSearchQuery(tc.input)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.input, err)
+		}
+		if parsed.Match != tc.match {
+			t.Fatalf("parse %q: expected %s, got %s", tc.input, tc.match, parsed.Match)
+		}
+	}
+
+	parsed, err := parseSearchQuery(`tag:work path:Work/ -@bob modified:>2026-09-01 "exact phrase"`)
+	if err != nil {
+		t.Fatalf("parse filters: %v", err)
+	}
+	if parsed.Match != `"exact phrase"` {
+		t.Fatalf("expected filters to be removed from match, got %s", parsed.Match)
+	}
+	expected := []searchFilter{
+		{Field: searchFilterTag, Value: "work"},
+		{Field: searchFilterPath, Value: "work/"},
+		{Field: searchFilterMention, Value: "bob", Negated: true},
+		{Field: searchFilterModified, Op: ">", Value: "2026-09-01"},
+	}
+	if len(parsed.Filters) != len(expected) {
+		t.Fatalf("expected %d filters, got %#v", len(expected), parsed.Filters)
+	}
+	for i, filter := range expected {
+		if parsed.Filters[i] != filter {
+			t.Fatalf("filter %d: expected %#v, got %#v", i, filter, parsed.Filters[i])
+		}
+	}
+
+	parsed, err = parseSearchQuery("report NOT (tag:x)")
+	if err != nil {
+		t.Fatalf("parse negated group: %v", err)
+	}
+	if len(parsed.Filters) != 1 || parsed.Filters[0] != (searchFilter{Field: searchFilterTag, Value: "x", Negated: true}) {
+		t.Fatalf("expected negated tag filter, got %#v", parsed.Filters)
+	}
+
+	parsed, err = parseSearchQuery("tag:a OR tag:b")
+	if err != nil {
+		t.Fatalf("parse filter OR: %v", err)
+	}
+	if parsed.Match != "" || len(parsed.Filters) != 0 || len(parsed.Groups) != 1 || len(parsed.Groups[0].Any) != 2 {
+		t.Fatalf("expected one OR group, got %#v", parsed)
+	}
+	tagged := func(tags ...string) searchNoteFacets {
+		facets := searchNoteFacets{Tags: map[string]bool{}}
+		for _, tag := range tags {
+			facets.Tags[tag] = true
+		}
+		return facets
+	}
+	if !matchSearchFilterExpr(parsed.Groups[0], tagged("b"), "") || matchSearchFilterExpr(parsed.Groups[0], tagged("c"), "") {
+		t.Fatalf("expected tag:a OR tag:b to match either tag only")
+	}
+
+	for _, input := range []string{"-only", "(open", "close)", "+++", "tag:", "modified:>soon", "foo OR tag:b", "-(foo tag:b)"} {
+		if _, err := parseSearchQuery(input); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestFindSearchLine(t *testing.T) {
+	terms := []searchTerm{{Words: []string{"quick", "fox"}}, {Words: []string{"jump"}, Prefix: true}}
+	content := "intro\n  the quick fox jumps high\nfox only"
+	line, snippet, highlights := findSearchLine(content, terms)
+	if line != 2 {
+		t.Fatalf("expected line 2, got %d", line)
+	}
+	if snippet != "the quick fox jumps high" {
+		t.Fatalf("unexpected snippet %q", snippet)
+	}
+	if len(highlights) != 2 || highlights[0] != (SearchHighlight{Start: 4, End: 13}) || highlights[1] != (SearchHighlight{Start: 14, End: 19}) {
+		t.Fatalf("unexpected highlights %#v", highlights)
+	}
+}
+
+func TestSearchIndexRetriesAfterFailedOpen(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{notesDir: dir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	blocker := filepath.Join(dir, aiFolderName)
+	if err := os.WriteFile(blocker, []byte("not a directory"), 0o644); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+	if _, err := s.getSearchIndex(); err == nil {
+		t.Fatalf("expected open to fail while %s is a file", aiFolderName)
+	}
+	if _, err := s.getSearchIndex(); err == nil || err.Error() == "search index unavailable" {
+		t.Fatalf("expected the second call to report its own cause, got %v", err)
+	}
+
+	if err := os.Remove(blocker); err != nil {
+		t.Fatalf("remove blocker: %v", err)
+	}
+	idx, err := s.getSearchIndex()
+	if err != nil {
+		t.Fatalf("expected retry to succeed: %v", err)
+	}
+	defer idx.db.Close()
+	if again, err := s.getSearchIndex(); err != nil || again != idx {
+		t.Fatalf("expected the opened index to be reused, got %v, %v", again, err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,6 +23,19 @@ type Server struct {
 	aiIndexOnce        sync.Once
 	aiIndexStore       *AIIndex
 	aiMu               sync.Mutex
+	searchIndexMu      sync.Mutex
+	searchIndexStore   *SearchIndex
+	renameMu           sync.Mutex
+	writeMu            sync.Mutex
+	journalMu          sync.Mutex
+	taskLogMu          sync.Mutex
+	vault              vaultCache
+	watcherOnce        sync.Once
+	watcherDone        chan struct{}
+	events             eventBus
+	// done is closed when the router context ends, so long-lived requests
+	// such as /events do not hold up a graceful shutdown.
+	done <-chan struct{}
 }
 
 var timeNow = time.Now
@@ -40,6 +52,9 @@ type TemplateContext struct {
 	Title  string
 	Path   string
 	Folder string
+	// Meta holds the front matter values for {{meta:key}} placeholders. It is
+	// filled from the rendered template when left nil.
+	Meta map[string]string
 }
 
 type TreeNode struct {
@@ -50,9 +65,11 @@ type TreeNode struct {
 }
 
 type NoteResponse struct {
-	Path     string    `json:"path"`
-	Content  string    `json:"content"`
-	Modified time.Time `json:"modified"`
+	Path        string    `json:"path"`
+	Content     string    `json:"content"`
+	Modified    time.Time `json:"modified"`
+	ETag        string    `json:"etag"`
+	FrontMatter *NoteMeta `json:"frontMatter,omitempty"`
 }
 
 type NotePayload struct {
@@ -63,18 +80,24 @@ type NotePayload struct {
 type NoteRenamePayload struct {
 	Path    string `json:"path"`
 	NewPath string `json:"newPath"`
+	DryRun  bool   `json:"dryRun,omitempty"`
 }
 
 type FolderPayload struct {
 	Path    string `json:"path"`
 	NewPath string `json:"newPath"`
+	DryRun  bool   `json:"dryRun,omitempty"`
 }
 
 type SearchResult struct {
-	Path string `json:"path"`
-	Name string `json:"name"`
-	Type string `json:"type,omitempty"`
-	ID   string `json:"id,omitempty"`
+	Path       string            `json:"path"`
+	Name       string            `json:"name"`
+	Type       string            `json:"type,omitempty"`
+	ID         string            `json:"id,omitempty"`
+	Line       int               `json:"line,omitempty"`
+	Snippet    string            `json:"snippet,omitempty"`
+	Highlights []SearchHighlight `json:"highlights,omitempty"`
+	Score      float64           `json:"score,omitempty"`
 }
 
 type TagGroup struct {
@@ -200,10 +223,13 @@ func (s *Server) handleGetNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := NoteResponse{
-		Path:     relPath,
-		Content:  string(data),
-		Modified: info.ModTime(),
+		Path:        relPath,
+		Content:     string(data),
+		Modified:    info.ModTime(),
+		ETag:        contentETag(data),
+		FrontMatter: parseNoteMeta(string(data)),
 	}
+	w.Header().Set("ETag", resp.ETag)
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -240,6 +266,9 @@ func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	if _, err := os.Stat(absPath); err == nil {
 		writeError(w, http.StatusConflict, "note already exists")
 		return
@@ -270,6 +299,9 @@ func (s *Server) handleCreateNote(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	content, _ = s.normalizeNoteDueDates(relPath, content)
+	content, _ = s.assignNoteBlockIDs(relPath, content)
+
 	if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to create note")
 		return
@@ -320,7 +352,34 @@ func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := os.WriteFile(absPath, []byte(payload.Content), 0o644); err != nil {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read note")
+		return
+	}
+	currentETag := contentETag(current)
+	if !ifMatchSatisfied(r, currentETag) {
+		writeConflict(w, "note changed on server", currentETag, NoteResponse{
+			Path:        relPath,
+			Content:     string(current),
+			Modified:    info.ModTime(),
+			ETag:        currentETag,
+			FrontMatter: parseNoteMeta(string(current)),
+		})
+		return
+	}
+	content, normalized := s.normalizeNoteDueDates(relPath, payload.Content)
+	content, assigned := s.assignNoteBlockIDs(relPath, content)
+	if string(current) != content {
+		if err := s.snapshotNote(relPath, string(current)); err != nil {
+			s.logger.Warn("note snapshot failed", "path", relPath, "error", err)
+		}
+	}
+
+	if err := os.WriteFile(absPath, []byte(content), 0o644); err != nil {
 		s.logger.Error("unable to update note", "path", relPath, "absPath", absPath, "error", err)
 		writeError(w, http.StatusInternalServerError, "unable to update note")
 		return
@@ -328,8 +387,15 @@ func (s *Server) handleUpdateNote(w http.ResponseWriter, r *http.Request) {
 
 	// Task parsing is done on demand from note contents.
 
-	s.logger.Info("note updated", "path", relPath, "bytes", len(payload.Content))
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath})
+	etag := contentETag([]byte(content))
+	s.logger.Info("note updated", "path", relPath, "bytes", len(content))
+	w.Header().Set("ETag", etag)
+	resp := map[string]string{"path": relPath, "etag": etag}
+	if normalized || assigned {
+		// The client's copy no longer matches the file, so send it back.
+		resp["content"] = content
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
@@ -363,6 +429,15 @@ func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if current, err := os.ReadFile(absPath); err == nil {
+		if err := s.snapshotNote(relPath, string(current)); err != nil {
+			s.logger.Warn("note snapshot failed", "path", relPath, "error", err)
+		}
+	}
+
 	if err := os.Remove(absPath); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to delete note")
 		return
@@ -440,126 +515,22 @@ func (s *Server) handleCreateFolder(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusCreated, map[string]string{"path": relPath})
 }
 
-func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
-	query := strings.TrimSpace(r.URL.Query().Get("query"))
-	if query == "" {
-		writeError(w, http.StatusBadRequest, "query is required")
-		return
-	}
-
-	lowerQuery := strings.ToLower(query)
-	var results []SearchResult
-
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			if isAiDir(d.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if isIgnoredFile(d.Name()) {
-			return nil
-		}
-		if !isMarkdown(d.Name()) {
-			return nil
-		}
-
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
-		}
-		rel = filepath.ToSlash(rel)
-		nameLower := strings.ToLower(d.Name())
-		if strings.Contains(nameLower, lowerQuery) {
-			results = append(results, SearchResult{
-				Path: rel,
-				Name: d.Name(),
-				Type: "note",
-			})
-			return nil
-		}
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		if strings.Contains(strings.ToLower(string(data)), lowerQuery) {
-			results = append(results, SearchResult{
-				Path: rel,
-				Name: d.Name(),
-				Type: "note",
-			})
-		}
-
-		return nil
-	})
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	notes, err := s.vaultNotes()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to search notes")
+		writeError(w, http.StatusInternalServerError, "unable to list tags")
 		return
 	}
 
-	// Task search is intentionally disabled for now.
-
-	writeJSON(w, http.StatusOK, results)
-}
-
-func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
-	tagPattern := regexp.MustCompile(`(^|\s)#([A-Za-z]+)\b`)
 	tagMap := make(map[string]map[string]string)
-
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			if isAiDir(d.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if isIgnoredFile(d.Name()) {
-			return nil
-		}
-		if !isMarkdown(d.Name()) {
-			return nil
-		}
-
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
-		}
-		rel = filepath.ToSlash(rel)
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		cleaned := stripCodeBlocksAndInline(string(data))
-		matches := tagPattern.FindAllStringSubmatch(cleaned, -1)
-		if len(matches) == 0 {
-			return nil
-		}
-
-		baseName := filepath.Base(rel)
-		for _, match := range matches {
-			tag := match[2]
-			if tag == "" {
-				continue
-			}
+	for _, note := range notes {
+		baseName := filepath.Base(note.Path)
+		for _, tag := range note.Tags {
 			if tagMap[tag] == nil {
 				tagMap[tag] = make(map[string]string)
 			}
-			tagMap[tag][rel] = baseName
+			tagMap[tag][note.Path] = baseName
 		}
-
-		return nil
-	})
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to list tags")
-		return
 	}
 
 	tags := make([]string, 0, len(tagMap))
@@ -592,61 +563,21 @@ func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMentions(w http.ResponseWriter, r *http.Request) {
-	mentionMap := make(map[string]map[string]string)
-
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			if isAiDir(d.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if isIgnoredFile(d.Name()) {
-			return nil
-		}
-		if !isMarkdown(d.Name()) {
-			return nil
-		}
-
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
-		}
-		rel = filepath.ToSlash(rel)
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		cleaned := stripCodeBlocksAndInline(string(data))
-		matches := taskMentionPattern.FindAllStringSubmatch(cleaned, -1)
-		if len(matches) == 0 {
-			return nil
-		}
+	notes, err := s.vaultNotes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to list mentions")
+		return
+	}
 
-		baseName := filepath.Base(rel)
-		for _, match := range matches {
-			if len(match) < 3 {
-				continue
-			}
-			mention := strings.ToLower(match[2])
-			if mention == "" {
-				continue
-			}
+	mentionMap := make(map[string]map[string]string)
+	for _, note := range notes {
+		baseName := filepath.Base(note.Path)
+		for _, mention := range note.Mentions {
 			if mentionMap[mention] == nil {
 				mentionMap[mention] = make(map[string]string)
 			}
-			mentionMap[mention][rel] = baseName
+			mentionMap[mention][note.Path] = baseName
 		}
-
-		return nil
-	})
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to list mentions")
-		return
 	}
 
 	mentions := make([]string, 0, len(mentionMap))
@@ -678,6 +609,29 @@ func (s *Server) handleMentions(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, groups)
 }
 
+// extractNoteTags returns the #tags in content, keeping their original case.
+// Callers strip code first so tags inside code blocks are ignored.
+func extractNoteTags(cleaned string) []string {
+	tags := make([]string, 0)
+	for _, match := range taskTagPattern.FindAllStringSubmatch(cleaned, -1) {
+		if match[2] != "" {
+			tags = append(tags, match[2])
+		}
+	}
+	return tags
+}
+
+// extractNoteMentions returns the lowercased @mentions in content.
+func extractNoteMentions(cleaned string) []string {
+	mentions := make([]string, 0)
+	for _, match := range taskMentionPattern.FindAllStringSubmatch(cleaned, -1) {
+		if match[2] != "" {
+			mentions = append(mentions, strings.ToLower(match[2]))
+		}
+	}
+	return mentions
+}
+
 func (s *Server) handleRenameNote(w http.ResponseWriter, r *http.Request) {
 	payload, err := decodeJSON[NoteRenamePayload](r.Body)
 	if err != nil {
@@ -743,18 +697,35 @@ func (s *Server) handleRenameNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.renameMu.Lock()
+	defer s.renameMu.Unlock()
+
+	plan, err := s.planRename(renameMove{from: relPath, to: relNewPath})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to plan rename")
+		return
+	}
+	if payload.DryRun {
+		writeJSON(w, http.StatusOK, plan.response(true))
+		return
+	}
+
 	if err := os.MkdirAll(filepath.Dir(absNewPath), 0o755); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to prepare destination")
 		return
 	}
 
-	if err := os.Rename(absPath, absNewPath); err != nil {
+	if err := s.applyRename(plan); err != nil {
+		if errors.Is(err, errRenameConflict) {
+			writeError(w, http.StatusConflict, "notes changed during rename; try again")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "unable to rename note")
 		return
 	}
 
-	s.logger.Info("note renamed", "path", relPath, "newPath", relNewPath)
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "newPath": relNewPath})
+	s.logger.Info("note renamed", "path", relPath, "newPath", relNewPath, "references", len(plan.changes))
+	writeJSON(w, http.StatusOK, plan.response(false))
 }
 
 func (s *Server) handleRenameFolder(w http.ResponseWriter, r *http.Request) {
@@ -809,18 +780,35 @@ func (s *Server) handleRenameFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.renameMu.Lock()
+	defer s.renameMu.Unlock()
+
+	plan, err := s.planRename(renameMove{from: relPath, to: relNewPath, folder: true})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to plan rename")
+		return
+	}
+	if payload.DryRun {
+		writeJSON(w, http.StatusOK, plan.response(true))
+		return
+	}
+
 	if err := os.MkdirAll(filepath.Dir(absNewPath), 0o755); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to prepare destination")
 		return
 	}
 
-	if err := os.Rename(absPath, absNewPath); err != nil {
+	if err := s.applyRename(plan); err != nil {
+		if errors.Is(err, errRenameConflict) {
+			writeError(w, http.StatusConflict, "notes changed during rename; try again")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "unable to rename folder")
 		return
 	}
 
-	s.logger.Info("folder renamed", "path", relPath, "newPath", relNewPath)
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "newPath": relNewPath})
+	s.logger.Info("folder renamed", "path", relPath, "newPath", relNewPath, "references", len(plan.changes))
+	writeJSON(w, http.StatusOK, plan.response(false))
 }
 
 func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
@@ -850,6 +838,9 @@ func (s *Server) handleDeleteFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	if err := os.RemoveAll(absPath); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to delete folder")
 		return
@@ -932,7 +923,7 @@ func (s *Server) buildTree(absPath, relPath string, showTemplates bool, sortBy,
 	var nodes []treeNodeSortEntry
 	for _, entry := range entries {
 		name := entry.Name()
-		if entry.IsDir() && isAiDir(name) {
+		if entry.IsDir() && isHiddenDir(name) {
 			continue
 		}
 		if relPath == "" && entry.IsDir() && strings.EqualFold(name, sheetsFolderName) {
@@ -1082,6 +1073,9 @@ func dailyNoteExists(rootDir, noteName string) (bool, error) {
 			return err
 		}
 		if d.IsDir() {
+			if isHiddenDir(d.Name()) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if d.Name() != noteName {
@@ -1114,6 +1108,9 @@ func (s *Server) ensureDailyNote() error {
 		return nil
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	today := timeNow().Format(dailyDateLayout)
 	noteName := today + ".md"
 	exists, err := dailyNoteExists(dailyDir, noteName)
@@ -1142,7 +1139,32 @@ func (s *Server) ensureDailyNote() error {
 			s.logger.Warn("template condition warning", "path", relPath, "warning", warning)
 		}
 	}
-	return os.WriteFile(notePath, []byte(finalContent), 0o644)
+
+	var rollover *dailyRollover
+	if settings, _, err := s.loadSettings(); err == nil && settings.RolloverTasks {
+		rollover, err = s.planDailyRollover(dateOnly(timeNow()))
+		if err != nil {
+			s.logger.Warn("task rollover skipped", "error", err)
+		}
+	}
+	if rollover != nil {
+		finalContent = appendRolloverTasks(finalContent, rollover.Lines)
+	}
+	if err := os.WriteFile(notePath, []byte(finalContent), 0o644); err != nil {
+		return err
+	}
+	if rollover == nil {
+		return nil
+	}
+	// The new note is written first so a failure here leaves the tasks in
+	// both notes rather than in neither.
+	sourcePath := filepath.Join(s.notesDir, filepath.FromSlash(rollover.SourcePath))
+	if err := os.WriteFile(sourcePath, []byte(rollover.SourceContent), 0o644); err != nil {
+		s.logger.Error("unable to mark migrated tasks", "path", rollover.SourcePath, "error", err)
+		return nil
+	}
+	s.logger.Info("daily tasks rolled over", "from", rollover.SourcePath, "to", filepath.ToSlash(filepath.Join(dailyFolderName, noteName)), "count", len(rollover.Lines))
+	return nil
 }
 
 func (s *Server) ensureInboxNote() error {
@@ -1150,6 +1172,9 @@ func (s *Server) ensureInboxNote() error {
 	if err != nil {
 		return err
 	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	info, err := os.Stat(absPath)
 	if err == nil {
 		if info.IsDir() {
@@ -1178,7 +1203,22 @@ func (s *Server) folderTemplateContent(dir string) ([]byte, bool, error) {
 	return content, true, nil
 }
 
+// applyTemplatePlaceholders replaces the placeholders in a template. Front
+// matter may itself use placeholders, so {{meta:key}} tokens are resolved in a
+// second pass against the front matter of the first pass's output.
 func applyTemplatePlaceholders(input string, now time.Time, ctx TemplateContext) string {
+	out := replaceTemplateTokens(input, now, ctx)
+	if ctx.Meta != nil || !strings.Contains(out, "{{meta:") {
+		return out
+	}
+	ctx.Meta = parseNoteMeta(out).templateValues()
+	if ctx.Meta == nil {
+		ctx.Meta = map[string]string{}
+	}
+	return replaceTemplateTokens(out, now, ctx)
+}
+
+func replaceTemplateTokens(input string, now time.Time, ctx TemplateContext) string {
 	const tokenPrefix = "{{"
 	const tokenSuffix = "}}"
 	start := strings.Index(input, tokenPrefix)
@@ -1226,6 +1266,12 @@ func resolveTemplateToken(token string, now time.Time, ctx TemplateContext) stri
 	}
 	key := parts[0]
 	format := parts[1]
+	if key == "meta" {
+		if ctx.Meta == nil {
+			return "{{" + token + "}}"
+		}
+		return ctx.Meta[strings.TrimSpace(format)]
+	}
 	layout := layoutFromTemplate(format)
 	switch key {
 	case "date", "time", "datetime", "day", "year", "month":
@@ -1668,7 +1714,8 @@ func isReservedRootFolder(name string) bool {
 		strings.ToLower(sheetsFolderName),
 		strings.ToLower(journalFolderName),
 		strings.ToLower(emailTemplatesDir),
-		strings.ToLower(aiFolderName):
+		strings.ToLower(aiFolderName),
+		strings.ToLower(historyFolderName):
 		return true
 	default:
 		return false
@@ -2,11 +2,18 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -15,7 +22,9 @@ import (
 func setupTestRouter(t *testing.T) (string, http.Handler) {
 	t.Helper()
 	dir := t.TempDir()
-	return dir, NewRouter(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return dir, NewRouterContext(ctx, dir)
 }
 
 func writeFile(t *testing.T, path, content string) {
@@ -29,6 +38,11 @@ func writeFile(t *testing.T, path, content string) {
 }
 
 func doRequest(t *testing.T, router http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return doRequestWithHeaders(t, router, method, path, body, nil)
+}
+
+func doRequestWithHeaders(t *testing.T, router http.Handler, method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
 	t.Helper()
 	var reader *bytes.Reader
 	if body != nil {
@@ -44,6 +58,9 @@ func doRequest(t *testing.T, router http.Handler, method, path string, body any)
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 	rec := httptest.NewRecorder()
 	router.ServeHTTP(rec, req)
 	return rec
@@ -478,10 +495,10 @@ func TestNotesCRUD(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-	var renameResp map[string]string
+	var renameResp RenameResponse
 	decodeJSONBody(t, rec, &renameResp)
-	if renameResp["newPath"] != "renamed.md" {
-		t.Fatalf("expected renamed.md, got %q", renameResp["newPath"])
+	if renameResp.NewPath != "renamed.md" {
+		t.Fatalf("expected renamed.md, got %q", renameResp.NewPath)
 	}
 
 	rec = doRequest(t, router, http.MethodGet, "/notes?path=renamed.md", nil)
@@ -507,7 +524,7 @@ func TestTasksFromNotes(t *testing.T) {
 		"Intro",
 		"- [ ] Call Mom +Home #Family @Alice >2025-01-31 ^2",
 		"  - [x] Done thing +Work >2025-02-01 ^5",
-		"- [ ] Bad due date >tomorrow",
+		"- [ ] Bad due date >someday-maybe",
 	}, "\n")
 	writeFile(t, filepath.Join(dir, "tasks-note.md"), content)
 
@@ -642,278 +659,1305 @@ func TestTasksToggle(t *testing.T) {
 	}
 }
 
-func TestTasksArchiveCompleted(t *testing.T) {
+func TestTasksRelativeDueDates(t *testing.T) {
 	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 1, 7, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
 
-	content := strings.Join([]string{
-		"- [x] Done task",
-		"- [ ] Active task",
-		"  - [✓] Done child",
-	}, "\n")
-	writeFile(t, filepath.Join(dir, "archive.md"), content)
-
-	rec := doRequest(t, router, http.MethodPatch, "/tasks/archive", nil)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
-	}
-
-	data, err := os.ReadFile(filepath.Join(dir, "archive.md"))
-	if err != nil {
-		t.Fatalf("read updated note: %v", err)
-	}
-	lines := strings.Split(string(data), "\n")
-	if !strings.HasPrefix(lines[0], "~ - [x] ") {
-		t.Fatalf("expected completed task to be archived, got %q", lines[0])
-	}
-	if !strings.HasPrefix(lines[2], "  ~ - [✓] ") {
-		t.Fatalf("expected completed task to be archived with indentation, got %q", lines[2])
-	}
-	if lines[1] != "- [ ] Active task" {
-		t.Fatalf("expected active task to remain, got %q", lines[1])
-	}
+	writeFile(t, filepath.Join(dir, "Daily", "2026-01-02.md"), "- [ ] Report >tomorrow\n")
+	writeFile(t, filepath.Join(dir, "Projects", "plan.md"), "- [ ] Draft >fri\n- [ ] Review >+3d\n")
 
-	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
-	}
+	rec := doRequest(t, router, http.MethodGet, "/tasks", nil)
 	var list TaskListResponse
 	decodeJSONBody(t, rec, &list)
-	if len(list.Tasks) != 1 {
-		t.Fatalf("expected 1 remaining task, got %d", len(list.Tasks))
+	if list.Notice != "" {
+		t.Fatalf("expected no due date warnings, got %q", list.Notice)
 	}
-	if list.Tasks[0].Text != "Active task" {
-		t.Fatalf("expected Active task to remain, got %q", list.Tasks[0].Text)
+	due := make(map[string]string)
+	for _, task := range list.Tasks {
+		due[task.Text] = task.DueDateISO
 	}
-}
-
-func TestFoldersCRUD(t *testing.T) {
-	_, router := setupTestRouter(t)
-
-	rec := doRequest(t, router, http.MethodPost, "/folders", map[string]string{
-		"path": "folder-a",
-	})
-	if rec.Code != http.StatusCreated {
-		t.Fatalf("expected status 201, got %d", rec.Code)
+	if due["Report"] != "2026-01-03" || due["Draft"] != "2026-01-09" || due["Review"] != "2026-01-10" {
+		t.Fatalf("unexpected due dates %#v", due)
 	}
 
-	rec = doRequest(t, router, http.MethodPatch, "/folders", map[string]string{
-		"path":    "folder-a",
-		"newPath": "folder-b",
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/due", map[string]any{
+		"path":       "Projects/plan.md",
+		"lineNumber": 1,
+		"lineHash":   hashLine("- [ ] Draft >fri"),
+		"dueDate":    "eom",
 	})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
+	data, err := os.ReadFile(filepath.Join(dir, "Projects", "plan.md"))
+	if err != nil || !strings.HasPrefix(string(data), "- [ ] Draft >2026-01-31\n") {
+		t.Fatalf("unexpected note content %q (%v)", data, err)
+	}
 
-	rec = doRequest(t, router, http.MethodDelete, "/folders?path=folder-b", nil)
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"normalizeDueDates": true})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":    "Projects/plan.md",
+		"content": "- [ ] Draft >tomorrow\n",
+	})
+	var updated map[string]string
+	decodeJSONBody(t, rec, &updated)
+	if updated["content"] != "- [ ] Draft >2026-01-08\n" {
+		t.Fatalf("expected normalized content in response, got %#v", updated)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "Projects", "plan.md"))
+	if err != nil || string(data) != "- [ ] Draft >2026-01-08\n" {
+		t.Fatalf("unexpected note content %q (%v)", data, err)
+	}
 }
 
-func TestSettingsCRUD(t *testing.T) {
+func TestTaskBlockIDs(t *testing.T) {
 	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "inbox.md"), "- [ ] Old task\n")
 
-	rec := doRequest(t, router, http.MethodGet, "/settings", nil)
+	rec := doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"taskBlockIds": true})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-	var settingsResp SettingsResponse
-	decodeJSONBody(t, rec, &settingsResp)
-	if settingsResp.Notice == "" {
-		t.Fatalf("expected notice when creating settings.json")
-	}
-	if settingsResp.Settings.DefaultView != "split" {
-		t.Fatalf("expected defaultView split, got %q", settingsResp.Settings.DefaultView)
-	}
-	if settingsResp.Settings.SidebarWidth != 300 {
-		t.Fatalf("expected sidebarWidth 300, got %d", settingsResp.Settings.SidebarWidth)
-	}
-	if settingsResp.Settings.StartOnToday {
-		t.Fatalf("expected startOnToday false by default")
-	}
-	if !settingsResp.Settings.ShowAiNode {
-		t.Fatalf("expected showAiNode true by default")
+	rec = doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+		"path":    "inbox.md",
+		"content": "- [ ] Call Bob\n- [ ] Pay rent *every:monthly >2026-03-01\n",
+	})
+	var updated map[string]string
+	decodeJSONBody(t, rec, &updated)
+	if !strings.Contains(updated["content"], "Call Bob ^t-") {
+		t.Fatalf("expected block ids in response content, got %#v", updated)
 	}
-	if _, err := os.Stat(filepath.Join(dir, "settings.json")); err != nil {
-		t.Fatalf("expected settings.json to exist")
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks/for-note?path=inbox.md", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 2 || !strings.HasPrefix(list.Tasks[0].ID, "t-") || list.Tasks[0].Text != "Call Bob" {
+		t.Fatalf("unexpected tasks %#v", list.Tasks)
 	}
+	callID := list.Tasks[0].ID
+	rentID := list.Tasks[1].ID
 
-	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{
-		"darkMode":     true,
-		"defaultView":  "preview",
-		"sidebarWidth": 280,
-		"startOnToday": true,
+	// Move the task below other lines in another note; its id still finds it.
+	writeFile(t, filepath.Join(dir, "inbox.md"), "- [ ] Pay rent *every:monthly >2026-03-01 ^"+rentID+"\n")
+	writeFile(t, filepath.Join(dir, "work.md"), "# Work\n\n- [ ] Call Bob, edited ^"+callID+"\n")
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+		"path":       "inbox.md",
+		"lineNumber": 1,
+		"id":         callID,
+		"completed":  true,
 	})
 	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
-	}
-	var updated Settings
-	decodeJSONBody(t, rec, &updated)
-	if !updated.DarkMode {
-		t.Fatalf("expected darkMode true")
-	}
-	if updated.DefaultView != "preview" {
-		t.Fatalf("expected defaultView preview, got %q", updated.DefaultView)
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
 	}
-	if updated.SidebarWidth != 280 {
-		t.Fatalf("expected sidebarWidth 280, got %d", updated.SidebarWidth)
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/due", map[string]any{"id": callID, "dueDate": "2026-04-01"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
 	}
-	if !updated.StartOnToday {
-		t.Fatalf("expected startOnToday true")
+	data, err := os.ReadFile(filepath.Join(dir, "work.md"))
+	if err != nil || string(data) != "# Work\n\n- [x] Call Bob, edited ^"+callID+" >2026-04-01\n" {
+		t.Fatalf("unexpected moved note content %q (%v)", data, err)
 	}
 
-	rec = doRequest(t, router, http.MethodGet, "/settings", nil)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/status", map[string]any{"id": rentID, "status": "done"})
+	var toggled TaskToggleResponse
+	decodeJSONBody(t, rec, &toggled)
+	if toggled.Next == nil || !strings.HasPrefix(toggled.Next.ID, "t-") || toggled.Next.ID == rentID {
+		t.Fatalf("expected next occurrence with a new id, got %#v", toggled.Next)
 	}
-	settingsResp = SettingsResponse{}
-	decodeJSONBody(t, rec, &settingsResp)
-	if !settingsResp.Settings.DarkMode {
-		t.Fatalf("expected darkMode true from settings")
+	if toggled.Next.DueDateISO != "2026-04-01" {
+		t.Fatalf("expected next due date, got %#v", toggled.Next)
 	}
-	if settingsResp.Settings.DefaultView != "preview" {
-		t.Fatalf("expected defaultView preview from settings")
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{"id": "t-ffff", "completed": true})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown id, got %d", rec.Code)
 	}
-	if settingsResp.Settings.SidebarWidth != 280 {
-		t.Fatalf("expected sidebarWidth 280 from settings")
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{"id": "inbox.md:1", "completed": true})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for malformed id, got %d", rec.Code)
 	}
-	if !settingsResp.Settings.StartOnToday {
-		t.Fatalf("expected startOnToday true from settings")
+
+	writeFile(t, filepath.Join(dir, "later.md"), "- [ ] One\n- [ ] Two\n")
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/ids", nil)
+	var assigned TaskAssignIDsResponse
+	decodeJSONBody(t, rec, &assigned)
+	if assigned.Assigned != 2 || assigned.Files != 1 {
+		t.Fatalf("unexpected backfill result %#v", assigned)
 	}
 }
 
-func TestReservedRootNames(t *testing.T) {
-	_, router := setupTestRouter(t)
+func TestTasksSubtasks(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	content := strings.Join([]string{
+		"- [ ] Release *every:monthly >2026-03-01",
+		"  - [x] Tag build",
+		"  - [ ] Publish notes",
+		"    - [-] Tweet",
+		"- [ ] Unrelated",
+	}, "\n")
+	writeFile(t, filepath.Join(dir, "release.md"), content)
 
-	rec := doRequest(t, router, http.MethodPost, "/folders", map[string]string{
-		"path": "email",
-	})
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", rec.Code)
+	rec := doRequest(t, router, http.MethodGet, "/tasks/for-note?path=release.md&tree=true", nil)
+	var tree TaskListResponse
+	decodeJSONBody(t, rec, &tree)
+	if len(tree.Tasks) != 2 || len(tree.Tasks[0].Subtasks) != 2 || len(tree.Tasks[0].Subtasks[1].Subtasks) != 1 {
+		t.Fatalf("unexpected task tree %#v", tree.Tasks)
+	}
+	if progress := tree.Tasks[0].Progress; progress == nil || *progress != 50 {
+		t.Fatalf("expected 50%% progress, got %#v", progress)
 	}
 
-	rec = doRequest(t, router, http.MethodPost, "/folders", map[string]string{
-		"path": ".ai",
-	})
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", rec.Code)
+	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var flat TaskListResponse
+	decodeJSONBody(t, rec, &flat)
+	if len(flat.Tasks) != 5 || flat.Tasks[2].ParentID != "release.md:1" || flat.Tasks[0].Subtasks != nil {
+		t.Fatalf("unexpected flat tasks %#v", flat.Tasks)
 	}
 
-	rec = doRequest(t, router, http.MethodPost, "/notes", map[string]string{
-		"path":    "Inbox",
-		"content": "Hi",
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"completeSubtasks": true})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+		"path":       "release.md",
+		"lineNumber": 1,
+		"lineHash":   flat.Tasks[0].LineHash,
+		"completed":  true,
 	})
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status 400, got %d", rec.Code)
+	var toggled TaskToggleResponse
+	decodeJSONBody(t, rec, &toggled)
+	if toggled.Next == nil || toggled.Next.LineNumber != 5 {
+		t.Fatalf("expected next occurrence after the subtasks, got %#v", toggled.Next)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "release.md"))
+	if err != nil {
+		t.Fatalf("read updated note: %v", err)
+	}
+	expected := strings.Join([]string{
+		"- [x] Release *every:monthly >2026-03-01",
+		"  - [x] Tag build",
+		"  - [x] Publish notes",
+		"    - [-] Tweet",
+		"- [ ] Release *every:monthly >2026-04-01",
+		"- [ ] Unrelated",
+	}, "\n")
+	if string(data) != expected {
+		t.Fatalf("unexpected note content %q", data)
 	}
 }
 
-func TestEmailSettingsCRUD(t *testing.T) {
+func TestTasksCompletionLog(t *testing.T) {
 	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
 
-	rec := doRequest(t, router, http.MethodGet, "/email/settings", nil)
+	writeFile(t, filepath.Join(dir, "work.md"), "- [ ] Ship release\n- [ ] Write notes\n")
+	rec := doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"completionDates": true})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-	var resp EmailSettingsResponse
-	decodeJSONBody(t, rec, &resp)
-	if resp.Notice == "" {
-		t.Fatalf("expected notice when creating email-settings.json")
-	}
-	if resp.Settings.SMTP.Host != "smtp.gmail.com" {
-		t.Fatalf("expected default smtp host, got %q", resp.Settings.SMTP.Host)
-	}
-	if resp.Settings.SMTP.Port != 587 {
-		t.Fatalf("expected default smtp port 587, got %d", resp.Settings.SMTP.Port)
-	}
-	if resp.Settings.Digest.Time != "08:00" {
-		t.Fatalf("expected default digest time 08:00, got %q", resp.Settings.Digest.Time)
+
+	toggle := func(line int, completed bool) {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/tasks/for-note?path=work.md", nil)
+		var list TaskListResponse
+		decodeJSONBody(t, rec, &list)
+		rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", map[string]any{
+			"path":       "work.md",
+			"lineNumber": line,
+			"lineHash":   list.Tasks[line-1].LineHash,
+			"completed":  completed,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
 	}
-	if _, err := os.Stat(filepath.Join(dir, "email-settings.json")); err != nil {
-		t.Fatalf("expected email-settings.json to exist")
+	toggle(1, true)
+	toggle(2, true)
+	data, err := os.ReadFile(filepath.Join(dir, "work.md"))
+	if err != nil || string(data) != "- [x] Ship release ✓2026-10-17\n- [x] Write notes ✓2026-10-17\n" {
+		t.Fatalf("unexpected note content %q (%v)", data, err)
 	}
-	if _, err := os.Stat(filepath.Join(dir, "email", "digest.template")); err != nil {
-		t.Fatalf("expected email digest template to exist")
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks/for-note?path=work.md", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if list.Tasks[0].CompletedDate != "2026-10-17" || list.Tasks[0].Text != "Ship release" {
+		t.Fatalf("unexpected task %#v", list.Tasks[0])
 	}
 
-	rec = doRequest(t, router, http.MethodPatch, "/email/settings", map[string]any{
-		"enabled": true,
-		"smtp": map[string]any{
-			"host":     "smtp.gmail.com",
-			"port":     587,
-			"username": "test@example.com",
-			"password": "secret",
-			"from":     "test@example.com",
-			"to":       "test@example.com",
-			"useTLS":   true,
-		},
-		"digest": map[string]any{
-			"enabled": true,
-			"time":    "07:45",
-		},
-		"due": map[string]any{
-			"enabled":        true,
-			"time":           "07:30",
-			"windowDays":     0,
-			"includeOverdue": true,
-		},
-	})
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
+	toggle(2, false)
+	data, err = os.ReadFile(filepath.Join(dir, "work.md"))
+	if err != nil || string(data) != "- [x] Ship release ✓2026-10-17\n- [ ] Write notes\n" {
+		t.Fatalf("unexpected note content %q (%v)", data, err)
 	}
-	var updated EmailSettings
-	decodeJSONBody(t, rec, &updated)
-	if !updated.Enabled {
-		t.Fatalf("expected email enabled true")
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks/log?from=today&to=2026-10-17", nil)
+	var log TaskLogResponse
+	decodeJSONBody(t, rec, &log)
+	events := make([]string, 0, len(log.Entries))
+	for _, entry := range log.Entries {
+		events = append(events, entry.Event+" "+entry.Text)
 	}
-	if updated.Digest.Time != "07:45" {
-		t.Fatalf("expected digest time 07:45, got %q", updated.Digest.Time)
+	if strings.Join(events, ",") != "done Ship release,done Write notes,reopened Write notes" {
+		t.Fatalf("unexpected log %v", events)
 	}
-
-	rec = doRequest(t, router, http.MethodGet, "/email/settings", nil)
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected status 200, got %d", rec.Code)
+	rec = doRequest(t, router, http.MethodGet, "/tasks/log?from=tomorrow", nil)
+	decodeJSONBody(t, rec, &log)
+	if len(log.Entries) != 0 {
+		t.Fatalf("expected no entries from tomorrow, got %#v", log.Entries)
 	}
-	resp = EmailSettingsResponse{}
-	decodeJSONBody(t, rec, &resp)
-	if !resp.Settings.Enabled {
-		t.Fatalf("expected enabled true from email settings")
+	rec = doRequest(t, router, http.MethodGet, "/tasks/log?from=someday", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid from, got %d", rec.Code)
 	}
-	if resp.Settings.Digest.Time != "07:45" {
-		t.Fatalf("expected digest time 07:45 from email settings")
+
+	// The next day's digest lists the logged completion even though it is not
+	// in a daily note.
+	timeNow = func() time.Time { return time.Date(2026, 10, 18, 7, 0, 0, 0, time.Local) }
+	server := &Server{notesDir: dir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	_, completed := server.buildYesterdaySummary()
+	if completed != "- Ship release · work.md" {
+		t.Fatalf("unexpected completed yesterday %q", completed)
 	}
 }
 
-func TestSearchEndpoint(t *testing.T) {
+func TestTreeRollsOverDailyTasks(t *testing.T) {
 	dir, router := setupTestRouter(t)
-	writeFile(t, filepath.Join(dir, "alpha.md"), "hello world")
-	writeFile(t, filepath.Join(dir, "beta.md"), "queryterm")
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
 
-	rec := doRequest(t, router, http.MethodGet, "/search?query=alpha", nil)
+	writeFile(t, filepath.Join(dir, "Daily", "2026-10-15.md"), "- [ ] Stale\n")
+	writeFile(t, filepath.Join(dir, "Daily", "2026-10-16.md"), "- [ ] Call Bob >2026-10-20 +work\n- [x] Done already\n")
+
+	rec := doRequest(t, router, http.MethodGet, "/tree", nil)
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-	var matches []SearchResult
-	decodeJSONBody(t, rec, &matches)
-	if len(matches) != 1 || matches[0].Path != "alpha.md" || matches[0].Type != "note" {
-		t.Fatalf("expected alpha.md match, got %#v", matches)
+	if err := os.Remove(filepath.Join(dir, "Daily", "2026-10-17.md")); err != nil {
+		t.Fatalf("remove daily note: %v", err)
 	}
 
-	rec = doRequest(t, router, http.MethodGet, "/search?query=queryterm", nil)
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{"rolloverTasks": true})
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-	matches = nil
-	decodeJSONBody(t, rec, &matches)
-	if len(matches) != 1 || matches[0].Path != "beta.md" || matches[0].Type != "note" {
-		t.Fatalf("expected beta.md match, got %#v", matches)
+	rec = doRequest(t, router, http.MethodGet, "/tree", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
 	}
-}
-
-func TestTagsEndpoint(t *testing.T) {
-	dir, router := setupTestRouter(t)
-	writeFile(t, filepath.Join(dir, "alpha.md"), "Hello #TagOne\n##NoTag\nword#NoTag\n#TagTwo and #tagtwo")
-	writeFile(t, filepath.Join(dir, "sub", "beta.md"), "Another #TagTwo")
+
+	today, err := os.ReadFile(filepath.Join(dir, "Daily", "2026-10-17.md"))
+	if err != nil {
+		t.Fatalf("read daily note: %v", err)
+	}
+	if string(today) != "- [ ] Call Bob >2026-10-20 +work\n" {
+		t.Fatalf("unexpected daily note %q", string(today))
+	}
+	yesterday, err := os.ReadFile(filepath.Join(dir, "Daily", "2026-10-16.md"))
+	if err != nil {
+		t.Fatalf("read previous daily note: %v", err)
+	}
+	if string(yesterday) != "- [<] Call Bob >2026-10-20 +work\n- [x] Done already\n" {
+		t.Fatalf("unexpected previous daily note %q", string(yesterday))
+	}
+
+	// The migrated copy is closed, so the task is open once, in today's note.
+	var list TaskListResponse
+	decodeJSONBody(t, doRequest(t, router, http.MethodGet, "/tasks", nil), &list)
+	open := make([]string, 0)
+	for _, task := range list.Tasks {
+		if task.Path == "Daily/2026-10-16.md" && task.LineNumber == 1 && task.Status != taskStatusMigrated {
+			t.Fatalf("expected the source task to be migrated, got %q", task.Status)
+		}
+		if !isClosedTaskStatus(task.Status) {
+			open = append(open, task.Path+": "+task.Text)
+		}
+	}
+	if strings.Join(open, ",") != "Daily/2026-10-15.md: Stale,Daily/2026-10-17.md: Call Bob" {
+		t.Fatalf("unexpected open tasks after rollover %v", open)
+	}
+	server := &Server{notesDir: dir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	tokens, err := server.buildEmailTokens(EmailSettings{})
+	if err != nil {
+		t.Fatalf("build email tokens: %v", err)
+	}
+	if count := strings.Count(tokens["tasks_by_project"], "Call Bob"); count != 1 {
+		t.Fatalf("expected one open Call Bob in the digest, got %d in %q", count, tokens["tasks_by_project"])
+	}
+	older, err := os.ReadFile(filepath.Join(dir, "Daily", "2026-10-15.md"))
+	if err != nil {
+		t.Fatalf("read older daily note: %v", err)
+	}
+	if string(older) != "- [ ] Stale\n" {
+		t.Fatalf("expected older daily note to be untouched, got %q", string(older))
+	}
+}
+
+func TestTasksICSFeed(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	writeFile(t, filepath.Join(dir, "work.md"), "- [ ] Ship release >2026-10-20 +Work ^1\n- [x] Write notes >2026-10-18 ✓2026-10-17\n- [ ] Someday\n")
+	writeFile(t, filepath.Join(dir, "Daily", "2026-10-16.md"), "Notes\n")
+	writeFile(t, filepath.Join(dir, taskFiltersFileName), `{"version":1,"filters":[{"id":"work","name":"Work","projects":["work"]}]}`)
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks.ics", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/calendar") {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"SUMMARY:Ship release\r\n",
+		"DUE;VALUE=DATE:20261020\r\n",
+		"PRIORITY:1\r\n",
+		"CATEGORIES:work\r\n",
+		"URL:http://example.com/?note=work.md\r\n",
+		"STATUS:COMPLETED\r\n",
+		"SUMMARY:Daily note 2026-10-16\r\n",
+		"DTSTART;VALUE=DATE:20261016\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected feed to contain %q, got %q", want, body)
+		}
+	}
+	if strings.Contains(body, "Someday") {
+		t.Fatalf("expected undated task to be left out")
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks.ics?filter=work&as=event", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	body = rec.Body.String()
+	if !strings.Contains(body, "BEGIN:VEVENT\r\nUID:work.md:1@scoli") || strings.Contains(body, "Write notes") || strings.Contains(body, "Daily note") {
+		t.Fatalf("unexpected filtered feed %q", body)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks.ics?filter=missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestCalDAVTasks(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	writeFile(t, filepath.Join(dir, "work.md"), "- [ ] Ship release >2026-10-20 +work ^t-abc1\n- [ ] Loose end\n")
+	writeFile(t, filepath.Join(dir, "Inbox.md"), "# Inbox\n")
+
+	dav := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := dav(http.MethodOptions, "/caldav/", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("DAV"), "calendar-access") {
+		t.Fatalf("unexpected options response %d %q", rec.Code, rec.Header().Get("DAV"))
+	}
+
+	rec = dav("PROPFIND", "/caldav/", "", map[string]string{"Depth": "1"})
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("expected status 207, got %d", rec.Code)
+	}
+	for _, want := range []string{"<D:href>/caldav/inbox/</D:href>", "<D:href>/caldav/work/</D:href>", `<C:comp name="VTODO"></C:comp>`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("expected %q in %s", want, rec.Body.String())
+		}
+	}
+
+	rec = dav("PROPFIND", "/caldav/work/", "", map[string]string{"Depth": "1"})
+	if !strings.Contains(rec.Body.String(), "<D:href>/caldav/work/t-abc1.ics</D:href>") {
+		t.Fatalf("expected task resource in %s", rec.Body.String())
+	}
+
+	rec = dav("REPORT", "/caldav/work/", `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop><D:href>/caldav/work/t-abc1.ics</D:href></C:calendar-multiget>`, nil)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "SUMMARY:Ship release") {
+		t.Fatalf("unexpected multiget response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = dav("REPORT", "/caldav/inbox/", `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><C:calendar-data/></D:prop></C:calendar-query>`, nil)
+	undated := rec.Body.String()
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(undated, "BEGIN:VTODO") || !strings.Contains(undated, "SUMMARY:Loose end") || !strings.Contains(undated, "UID:work.md:2@scoli") {
+		t.Fatalf("unexpected undated task %d %s", rec.Code, undated)
+	}
+	if strings.Contains(undated, "DUE") || strings.Contains(undated, "DTSTART") {
+		t.Fatalf("expected no dates on an undated task, got %s", undated)
+	}
+
+	rec = dav(http.MethodGet, "/caldav/work/t-abc1.ics", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "DUE;VALUE=DATE:20261020") {
+		t.Fatalf("unexpected task %d %s", rec.Code, rec.Body.String())
+	}
+	etag := rec.Header().Get("ETag")
+
+	rec = dav(http.MethodPut, "/caldav/work/t-abc1.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:t-abc1@scoli\r\nSUMMARY:Ship release\r\nDUE;VALUE=DATE:20261022\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "work.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "- [x] Ship release") || !strings.Contains(string(data), ">2026-10-22") {
+		t.Fatalf("expected task to be completed and rescheduled, got %q", string(data))
+	}
+
+	rec = dav(http.MethodPut, "/caldav/work/t-abc1.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSTATUS:NEEDS-ACTION\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected stale etag to fail, got %d", rec.Code)
+	}
+
+	rec = dav(http.MethodGet, "/caldav/work/t-abc1.ics", "", nil)
+	rec = dav(http.MethodPut, "/caldav/work/t-abc1.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:t-abc1@scoli\r\nSUMMARY:Ship release\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", map[string]string{"If-Match": rec.Header().Get("ETag")})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "work.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "- [x] Ship release +work") || strings.Contains(strings.SplitN(string(data), "\n", 2)[0], ">") {
+		t.Fatalf("expected a removed DUE to clear the due date, got %q", string(data))
+	}
+
+	rec = dav(http.MethodPut, "/caldav/Home%20Chores/new-task.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:Sweep\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a calendar name that is not a project to be rejected, got %d", rec.Code)
+	}
+
+	rec = dav(http.MethodPut, "/caldav/work/3F2504E0-4F89.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:3F2504E0-4F89\r\nSUMMARY:Order\\, label boxes\r\nDUE:20261019T090000\r\nPRIORITY:1\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", map[string]string{"If-None-Match": "*"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	inbox, err := os.ReadFile(filepath.Join(dir, "Inbox.md"))
+	if err != nil {
+		t.Fatalf("read inbox: %v", err)
+	}
+	if !strings.HasPrefix(string(inbox), "# Inbox\n- [ ] Order, label boxes +work >2026-10-19 ^1 ^t-") {
+		t.Fatalf("unexpected inbox %q", string(inbox))
+	}
+	if location := rec.Header().Get("Location"); location != "/caldav/work/3F2504E0-4F89.ics" {
+		t.Fatalf("unexpected location %q", location)
+	}
+
+	rec = dav(http.MethodGet, "/caldav/work/3F2504E0-4F89.ics", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "UID:3F2504E0-4F89\r\n") {
+		t.Fatalf("expected the client's name and uid to be kept, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = dav(http.MethodPut, "/caldav/work/3F2504E0-4F89.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:3F2504E0-4F89\r\nSUMMARY:Order\\, label boxes\r\nDUE:20261019T090000\r\nSTATUS:COMPLETED\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", map[string]string{"If-Match": rec.Header().Get("ETag")})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	inbox, err = os.ReadFile(filepath.Join(dir, "Inbox.md"))
+	if err != nil {
+		t.Fatalf("read inbox: %v", err)
+	}
+	if strings.Count(string(inbox), "label boxes") != 1 || !strings.Contains(string(inbox), "- [x] Order, label boxes") {
+		t.Fatalf("expected the created task to be updated in place, got %q", string(inbox))
+	}
+
+	rec = dav(http.MethodPut, "/caldav/work/pick%20up%3F.ics", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:Pick up parcel\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", nil)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/caldav/work/pick%20up%3F.ics" {
+		t.Fatalf("unexpected create response %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = dav("PROPFIND", "/caldav/work/", "", map[string]string{"Depth": "1"})
+	if !strings.Contains(rec.Body.String(), "<D:href>/caldav/work/pick%20up%3F.ics</D:href>") {
+		t.Fatalf("expected an escaped resource href in %s", rec.Body.String())
+	}
+
+	rec = dav(http.MethodDelete, "/caldav/work/t-abc1.ics", "", nil)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rec.Code)
+	}
+}
+
+func TestTasksFilterQuery(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	originalNow := timeNow
+	timeNow = func() time.Time { return time.Date(2026, 10, 17, 9, 0, 0, 0, time.Local) }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	writeFile(t, filepath.Join(dir, "work.md"), "- [ ] Deploy >2026-10-20 +work ^2\n- [ ] Review >2026-10-18 +work #ops\n- [x] Shipped +work\n")
+	writeFile(t, filepath.Join(dir, "home.md"), "- [ ] Groceries >today +home\n- [ ] Taxes +home #ops\n")
+	writeFile(t, filepath.Join(dir, taskFiltersFileName), `{"version":1,"filters":[{
+		"id":"open-ops","name":"Open or ops",
+		"completed":false,
+		"any":[{"projects":["work"]},{"tags":["ops"]}],
+		"not":[{"text":"review"}],
+		"sort":["-due"],
+		"groupBy":"project"
+	}]}`)
+
+	texts := func(tasks []TaskItem) string {
+		out := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			out = append(out, task.Text)
+		}
+		return strings.Join(out, ",")
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks?filter=open-ops", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp TaskListResponse
+	decodeJSONBody(t, rec, &resp)
+	if got := texts(resp.Tasks); got != "Deploy,Taxes" {
+		t.Fatalf("unexpected filtered tasks %q", got)
+	}
+	if len(resp.Groups) != 2 || resp.Groups[0].Key != "home" || resp.Groups[1].Key != "work" {
+		t.Fatalf("unexpected groups %#v", resp.Groups)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks?filter=open-ops&project=work&groupBy=due", nil)
+	resp = TaskListResponse{}
+	decodeJSONBody(t, rec, &resp)
+	if got := texts(resp.Tasks); got != "Deploy" || len(resp.Groups) != 1 || resp.Groups[0].Key != taskDueThisWeek {
+		t.Fatalf("unexpected narrowed result %q %#v", got, resp.Groups)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks?status=open&dueTo=%2B1d&sort=due", nil)
+	resp = TaskListResponse{}
+	decodeJSONBody(t, rec, &resp)
+	if got := texts(resp.Tasks); got != "Groceries,Review" || resp.Groups != nil {
+		t.Fatalf("unexpected ad-hoc result %q", got)
+	}
+
+	for _, query := range []string{"filter=missing", "sort=size", "groupBy=mention", "priorityMin=high", "dueFrom=someday-soon"} {
+		rec = doRequest(t, router, http.MethodGet, "/tasks?"+query, nil)
+		if rec.Code == http.StatusOK {
+			t.Fatalf("expected %s to fail", query)
+		}
+	}
+}
+
+func TestSheetsFormulaValues(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/sheets", map[string]any{
+		"path": "budget",
+		"data": [][]string{{"Item", "Cost"}, {"Rent", "1200"}, {"Power", "80"}, {"Total", "=SUM(B2:B3)"}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=budget.jsh", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var sheet SheetResponse
+	decodeJSONBody(t, rec, &sheet)
+	if sheet.Data[3][1] != "=SUM(B2:B3)" || sheet.Values[3][1] != "1280" || sheet.Values[1][1] != "1200" {
+		t.Fatalf("unexpected sheet %#v", sheet)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=budget.jsh", nil)
+	if !strings.Contains(rec.Body.String(), "Total,=SUM(B2:B3)") {
+		t.Fatalf("expected formulas in export, got %q", rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=budget.jsh&values=true", nil)
+	if !strings.Contains(rec.Body.String(), "Total,1280") {
+		t.Fatalf("expected values in export, got %q", rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=budget.jsh&values=maybe", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestSheetsColumnSettings(t *testing.T) {
+	dir, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/sheets/import", map[string]string{
+		"path": "bills",
+		"csv":  "Item,Cost,Due\nRent,1200,2026-11-01\nPower,80,2026-10-20\n",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=bills.jsh", nil)
+	var sheet SheetResponse
+	decodeJSONBody(t, rec, &sheet)
+	if !sheet.Settings.FrozenHeader || len(sheet.Settings.Columns) != 3 ||
+		sheet.Settings.Columns[1].Type != sheetColumnNumber || sheet.Settings.Columns[2].Type != sheetColumnDate {
+		t.Fatalf("unexpected settings %#v", sheet.Settings)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/sheets", map[string]any{
+		"path": "bills.jsh",
+		"data": [][]string{{"Item", "Cost", "Due"}, {"Rent", "a lot", "2026-11-01"}},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "cell B2 is not a valid number") {
+		t.Fatalf("expected type error, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/sheets", map[string]any{
+		"path": "bills.jsh",
+		"data": [][]string{{"Item", "Cost", "Due"}, {"Rent", "1250", "2026-11-01"}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, sheetsFolderName, "bills.jsh"))
+	if err != nil {
+		t.Fatalf("read sheet: %v", err)
+	}
+	var stored sheetFile
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		t.Fatalf("decode sheet: %v", err)
+	}
+	if stored.Version != sheetFileVersion || len(stored.Columns) != 3 || stored.Data[1][1] != "1250" {
+		t.Fatalf("expected settings to be kept, got %s", raw)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/sheets", map[string]any{
+		"path": "bills.jsh",
+		"data": [][]string{{"Item", "Cost", "Due"}, {"Rent", "a lot", "2026-11-01"}},
+		"settings": map[string]any{
+			"columns": []map[string]any{{"name": "Item", "width": 180}},
+			"sort":    map[string]any{"column": 0, "descending": true},
+		},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=bills.jsh", nil)
+	decodeJSONBody(t, rec, &sheet)
+	if sheet.Settings.FrozenHeader || sheet.Settings.Columns[0].Width != 180 ||
+		sheet.Settings.Sort == nil || !sheet.Settings.Sort.Descending {
+		t.Fatalf("unexpected settings %#v", sheet.Settings)
+	}
+
+	writeFile(t, filepath.Join(dir, sheetsFolderName, "legacy.jsh"), `{"data":[["a","b"]]}`)
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=legacy.jsh", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	decodeJSONBody(t, rec, &sheet)
+	if len(sheet.Settings.Columns) != 0 || sheet.Data[0][1] != "b" {
+		t.Fatalf("unexpected legacy sheet %#v", sheet)
+	}
+	writeFile(t, filepath.Join(dir, sheetsFolderName, "future.jsh"), `{"version":99,"data":[]}`)
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=future.jsh", nil)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestSheetsWorkbookImportExport(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	var workbook bytes.Buffer
+	exports := []workbookExport{
+		{Name: "Notes", Sheet: sheetFile{Data: [][]string{{"hello"}}}},
+		{Name: "Bills", Sheet: sheetFile{Data: [][]string{{"Item", "Cost"}, {"Rent", "1200"}, {"Total", "=SUM(B2:B2)"}}}},
+	}
+	if err := writeXLSX(&workbook, exports, false, time.Now()); err != nil {
+		t.Fatalf("write xlsx: %v", err)
+	}
+
+	rec := doRequest(t, router, http.MethodPost, "/sheets/import/worksheets", map[string]any{"file": workbook.Bytes()})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var listed SheetWorksheetsResponse
+	decodeJSONBody(t, rec, &listed)
+	if len(listed.Worksheets) != 2 || listed.Worksheets[1] != (SheetWorksheet{Name: "Bills", Rows: 3, Columns: 2}) {
+		t.Fatalf("unexpected worksheets %#v", listed)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets/import", map[string]any{"path": "bills", "file": workbook.Bytes(), "worksheet": "Missing"})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "available: Notes, Bills") {
+		t.Fatalf("expected worksheet error, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodPost, "/sheets/import", map[string]any{"path": "bills", "file": workbook.Bytes(), "worksheet": "bills"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=bills.jsh", nil)
+	var sheet SheetResponse
+	decodeJSONBody(t, rec, &sheet)
+	if sheet.Data[2][1] != "=SUM(B2:B2)" || sheet.Values[2][1] != "1200" || sheet.Settings.Columns[1].Type != sheetColumnNumber {
+		t.Fatalf("unexpected sheet %#v", sheet)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets/import", map[string]any{"path": "other", "file": []byte("not a workbook")})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	oversized := bytes.Repeat([]byte{'a'}, sheetMaxBody)
+	for _, path := range []string{"/sheets/import", "/sheets", "/sheets/operations"} {
+		rec = doRequest(t, router, http.MethodPost, path, map[string]any{"path": "huge", "csv": string(oversized)})
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected status 413, got %d", path, rec.Code)
+		}
+	}
+	rec = doRequest(t, router, http.MethodPost, "/sheets", map[string]any{"path": "notes", "data": [][]string{{"a"}}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=bills.jsh&path=notes.jsh&format=xlsx", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, "Sheets.xlsx") {
+		t.Fatalf("unexpected disposition %q", got)
+	}
+	sheets, err := readWorkbook(rec.Body.Bytes())
+	if err != nil || len(sheets) != 2 || sheets[0].Name != "bills" || sheets[1].Name != "notes" {
+		t.Fatalf("unexpected export %#v (%v)", sheets, err)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=bills.jsh&path=notes.jsh", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/export?path=bills.jsh&format=ods", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestSheetsOperations(t *testing.T) {
+	dir, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/sheets/import", map[string]string{
+		"path": "log",
+		"csv":  "Day,Steps\n2026-10-16,8000\n",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path": "log",
+		"operations": []map[string]any{
+			{"op": "append-rows", "rows": [][]string{{"2026-10-17", "9500"}}},
+			{"op": "set-cell", "row": 1, "column": 1, "value": "8200"},
+		},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result SheetOperationsResponse
+	decodeJSONBody(t, rec, &result)
+	if result.Path != "log.jsh" || result.Rows != 3 || result.Columns != 2 || result.ETag == "" {
+		t.Fatalf("unexpected response %#v", result)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path": "log.jsh",
+		"operations": []map[string]any{
+			{"op": "delete-row", "row": 1},
+			{"op": "append-rows", "rows": [][]string{{"yesterday", "100"}}},
+		},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "cell A3 is not a valid date") {
+		t.Fatalf("expected type error, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path":       "log.jsh",
+		"operations": []map[string]any{{"op": "delete-row", "row": 1}, {"op": "delete-row", "row": 5}},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "operation 2 (delete-row): row 5 is out of range") {
+		t.Fatalf("expected range error, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path=log.jsh", nil)
+	var sheet SheetResponse
+	decodeJSONBody(t, rec, &sheet)
+	want := [][]string{{"Day", "Steps"}, {"2026-10-16", "8200"}, {"2026-10-17", "9500"}}
+	if !reflect.DeepEqual(sheet.Data, want) || sheet.ETag != result.ETag {
+		t.Fatalf("expected failed operations to leave the sheet alone, got %q", sheet.Data)
+	}
+
+	rec = doRequestWithHeaders(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path":       "log.jsh",
+		"operations": []map[string]any{{"op": "insert-row", "row": 1}},
+	}, map[string]string{"If-Match": `"stale"`})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	rec = doRequestWithHeaders(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path":       "log.jsh",
+		"operations": []map[string]any{{"op": "move-row", "row": 2, "to": 1}},
+	}, map[string]string{"If-Match": sheet.ETag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets/operations", map[string]any{"path": "log.jsh"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodPost, "/sheets/operations", map[string]any{
+		"path":       "missing.jsh",
+		"operations": []map[string]any{{"op": "insert-row", "row": 0}},
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, sheetsFolderName, "missing.jsh")); !os.IsNotExist(err) {
+		t.Fatalf("expected no sheet to be created")
+	}
+}
+
+func TestSheetsQuery(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/sheets/import", map[string]string{
+		"path": "invoices",
+		"csv":  "Client,Amount,Status\nAcme,1200,open\nGlobex,80,paid\nInitech,900,open\n",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	query := url.Values{"path": {"invoices"}, "where": {"Status = open", "Amount > 500"}, "sort": {"-Amount"}}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/query?"+query.Encode(), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result SheetQueryResponse
+	decodeJSONBody(t, rec, &result)
+	want := [][]any{{"Acme", 1200.0, "open"}, {"Initech", 900.0, "open"}}
+	if result.Path != "invoices.jsh" || result.Total != 2 || !reflect.DeepEqual(result.Rows, want) {
+		t.Fatalf("unexpected result %#v", result)
+	}
+
+	query = url.Values{"path": {"invoices.jsh"}, "groupBy": {"Status"}, "aggregate": {"sum(Amount)"}}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/query?"+query.Encode(), nil)
+	var grouped SheetQueryResponse
+	decodeJSONBody(t, rec, &grouped)
+	if !reflect.DeepEqual(grouped.Rows, [][]any{{"open", 2100.0}, {"paid", 80.0}}) || grouped.SourceRows != nil {
+		t.Fatalf("unexpected result %#v", grouped)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/sheets/query?path=invoices&where=Owner%3Dme", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/sheets/query?path=missing", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestTasksToggleRecurring(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "chores.md"), "- [ ] Take out bins *every:weekly >2026-01-06\n- [ ] Other\n")
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks/for-note?path=chores.md", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 2 || list.Tasks[0].Recurrence != "weekly" {
+		t.Fatalf("unexpected tasks %#v", list.Tasks)
+	}
+
+	toggle := map[string]any{
+		"path":       "chores.md",
+		"lineNumber": 1,
+		"lineHash":   list.Tasks[0].LineHash,
+		"completed":  true,
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", toggle)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp TaskToggleResponse
+	decodeJSONBody(t, rec, &resp)
+	if resp.Next == nil || resp.Next.LineNumber != 2 || resp.Next.DueDateISO != "2026-01-13" || resp.Next.Completed {
+		t.Fatalf("unexpected next task %#v", resp.Next)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "chores.md"))
+	if err != nil {
+		t.Fatalf("read updated note: %v", err)
+	}
+	expected := "- [x] Take out bins *every:weekly >2026-01-06\n- [ ] Take out bins *every:weekly >2026-01-13\n- [ ] Other\n"
+	if string(data) != expected {
+		t.Fatalf("unexpected note content %q", data)
+	}
+
+	toggle["lineHash"] = hashLine("- [x] Take out bins *every:weekly >2026-01-06")
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/toggle", toggle)
+	var again TaskToggleResponse
+	decodeJSONBody(t, rec, &again)
+	if again.Status != "updated" || again.Next != nil {
+		t.Fatalf("expected no new occurrence for a completed task, got %#v", again)
+	}
+}
+
+func TestTasksStatus(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	content := strings.Join([]string{
+		"- [/] Draft spec",
+		"- [?] Waiting on legal",
+		"- [>] Later idea",
+		"- [-] Dropped",
+		"- [ ] Plain",
+	}, "\n")
+	writeFile(t, filepath.Join(dir, "status.md"), content)
+
+	rec := doRequest(t, router, http.MethodGet, "/tasks/for-note?path=status.md", nil)
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	statuses := make([]string, 0, len(list.Tasks))
+	for _, task := range list.Tasks {
+		statuses = append(statuses, task.Status)
+		if task.Completed {
+			t.Fatalf("expected no completed tasks, got %#v", task)
+		}
+	}
+	if strings.Join(statuses, ",") != "in-progress,blocked,deferred,cancelled,open" {
+		t.Fatalf("unexpected statuses %v", statuses)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/status", map[string]any{
+		"path":       "status.md",
+		"lineNumber": 5,
+		"lineHash":   list.Tasks[4].LineHash,
+		"status":     "Blocked",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/status", map[string]any{
+		"path":       "status.md",
+		"lineNumber": 1,
+		"lineHash":   list.Tasks[0].LineHash,
+		"status":     "paused",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown status, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/tasks/archive", nil)
+	var archived TaskArchiveResponse
+	decodeJSONBody(t, rec, &archived)
+	if archived.Archived != 1 {
+		t.Fatalf("expected only the cancelled task to be archived, got %#v", archived)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "status.md"))
+	if err != nil {
+		t.Fatalf("read updated note: %v", err)
+	}
+	expected := strings.Join([]string{
+		"- [/] Draft spec",
+		"- [?] Waiting on legal",
+		"- [>] Later idea",
+		"~ - [-] Dropped",
+		"- [?] Plain",
+	}, "\n")
+	if string(data) != expected {
+		t.Fatalf("unexpected note content %q", data)
+	}
+
+	rec = doRequest(t, router, http.MethodPut, "/tasks/filters", map[string]any{
+		"version": 1,
+		"filters": []map[string]any{{"id": "stuck", "name": "Stuck", "statuses": []string{"stuck"}}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for unknown filter status, got %d", rec.Code)
+	}
+}
+
+func TestTasksArchiveCompleted(t *testing.T) {
+	dir, router := setupTestRouter(t)
+
+	content := strings.Join([]string{
+		"- [x] Done task",
+		"- [ ] Active task",
+		"  - [✓] Done child",
+	}, "\n")
+	writeFile(t, filepath.Join(dir, "archive.md"), content)
+
+	rec := doRequest(t, router, http.MethodPatch, "/tasks/archive", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "archive.md"))
+	if err != nil {
+		t.Fatalf("read updated note: %v", err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if !strings.HasPrefix(lines[0], "~ - [x] ") {
+		t.Fatalf("expected completed task to be archived, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], "  ~ - [✓] ") {
+		t.Fatalf("expected completed task to be archived with indentation, got %q", lines[2])
+	}
+	if lines[1] != "- [ ] Active task" {
+		t.Fatalf("expected active task to remain, got %q", lines[1])
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var list TaskListResponse
+	decodeJSONBody(t, rec, &list)
+	if len(list.Tasks) != 1 {
+		t.Fatalf("expected 1 remaining task, got %d", len(list.Tasks))
+	}
+	if list.Tasks[0].Text != "Active task" {
+		t.Fatalf("expected Active task to remain, got %q", list.Tasks[0].Text)
+	}
+}
+
+func TestFoldersCRUD(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/folders", map[string]string{
+		"path": "folder-a",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/folders", map[string]string{
+		"path":    "folder-a",
+		"newPath": "folder-b",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodDelete, "/folders?path=folder-b", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+}
+
+func TestSettingsCRUD(t *testing.T) {
+	dir, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodGet, "/settings", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var settingsResp SettingsResponse
+	decodeJSONBody(t, rec, &settingsResp)
+	if settingsResp.Notice == "" {
+		t.Fatalf("expected notice when creating settings.json")
+	}
+	if settingsResp.Settings.DefaultView != "split" {
+		t.Fatalf("expected defaultView split, got %q", settingsResp.Settings.DefaultView)
+	}
+	if settingsResp.Settings.SidebarWidth != 300 {
+		t.Fatalf("expected sidebarWidth 300, got %d", settingsResp.Settings.SidebarWidth)
+	}
+	if settingsResp.Settings.StartOnToday {
+		t.Fatalf("expected startOnToday false by default")
+	}
+	if !settingsResp.Settings.ShowAiNode {
+		t.Fatalf("expected showAiNode true by default")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "settings.json")); err != nil {
+		t.Fatalf("expected settings.json to exist")
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]any{
+		"darkMode":     true,
+		"defaultView":  "preview",
+		"sidebarWidth": 280,
+		"startOnToday": true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var updated Settings
+	decodeJSONBody(t, rec, &updated)
+	if !updated.DarkMode {
+		t.Fatalf("expected darkMode true")
+	}
+	if updated.DefaultView != "preview" {
+		t.Fatalf("expected defaultView preview, got %q", updated.DefaultView)
+	}
+	if updated.SidebarWidth != 280 {
+		t.Fatalf("expected sidebarWidth 280, got %d", updated.SidebarWidth)
+	}
+	if !updated.StartOnToday {
+		t.Fatalf("expected startOnToday true")
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/settings", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	settingsResp = SettingsResponse{}
+	decodeJSONBody(t, rec, &settingsResp)
+	if !settingsResp.Settings.DarkMode {
+		t.Fatalf("expected darkMode true from settings")
+	}
+	if settingsResp.Settings.DefaultView != "preview" {
+		t.Fatalf("expected defaultView preview from settings")
+	}
+	if settingsResp.Settings.SidebarWidth != 280 {
+		t.Fatalf("expected sidebarWidth 280 from settings")
+	}
+	if !settingsResp.Settings.StartOnToday {
+		t.Fatalf("expected startOnToday true from settings")
+	}
+}
+
+func TestReservedRootNames(t *testing.T) {
+	_, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodPost, "/folders", map[string]string{
+		"path": "email",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/folders", map[string]string{
+		"path": ".ai",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/notes", map[string]string{
+		"path":    "Inbox",
+		"content": "Hi",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestEmailSettingsCRUD(t *testing.T) {
+	dir, router := setupTestRouter(t)
+
+	rec := doRequest(t, router, http.MethodGet, "/email/settings", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp EmailSettingsResponse
+	decodeJSONBody(t, rec, &resp)
+	if resp.Notice == "" {
+		t.Fatalf("expected notice when creating email-settings.json")
+	}
+	if resp.Settings.SMTP.Host != "smtp.gmail.com" {
+		t.Fatalf("expected default smtp host, got %q", resp.Settings.SMTP.Host)
+	}
+	if resp.Settings.SMTP.Port != 587 {
+		t.Fatalf("expected default smtp port 587, got %d", resp.Settings.SMTP.Port)
+	}
+	if resp.Settings.Digest.Time != "08:00" {
+		t.Fatalf("expected default digest time 08:00, got %q", resp.Settings.Digest.Time)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "email-settings.json")); err != nil {
+		t.Fatalf("expected email-settings.json to exist")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "email", "digest.template")); err != nil {
+		t.Fatalf("expected email digest template to exist")
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/email/settings", map[string]any{
+		"enabled": true,
+		"smtp": map[string]any{
+			"host":     "smtp.gmail.com",
+			"port":     587,
+			"username": "test@example.com",
+			"password": "secret",
+			"from":     "test@example.com",
+			"to":       "test@example.com",
+			"useTLS":   true,
+		},
+		"digest": map[string]any{
+			"enabled": true,
+			"time":    "07:45",
+		},
+		"due": map[string]any{
+			"enabled":        true,
+			"time":           "07:30",
+			"windowDays":     0,
+			"includeOverdue": true,
+		},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var updated EmailSettings
+	decodeJSONBody(t, rec, &updated)
+	if !updated.Enabled {
+		t.Fatalf("expected email enabled true")
+	}
+	if updated.Digest.Time != "07:45" {
+		t.Fatalf("expected digest time 07:45, got %q", updated.Digest.Time)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/email/settings", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	resp = EmailSettingsResponse{}
+	decodeJSONBody(t, rec, &resp)
+	if !resp.Settings.Enabled {
+		t.Fatalf("expected enabled true from email settings")
+	}
+	if resp.Settings.Digest.Time != "07:45" {
+		t.Fatalf("expected digest time 07:45 from email settings")
+	}
+}
+
+func TestSearchEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "alpha.md"), "hello world")
+	writeFile(t, filepath.Join(dir, "beta.md"), "queryterm")
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query=alpha", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var matches []SearchResult
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 1 || matches[0].Path != "alpha.md" || matches[0].Type != "note" {
+		t.Fatalf("expected alpha.md match, got %#v", matches)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/search?query=queryterm", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	matches = nil
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 1 || matches[0].Path != "beta.md" || matches[0].Type != "note" {
+		t.Fatalf("expected beta.md match, got %#v", matches)
+	}
+}
+
+func TestTagsEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "alpha.md"), "Hello #TagOne\n##NoTag\nword#NoTag\n#TagTwo and #tagtwo")
+	writeFile(t, filepath.Join(dir, "sub", "beta.md"), "Another #TagTwo")
 
 	rec := doRequest(t, router, http.MethodGet, "/tags", nil)
 	if rec.Code != http.StatusOK {
@@ -1012,3 +2056,631 @@ func TestFilesEndpoint(t *testing.T) {
 		t.Fatalf("expected file contents")
 	}
 }
+
+func TestSearchRankedSnippets(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Roadmap.md"), "# Plan\n\nShip the search index this quarter.\nOther line")
+	writeFile(t, filepath.Join(dir, "search.md"), "Search notes about search ranking.")
+	writeFile(t, filepath.Join(dir, "misc.md"), "Nothing relevant here, search once.")
+	writeFile(t, filepath.Join(dir, "excluded.md"), "search index but also draft")
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query=search", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var matches []SearchResult
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 4 {
+		t.Fatalf("expected 4 matches, got %#v", matches)
+	}
+	if matches[0].Path != "search.md" {
+		t.Fatalf("expected title match to rank first, got %q", matches[0].Path)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/search?query=%22search+index%22+-draft", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	matches = nil
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 1 || matches[0].Path != "Work/Roadmap.md" {
+		t.Fatalf("expected Work/Roadmap.md only, got %#v", matches)
+	}
+	match := matches[0]
+	if match.Line != 3 {
+		t.Fatalf("expected match on line 3, got %d", match.Line)
+	}
+	if match.Snippet != "Ship the search index this quarter." {
+		t.Fatalf("unexpected snippet %q", match.Snippet)
+	}
+	if len(match.Highlights) != 1 || match.Highlights[0].Start != 9 || match.Highlights[0].End != 21 {
+		t.Fatalf("unexpected highlights %#v", match.Highlights)
+	}
+
+	writeFile(t, filepath.Join(dir, "misc.md"), "Now mentions quarterly goals")
+	rec = doRequest(t, router, http.MethodGet, "/search?query=quarter*+OR+goal", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	matches = nil
+	decodeJSONBody(t, rec, &matches)
+	paths := make(map[string]bool)
+	for _, item := range matches {
+		paths[item.Path] = true
+	}
+	if len(matches) != 2 || !paths["misc.md"] || !paths["Work/Roadmap.md"] {
+		t.Fatalf("expected refreshed index to match misc.md and Work/Roadmap.md, got %#v", matches)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "misc.md")); err != nil {
+		t.Fatalf("remove note: %v", err)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/search?query=quarterly", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	matches = nil
+	decodeJSONBody(t, rec, &matches)
+	if len(matches) != 0 {
+		t.Fatalf("expected deleted note to drop out of the index, got %#v", matches)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), "Budget review #work\n- [ ] Send numbers @alice +finance")
+	writeFile(t, filepath.Join(dir, "Work", "Sync.md"), "Budget sync #work with @bob")
+	writeFile(t, filepath.Join(dir, "Home.md"), "Budget for groceries #home")
+
+	old := time.Date(2026, 8, 1, 12, 0, 0, 0, time.Local)
+	if err := os.Chtimes(filepath.Join(dir, "Work", "Sync.md"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	search := func(query string) []string {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search %q: expected status 200, got %d", query, rec.Code)
+		}
+		var matches []SearchResult
+		decodeJSONBody(t, rec, &matches)
+		paths := make([]string, 0, len(matches))
+		for _, match := range matches {
+			paths = append(paths, match.Path)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	cases := []struct {
+		query string
+		paths []string
+	}{
+		{query: "budget tag:work", paths: []string{"Work/Plan.md", "Work/Sync.md"}},
+		{query: "budget -#work", paths: []string{"Home.md"}},
+		{query: "path:work/ -@bob", paths: []string{"Work/Plan.md"}},
+		{query: "project:finance", paths: []string{"Work/Plan.md"}},
+		{query: "budget modified:<2026-09-01", paths: []string{"Work/Sync.md"}},
+		{query: "tag:work modified:>=2026-09-01", paths: []string{"Work/Plan.md"}},
+		{query: "budget NOT (tag:work)", paths: []string{"Home.md"}},
+		{query: "budget -(tag:work @alice)", paths: []string{"Home.md", "Work/Sync.md"}},
+		{query: "tag:home OR @bob", paths: []string{"Home.md", "Work/Sync.md"}},
+		{query: "budget (tag:home OR +finance)", paths: []string{"Home.md", "Work/Plan.md"}},
+	}
+	for _, tc := range cases {
+		paths := search(tc.query)
+		if strings.Join(paths, ",") != strings.Join(tc.paths, ",") {
+			t.Fatalf("search %q: expected %v, got %v", tc.query, tc.paths, paths)
+		}
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape("modified:>later"), nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for invalid date, got %d", rec.Code)
+	}
+	for _, query := range []string{"budget OR tag:home", "NOT (budget tag:home)"} {
+		rec = doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("search %q: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestLinksEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Roadmap.md"), "# Roadmap\n\nDepends on [[Budget]] and [[Missing Note]].\nSee [[#Goals]].")
+	writeFile(t, filepath.Join(dir, "Budget.md"), "Budget lines\nFeeds the [[Projects/Roadmap#Q3|roadmap]].\n")
+	writeFile(t, filepath.Join(dir, "Daily", "2026-01-06.md"), "Talked about the roadmap today.\n`roadmap` in code\n[[Roadmap]] again")
+
+	rec := doRequest(t, router, http.MethodGet, "/links?path=Projects/Roadmap.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp LinksResponse
+	decodeJSONBody(t, rec, &resp)
+	if resp.Title != "Roadmap" {
+		t.Fatalf("expected title Roadmap, got %q", resp.Title)
+	}
+	if len(resp.Outgoing) != 3 {
+		t.Fatalf("expected 3 outgoing links, got %#v", resp.Outgoing)
+	}
+	if resp.Outgoing[0].Path != "Budget.md" || !resp.Outgoing[0].Exists || resp.Outgoing[0].Line != 3 {
+		t.Fatalf("unexpected first outgoing link %#v", resp.Outgoing[0])
+	}
+	if resp.Outgoing[1].Exists || resp.Outgoing[1].Target != "Missing Note" {
+		t.Fatalf("expected unresolved link, got %#v", resp.Outgoing[1])
+	}
+	if resp.Outgoing[2].Path != "Projects/Roadmap.md" || resp.Outgoing[2].Heading != "Goals" {
+		t.Fatalf("expected self heading link, got %#v", resp.Outgoing[2])
+	}
+
+	if len(resp.Backlinks) != 2 {
+		t.Fatalf("expected 2 backlinks, got %#v", resp.Backlinks)
+	}
+	if resp.Backlinks[0].Path != "Budget.md" || resp.Backlinks[0].Line != 2 || resp.Backlinks[0].Context != "Feeds the [[Projects/Roadmap#Q3|roadmap]]." {
+		t.Fatalf("unexpected backlink %#v", resp.Backlinks[0])
+	}
+	if resp.Backlinks[1].Path != "Daily/2026-01-06.md" || resp.Backlinks[1].Line != 3 {
+		t.Fatalf("unexpected backlink %#v", resp.Backlinks[1])
+	}
+
+	if len(resp.UnlinkedMentions) != 1 || resp.UnlinkedMentions[0].Path != "Daily/2026-01-06.md" || resp.UnlinkedMentions[0].Line != 1 {
+		t.Fatalf("unexpected unlinked mentions %#v", resp.UnlinkedMentions)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/links?path=Nope.md", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestRenameRewritesReferences(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Projects", "Plan.md"), "# Plan\n![chart](chart.png)\nSee [[Budget]].")
+	writeFile(t, filepath.Join(dir, "Projects", "chart.png"), "png")
+	writeFile(t, filepath.Join(dir, "Budget.md"), "Budget")
+	writeFile(t, filepath.Join(dir, "Home.md"), "Start at [[Plan]] or [[Projects/Plan#Goals|goals]].\n[plan](Projects/Plan.md) and `[[Plan]]`\n![chart](Projects/chart.png)")
+	writeFile(t, filepath.Join(dir, "task-sets.json"), `{"version":1,"filters":[{"id":"work","name":"Work","pathPrefix":"Projects/"},{"id":"other","name":"Other","pathPrefix":"Home"}]}`)
+
+	rec := doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]any{
+		"path":    "Projects/Plan.md",
+		"newPath": "Projects/Roadmap.md",
+		"dryRun":  true,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var preview RenameResponse
+	decodeJSONBody(t, rec, &preview)
+	if !preview.DryRun || len(preview.Changes) != 2 {
+		t.Fatalf("expected dry run with 2 changed lines, got %#v", preview)
+	}
+	if preview.Changes[0].Path != "Home.md" || preview.Changes[0].Line != 1 ||
+		preview.Changes[0].After != "Start at [[Roadmap]] or [[Projects/Roadmap#Goals|goals]]." {
+		t.Fatalf("unexpected first change %#v", preview.Changes[0])
+	}
+	if preview.Changes[1].After != "[plan](Projects/Roadmap.md) and `[[Plan]]`" {
+		t.Fatalf("unexpected second change %#v", preview.Changes[1])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Projects", "Plan.md")); err != nil {
+		t.Fatalf("expected dry run to leave note in place: %v", err)
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/folders", map[string]string{
+		"path":    "Projects",
+		"newPath": "Archive/Projects",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var moved RenameResponse
+	decodeJSONBody(t, rec, &moved)
+	if len(moved.Filters) != 1 || moved.Filters[0] != "work" {
+		t.Fatalf("expected work filter to be updated, got %#v", moved.Filters)
+	}
+
+	home, err := os.ReadFile(filepath.Join(dir, "Home.md"))
+	if err != nil {
+		t.Fatalf("read home: %v", err)
+	}
+	expectedHome := "Start at [[Plan]] or [[Archive/Projects/Plan#Goals|goals]].\n[plan](Archive/Projects/Plan.md) and `[[Plan]]`\n![chart](Archive/Projects/chart.png)"
+	if string(home) != expectedHome {
+		t.Fatalf("unexpected home content %q", string(home))
+	}
+	plan, err := os.ReadFile(filepath.Join(dir, "Archive", "Projects", "Plan.md"))
+	if err != nil {
+		t.Fatalf("read plan: %v", err)
+	}
+	if string(plan) != "# Plan\n![chart](chart.png)\nSee [[Budget]]." {
+		t.Fatalf("expected moved note links to stay valid, got %q", string(plan))
+	}
+	filters, err := os.ReadFile(filepath.Join(dir, "task-sets.json"))
+	if err != nil {
+		t.Fatalf("read filters: %v", err)
+	}
+	if !strings.Contains(string(filters), `"pathPrefix": "Archive/Projects/"`) || !strings.Contains(string(filters), `"pathPrefix": "Home"`) {
+		t.Fatalf("unexpected filters %s", string(filters))
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/notes/rename", map[string]string{
+		"path":    "Budget.md",
+		"newPath": "Finance/Budget.md",
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	plan, err = os.ReadFile(filepath.Join(dir, "Archive", "Projects", "Plan.md"))
+	if err != nil {
+		t.Fatalf("read plan: %v", err)
+	}
+	if string(plan) != "# Plan\n![chart](chart.png)\nSee [[Budget]]." {
+		t.Fatalf("expected unique title link to survive move, got %q", string(plan))
+	}
+}
+
+func TestGraphEndpoint(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), "Plan #work with @alice\nSee [[Budget]] and [[Budget]].\n- [ ] Draft +launch")
+	writeFile(t, filepath.Join(dir, "Work", "Budget.md"), "Numbers #work")
+	writeFile(t, filepath.Join(dir, "Home.md"), "Groceries #home\n[[Plan]]")
+
+	fetch := func(query string) GraphResponse {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodGet, "/graph"+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("graph %q: expected status 200, got %d", query, rec.Code)
+		}
+		var graph GraphResponse
+		decodeJSONBody(t, rec, &graph)
+		return graph
+	}
+	nodeIDs := func(graph GraphResponse) string {
+		ids := make([]string, 0, len(graph.Nodes))
+		for _, node := range graph.Nodes {
+			ids = append(ids, node.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	graph := fetch("")
+	expected := "mention:alice,note:Home.md,note:Work/Budget.md,note:Work/Plan.md,project:launch,tag:home,tag:work"
+	if nodeIDs(graph) != expected {
+		t.Fatalf("unexpected nodes %s", nodeIDs(graph))
+	}
+	foundLink := false
+	for _, edge := range graph.Edges {
+		if edge.Source == "note:Work/Plan.md" && edge.Target == "note:Work/Budget.md" {
+			foundLink = edge.Type == "link" && edge.Weight == 2
+		}
+	}
+	if !foundLink {
+		t.Fatalf("expected weighted link edge, got %#v", graph.Edges)
+	}
+
+	graph = fetch("?folder=Work")
+	if nodeIDs(graph) != "mention:alice,note:Work/Budget.md,note:Work/Plan.md,project:launch,tag:work" {
+		t.Fatalf("unexpected folder nodes %s", nodeIDs(graph))
+	}
+
+	graph = fetch("?tag=home")
+	if nodeIDs(graph) != "note:Home.md,tag:home" {
+		t.Fatalf("unexpected tag nodes %s", nodeIDs(graph))
+	}
+
+	graph = fetch("?focus=Work/Budget.md")
+	if nodeIDs(graph) != "note:Work/Budget.md,note:Work/Plan.md,tag:work" {
+		t.Fatalf("unexpected focus nodes %s", nodeIDs(graph))
+	}
+
+	writeFile(t, filepath.Join(dir, "Work", "Budget.md"), "Numbers only, now with more text")
+	graph = fetch("?focus=Work/Budget.md&depth=2")
+	if nodeIDs(graph) != "mention:alice,note:Home.md,note:Work/Budget.md,note:Work/Plan.md,project:launch,tag:work" {
+		t.Fatalf("expected refreshed focus nodes, got %s", nodeIDs(graph))
+	}
+
+	rec := doRequest(t, router, http.MethodGet, "/graph?focus=Missing.md", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestNoteHistoryKeepsEveryAutosave(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Notes", "plan.md"), "monday\n")
+
+	originalNow := timeNow
+	current := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return current }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	rec := doRequest(t, router, http.MethodPatch, "/settings", map[string]int{"historyMaxVersions": 3})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	save := func(content string) {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+			"path":    "Notes/plan.md",
+			"content": content,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		current = current.Add(5 * time.Second)
+	}
+	save("important draft\n")
+	save("")
+
+	var history NoteHistoryResponse
+	decodeJSONBody(t, doRequest(t, router, http.MethodGet, "/notes/history?path=Notes/plan.md", nil), &history)
+	if len(history.Versions) != 2 {
+		t.Fatalf("expected a version for each save, got %#v", history.Versions)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/notes/history/version?path=Notes/plan.md&id="+history.Versions[0].ID, nil)
+	var latest NoteVersionResponse
+	decodeJSONBody(t, rec, &latest)
+	if latest.Content != "important draft\n" {
+		t.Fatalf("expected the content replaced by a quick autosave to be kept, got %q", latest.Content)
+	}
+
+	for i := 0; i < 5; i++ {
+		save(fmt.Sprintf("draft %d\n", i))
+	}
+	decodeJSONBody(t, doRequest(t, router, http.MethodGet, "/notes/history?path=Notes/plan.md", nil), &history)
+	if len(history.Versions) != 3 {
+		t.Fatalf("expected versions pruned to the configured count, got %#v", history.Versions)
+	}
+	rec = doRequest(t, router, http.MethodGet, "/notes/history/version?path=Notes/plan.md&id="+history.Versions[0].ID, nil)
+	decodeJSONBody(t, rec, &latest)
+	if latest.Content != "draft 3\n" {
+		t.Fatalf("expected the newest replaced content to be kept, got %q", latest.Content)
+	}
+}
+
+func TestNoteHistory(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Notes", "plan.md"), "one\ntwo\n")
+
+	originalNow := timeNow
+	current := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return current }
+	t.Cleanup(func() { timeNow = originalNow })
+
+	save := func(content string) {
+		t.Helper()
+		rec := doRequest(t, router, http.MethodPatch, "/notes", map[string]string{
+			"path":    "Notes/plan.md",
+			"content": content,
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		current = current.Add(time.Minute)
+	}
+	save("one\nTWO\n")
+	save("one\nTWO\n")
+	save("one\nTWO\nthree\n")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes/history?path=Notes/plan.md", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var history NoteHistoryResponse
+	decodeJSONBody(t, rec, &history)
+	if len(history.Versions) != 2 {
+		t.Fatalf("expected 2 versions, got %#v", history.Versions)
+	}
+	oldest := history.Versions[1].ID
+	if oldest != "20260301T090000.000000000Z" {
+		t.Fatalf("unexpected version id %q", oldest)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".history", "Notes", "plan.md", oldest+".md")); err != nil {
+		t.Fatalf("expected snapshot on disk: %v", err)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/history/diff?path=Notes/plan.md&from="+oldest, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var diff NoteDiffResponse
+	decodeJSONBody(t, rec, &diff)
+	expectedDiff := "--- Notes/plan.md@" + oldest + "\n+++ Notes/plan.md@current\n@@ -1,2 +1,3 @@\n one\n-two\n+TWO\n+three\n"
+	if diff.Diff != expectedDiff {
+		t.Fatalf("unexpected diff:\n%s", diff.Diff)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tree", nil)
+	if strings.Contains(rec.Body.String(), ".history") {
+		t.Fatalf("expected history folder to be hidden from tree")
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/notes/history/restore", map[string]string{
+		"path":    "Notes/plan.md",
+		"version": oldest,
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Notes", "plan.md"))
+	if err != nil {
+		t.Fatalf("read note: %v", err)
+	}
+	if string(data) != "one\ntwo\n" {
+		t.Fatalf("expected restored content, got %q", string(data))
+	}
+
+	rec = doRequest(t, router, http.MethodPatch, "/settings", map[string]int{"historyMaxVersions": 1})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	save("final\n")
+	history = NoteHistoryResponse{}
+	decodeJSONBody(t, doRequest(t, router, http.MethodGet, "/notes/history?path=Notes/plan.md", nil), &history)
+	if len(history.Versions) != 1 {
+		t.Fatalf("expected retention to keep 1 version, got %#v", history.Versions)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/notes/history/version?path=Notes/plan.md&id=bogus", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestWritePreconditions(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Notes", "plan.md"), "one\n")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes?path=Notes/plan.md", nil)
+	var note NoteResponse
+	decodeJSONBody(t, rec, &note)
+	if note.ETag == "" || rec.Header().Get("ETag") != note.ETag {
+		t.Fatalf("expected matching etag, got %q and header %q", note.ETag, rec.Header().Get("ETag"))
+	}
+
+	update := map[string]string{"path": "Notes/plan.md", "content": "two\n"}
+	rec = doRequestWithHeaders(t, router, http.MethodPatch, "/notes", update, map[string]string{"If-Match": note.ETag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var updated map[string]string
+	decodeJSONBody(t, rec, &updated)
+	if updated["etag"] == "" || updated["etag"] == note.ETag {
+		t.Fatalf("expected new etag, got %#v", updated)
+	}
+
+	update["content"] = "weak\n"
+	rec = doRequestWithHeaders(t, router, http.MethodPatch, "/notes", update, map[string]string{"If-Match": "W/" + updated["etag"]})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected a weak etag to fail If-Match, got %d", rec.Code)
+	}
+
+	update["content"] = "stale\n"
+	rec = doRequestWithHeaders(t, router, http.MethodPatch, "/notes", update, map[string]string{"If-Match": note.ETag})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	var noteConflict struct {
+		Error   string       `json:"error"`
+		Current NoteResponse `json:"current"`
+	}
+	decodeJSONBody(t, rec, &noteConflict)
+	if noteConflict.Current.Content != "two\n" || noteConflict.Current.ETag != updated["etag"] {
+		t.Fatalf("unexpected conflict body: %#v", noteConflict)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Notes", "plan.md"))
+	if err != nil || string(data) != "two\n" {
+		t.Fatalf("expected note to be unchanged, got %q (%v)", data, err)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/sheets", map[string]any{
+		"path": "budget",
+		"data": [][]string{{"a"}},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var created map[string]string
+	decodeJSONBody(t, rec, &created)
+	rec = doRequest(t, router, http.MethodGet, "/sheets?path="+created["path"], nil)
+	var sheet SheetResponse
+	decodeJSONBody(t, rec, &sheet)
+	sheetUpdate := map[string]any{"path": created["path"], "data": [][]string{{"b"}}}
+	rec = doRequestWithHeaders(t, router, http.MethodPatch, "/sheets", sheetUpdate, map[string]string{"If-Match": sheet.ETag})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	rec = doRequestWithHeaders(t, router, http.MethodPatch, "/sheets", sheetUpdate, map[string]string{"If-Match": sheet.ETag})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	var sheetConflict struct {
+		Current SheetResponse `json:"current"`
+	}
+	decodeJSONBody(t, rec, &sheetConflict)
+	if len(sheetConflict.Current.Data) == 0 || sheetConflict.Current.Data[0][0] != "b" {
+		t.Fatalf("unexpected conflict body: %#v", sheetConflict)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/journal", nil)
+	var journal JournalListResponse
+	decodeJSONBody(t, rec, &journal)
+	if journal.ETag == "" {
+		t.Fatalf("expected journal etag")
+	}
+	rec = doRequestWithHeaders(t, router, http.MethodPost, "/journal", map[string]string{"content": "first"}, map[string]string{"If-Match": journal.ETag})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+	rec = doRequestWithHeaders(t, router, http.MethodPost, "/journal", map[string]string{"content": "second"}, map[string]string{"If-Match": journal.ETag})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status 409, got %d", rec.Code)
+	}
+	var journalConflict struct {
+		Current JournalListResponse `json:"current"`
+	}
+	decodeJSONBody(t, rec, &journalConflict)
+	if len(journalConflict.Current.Entries) != 1 || journalConflict.Current.Entries[0].Content != "first" {
+		t.Fatalf("unexpected conflict body: %#v", journalConflict)
+	}
+
+	rec = doRequest(t, router, http.MethodPost, "/journal", map[string]string{"content": "unconditional"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", rec.Code)
+	}
+}
+
+func TestFrontMatterMetadata(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	writeFile(t, filepath.Join(dir, "Work", "Plan.md"), "---\ntags: [planning]\naliases: [Q3 Plan]\nproject: launch\nstatus: Active\ncreated: 2026-03-01\n---\n# Plan\n- [ ] ship it\n- [ ] call vendor +ops\n")
+	writeFile(t, filepath.Join(dir, "Notes.md"), "See [[Q3 Plan]].\n")
+
+	rec := doRequest(t, router, http.MethodGet, "/notes?path=Work/Plan.md", nil)
+	var note NoteResponse
+	decodeJSONBody(t, rec, &note)
+	if note.FrontMatter == nil || note.FrontMatter.Project != "launch" || note.FrontMatter.Fields["status"] != "Active" {
+		t.Fatalf("unexpected front matter %#v", note.FrontMatter)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tags", nil)
+	var groups []TagGroup
+	decodeJSONBody(t, rec, &groups)
+	if len(groups) != 1 || groups[0].Tag != "planning" || groups[0].Notes[0].Path != "Work/Plan.md" {
+		t.Fatalf("unexpected tags %#v", groups)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/tasks", nil)
+	var tasks TaskListResponse
+	decodeJSONBody(t, rec, &tasks)
+	if len(tasks.Tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %#v", tasks.Tasks)
+	}
+	if tasks.Tasks[0].Project != "launch" || tasks.Tasks[1].Project != "ops" || tasks.Tasks[0].LineNumber != 9 {
+		t.Fatalf("unexpected task projects %#v", tasks.Tasks)
+	}
+	if status := tasks.Tasks[0].Meta["status"]; len(status) != 1 || status[0] != "active" {
+		t.Fatalf("unexpected task meta %#v", tasks.Tasks[0].Meta)
+	}
+
+	rec = doRequest(t, router, http.MethodGet, "/links?path=Work/Plan.md", nil)
+	var links LinksResponse
+	decodeJSONBody(t, rec, &links)
+	if len(links.Backlinks) != 1 || links.Backlinks[0].Path != "Notes.md" {
+		t.Fatalf("expected alias backlink, got %#v", links.Backlinks)
+	}
+
+	for query, expected := range map[string]int{
+		"meta:status=active":  1,
+		"meta:status":         1,
+		"meta:status=done":    0,
+		"created:<2026-04-01": 1,
+		"project:launch":      1,
+	} {
+		rec = doRequest(t, router, http.MethodGet, "/search?query="+url.QueryEscape(query), nil)
+		var matches []SearchResult
+		decodeJSONBody(t, rec, &matches)
+		if len(matches) != expected {
+			t.Fatalf("search %q: expected %d matches, got %#v", query, expected, matches)
+		}
+	}
+}
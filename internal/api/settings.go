@@ -50,6 +50,13 @@ type Settings struct {
 	NotesSortOrder       string            `json:"notesSortOrder"`
 	ExternalCommandsPath string            `json:"externalCommandsPath"`
 	RootIcons            map[string]string `json:"rootIcons,omitempty"`
+	HistoryMaxVersions   int               `json:"historyMaxVersions"`
+	HistoryMaxAgeDays    int               `json:"historyMaxAgeDays"`
+	NormalizeDueDates    bool              `json:"normalizeDueDates"`
+	TaskBlockIDs         bool              `json:"taskBlockIds"`
+	CompleteSubtasks     bool              `json:"completeSubtasks"`
+	CompletionDates      bool              `json:"completionDates"`
+	RolloverTasks        bool              `json:"rolloverTasks"`
 }
 
 type SettingsResponse struct {
@@ -68,6 +75,13 @@ type SettingsPayload struct {
 	NotesSortBy          *string `json:"notesSortBy,omitempty"`
 	NotesSortOrder       *string `json:"notesSortOrder,omitempty"`
 	ExternalCommandsPath *string `json:"externalCommandsPath,omitempty"`
+	HistoryMaxVersions   *int    `json:"historyMaxVersions,omitempty"`
+	HistoryMaxAgeDays    *int    `json:"historyMaxAgeDays,omitempty"`
+	NormalizeDueDates    *bool   `json:"normalizeDueDates,omitempty"`
+	TaskBlockIDs         *bool   `json:"taskBlockIds,omitempty"`
+	CompleteSubtasks     *bool   `json:"completeSubtasks,omitempty"`
+	CompletionDates      *bool   `json:"completionDates,omitempty"`
+	RolloverTasks        *bool   `json:"rolloverTasks,omitempty"`
 }
 
 func (s *Server) handleSettingsGet(w http.ResponseWriter, r *http.Request) {
@@ -107,7 +121,7 @@ func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	changed := make([]string, 0, 9)
+	changed := make([]string, 0, 16)
 	if payload.DarkMode != nil {
 		settings.DarkMode = *payload.DarkMode
 		changed = append(changed, "darkMode")
@@ -144,6 +158,34 @@ func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
 		settings.ExternalCommandsPath = *payload.ExternalCommandsPath
 		changed = append(changed, "externalCommandsPath")
 	}
+	if payload.HistoryMaxVersions != nil {
+		settings.HistoryMaxVersions = *payload.HistoryMaxVersions
+		changed = append(changed, "historyMaxVersions")
+	}
+	if payload.HistoryMaxAgeDays != nil {
+		settings.HistoryMaxAgeDays = *payload.HistoryMaxAgeDays
+		changed = append(changed, "historyMaxAgeDays")
+	}
+	if payload.NormalizeDueDates != nil {
+		settings.NormalizeDueDates = *payload.NormalizeDueDates
+		changed = append(changed, "normalizeDueDates")
+	}
+	if payload.TaskBlockIDs != nil {
+		settings.TaskBlockIDs = *payload.TaskBlockIDs
+		changed = append(changed, "taskBlockIds")
+	}
+	if payload.CompleteSubtasks != nil {
+		settings.CompleteSubtasks = *payload.CompleteSubtasks
+		changed = append(changed, "completeSubtasks")
+	}
+	if payload.CompletionDates != nil {
+		settings.CompletionDates = *payload.CompletionDates
+		changed = append(changed, "completionDates")
+	}
+	if payload.RolloverTasks != nil {
+		settings.RolloverTasks = *payload.RolloverTasks
+		changed = append(changed, "rolloverTasks")
+	}
 	if err := s.saveSettings(settings); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to save settings")
 		return
@@ -163,7 +205,7 @@ func (s *Server) loadSettings() (Settings, string, error) {
 	if err != nil {
 		if os.IsNotExist(err) {
 			settings := Settings{
-				Version:              8,
+				Version:              14,
 				DarkMode:             false,
 				DefaultView:          "split",
 				SidebarWidth:         300,
@@ -174,6 +216,8 @@ func (s *Server) loadSettings() (Settings, string, error) {
 				NotesSortOrder:       notesSortOrderAsc,
 				ExternalCommandsPath: "",
 				RootIcons:            map[string]string{},
+				HistoryMaxVersions:   defaultHistoryVersions,
+				HistoryMaxAgeDays:    defaultHistoryMaxAgeDays,
 			}
 			if err := os.MkdirAll(s.notesDir, 0o755); err != nil {
 				return settings, "", err
@@ -237,6 +281,31 @@ func (s *Server) loadSettings() (Settings, string, error) {
 	if settings.Version < 8 {
 		settings.Version = 8
 	}
+	if settings.Version < 9 {
+		settings.HistoryMaxVersions = defaultHistoryVersions
+		settings.HistoryMaxAgeDays = defaultHistoryMaxAgeDays
+		settings.Version = 9
+	}
+	if settings.Version < 10 {
+		settings.NormalizeDueDates = false
+		settings.Version = 10
+	}
+	if settings.Version < 11 {
+		settings.TaskBlockIDs = false
+		settings.Version = 11
+	}
+	if settings.Version < 12 {
+		settings.CompleteSubtasks = false
+		settings.Version = 12
+	}
+	if settings.Version < 13 {
+		settings.CompletionDates = false
+		settings.Version = 13
+	}
+	if settings.Version < 14 {
+		settings.RolloverTasks = false
+		settings.Version = 14
+	}
 	if settings.RootIcons == nil {
 		settings.RootIcons = map[string]string{}
 	}
@@ -277,6 +346,16 @@ func validateSettingsPayload(payload SettingsPayload) error {
 			return errors.New("notesSortOrder must be asc or desc")
 		}
 	}
+	if payload.HistoryMaxVersions != nil {
+		if *payload.HistoryMaxVersions < 0 || *payload.HistoryMaxVersions > 1000 {
+			return errors.New("historyMaxVersions must be between 0 and 1000")
+		}
+	}
+	if payload.HistoryMaxAgeDays != nil {
+		if *payload.HistoryMaxAgeDays < 0 || *payload.HistoryMaxAgeDays > 3650 {
+			return errors.New("historyMaxAgeDays must be between 0 and 3650")
+		}
+	}
 	if payload.ExternalCommandsPath != nil {
 		cleaned, err := cleanRelPath(*payload.ExternalCommandsPath)
 		if err != nil {
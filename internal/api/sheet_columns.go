@@ -0,0 +1,201 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Column types of a sheet. An untyped column holds text.
+const (
+	sheetColumnText   = "text"
+	sheetColumnNumber = "number"
+	sheetColumnDate   = "date"
+	sheetColumnBool   = "bool"
+	sheetColumnSelect = "select"
+)
+
+// SheetColumn describes one column of a sheet, by position. Format is a
+// display hint for clients, such as "0.00" for a number column; Options are
+// the values a select column allows.
+type SheetColumn struct {
+	Name    string   `json:"name,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Width   int      `json:"width,omitempty"`
+	Format  string   `json:"format,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+// SheetSort is the sort a sheet was last viewed with. Column is 0-based.
+type SheetSort struct {
+	Column     int  `json:"column"`
+	Descending bool `json:"descending,omitempty"`
+}
+
+// SheetSettings are the per-sheet settings stored next to the cells. With
+// FrozenHeader set, the first row holds column names and is not type
+// checked.
+type SheetSettings struct {
+	Columns      []SheetColumn `json:"columns"`
+	FrozenHeader bool          `json:"frozenHeader"`
+	Sort         *SheetSort    `json:"sort,omitempty"`
+}
+
+func validateSheetSettings(settings *SheetSettings) error {
+	if settings.Columns == nil {
+		settings.Columns = []SheetColumn{}
+	}
+	for i := range settings.Columns {
+		column := &settings.Columns[i]
+		column.Name = strings.TrimSpace(column.Name)
+		column.Type = strings.ToLower(strings.TrimSpace(column.Type))
+		switch column.Type {
+		case "", sheetColumnText, sheetColumnNumber, sheetColumnDate, sheetColumnBool:
+		case sheetColumnSelect:
+			if len(column.Options) == 0 {
+				return fmt.Errorf("column %s: select columns need options", sheetColumnName(i))
+			}
+		default:
+			return fmt.Errorf("column %s: unknown type %q", sheetColumnName(i), column.Type)
+		}
+		if column.Type != sheetColumnSelect && len(column.Options) > 0 {
+			return fmt.Errorf("column %s: only select columns take options", sheetColumnName(i))
+		}
+		if column.Width < 0 {
+			return fmt.Errorf("column %s: width must be >= 0", sheetColumnName(i))
+		}
+	}
+	if settings.Sort != nil && settings.Sort.Column < 0 {
+		return errors.New("sort column must be >= 0")
+	}
+	return nil
+}
+
+// validateSheetData checks every cell of a typed column against its type.
+// Empty cells and formulas are always accepted.
+func validateSheetData(data [][]string, settings SheetSettings) error {
+	for row, cells := range data {
+		if row == 0 && settings.FrozenHeader {
+			continue
+		}
+		for col, column := range settings.Columns {
+			if col >= len(cells) {
+				break
+			}
+			value := strings.TrimSpace(cells[col])
+			if value == "" || isSheetFormula(value) {
+				continue
+			}
+			if !sheetValueFits(value, column) {
+				return fmt.Errorf("cell %s%d is not a valid %s", sheetColumnName(col), row+1, column.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func sheetValueFits(value string, column SheetColumn) bool {
+	switch column.Type {
+	case sheetColumnNumber:
+		_, ok := literalSheetValue(value).(float64)
+		return ok
+	case sheetColumnDate:
+		_, err := time.Parse(dailyDateLayout, value)
+		return err == nil
+	case sheetColumnBool:
+		return strings.EqualFold(value, "true") || strings.EqualFold(value, "false")
+	case sheetColumnSelect:
+		for _, option := range column.Options {
+			if value == option {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// inferSheetSettings guesses the column types of imported rows. The first row
+// is taken as a header, and frozen, unless every cell in it fits the type
+// found for its column and at least one column is not text.
+func inferSheetSettings(data [][]string) SheetSettings {
+	if len(data) == 0 {
+		return SheetSettings{Columns: []SheetColumn{}}
+	}
+	width := 0
+	for _, row := range data {
+		width = max(width, len(row))
+	}
+
+	columns := inferSheetColumns(data[1:], width)
+	header := true
+	if len(data) > 1 {
+		typed := false
+		fits := true
+		for col, column := range columns {
+			if column.Type == sheetColumnText {
+				continue
+			}
+			typed = true
+			value := strings.TrimSpace(sheetCellAt(data, 0, col))
+			if value != "" && !sheetValueFits(value, column) {
+				fits = false
+			}
+		}
+		header = !typed || !fits
+	}
+	if !header {
+		return SheetSettings{Columns: inferSheetColumns(data, width)}
+	}
+	for col := range columns {
+		columns[col].Name = strings.TrimSpace(sheetCellAt(data, 0, col))
+	}
+	return SheetSettings{Columns: columns, FrozenHeader: true}
+}
+
+// inferSheetColumns types each column as number, date or bool when all of
+// its non-empty literal cells agree, and as text otherwise.
+func inferSheetColumns(rows [][]string, width int) []SheetColumn {
+	columns := make([]SheetColumn, width)
+	for col := range columns {
+		columns[col].Type = sheetColumnText
+		for _, candidate := range []string{sheetColumnNumber, sheetColumnDate, sheetColumnBool} {
+			seen := false
+			fits := true
+			for row := range rows {
+				value := strings.TrimSpace(sheetCellAt(rows, row, col))
+				if value == "" || isSheetFormula(value) {
+					continue
+				}
+				seen = true
+				if !sheetValueFits(value, SheetColumn{Type: candidate}) {
+					fits = false
+					break
+				}
+			}
+			if seen && fits {
+				columns[col].Type = candidate
+				break
+			}
+		}
+	}
+	return columns
+}
+
+func sheetCellAt(data [][]string, row, col int) string {
+	if row < len(data) && col < len(data[row]) {
+		return data[row][col]
+	}
+	return ""
+}
+
+// sheetColumnName is the letter name of a 0-based column: A, B, ..., AA.
+func sheetColumnName(col int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInferSheetSettings(t *testing.T) {
+	settings := inferSheetSettings([][]string{
+		{"Item", "Cost", "Paid", "Due", "Note"},
+		{"Rent", "1200", "true", "2026-11-01", ""},
+		{"Power", "80.5", "FALSE", "2026-10-20", "estimate"},
+		{"Total", "=SUM(B2:B3)", "", "", "12"},
+	})
+	if !settings.FrozenHeader {
+		t.Fatalf("expected the header row to be detected")
+	}
+	want := []SheetColumn{
+		{Name: "Item", Type: sheetColumnText},
+		{Name: "Cost", Type: sheetColumnNumber},
+		{Name: "Paid", Type: sheetColumnBool},
+		{Name: "Due", Type: sheetColumnDate},
+		{Name: "Note", Type: sheetColumnText},
+	}
+	if len(settings.Columns) != len(want) {
+		t.Fatalf("unexpected columns %#v", settings.Columns)
+	}
+	for i := range want {
+		if settings.Columns[i].Name != want[i].Name || settings.Columns[i].Type != want[i].Type {
+			t.Errorf("column %d = %#v, want %#v", i, settings.Columns[i], want[i])
+		}
+	}
+
+	headless := inferSheetSettings([][]string{{"Rent", "1200"}, {"Power", "80"}})
+	if headless.FrozenHeader || headless.Columns[0].Name != "" || headless.Columns[1].Type != sheetColumnNumber {
+		t.Fatalf("expected rows without a header, got %#v", headless)
+	}
+}
+
+func TestValidateSheet(t *testing.T) {
+	settings := SheetSettings{
+		FrozenHeader: true,
+		Columns: []SheetColumn{
+			{Type: "Number"},
+			{Type: "select", Options: []string{"low", "high"}},
+		},
+	}
+	sheet := sheetFile{SheetSettings: settings, Data: [][]string{{"Cost", "Level"}, {"12%", "low"}, {"=A2*2", ""}}}
+	if err := validateSheet(&sheet); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sheet.Columns[0].Type != sheetColumnNumber {
+		t.Fatalf("expected type to be normalized, got %q", sheet.Columns[0].Type)
+	}
+
+	sheet.Data = [][]string{{"Cost", "Level"}, {"12", "medium"}}
+	err := validateSheet(&sheet)
+	if err == nil || !strings.Contains(err.Error(), "cell B2") {
+		t.Fatalf("expected select error, got %v", err)
+	}
+
+	tests := []SheetSettings{
+		{Columns: []SheetColumn{{Type: "currency"}}},
+		{Columns: []SheetColumn{{Type: "select"}}},
+		{Columns: []SheetColumn{{Type: "text", Options: []string{"a"}}}},
+		{Columns: []SheetColumn{{Width: -1}}},
+		{Sort: &SheetSort{Column: -1}},
+	}
+	for _, tt := range tests {
+		if err := validateSheetSettings(&tt); err == nil {
+			t.Errorf("expected %#v to be rejected", tt)
+		}
+	}
+}
+
+func TestSheetColumnName(t *testing.T) {
+	for col, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 701: "ZZ", 702: "AAA"} {
+		if got := sheetColumnName(col); got != want {
+			t.Errorf("sheetColumnName(%d) = %q, want %q", col, got, want)
+		}
+		if parsed, ok := parseSheetColumn(want); !ok || parsed != col {
+			t.Errorf("parseSheetColumn(%q) = %d", want, parsed)
+		}
+	}
+}
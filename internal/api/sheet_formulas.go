@@ -0,0 +1,1262 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Error values shown in place of a formula's result.
+const (
+	sheetErrCycle  = "#CYCLE!"
+	sheetErrDiv0   = "#DIV/0!"
+	sheetErrNA     = "#N/A"
+	sheetErrName   = "#NAME?"
+	sheetErrRef    = "#REF!"
+	sheetErrSyntax = "#ERROR!"
+	sheetErrValue  = "#VALUE!"
+)
+
+// sheetValue is the result of evaluating a cell or expression: nil for an
+// empty cell, float64, string, bool, sheetError or sheetRange.
+type sheetValue any
+
+type sheetError string
+
+// sheetRange holds the values of an A1:B2 range, row by row. It is only
+// valid as a function argument.
+type sheetRange [][]sheetValue
+
+type sheetCell struct {
+	row, col int
+}
+
+// sheetMaxDepth bounds how many formulas can be evaluating at once while
+// following references, so a long chain cannot exhaust the stack.
+const sheetMaxDepth = 4096
+
+// sheetEvaluator computes the cells of one sheet. Each formula is evaluated
+// at most once; a formula that reaches itself again evaluates to #CYCLE!.
+//
+// A reference more than sheetMaxDepth formulas deep is not followed: the cell
+// is kept in deferred and the evaluation is abandoned without caching.
+// evaluate then computes the deferred cell on its own and tries again, so long
+// chains are walked in slices instead of on one stack. The cells waiting on
+// a deferred one are in pending, and reaching one of them again is a cycle.
+type sheetEvaluator struct {
+	data     [][]string
+	width    int
+	today    time.Time
+	values   map[sheetCell]sheetValue
+	active   map[sheetCell]bool
+	pending  map[sheetCell]bool
+	deferred *sheetCell
+}
+
+// evaluateSheet returns the displayed value of every cell: literals as they
+// are and formulas, cells starting with "=", replaced by their result.
+func evaluateSheet(data [][]string, today time.Time) [][]string {
+	e := &sheetEvaluator{
+		data:    data,
+		today:   today,
+		values:  make(map[sheetCell]sheetValue),
+		active:  make(map[sheetCell]bool),
+		pending: make(map[sheetCell]bool),
+	}
+	for _, cells := range data {
+		e.width = max(e.width, len(cells))
+	}
+	values := make([][]string, len(data))
+	for row, cells := range data {
+		values[row] = make([]string, len(cells))
+		for col, raw := range cells {
+			if !isSheetFormula(raw) {
+				values[row][col] = raw
+				continue
+			}
+			values[row][col] = formatSheetValue(e.evaluate(sheetCell{row, col}))
+		}
+	}
+	return values
+}
+
+func isSheetFormula(raw string) bool {
+	return len(raw) > 1 && raw[0] == '='
+}
+
+// evaluate computes a formula cell, first computing the cells its evaluation
+// had to defer, deepest first.
+func (e *sheetEvaluator) evaluate(target sheetCell) sheetValue {
+	stack := []sheetCell{target}
+	for {
+		next := stack[len(stack)-1]
+		delete(e.pending, next)
+		e.deferred = nil
+		value := e.cell(next.row, next.col)
+		if e.deferred == nil {
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return value
+			}
+			continue
+		}
+		e.pending[next] = true
+		stack = append(stack, *e.deferred)
+	}
+}
+
+func (e *sheetEvaluator) cell(row, col int) sheetValue {
+	if row < 0 || row >= len(e.data) || col < 0 || col >= len(e.data[row]) {
+		return nil
+	}
+	raw := e.data[row][col]
+	if !isSheetFormula(raw) {
+		return literalSheetValue(raw)
+	}
+	key := sheetCell{row, col}
+	if value, ok := e.values[key]; ok {
+		return value
+	}
+	if e.deferred != nil {
+		// The result is thrown away once the evaluation unwinds.
+		return nil
+	}
+	if e.active[key] || e.pending[key] {
+		return sheetError(sheetErrCycle)
+	}
+	if len(e.active) >= sheetMaxDepth {
+		e.deferred = &key
+		return nil
+	}
+	e.active[key] = true
+	var value sheetValue
+	expr, err := parseSheetFormula(raw[1:])
+	if err != nil {
+		value = sheetError(sheetErrSyntax)
+	} else {
+		value = expr.eval(e)
+		if _, ok := value.(sheetRange); ok {
+			value = sheetError(sheetErrValue)
+		}
+	}
+	delete(e.active, key)
+	if e.deferred == nil {
+		e.values[key] = value
+	}
+	return value
+}
+
+// literalSheetValue reads a literal cell: numbers and percentages become
+// numbers, anything else stays text.
+func literalSheetValue(raw string) sheetValue {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil
+	}
+	if number, ok := parseSheetNumber(trimmed); ok {
+		return number
+	}
+	if percent, ok := strings.CutSuffix(trimmed, "%"); ok {
+		if number, ok := parseSheetNumber(strings.TrimSpace(percent)); ok {
+			return number / 100
+		}
+	}
+	return raw
+}
+
+// parseSheetNumber accepts finite decimal literals only. strconv.ParseFloat
+// also reads "NaN", "Inf" and hex floats, which are text in a sheet.
+func parseSheetNumber(text string) (float64, bool) {
+	if text == "" || strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && !strings.ContainsRune("+-.eE", r)
+	}) >= 0 {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// formatSheetValue renders a result for display. Numbers are rounded to ten
+// decimals so that 0.1+0.2 shows as 0.3.
+func formatSheetValue(value sheetValue) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return sheetErrValue
+		}
+		if rounded := math.Round(v*1e10) / 1e10; !math.IsInf(rounded, 0) {
+			v = rounded
+		}
+		if v == 0 {
+			v = 0 // drop a negative zero
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case sheetError:
+		return string(v)
+	case string:
+		return v
+	}
+	return sheetErrValue
+}
+
+// Formula syntax
+
+type sheetTokenKind int
+
+const (
+	sheetTokenEOF sheetTokenKind = iota
+	sheetTokenNumber
+	sheetTokenString
+	sheetTokenName
+	sheetTokenOp
+)
+
+type sheetToken struct {
+	kind sheetTokenKind
+	text string
+}
+
+var errSheetSyntax = errors.New("invalid formula")
+
+func tokenizeSheetFormula(input string) ([]sheetToken, error) {
+	var tokens []sheetToken
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(input) && (input[i] >= '0' && input[i] <= '9' || input[i] == '.') {
+				i++
+			}
+			if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+				j := i + 1
+				if j < len(input) && (input[j] == '+' || input[j] == '-') {
+					j++
+				}
+				if j < len(input) && input[j] >= '0' && input[j] <= '9' {
+					for i = j; i < len(input) && input[i] >= '0' && input[i] <= '9'; i++ {
+					}
+				}
+			}
+			tokens = append(tokens, sheetToken{sheetTokenNumber, input[start:i]})
+		case c == '"':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(input) {
+					return nil, errSheetSyntax
+				}
+				if input[i] == '"' {
+					if i+1 < len(input) && input[i+1] == '"' {
+						b.WriteByte('"')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, sheetToken{sheetTokenString, b.String()})
+		case c == '$' || c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(input) && (input[i] == '$' || input[i] == '_' || input[i] == '.' ||
+				input[i] >= 'A' && input[i] <= 'Z' || input[i] >= 'a' && input[i] <= 'z' ||
+				input[i] >= '0' && input[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, sheetToken{sheetTokenName, input[start:i]})
+		case c == '<' || c == '>':
+			if i+1 < len(input) && (input[i+1] == '=' || c == '<' && input[i+1] == '>') {
+				tokens = append(tokens, sheetToken{sheetTokenOp, input[i : i+2]})
+				i += 2
+				continue
+			}
+			tokens = append(tokens, sheetToken{sheetTokenOp, string(c)})
+			i++
+		case strings.IndexByte("+-*/^&=%(),:;", c) >= 0:
+			op := string(c)
+			if c == ';' {
+				// Some locales separate arguments with semicolons.
+				op = ","
+			}
+			tokens = append(tokens, sheetToken{sheetTokenOp, op})
+			i++
+		default:
+			return nil, errSheetSyntax
+		}
+	}
+	return append(tokens, sheetToken{kind: sheetTokenEOF}), nil
+}
+
+// sheetExpr is a parsed formula.
+type sheetExpr interface {
+	eval(e *sheetEvaluator) sheetValue
+}
+
+type sheetLiteral struct{ value sheetValue }
+
+type sheetRef struct{ cell sheetCell }
+
+// sheetRangeRef is a rectangular range. A column range such as A:B has a
+// to row of -1 and covers every row.
+type sheetRangeRef struct{ from, to sheetCell }
+
+type sheetUnary struct {
+	op string
+	x  sheetExpr
+}
+
+type sheetBinary struct {
+	op   string
+	l, r sheetExpr
+}
+
+type sheetCall struct {
+	name string
+	args []sheetExpr
+}
+
+type sheetParser struct {
+	tokens []sheetToken
+	pos    int
+}
+
+func parseSheetFormula(input string) (sheetExpr, error) {
+	tokens, err := tokenizeSheetFormula(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &sheetParser{tokens: tokens}
+	expr, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != sheetTokenEOF {
+		return nil, errSheetSyntax
+	}
+	return expr, nil
+}
+
+func (p *sheetParser) peek() sheetToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sheetParser) next() sheetToken {
+	token := p.tokens[p.pos]
+	if token.kind != sheetTokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+func (p *sheetParser) acceptOp(ops ...string) (string, bool) {
+	token := p.peek()
+	if token.kind != sheetTokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if token.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// binary parses a left-associative chain of ops over operands parsed by
+// operand.
+func (p *sheetParser) binary(operand func() (sheetExpr, error), ops ...string) (sheetExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = sheetBinary{op: op, l: left, r: right}
+	}
+}
+
+func (p *sheetParser) comparison() (sheetExpr, error) {
+	return p.binary(p.concat, "=", "<>", "<", ">", "<=", ">=")
+}
+
+func (p *sheetParser) concat() (sheetExpr, error) {
+	return p.binary(p.additive, "&")
+}
+
+func (p *sheetParser) additive() (sheetExpr, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *sheetParser) multiplicative() (sheetExpr, error) {
+	return p.binary(p.power, "*", "/")
+}
+
+func (p *sheetParser) power() (sheetExpr, error) {
+	return p.binary(p.unary, "^")
+}
+
+func (p *sheetParser) unary() (sheetExpr, error) {
+	if op, ok := p.acceptOp("-", "+"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return sheetUnary{op: op, x: x}, nil
+	}
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOp("%"); !ok {
+			return x, nil
+		}
+		x = sheetUnary{op: "%", x: x}
+	}
+}
+
+func (p *sheetParser) primary() (sheetExpr, error) {
+	token := p.next()
+	switch token.kind {
+	case sheetTokenNumber:
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, errSheetSyntax
+		}
+		return sheetLiteral{number}, nil
+	case sheetTokenString:
+		return sheetLiteral{token.text}, nil
+	case sheetTokenOp:
+		if token.text != "(" {
+			return nil, errSheetSyntax
+		}
+		expr, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.acceptOp(")"); !ok {
+			return nil, errSheetSyntax
+		}
+		return expr, nil
+	case sheetTokenName:
+		return p.name(token.text)
+	}
+	return nil, errSheetSyntax
+}
+
+func (p *sheetParser) name(text string) (sheetExpr, error) {
+	if _, ok := p.acceptOp("("); ok {
+		return p.call(strings.ToUpper(text))
+	}
+	if _, ok := p.acceptOp(":"); ok {
+		end := p.next()
+		if end.kind != sheetTokenName {
+			return nil, errSheetSyntax
+		}
+		if from, ok := parseSheetCellRef(text); ok {
+			to, ok := parseSheetCellRef(end.text)
+			if !ok {
+				return nil, errSheetSyntax
+			}
+			return sheetRangeRef{from: from, to: to}, nil
+		}
+		fromCol, ok := parseSheetColumn(text)
+		if !ok {
+			return nil, errSheetSyntax
+		}
+		toCol, ok := parseSheetColumn(end.text)
+		if !ok {
+			return nil, errSheetSyntax
+		}
+		return sheetRangeRef{from: sheetCell{0, fromCol}, to: sheetCell{-1, toCol}}, nil
+	}
+	if cell, ok := parseSheetCellRef(text); ok {
+		return sheetRef{cell}, nil
+	}
+	switch strings.ToUpper(text) {
+	case "TRUE":
+		return sheetLiteral{true}, nil
+	case "FALSE":
+		return sheetLiteral{false}, nil
+	}
+	return sheetLiteral{sheetError(sheetErrName)}, nil
+}
+
+func (p *sheetParser) call(name string) (sheetCall, error) {
+	call := sheetCall{name: name}
+	if _, ok := p.acceptOp(")"); ok {
+		return call, nil
+	}
+	for {
+		arg, err := p.comparison()
+		if err != nil {
+			return sheetCall{}, err
+		}
+		call.args = append(call.args, arg)
+		if _, ok := p.acceptOp(")"); ok {
+			return call, nil
+		}
+		if _, ok := p.acceptOp(","); !ok {
+			return sheetCall{}, errSheetSyntax
+		}
+	}
+}
+
+// parseSheetCellRef reads an A1 reference, ignoring $ anchors.
+func parseSheetCellRef(text string) (sheetCell, bool) {
+	text = strings.ReplaceAll(text, "$", "")
+	split := strings.IndexFunc(text, unicode.IsDigit)
+	if split <= 0 {
+		return sheetCell{}, false
+	}
+	col, ok := parseSheetColumn(text[:split])
+	if !ok {
+		return sheetCell{}, false
+	}
+	row, err := strconv.Atoi(text[split:])
+	if err != nil || row < 1 {
+		return sheetCell{}, false
+	}
+	return sheetCell{row - 1, col}, true
+}
+
+// parseSheetColumn reads column letters: A is 0, Z is 25, AA is 26.
+func parseSheetColumn(text string) (int, bool) {
+	text = strings.ReplaceAll(text, "$", "")
+	if text == "" || len(text) > 3 {
+		return 0, false
+	}
+	col := 0
+	for _, r := range strings.ToUpper(text) {
+		if r < 'A' || r > 'Z' {
+			return 0, false
+		}
+		col = col*26 + int(r-'A') + 1
+	}
+	return col - 1, true
+}
+
+// sheetFormulaSupported reports whether a formula, without its leading "=",
+// parses and uses only known functions. Imports keep such formulas.
+func sheetFormulaSupported(formula string) bool {
+	expr, err := parseSheetFormula(formula)
+	return err == nil && sheetExprSupported(expr)
+}
+
+func sheetExprSupported(expr sheetExpr) bool {
+	switch x := expr.(type) {
+	case sheetLiteral:
+		_, failed := x.value.(sheetError)
+		return !failed
+	case sheetUnary:
+		return sheetExprSupported(x.x)
+	case sheetBinary:
+		return sheetExprSupported(x.l) && sheetExprSupported(x.r)
+	case sheetCall:
+		if _, ok := sheetFunctions[x.name]; !ok && x.name != "IF" {
+			return false
+		}
+		for _, arg := range x.args {
+			if !sheetExprSupported(arg) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Evaluation
+
+func (x sheetLiteral) eval(*sheetEvaluator) sheetValue {
+	return x.value
+}
+
+func (x sheetRef) eval(e *sheetEvaluator) sheetValue {
+	return e.cell(x.cell.row, x.cell.col)
+}
+
+func (x sheetRangeRef) eval(e *sheetEvaluator) sheetValue {
+	fromRow, toRow := x.from.row, x.to.row
+	if toRow < 0 {
+		fromRow, toRow = 0, len(e.data)-1
+	}
+	fromRow, toRow = min(fromRow, toRow), max(fromRow, toRow)
+	fromCol, toCol := min(x.from.col, x.to.col), max(x.from.col, x.to.col)
+	// Cells past the data are empty, so the range stops at the last row and
+	// column that hold anything.
+	toRow, toCol = min(toRow, len(e.data)-1), min(toCol, e.width-1)
+	if fromRow > toRow || fromCol > toCol {
+		return sheetRange{}
+	}
+	values := make(sheetRange, 0, toRow-fromRow+1)
+	for row := fromRow; row <= toRow; row++ {
+		cells := make([]sheetValue, 0, toCol-fromCol+1)
+		for col := fromCol; col <= toCol; col++ {
+			cells = append(cells, e.cell(row, col))
+		}
+		values = append(values, cells)
+	}
+	return values
+}
+
+func (x sheetUnary) eval(e *sheetEvaluator) sheetValue {
+	number, errValue := sheetNumber(x.x.eval(e))
+	if errValue != nil {
+		return errValue
+	}
+	switch x.op {
+	case "-":
+		return -number
+	case "%":
+		return number / 100
+	}
+	return number
+}
+
+func (x sheetBinary) eval(e *sheetEvaluator) sheetValue {
+	left := x.l.eval(e)
+	right := x.r.eval(e)
+	for _, value := range []sheetValue{left, right} {
+		switch value.(type) {
+		case sheetError:
+			return value
+		case sheetRange:
+			return sheetError(sheetErrValue)
+		}
+	}
+	switch x.op {
+	case "&":
+		return sheetText(left) + sheetText(right)
+	case "=":
+		return compareSheetValues(left, right) == 0
+	case "<>":
+		return compareSheetValues(left, right) != 0
+	case "<":
+		return compareSheetValues(left, right) < 0
+	case ">":
+		return compareSheetValues(left, right) > 0
+	case "<=":
+		return compareSheetValues(left, right) <= 0
+	case ">=":
+		return compareSheetValues(left, right) >= 0
+	}
+
+	a, errValue := sheetNumber(left)
+	if errValue != nil {
+		return errValue
+	}
+	b, errValue := sheetNumber(right)
+	if errValue != nil {
+		return errValue
+	}
+	switch x.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			return sheetError(sheetErrDiv0)
+		}
+		return a / b
+	case "^":
+		result := math.Pow(a, b)
+		if math.IsNaN(result) || math.IsInf(result, 0) {
+			return sheetError(sheetErrValue)
+		}
+		return result
+	}
+	return sheetError(sheetErrValue)
+}
+
+func (x sheetCall) eval(e *sheetEvaluator) sheetValue {
+	// IF only evaluates the branch it takes.
+	if x.name == "IF" {
+		if len(x.args) < 2 || len(x.args) > 3 {
+			return sheetError(sheetErrValue)
+		}
+		condition, errValue := sheetBool(x.args[0].eval(e))
+		if errValue != nil {
+			return errValue
+		}
+		if condition {
+			return x.args[1].eval(e)
+		}
+		if len(x.args) == 3 {
+			return x.args[2].eval(e)
+		}
+		return false
+	}
+
+	fn, ok := sheetFunctions[x.name]
+	if !ok {
+		return sheetError(sheetErrName)
+	}
+	args := make([]sheetValue, 0, len(x.args))
+	for _, arg := range x.args {
+		args = append(args, arg.eval(e))
+	}
+	if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+		return sheetError(sheetErrValue)
+	}
+	if !fn.ranges {
+		for _, arg := range args {
+			switch arg.(type) {
+			case sheetError:
+				return arg
+			case sheetRange:
+				return sheetError(sheetErrValue)
+			}
+		}
+	}
+	return fn.call(e, args)
+}
+
+// sheetNumber coerces a scalar to a number. Empty cells are zero and
+// booleans are one or zero.
+func sheetNumber(value sheetValue) (float64, sheetValue) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if number, ok := literalSheetValue(v).(float64); ok {
+			return number, nil
+		}
+	case sheetError:
+		return 0, v
+	}
+	return 0, sheetError(sheetErrValue)
+}
+
+func sheetText(value sheetValue) string {
+	return formatSheetValue(value)
+}
+
+func sheetBool(value sheetValue) (bool, sheetValue) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToUpper(strings.TrimSpace(v)) {
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		}
+	}
+	number, errValue := sheetNumber(value)
+	if errValue != nil {
+		return false, errValue
+	}
+	return number != 0, nil
+}
+
+func sheetDate(value sheetValue) (time.Time, sheetValue) {
+	if errValue, ok := value.(sheetError); ok {
+		return time.Time{}, errValue
+	}
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, sheetError(sheetErrValue)
+	}
+	date, err := time.ParseInLocation(dailyDateLayout, strings.TrimSpace(text), time.Local)
+	if err != nil {
+		return time.Time{}, sheetError(sheetErrValue)
+	}
+	return date, nil
+}
+
+// compareSheetValues orders numbers before text, and compares text without
+// regard to case. An empty cell equals zero or empty text.
+func compareSheetValues(a, b sheetValue) int {
+	if a == nil {
+		if _, ok := b.(string); ok {
+			a = ""
+		}
+	}
+	if b == nil {
+		if _, ok := a.(string); ok {
+			b = ""
+		}
+	}
+	textA, aIsText := a.(string)
+	textB, bIsText := b.(string)
+	switch {
+	case aIsText && bIsText:
+		return orderedCompare(strings.ToLower(textA), strings.ToLower(textB), false)
+	case aIsText:
+		return 1
+	case bIsText:
+		return -1
+	}
+	numberA, _ := sheetNumber(a)
+	numberB, _ := sheetNumber(b)
+	switch {
+	case numberA < numberB:
+		return -1
+	case numberA > numberB:
+		return 1
+	}
+	return 0
+}
+
+// Functions
+
+type sheetFunction struct {
+	minArgs int
+	maxArgs int // -1 for any number
+	// ranges lets the function take range arguments and see errors itself.
+	ranges bool
+	call   func(e *sheetEvaluator, args []sheetValue) sheetValue
+}
+
+var sheetFunctions map[string]sheetFunction
+
+func init() {
+	sheetFunctions = map[string]sheetFunction{
+		"SUM":     {1, -1, true, sheetSum},
+		"AVG":     {1, -1, true, sheetAverage},
+		"AVERAGE": {1, -1, true, sheetAverage},
+		"MIN":     {1, -1, true, sheetMin},
+		"MAX":     {1, -1, true, sheetMax},
+		"COUNT":   {1, -1, true, sheetCount},
+		"COUNTA":  {1, -1, true, sheetCountA},
+		"VLOOKUP": {3, 4, true, sheetVLookup},
+		"CONCAT":  {1, -1, true, sheetConcat},
+		"AND":     {1, -1, false, sheetAnd},
+		"OR":      {1, -1, false, sheetOr},
+		"NOT":     {1, 1, false, sheetNot},
+		"ROUND":   {1, 2, false, sheetRound},
+		"ABS":     {1, 1, false, sheetAbs},
+		"LEN":     {1, 1, false, sheetLen},
+		"UPPER":   {1, 1, false, sheetTextFunc(strings.ToUpper)},
+		"LOWER":   {1, 1, false, sheetTextFunc(strings.ToLower)},
+		"TRIM":    {1, 1, false, sheetTextFunc(strings.TrimSpace)},
+		"LEFT":    {1, 2, false, sheetLeft},
+		"RIGHT":   {1, 2, false, sheetRight},
+		"MID":     {3, 3, false, sheetMid},
+		"TODAY":   {0, 0, false, sheetToday},
+		"DATE":    {3, 3, false, sheetDateFunc},
+		"YEAR":    {1, 1, false, sheetDatePart(func(t time.Time) int { return t.Year() })},
+		"MONTH":   {1, 1, false, sheetDatePart(func(t time.Time) int { return int(t.Month()) })},
+		"DAY":     {1, 1, false, sheetDatePart(func(t time.Time) int { return t.Day() })},
+		"DAYS":    {2, 2, false, sheetDays},
+		"EDATE":   {2, 2, false, sheetEDate},
+	}
+}
+
+// sheetNumbers collects the numbers of aggregate arguments. Text, booleans
+// and empty cells inside ranges are skipped; direct arguments must be
+// numeric.
+func sheetNumbers(args []sheetValue) ([]float64, sheetValue) {
+	var numbers []float64
+	for _, arg := range args {
+		if values, ok := arg.(sheetRange); ok {
+			for _, row := range values {
+				for _, value := range row {
+					switch v := value.(type) {
+					case float64:
+						numbers = append(numbers, v)
+					case sheetError:
+						return nil, v
+					}
+				}
+			}
+			continue
+		}
+		number, errValue := sheetNumber(arg)
+		if errValue != nil {
+			return nil, errValue
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func sheetSum(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	numbers, errValue := sheetNumbers(args)
+	if errValue != nil {
+		return errValue
+	}
+	total := 0.0
+	for _, number := range numbers {
+		total += number
+	}
+	return total
+}
+
+func sheetAverage(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	numbers, errValue := sheetNumbers(args)
+	if errValue != nil {
+		return errValue
+	}
+	if len(numbers) == 0 {
+		return sheetError(sheetErrDiv0)
+	}
+	total := 0.0
+	for _, number := range numbers {
+		total += number
+	}
+	return total / float64(len(numbers))
+}
+
+func sheetMin(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	numbers, errValue := sheetNumbers(args)
+	if errValue != nil {
+		return errValue
+	}
+	if len(numbers) == 0 {
+		return 0.0
+	}
+	result := numbers[0]
+	for _, number := range numbers[1:] {
+		result = math.Min(result, number)
+	}
+	return result
+}
+
+func sheetMax(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	numbers, errValue := sheetNumbers(args)
+	if errValue != nil {
+		return errValue
+	}
+	if len(numbers) == 0 {
+		return 0.0
+	}
+	result := numbers[0]
+	for _, number := range numbers[1:] {
+		result = math.Max(result, number)
+	}
+	return result
+}
+
+// sheetCount counts numbers, skipping errors as well as text.
+func sheetCount(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	count := 0
+	for _, arg := range args {
+		if values, ok := arg.(sheetRange); ok {
+			for _, row := range values {
+				for _, value := range row {
+					if _, ok := value.(float64); ok {
+						count++
+					}
+				}
+			}
+			continue
+		}
+		if _, errValue := sheetNumber(arg); errValue == nil && arg != nil {
+			count++
+		}
+	}
+	return float64(count)
+}
+
+// sheetCountA counts non-empty values.
+func sheetCountA(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	count := 0
+	for _, arg := range args {
+		if values, ok := arg.(sheetRange); ok {
+			for _, row := range values {
+				for _, value := range row {
+					if value != nil {
+						count++
+					}
+				}
+			}
+			continue
+		}
+		if arg != nil {
+			count++
+		}
+	}
+	return float64(count)
+}
+
+// sheetVLookup finds a value in the first column of a range and returns the
+// cell in the given column of that row. The lookup is exact when the fourth
+// argument is FALSE; otherwise the first column must be sorted and the last
+// row not greater than the value matches.
+func sheetVLookup(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	for i, arg := range args {
+		if errValue, ok := arg.(sheetError); ok {
+			return errValue
+		}
+		if _, ok := arg.(sheetRange); ok != (i == 1) {
+			return sheetError(sheetErrValue)
+		}
+	}
+	lookup := args[0]
+	table := args[1].(sheetRange)
+	column, errValue := sheetNumber(args[2])
+	if errValue != nil {
+		return errValue
+	}
+	index := int(column) - 1
+	if index < 0 {
+		return sheetError(sheetErrValue)
+	}
+	if len(table) == 0 || index >= len(table[0]) {
+		return sheetError(sheetErrRef)
+	}
+	approximate := true
+	if len(args) == 4 {
+		exact, errValue := sheetBool(args[3])
+		if errValue != nil {
+			return errValue
+		}
+		approximate = exact
+	}
+
+	match := -1
+	for i, row := range table {
+		if row[0] == nil {
+			continue
+		}
+		cmp := compareSheetValues(row[0], lookup)
+		if !approximate {
+			if cmp == 0 {
+				match = i
+				break
+			}
+			continue
+		}
+		if cmp > 0 {
+			break
+		}
+		match = i
+	}
+	if match < 0 {
+		return sheetError(sheetErrNA)
+	}
+	return table[match][index]
+}
+
+func sheetConcat(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	var b strings.Builder
+	for _, arg := range args {
+		values, ok := arg.(sheetRange)
+		if !ok {
+			values = sheetRange{{arg}}
+		}
+		for _, row := range values {
+			for _, value := range row {
+				if errValue, ok := value.(sheetError); ok {
+					return errValue
+				}
+				b.WriteString(sheetText(value))
+			}
+		}
+	}
+	return b.String()
+}
+
+func sheetAnd(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	result := true
+	for _, arg := range args {
+		value, errValue := sheetBool(arg)
+		if errValue != nil {
+			return errValue
+		}
+		result = result && value
+	}
+	return result
+}
+
+func sheetOr(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	result := false
+	for _, arg := range args {
+		value, errValue := sheetBool(arg)
+		if errValue != nil {
+			return errValue
+		}
+		result = result || value
+	}
+	return result
+}
+
+func sheetNot(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	value, errValue := sheetBool(args[0])
+	if errValue != nil {
+		return errValue
+	}
+	return !value
+}
+
+func sheetRound(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	number, errValue := sheetNumber(args[0])
+	if errValue != nil {
+		return errValue
+	}
+	digits := 0.0
+	if len(args) == 2 {
+		if digits, errValue = sheetNumber(args[1]); errValue != nil {
+			return errValue
+		}
+	}
+	scale := math.Pow(10, math.Trunc(digits))
+	return math.Round(number*scale) / scale
+}
+
+func sheetAbs(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	number, errValue := sheetNumber(args[0])
+	if errValue != nil {
+		return errValue
+	}
+	return math.Abs(number)
+}
+
+func sheetLen(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	return float64(utf8.RuneCountInString(sheetText(args[0])))
+}
+
+func sheetTextFunc(fn func(string) string) func(*sheetEvaluator, []sheetValue) sheetValue {
+	return func(_ *sheetEvaluator, args []sheetValue) sheetValue {
+		return fn(sheetText(args[0]))
+	}
+}
+
+// sheetCharCount reads an optional character count argument, defaulting to one.
+func sheetCharCount(args []sheetValue, index int) (int, sheetValue) {
+	if len(args) <= index {
+		return 1, nil
+	}
+	number, errValue := sheetNumber(args[index])
+	if errValue != nil {
+		return 0, errValue
+	}
+	if number < 0 || math.IsNaN(number) {
+		return 0, sheetError(sheetErrValue)
+	}
+	return int(min(number, math.MaxInt32)), nil
+}
+
+func sheetLeft(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	count, errValue := sheetCharCount(args, 1)
+	if errValue != nil {
+		return errValue
+	}
+	runes := []rune(sheetText(args[0]))
+	return string(runes[:min(count, len(runes))])
+}
+
+func sheetRight(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	count, errValue := sheetCharCount(args, 1)
+	if errValue != nil {
+		return errValue
+	}
+	runes := []rune(sheetText(args[0]))
+	return string(runes[len(runes)-min(count, len(runes)):])
+}
+
+// sheetMid takes count characters starting at the 1-based position start.
+func sheetMid(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	start, errValue := sheetNumber(args[1])
+	if errValue != nil {
+		return errValue
+	}
+	if start < 1 || math.IsNaN(start) {
+		return sheetError(sheetErrValue)
+	}
+	count, errValue := sheetCharCount(args, 2)
+	if errValue != nil {
+		return errValue
+	}
+	runes := []rune(sheetText(args[0]))
+	from := int(min(start-1, float64(len(runes))))
+	return string(runes[from : from+min(count, len(runes)-from)])
+}
+
+// Dates are YYYY-MM-DD text, as in task due dates.
+
+func sheetToday(e *sheetEvaluator, _ []sheetValue) sheetValue {
+	return e.today.Format(dailyDateLayout)
+}
+
+func sheetDateFunc(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	var parts [3]int
+	for i, arg := range args {
+		number, errValue := sheetNumber(arg)
+		if errValue != nil {
+			return errValue
+		}
+		parts[i] = int(number)
+	}
+	if parts[0] < 1 || parts[0] > 9999 {
+		return sheetError(sheetErrValue)
+	}
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.Local).Format(dailyDateLayout)
+}
+
+func sheetDatePart(part func(time.Time) int) func(*sheetEvaluator, []sheetValue) sheetValue {
+	return func(_ *sheetEvaluator, args []sheetValue) sheetValue {
+		date, errValue := sheetDate(args[0])
+		if errValue != nil {
+			return errValue
+		}
+		return float64(part(date))
+	}
+}
+
+// sheetDays counts the days from the second date to the first.
+func sheetDays(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	end, errValue := sheetDate(args[0])
+	if errValue != nil {
+		return errValue
+	}
+	start, errValue := sheetDate(args[1])
+	if errValue != nil {
+		return errValue
+	}
+	return math.Round(end.Sub(start).Hours() / 24)
+}
+
+// sheetEDate moves a date by whole months, clamping to the end of a shorter
+// month.
+func sheetEDate(_ *sheetEvaluator, args []sheetValue) sheetValue {
+	date, errValue := sheetDate(args[0])
+	if errValue != nil {
+		return errValue
+	}
+	months, errValue := sheetNumber(args[1])
+	if errValue != nil {
+		return errValue
+	}
+	first := time.Date(date.Year(), date.Month()+time.Month(months), 1, 0, 0, 0, 0, time.Local)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(date.Day(), lastDay), 0, 0, 0, 0, time.Local).Format(dailyDateLayout)
+}
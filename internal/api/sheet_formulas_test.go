@@ -0,0 +1,188 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvaluateSheet(t *testing.T) {
+	today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	data := [][]string{
+		{"Item", "Cost", "Due"},
+		{"Rent", "1200", "2026-11-01"},
+		{"Power", "80.5", "2026-10-20"},
+		{"Water", "n/a", ""},
+		{"Total", "=SUM(B2:B4)", "=COUNT(B2:B4)"},
+		{"Average", "=AVG(B2:B3)", "=DAYS(C2,TODAY())"},
+		{"Max", "=MAX(B2:B4)", "=ROUND(B6/7, 2)"},
+		{"Label", `=UPPER(A2)&": "&B2`, `=IF(B5>1000,"over","under")`},
+		{"Lookup", `=VLOOKUP("power",A2:B4,2,FALSE)`, `=VLOOKUP("Gas",A2:B4,2,FALSE)`},
+		{"Cycle", "=C10+1", "=B10*2"},
+		{"Errors", "=1/0", "=NOPE(1)"},
+		{"Syntax", "=SUM(B2", "=A1:B2"},
+		{"Dates", "=EDATE(\"2026-01-31\",1)", "=YEAR(C2)+MONTH(C2)/100"},
+		{"Text", "=MID(\"spreadsheet\",3,4)", "=LEN(LEFT(\"héllo\",2))"},
+		{"Float", "=0.1+0.2", "=-B2%"},
+	}
+
+	values := evaluateSheet(data, today)
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"A1", "Item"},
+		{"B4", "n/a"},
+		{"B5", "1280.5"},
+		{"C5", "2"},
+		{"B6", "640.25"},
+		{"C6", "15"},
+		{"B7", "1200"},
+		{"C7", "91.46"},
+		{"B8", "RENT: 1200"},
+		{"C8", "over"},
+		{"B9", "80.5"},
+		{"C9", "#N/A"},
+		{"B10", "#CYCLE!"},
+		{"C10", "#CYCLE!"},
+		{"B11", "#DIV/0!"},
+		{"C11", "#NAME?"},
+		{"B12", "#ERROR!"},
+		{"C12", "#VALUE!"},
+		{"B13", "2026-02-28"},
+		{"C13", "2026.11"},
+		{"B14", "read"},
+		{"C14", "2"},
+		{"B15", "0.3"},
+		{"C15", "-12"},
+	}
+	for _, tt := range tests {
+		cell, ok := parseSheetCellRef(tt.cell)
+		if !ok {
+			t.Fatalf("invalid cell %s", tt.cell)
+		}
+		if got := values[cell.row][cell.col]; got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+	if got := evaluateSheet([][]string{{"1", "=SUM(A:A)"}, {"2", "=SUM(B:B)"}}, today); got[0][1] != "3" || got[1][1] != "#CYCLE!" {
+		t.Fatalf("unexpected column ranges %q", got)
+	}
+	if data[4][1] != "=SUM(B2:B4)" {
+		t.Fatalf("evaluation changed the raw data")
+	}
+}
+
+func TestSheetVLookupApproximate(t *testing.T) {
+	data := [][]string{
+		{"0", "F"},
+		{"60", "D"},
+		{"70", "C"},
+		{"80", "B"},
+		{"90", "A"},
+		{"=VLOOKUP(85,A1:B5,2)", "=VLOOKUP(-1,A1:B5,2,TRUE)"},
+	}
+	values := evaluateSheet(data, time.Now())
+	if values[5][0] != "B" || values[5][1] != "#N/A" {
+		t.Fatalf("unexpected lookups %q", values[5])
+	}
+}
+
+func TestEvaluateSheetHugeArguments(t *testing.T) {
+	data := [][]string{
+		{"1", "=SUM(A1:A999999999999999)", `=LEFT("abc",1E300)`},
+		{"2", "=COUNT(A1:A100000)", `=RIGHT("abc",1E300*1E300)`},
+		{"x", "=SUM(C5000:ZZZ6000)", `=MID("abc",1E300,1E300)`},
+		{"", "=SUM(A1:ZZZ3)", `=MID("abc",2,"NaN")`},
+	}
+	start := time.Now()
+	values := evaluateSheet(data, time.Now())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("evaluation took %s", elapsed)
+	}
+	want := [][]string{
+		{"1", "3", "abc"},
+		{"2", "2", "abc"},
+		{"x", "0", ""},
+		{"", "8", "#VALUE!"},
+	}
+	for row := range want {
+		for col := range want[row] {
+			if values[row][col] != want[row][col] {
+				t.Errorf("row %d col %d = %q, want %q", row, col, values[row][col], want[row][col])
+			}
+		}
+	}
+}
+
+func TestEvaluateSheetLongChain(t *testing.T) {
+	const rows = 100000
+	forward := make([][]string, rows)
+	backward := make([][]string, rows)
+	forward[0] = []string{"1"}
+	backward[rows-1] = []string{"1"}
+	for row := 1; row < rows; row++ {
+		forward[row] = []string{"=A" + strconv.Itoa(row) + "+1"}
+		backward[rows-1-row] = []string{"=A" + strconv.Itoa(rows-row+1) + "+1"}
+	}
+
+	values := evaluateSheet(forward, time.Now())
+	if got := values[rows-1][0]; got != strconv.Itoa(rows) {
+		t.Fatalf("expected the end of a top-down chain to be %d, got %q", rows, got)
+	}
+
+	values = evaluateSheet(backward, time.Now())
+	for _, row := range []int{0, 1, rows - sheetMaxDepth, rows - 2} {
+		if got := values[row][0]; got != strconv.Itoa(rows-row) {
+			t.Fatalf("row %d of a bottom-up chain: expected %d, got %q", row, rows-row, got)
+		}
+	}
+
+	backward[rows-1] = []string{"=A1+1"}
+	values = evaluateSheet(backward, time.Now())
+	for _, row := range []int{0, rows / 2, rows - 1} {
+		if got := values[row][0]; got != sheetErrCycle {
+			t.Fatalf("row %d of a long cycle: expected %s, got %q", row, sheetErrCycle, got)
+		}
+	}
+}
+
+func TestEvaluateSheetNonFiniteText(t *testing.T) {
+	values := evaluateSheet([][]string{
+		{"Nan", "=SUM(A1:A3)", "=A1", "=A2&\"!\""},
+		{"inf"},
+		{"2"},
+	}, time.Now())
+	if values[0][1] != "2" || values[0][2] != "Nan" || values[0][3] != "inf!" {
+		t.Fatalf("expected NaN and Inf spellings to stay text, got %q", values[0])
+	}
+	for _, text := range []string{"NaN", "-Infinity", "+inf", "0x1p3", "1e999", "nan%"} {
+		if value, ok := literalSheetValue(text).(string); !ok || value != text {
+			t.Errorf("literal %q: expected text, got %#v", text, literalSheetValue(text))
+		}
+	}
+	if settings := inferSheetSettings([][]string{{"Nan"}, {"inf"}}); settings.Columns[0].Type != sheetColumnText {
+		t.Fatalf("expected a text column, got %#v", settings.Columns[0])
+	}
+}
+
+func TestParseSheetCellRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want sheetCell
+		ok   bool
+	}{
+		{"A1", sheetCell{0, 0}, true},
+		{"$B$3", sheetCell{2, 1}, true},
+		{"aa10", sheetCell{9, 26}, true},
+		{"A0", sheetCell{}, false},
+		{"1A", sheetCell{}, false},
+		{"TRUE", sheetCell{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSheetCellRef(tt.ref)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSheetCellRef(%q) = %v, %v", tt.ref, got, ok)
+		}
+	}
+}
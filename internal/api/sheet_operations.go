@@ -0,0 +1,239 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sheet operations, applied in order by POST /sheets/operations.
+const (
+	sheetOpSetCell      = "set-cell"
+	sheetOpInsertRow    = "insert-row"
+	sheetOpDeleteRow    = "delete-row"
+	sheetOpInsertColumn = "insert-column"
+	sheetOpDeleteColumn = "delete-column"
+	sheetOpAppendRows   = "append-rows"
+	sheetOpMoveRow      = "move-row"
+)
+
+var errSheetTooLarge = errors.New("sheet would be too large")
+
+// SheetOperation is one change to a sheet. Row, Column and To are 0-based;
+// Count defaults to 1 for inserts and deletes.
+type SheetOperation struct {
+	Op     string     `json:"op"`
+	Row    *int       `json:"row,omitempty"`
+	Column *int       `json:"column,omitempty"`
+	Value  string     `json:"value,omitempty"`
+	Count  int        `json:"count,omitempty"`
+	To     *int       `json:"to,omitempty"`
+	Rows   [][]string `json:"rows,omitempty"`
+}
+
+type SheetOperationsPayload struct {
+	Path       string           `json:"path"`
+	Operations []SheetOperation `json:"operations"`
+}
+
+type SheetOperationsResponse struct {
+	Path    string `json:"path"`
+	ETag    string `json:"etag"`
+	Rows    int    `json:"rows"`
+	Columns int    `json:"columns"`
+}
+
+// applySheetOperations returns the sheet with every operation applied, or
+// the first operation's error; the sheet passed in is left unchanged.
+// Formulas are kept as written, so references are not shifted by inserts
+// and deletes.
+func applySheetOperations(sheet sheetFile, ops []SheetOperation) (sheetFile, error) {
+	grid := sheetGrid{
+		data:     make([][]string, len(sheet.Data)),
+		settings: sheet.SheetSettings,
+	}
+	for i, row := range sheet.Data {
+		grid.data[i] = slices.Clone(row)
+		grid.width = max(grid.width, len(row))
+	}
+	grid.settings.Columns = slices.Clone(sheet.Columns)
+	if sheet.Sort != nil {
+		sort := *sheet.Sort
+		grid.settings.Sort = &sort
+	}
+
+	for i, op := range ops {
+		if err := grid.apply(op); err != nil {
+			name := strings.TrimSpace(op.Op)
+			if name == "" {
+				return sheetFile{}, fmt.Errorf("operation %d: %w", i+1, err)
+			}
+			return sheetFile{}, fmt.Errorf("operation %d (%s): %w", i+1, name, err)
+		}
+	}
+	sheet.Data = grid.data
+	sheet.SheetSettings = grid.settings
+	return sheet, nil
+}
+
+// sheetGrid is a rectangular copy of a sheet's cells being edited.
+type sheetGrid struct {
+	data     [][]string
+	width    int
+	settings SheetSettings
+}
+
+func (g *sheetGrid) apply(op SheetOperation) error {
+	switch strings.ToLower(strings.TrimSpace(op.Op)) {
+	case sheetOpSetCell:
+		if op.Row == nil || op.Column == nil {
+			return errors.New("row and column are required")
+		}
+		row, col := *op.Row, *op.Column
+		if row < 0 || col < 0 {
+			return errors.New("row and column must be >= 0")
+		}
+		if row >= workbookMaxRows || col >= workbookMaxColumns {
+			return errSheetTooLarge
+		}
+		if err := g.grow(max(len(g.data), row+1), max(g.width, col+1)); err != nil {
+			return err
+		}
+		g.data[row][col] = op.Value
+	case sheetOpInsertRow:
+		row, count, err := g.span(op.Row, op.Count, "row", len(g.data)+1, workbookMaxRows)
+		if err != nil {
+			return err
+		}
+		if err := g.grow(len(g.data)+count, g.width); err != nil {
+			return err
+		}
+		// grow appended the new rows; rotate them into place.
+		inserted := slices.Clone(g.data[len(g.data)-count:])
+		copy(g.data[row+count:], g.data[row:len(g.data)-count])
+		copy(g.data[row:], inserted)
+	case sheetOpDeleteRow:
+		row, count, err := g.span(op.Row, op.Count, "row", len(g.data), workbookMaxRows)
+		if err != nil {
+			return err
+		}
+		if row+count > len(g.data) {
+			return fmt.Errorf("rows %d-%d are out of range", row, row+count-1)
+		}
+		g.data = slices.Delete(g.data, row, row+count)
+	case sheetOpInsertColumn:
+		col, count, err := g.span(op.Column, op.Count, "column", g.width+1, workbookMaxColumns)
+		if err != nil {
+			return err
+		}
+		if err := g.grow(len(g.data), g.width+count); err != nil {
+			return err
+		}
+		for i, cells := range g.data {
+			g.data[i] = slices.Insert(cells[:len(cells)-count], col, make([]string, count)...)
+		}
+		if col < len(g.settings.Columns) {
+			g.settings.Columns = slices.Insert(g.settings.Columns, col, make([]SheetColumn, count)...)
+		}
+		if sort := g.settings.Sort; sort != nil && sort.Column >= col {
+			sort.Column += count
+		}
+	case sheetOpDeleteColumn:
+		col, count, err := g.span(op.Column, op.Count, "column", g.width, workbookMaxColumns)
+		if err != nil {
+			return err
+		}
+		if col+count > g.width {
+			return fmt.Errorf("columns %s-%s are out of range", sheetColumnName(col), sheetColumnName(col+count-1))
+		}
+		for i, cells := range g.data {
+			g.data[i] = slices.Delete(cells, col, col+count)
+		}
+		g.width -= count
+		if col < len(g.settings.Columns) {
+			g.settings.Columns = slices.Delete(g.settings.Columns, col, min(col+count, len(g.settings.Columns)))
+		}
+		if sort := g.settings.Sort; sort != nil {
+			switch {
+			case sort.Column >= col+count:
+				sort.Column -= count
+			case sort.Column >= col:
+				g.settings.Sort = nil
+			}
+		}
+	case sheetOpAppendRows:
+		if len(op.Rows) == 0 {
+			return errors.New("rows are required")
+		}
+		start := len(g.data)
+		width := g.width
+		for _, cells := range op.Rows {
+			width = max(width, len(cells))
+		}
+		if err := g.grow(start+len(op.Rows), width); err != nil {
+			return err
+		}
+		for i, cells := range op.Rows {
+			copy(g.data[start+i], cells)
+		}
+	case sheetOpMoveRow:
+		if op.Row == nil || op.To == nil {
+			return errors.New("row and to are required")
+		}
+		from, to := *op.Row, *op.To
+		if from < 0 || from >= len(g.data) {
+			return fmt.Errorf("row %d is out of range", from)
+		}
+		if to < 0 || to >= len(g.data) {
+			return fmt.Errorf("to %d is out of range", to)
+		}
+		moved := g.data[from]
+		g.data = slices.Insert(slices.Delete(g.data, from, from+1), to, moved)
+	case "":
+		return errors.New("op is required")
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+// span checks the start and count of a row or column insert or delete, where
+// start must be below limit and count at most maxCount, so that neither can
+// overflow the arithmetic that follows.
+func (g *sheetGrid) span(start *int, count int, name string, limit, maxCount int) (int, int, error) {
+	if start == nil {
+		return 0, 0, fmt.Errorf("%s is required", name)
+	}
+	if *start < 0 || *start >= limit {
+		return 0, 0, fmt.Errorf("%s %d is out of range", name, *start)
+	}
+	if count < 0 {
+		return 0, 0, errors.New("count must be >= 0")
+	}
+	if count > maxCount {
+		return 0, 0, errSheetTooLarge
+	}
+	if count == 0 {
+		count = 1
+	}
+	return *start, count, nil
+}
+
+// grow pads the grid to at least rows by width cells, within the limits of
+// an imported worksheet.
+func (g *sheetGrid) grow(rows, width int) error {
+	if rows > workbookMaxRows || width > workbookMaxColumns || rows*width > workbookMaxCells {
+		return errSheetTooLarge
+	}
+	if width > g.width {
+		for i, cells := range g.data {
+			g.data[i] = append(cells, make([]string, width-g.width)...)
+		}
+		g.width = width
+	}
+	for len(g.data) < rows {
+		g.data = append(g.data, make([]string, g.width))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplySheetOperations(t *testing.T) {
+	at := func(n int) *int { return &n }
+	sheet := sheetFile{
+		SheetSettings: SheetSettings{
+			FrozenHeader: true,
+			Columns: []SheetColumn{
+				{Name: "Item", Type: sheetColumnText},
+				{Name: "Cost", Type: sheetColumnNumber},
+				{Name: "Due", Type: sheetColumnDate},
+			},
+			Sort: &SheetSort{Column: 2},
+		},
+		Data: [][]string{
+			{"Item", "Cost", "Due"},
+			{"Rent", "1200", "2026-11-01"},
+			{"Power", "80", "2026-10-20"},
+		},
+	}
+
+	got, err := applySheetOperations(sheet, []SheetOperation{
+		{Op: "set-cell", Row: at(2), Column: at(1), Value: "85"},
+		{Op: "append-rows", Rows: [][]string{{"Water", "30"}, {"Phone", "45", "2026-10-25", "monthly"}}},
+		{Op: "insert-column", Column: at(1)},
+		{Op: "delete-row", Row: at(1)},
+		{Op: "move-row", Row: at(3), To: at(1)},
+		{Op: "insert-row", Row: at(4), Count: 2},
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := [][]string{
+		{"Item", "", "Cost", "Due", ""},
+		{"Phone", "", "45", "2026-10-25", "monthly"},
+		{"Power", "", "85", "2026-10-20", ""},
+		{"Water", "", "30", "", ""},
+		{"", "", "", "", ""},
+		{"", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("unexpected data\n got %q\nwant %q", got.Data, want)
+	}
+	if len(got.Columns) != 4 || !reflect.DeepEqual(got.Columns[1], SheetColumn{}) || got.Columns[2].Name != "Cost" || got.Sort.Column != 3 {
+		t.Fatalf("unexpected settings %#v", got.SheetSettings)
+	}
+	if sheet.Data[2][1] != "80" || len(sheet.Columns) != 3 || sheet.Sort.Column != 2 {
+		t.Fatalf("the original sheet was changed")
+	}
+
+	got, err = applySheetOperations(sheet, []SheetOperation{{Op: "delete-column", Column: at(1), Count: 2}})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, [][]string{{"Item"}, {"Rent"}, {"Power"}}) || len(got.Columns) != 1 || got.Sort != nil {
+		t.Fatalf("unexpected sheet %#v", got)
+	}
+}
+
+func TestApplySheetOperationsErrors(t *testing.T) {
+	at := func(n int) *int { return &n }
+	sheet := sheetFile{Data: [][]string{{"a", "b"}, {"c", "d"}}}
+	tests := []struct {
+		ops  []SheetOperation
+		want string
+	}{
+		{[]SheetOperation{{Op: "set-cell", Row: at(0)}}, "operation 1 (set-cell): row and column are required"},
+		{[]SheetOperation{{Op: "append-rows", Rows: [][]string{{"e"}}}, {Op: "delete-row", Row: at(2), Count: 2}}, "operation 2 (delete-row): rows 2-3 are out of range"},
+		{[]SheetOperation{{Op: "delete-column", Column: at(2)}}, "column 2 is out of range"},
+		{[]SheetOperation{{Op: "insert-row", Row: at(3)}}, "row 3 is out of range"},
+		{[]SheetOperation{{Op: "move-row", Row: at(0), To: at(5)}}, "to 5 is out of range"},
+		{[]SheetOperation{{Op: "append-rows"}}, "rows are required"},
+		{[]SheetOperation{{Op: "set-cell", Row: at(1), Column: at(1 << 20)}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "set-cell", Row: at(math.MaxInt), Column: at(0)}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "set-cell", Row: at(0), Column: at(math.MaxInt)}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "insert-row", Row: at(1), Count: math.MaxInt}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "insert-column", Column: at(1), Count: math.MaxInt}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "delete-row", Row: at(1), Count: math.MaxInt}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "delete-column", Column: at(1), Count: math.MaxInt}}, "sheet would be too large"},
+		{[]SheetOperation{{Op: "insert-row", Row: at(math.MaxInt)}}, "is out of range"},
+		{[]SheetOperation{{Op: "merge"}}, `unknown op "merge"`},
+		{[]SheetOperation{{}}, "operation 1: op is required"},
+	}
+	for _, tt := range tests {
+		_, err := applySheetOperations(sheet, tt.ops)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected %q, got %v", tt.want, err)
+		}
+	}
+}
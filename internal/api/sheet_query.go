@@ -0,0 +1,521 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SheetQueryResponse is the result of a sheet query. Rows hold numbers and
+// booleans for number and bool columns, strings otherwise, and null for empty
+// cells. SourceRows are the 0-based sheet rows the results came from, for
+// queries without groupBy or aggregate.
+type SheetQueryResponse struct {
+	Path       string   `json:"path"`
+	Columns    []string `json:"columns"`
+	Rows       [][]any  `json:"rows"`
+	SourceRows []int    `json:"sourceRows,omitempty"`
+	Total      int      `json:"total"`
+}
+
+// sheetQuery selects, filters, groups and sorts the rows of a sheet whose
+// first row names its columns. A row must match every condition.
+type sheetQuery struct {
+	Select     []string
+	Where      []sheetCondition
+	GroupBy    []string
+	Aggregates []sheetAggregate
+	Sort       []string
+	Limit      int
+}
+
+type sheetCondition struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+type sheetAggregate struct {
+	Func   string
+	Column string
+}
+
+// sheetConditionOps are the where operators, longest first so that "<=" is
+// not read as "<". "~" matches text containing the value.
+var sheetConditionOps = []string{"!=", "!~", "<=", ">=", "=", "<", ">", "~"}
+
+var sheetAggregateFuncs = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+// sheetQueryFromValues reads a query from URL parameters: repeated where
+// conditions such as "Amount > 500", and select, groupBy, aggregate and sort
+// lists, which may also be comma-separated. Sort keys take a "-" prefix to
+// sort descending.
+func sheetQueryFromValues(values url.Values) (sheetQuery, error) {
+	query := sheetQuery{
+		Select:  queryList(values, "select"),
+		GroupBy: queryList(values, "groupBy"),
+		Sort:    queryList(values, "sort"),
+	}
+	for _, raw := range values["where"] {
+		condition, err := parseSheetCondition(raw)
+		if err != nil {
+			return sheetQuery{}, err
+		}
+		query.Where = append(query.Where, condition)
+	}
+	for _, raw := range queryList(values, "aggregate") {
+		aggregate, err := parseSheetAggregate(raw)
+		if err != nil {
+			return sheetQuery{}, err
+		}
+		query.Aggregates = append(query.Aggregates, aggregate)
+	}
+	if raw := strings.TrimSpace(values.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return sheetQuery{}, errors.New("invalid limit")
+		}
+		query.Limit = limit
+	}
+	if len(query.Select) > 0 && query.grouped() {
+		return sheetQuery{}, errors.New("select cannot be combined with groupBy or aggregate")
+	}
+	return query, nil
+}
+
+func parseSheetCondition(raw string) (sheetCondition, error) {
+	index := strings.IndexAny(raw, "=!<>~")
+	if index <= 0 || strings.TrimSpace(raw[:index]) == "" {
+		return sheetCondition{}, fmt.Errorf("invalid where %q", raw)
+	}
+	for _, op := range sheetConditionOps {
+		if !strings.HasPrefix(raw[index:], op) {
+			continue
+		}
+		value := strings.TrimSpace(raw[index+len(op):])
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		return sheetCondition{Column: strings.TrimSpace(raw[:index]), Op: op, Value: value}, nil
+	}
+	return sheetCondition{}, fmt.Errorf("invalid where %q", raw)
+}
+
+// parseSheetAggregate reads "count" or "fn(Column)".
+func parseSheetAggregate(raw string) (sheetAggregate, error) {
+	name, column, hasColumn := strings.Cut(raw, "(")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if hasColumn {
+		var ok bool
+		column, ok = strings.CutSuffix(strings.TrimSpace(column), ")")
+		column = strings.TrimSpace(column)
+		if !ok || column == "" {
+			return sheetAggregate{}, fmt.Errorf("invalid aggregate %q", raw)
+		}
+	}
+	if !sheetAggregateFuncs[name] {
+		return sheetAggregate{}, fmt.Errorf("unknown aggregate %q", raw)
+	}
+	if column == "" && name != "count" {
+		return sheetAggregate{}, fmt.Errorf("aggregate %s needs a column", name)
+	}
+	return sheetAggregate{Func: name, Column: column}, nil
+}
+
+func (a sheetAggregate) name() string {
+	if a.Column == "" {
+		return a.Func
+	}
+	return a.Func + "(" + a.Column + ")"
+}
+
+func (q sheetQuery) grouped() bool {
+	return len(q.GroupBy) > 0 || len(q.Aggregates) > 0
+}
+
+// run answers the query from the sheet's values, with formulas evaluated.
+func (q sheetQuery) run(sheet sheetFile, today time.Time) (SheetQueryResponse, error) {
+	values := evaluateSheet(sheet.Data, today)
+	table := sheetTable{columns: sheet.Columns}
+	if len(values) > 0 {
+		table.header = values[0]
+		table.rows = values[1:]
+	}
+
+	matched := make([]int, 0, len(table.rows))
+	conditions := make([]int, len(q.Where))
+	for i, condition := range q.Where {
+		col, err := table.column(condition.Column)
+		if err != nil {
+			return SheetQueryResponse{}, err
+		}
+		conditions[i] = col
+	}
+	for row, cells := range table.rows {
+		match := true
+		for i, condition := range q.Where {
+			if !condition.matches(cells[conditions[i]]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			matched = append(matched, row)
+		}
+	}
+
+	if q.grouped() {
+		return q.runGrouped(table, matched)
+	}
+
+	selected := make([]int, 0, len(q.Select))
+	for _, name := range q.Select {
+		col, err := table.column(name)
+		if err != nil {
+			return SheetQueryResponse{}, err
+		}
+		selected = append(selected, col)
+	}
+	if len(q.Select) == 0 {
+		for col := range table.header {
+			selected = append(selected, col)
+		}
+	}
+	keys, err := sheetSortKeys(q.Sort, table.column)
+	if err != nil {
+		return SheetQueryResponse{}, err
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := table.rows[matched[i]], table.rows[matched[j]]
+		for _, key := range keys {
+			if cmp := compareQueryResults(table.value(a, key.index), table.value(b, key.index), key.descending); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	resp := SheetQueryResponse{
+		Columns:    make([]string, len(selected)),
+		Rows:       [][]any{},
+		SourceRows: []int{},
+		Total:      len(matched),
+	}
+	for i, col := range selected {
+		resp.Columns[i] = table.name(col)
+	}
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	for _, row := range matched {
+		cells := make([]any, len(selected))
+		for i, col := range selected {
+			cells[i] = table.value(table.rows[row], col)
+		}
+		resp.Rows = append(resp.Rows, cells)
+		resp.SourceRows = append(resp.SourceRows, row+1)
+	}
+	return resp, nil
+}
+
+// runGrouped returns one row per distinct groupBy value, ordered by those
+// values unless sorted otherwise, or a single row without groupBy.
+func (q sheetQuery) runGrouped(table sheetTable, matched []int) (SheetQueryResponse, error) {
+	groupCols := make([]int, len(q.GroupBy))
+	resp := SheetQueryResponse{Rows: [][]any{}}
+	for i, name := range q.GroupBy {
+		col, err := table.column(name)
+		if err != nil {
+			return SheetQueryResponse{}, err
+		}
+		groupCols[i] = col
+		resp.Columns = append(resp.Columns, table.name(col))
+	}
+	aggregateCols := make([]int, len(q.Aggregates))
+	for i, aggregate := range q.Aggregates {
+		aggregateCols[i] = -1
+		if aggregate.Column != "" {
+			col, err := table.column(aggregate.Column)
+			if err != nil {
+				return SheetQueryResponse{}, err
+			}
+			aggregateCols[i] = col
+		}
+		resp.Columns = append(resp.Columns, aggregate.name())
+	}
+
+	var order []string
+	groups := make(map[string][]int)
+	for _, row := range matched {
+		parts := make([]string, len(groupCols))
+		for i, col := range groupCols {
+			parts[i] = table.rows[row][col]
+		}
+		key := strings.Join(parts, "\x00")
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	if len(groupCols) == 0 && len(order) == 0 {
+		order = append(order, "")
+	}
+
+	for _, key := range order {
+		rows := groups[key]
+		cells := make([]any, 0, len(resp.Columns))
+		for _, col := range groupCols {
+			cells = append(cells, table.value(table.rows[rows[0]], col))
+		}
+		for i, aggregate := range q.Aggregates {
+			cells = append(cells, table.aggregate(aggregate.Func, aggregateCols[i], rows))
+		}
+		resp.Rows = append(resp.Rows, cells)
+	}
+
+	sortNames := q.Sort
+	if len(sortNames) == 0 {
+		sortNames = resp.Columns[:len(groupCols)]
+	}
+	keys, err := sheetSortKeys(sortNames, func(name string) (int, error) {
+		for i, column := range resp.Columns {
+			if strings.EqualFold(column, name) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown result column %q", name)
+	})
+	if err != nil {
+		return SheetQueryResponse{}, err
+	}
+	sort.SliceStable(resp.Rows, func(i, j int) bool {
+		for _, key := range keys {
+			if cmp := compareQueryResults(resp.Rows[i][key.index], resp.Rows[j][key.index], key.descending); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	resp.Total = len(resp.Rows)
+	if q.Limit > 0 && len(resp.Rows) > q.Limit {
+		resp.Rows = resp.Rows[:q.Limit]
+	}
+	return resp, nil
+}
+
+func (c sheetCondition) matches(value string) bool {
+	value = strings.TrimSpace(value)
+	switch c.Op {
+	case "~":
+		return c.Value != "" && strings.Contains(strings.ToLower(value), strings.ToLower(c.Value))
+	case "!~":
+		return c.Value == "" || !strings.Contains(strings.ToLower(value), strings.ToLower(c.Value))
+	case "=":
+		return compareSheetText(value, c.Value) == 0
+	case "!=":
+		return compareSheetText(value, c.Value) != 0
+	}
+	// Ordering comparisons never match empty cells.
+	if value == "" || c.Value == "" {
+		return false
+	}
+	cmp := compareSheetText(value, c.Value)
+	switch c.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// sheetTable is the header and body of a sheet's evaluated values.
+type sheetTable struct {
+	header  []string
+	rows    [][]string
+	columns []SheetColumn
+}
+
+// column finds a column by its header, ignoring case, or by its letter.
+func (t sheetTable) column(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	for col, header := range t.header {
+		if strings.EqualFold(strings.TrimSpace(header), name) {
+			return col, nil
+		}
+	}
+	if col, ok := parseSheetColumn(strings.ToUpper(name)); ok && col < len(t.header) {
+		return col, nil
+	}
+	return 0, fmt.Errorf("unknown column %q", name)
+}
+
+// name is a column's header, or its letter when the header is empty.
+func (t sheetTable) name(col int) string {
+	if header := strings.TrimSpace(t.header[col]); header != "" {
+		return header
+	}
+	return sheetColumnName(col)
+}
+
+// value types a cell by its column: a number or boolean for number and bool
+// columns, nil when empty and the text otherwise.
+func (t sheetTable) value(cells []string, col int) any {
+	value := strings.TrimSpace(cells[col])
+	if value == "" {
+		return nil
+	}
+	if col >= len(t.columns) {
+		return value
+	}
+	switch t.columns[col].Type {
+	case sheetColumnNumber:
+		if number, ok := literalSheetValue(value).(float64); ok {
+			return queryNumber(number)
+		}
+	case sheetColumnBool:
+		if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+			return strings.EqualFold(value, "true")
+		}
+	}
+	return value
+}
+
+// aggregate computes fn over the given rows of col; count without a column
+// (col < 0) counts rows. Sum and avg skip cells that are not numbers, and avg,
+// min and max are nil when no cell qualifies.
+func (t sheetTable) aggregate(fn string, col int, rows []int) any {
+	if fn == "count" {
+		if col < 0 {
+			return len(rows)
+		}
+		count := 0
+		for _, row := range rows {
+			if strings.TrimSpace(t.rows[row][col]) != "" {
+				count++
+			}
+		}
+		return count
+	}
+	if fn == "min" || fn == "max" {
+		var best any
+		for _, row := range rows {
+			value := t.value(t.rows[row], col)
+			if value == nil {
+				continue
+			}
+			cmp := compareQueryResults(value, best, false)
+			if best == nil || (fn == "min" && cmp < 0) || (fn == "max" && cmp > 0) {
+				best = value
+			}
+		}
+		return best
+	}
+	sum := 0.0
+	count := 0
+	for _, row := range rows {
+		if number, ok := literalSheetValue(t.rows[row][col]).(float64); ok {
+			sum += number
+			count++
+		}
+	}
+	if fn == "avg" {
+		if count == 0 {
+			return nil
+		}
+		return queryNumber(sum / float64(count))
+	}
+	return queryNumber(sum)
+}
+
+type sheetSortKey struct {
+	index      int
+	descending bool
+}
+
+func sheetSortKeys(names []string, resolve func(string) (int, error)) ([]sheetSortKey, error) {
+	keys := make([]sheetSortKey, 0, len(names))
+	for _, raw := range names {
+		name, descending := strings.CutPrefix(strings.TrimSpace(raw), "-")
+		index, err := resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, sheetSortKey{index: index, descending: descending})
+	}
+	return keys, nil
+}
+
+// compareQueryResults orders typed query values. Empty values sort last in
+// either direction.
+func compareQueryResults(a, b any, descending bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	cmp := compareSheetText(queryText(a), queryText(b))
+	if descending {
+		return -cmp
+	}
+	return cmp
+}
+
+// compareSheetText compares two cells as numbers when both are numbers, and
+// as text ignoring case otherwise. Dates compare correctly as text.
+func compareSheetText(a, b string) int {
+	x, xNumber := literalSheetValue(a).(float64)
+	y, yNumber := literalSheetValue(b).(float64)
+	if xNumber && yNumber {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b)))
+}
+
+func queryText(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// queryNumber rounds a result as formulas display it. JSON cannot hold
+// infinities, so those become nil.
+func queryNumber(value float64) any {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return nil
+	}
+	if rounded := math.Round(value*1e10) / 1e10; !math.IsInf(rounded, 0) {
+		value = rounded
+	}
+	if value == 0 {
+		return 0.0 // drop a negative zero
+	}
+	return value
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testInvoices() sheetFile {
+	return sheetFile{
+		SheetSettings: SheetSettings{
+			FrozenHeader: true,
+			Columns: []SheetColumn{
+				{Name: "Client", Type: sheetColumnText},
+				{Name: "Amount", Type: sheetColumnNumber},
+				{Name: "Status", Type: sheetColumnSelect, Options: []string{"open", "paid"}},
+				{Name: "Due", Type: sheetColumnDate},
+				{Name: "Sent", Type: sheetColumnBool},
+			},
+		},
+		Data: [][]string{
+			{"Client", "Amount", "Status", "Due", "Sent"},
+			{"Acme", "1200", "open", "2026-11-01", "true"},
+			{"Globex", "80", "paid", "2026-10-01", "true"},
+			{"acme", "650.5", "open", "2026-10-20", "false"},
+			{"Initech", "=B2/2", "open", "", ""},
+			{"Globex", "45", "open", "2026-12-01", "FALSE"},
+		},
+	}
+}
+
+func runSheetQuery(t *testing.T, raw string) SheetQueryResponse {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	query, err := sheetQueryFromValues(values)
+	if err != nil {
+		t.Fatalf("query %q: %v", raw, err)
+	}
+	resp, err := query.run(testInvoices(), time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("run %q: %v", raw, err)
+	}
+	return resp
+}
+
+func TestSheetQueryRows(t *testing.T) {
+	resp := runSheetQuery(t, "where=Status%3Dopen&where=Amount+>+500&select=Client,Amount,Sent&sort=-amount")
+	want := [][]any{{"Acme", 1200.0, true}, {"acme", 650.5, false}, {"Initech", 600.0, nil}}
+	if !reflect.DeepEqual(resp.Columns, []string{"Client", "Amount", "Sent"}) || !reflect.DeepEqual(resp.Rows, want) {
+		t.Fatalf("unexpected result %#v", resp)
+	}
+	if !reflect.DeepEqual(resp.SourceRows, []int{1, 3, 4}) || resp.Total != 3 {
+		t.Fatalf("unexpected source rows %v, total %d", resp.SourceRows, resp.Total)
+	}
+
+	resp = runSheetQuery(t, "where=Due<2026-11-01&where=client~ac&sort=Due&limit=1")
+	if resp.Total != 1 || len(resp.Rows) != 1 || resp.Rows[0][0] != "acme" || len(resp.Columns) != 5 {
+		t.Fatalf("unexpected result %#v", resp)
+	}
+
+	resp = runSheetQuery(t, `where=Due="" &select=A`)
+	if !reflect.DeepEqual(resp.Rows, [][]any{{"Initech"}}) {
+		t.Fatalf("unexpected result %#v", resp)
+	}
+}
+
+func TestSheetQueryAggregates(t *testing.T) {
+	resp := runSheetQuery(t, "groupBy=Status&aggregate=count,sum(Amount),avg(Amount),max(Due)&sort=-count")
+	want := [][]any{
+		{"open", 4, 2495.5, 623.875, "2026-12-01"},
+		{"paid", 1, 80.0, 80.0, "2026-10-01"},
+	}
+	if !reflect.DeepEqual(resp.Columns, []string{"Status", "count", "sum(Amount)", "avg(Amount)", "max(Due)"}) || !reflect.DeepEqual(resp.Rows, want) {
+		t.Fatalf("unexpected result %#v", resp)
+	}
+
+	resp = runSheetQuery(t, "groupBy=Client")
+	if !reflect.DeepEqual(resp.Rows, [][]any{{"Acme"}, {"acme"}, {"Globex"}, {"Initech"}}) || resp.Total != 4 {
+		t.Fatalf("expected groups in client order, got %#v", resp.Rows)
+	}
+
+	resp = runSheetQuery(t, "where=Status=void&aggregate=count,sum(Amount),min(Amount)")
+	if !reflect.DeepEqual(resp.Rows, [][]any{{0, 0.0, nil}}) {
+		t.Fatalf("unexpected empty aggregate %#v", resp.Rows)
+	}
+}
+
+func TestSheetQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"where=Amount", "invalid where"},
+		{"where=%3D5", "invalid where"},
+		{"aggregate=median(Amount)", "unknown aggregate"},
+		{"aggregate=sum", "aggregate sum needs a column"},
+		{"limit=0", "invalid limit"},
+		{"select=Client&groupBy=Status", "select cannot be combined"},
+		{"where=Owner%3Dme", `unknown column "Owner"`},
+		{"groupBy=Status&sort=Amount", `unknown result column "Amount"`},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		query, err := sheetQueryFromValues(values)
+		if err == nil {
+			_, err = query.run(testInvoices(), time.Now())
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q, got %v", tt.query, tt.want, err)
+		}
+	}
+}
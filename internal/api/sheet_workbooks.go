@@ -0,0 +1,1010 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on imported workbooks, which are zip files and can expand far beyond
+// their upload size.
+// The part size and cell limits apply to each worksheet; the read size,
+// worksheet count and total cells bound the work done for a whole workbook,
+// whose worksheets may all point at the same large part.
+const (
+	workbookMaxPartSize   = 32 << 20
+	workbookMaxReadSize   = 4 * workbookMaxPartSize
+	workbookMaxRows       = 100000
+	workbookMaxColumns    = 16384
+	workbookMaxCells      = 1000000
+	workbookMaxTotalCells = 4 * workbookMaxCells
+	workbookMaxSheets     = 256
+)
+
+var (
+	errWorkbookFormat   = errors.New("unsupported file: expected .xlsx or .ods")
+	errWorkbookTooLarge = errors.New("worksheet is too large")
+)
+
+// workbookCell is an imported cell as sheet text. Type is the column type
+// its source cell had, or empty for empty cells and formulas.
+type workbookCell struct {
+	Value string
+	Type  string
+}
+
+// workbookSheet is an imported worksheet. A sheet with discard set only
+// measures its cells: rows, width and count are kept, Cells stays empty.
+type workbookSheet struct {
+	Name    string
+	Cells   [][]workbookCell
+	count   int
+	rows    int
+	width   int
+	discard bool
+}
+
+// set stores a cell, growing the grid as needed. The grid the cell would
+// need is checked against workbookMaxCells before anything is allocated, so
+// a few far-off cells cannot stretch it.
+func (ws *workbookSheet) set(row, col int, cell workbookCell) error {
+	if cell.Value == "" {
+		return nil
+	}
+	if row < 0 || col < 0 {
+		return errors.New("invalid cell position")
+	}
+	if err := ws.reserve(1, row+1, col+1); err != nil {
+		return err
+	}
+	if ws.discard {
+		return nil
+	}
+	for len(ws.Cells) <= row {
+		ws.Cells = append(ws.Cells, nil)
+	}
+	for len(ws.Cells[row]) <= col {
+		ws.Cells[row] = append(ws.Cells[row], workbookCell{})
+	}
+	ws.Cells[row][col] = cell
+	return nil
+}
+
+// reserve accounts for count more cells in a grid of at least rows by width.
+func (ws *workbookSheet) reserve(count, rows, width int) error {
+	if rows > workbookMaxRows || width > workbookMaxColumns {
+		return errWorkbookTooLarge
+	}
+	if ws.count += count; ws.count > workbookMaxCells {
+		return errWorkbookTooLarge
+	}
+	rows, width = max(ws.rows, rows), max(ws.width, width)
+	if rows*width > workbookMaxCells {
+		return errWorkbookTooLarge
+	}
+	ws.rows, ws.width = rows, width
+	return nil
+}
+
+// repeatRow copies row into the times-1 rows below it. cells is the number
+// of cells the row holds.
+func (ws *workbookSheet) repeatRow(row, times, cells int) error {
+	if ws.discard {
+		if times <= 1 || cells == 0 {
+			return nil
+		}
+		if times-1 > workbookMaxCells/cells {
+			return errWorkbookTooLarge
+		}
+		return ws.reserve((times-1)*cells, row+times, 0)
+	}
+	for i := 1; i < times; i++ {
+		for j, value := range ws.Cells[row] {
+			if err := ws.set(row+i, j, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sheet converts an imported worksheet. Column types are inferred as for a
+// CSV import, except that a column holding any cell the workbook stored as
+// text stays text, so codes such as 00123 are not read as numbers.
+func (ws workbookSheet) sheet() (sheetFile, error) {
+	width := 0
+	for _, row := range ws.Cells {
+		width = max(width, len(row))
+	}
+	if width*len(ws.Cells) > workbookMaxCells {
+		return sheetFile{}, errWorkbookTooLarge
+	}
+	data := make([][]string, len(ws.Cells))
+	for i, row := range ws.Cells {
+		data[i] = make([]string, width)
+		for j, cell := range row {
+			data[i][j] = cell.Value
+		}
+	}
+
+	settings := inferSheetSettings(data)
+	for col := range settings.Columns {
+		column := &settings.Columns[col]
+		if column.Type == sheetColumnText {
+			continue
+		}
+		for row, cells := range ws.Cells {
+			if row == 0 && settings.FrozenHeader {
+				continue
+			}
+			if col < len(cells) && cells[col].Type == sheetColumnText {
+				column.Type = sheetColumnText
+				break
+			}
+		}
+	}
+	return sheetFile{SheetSettings: settings, Data: data}, nil
+}
+
+// workbook is an opened .xlsx or .ods file. Worksheets are read one at a
+// time, so an import only parses the worksheet it needs.
+type workbook struct {
+	archive *workbookArchive
+	names   []string
+	xlsx    *xlsxParts
+	ods     []byte
+}
+
+// openWorkbook reads the worksheet names of an .xlsx or .ods file.
+func openWorkbook(data []byte) (*workbook, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errWorkbookFormat
+	}
+	archive := &workbookArchive{files: make(map[string]*zip.File, len(reader.File))}
+	for _, file := range reader.File {
+		archive.files[file.Name] = file
+	}
+	wb := &workbook{archive: archive}
+	switch {
+	case archive.files["xl/workbook.xml"] != nil:
+		if wb.xlsx, err = openXLSX(archive); err != nil {
+			return nil, err
+		}
+		wb.names = wb.xlsx.names
+	case archive.files["content.xml"] != nil:
+		if mimetype, err := archive.part("mimetype"); err == nil &&
+			!strings.HasPrefix(string(mimetype), "application/vnd.oasis.opendocument.spreadsheet") {
+			return nil, errWorkbookFormat
+		}
+		if wb.ods, err = archive.part("content.xml"); err != nil {
+			return nil, err
+		}
+		sheets, err := readODS(wb.ods, -1)
+		if err != nil {
+			return nil, err
+		}
+		for _, sheet := range sheets {
+			wb.names = append(wb.names, sheet.Name)
+		}
+	default:
+		return nil, errWorkbookFormat
+	}
+	if len(wb.names) > workbookMaxSheets {
+		return nil, fmt.Errorf("workbook has more than %d worksheets", workbookMaxSheets)
+	}
+	return wb, nil
+}
+
+// find picks a worksheet by name, ignoring case, or the first one when name
+// is empty.
+func (wb *workbook) find(name string) (int, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, len(wb.names) > 0
+	}
+	for i, candidate := range wb.names {
+		if candidate == name {
+			return i, true
+		}
+	}
+	for i, candidate := range wb.names {
+		if strings.EqualFold(candidate, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// worksheet reads the cells of one worksheet.
+func (wb *workbook) worksheet(index int) (workbookSheet, error) {
+	if wb.ods != nil {
+		sheets, err := readODS(wb.ods, index)
+		if err != nil {
+			return workbookSheet{}, err
+		}
+		return sheets[index], nil
+	}
+	return wb.xlsx.worksheet(wb.archive, index, false)
+}
+
+// dimensions measures every worksheet without keeping its cells.
+func (wb *workbook) dimensions() ([]workbookSheet, error) {
+	if wb.ods != nil {
+		return readODS(wb.ods, -1)
+	}
+	sheets := make([]workbookSheet, 0, len(wb.names))
+	total := 0
+	for i := range wb.names {
+		sheet, err := wb.xlsx.worksheet(wb.archive, i, true)
+		if err != nil {
+			return nil, err
+		}
+		if total += sheet.count; total > workbookMaxTotalCells {
+			return nil, errWorkbookTooLarge
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// workbookArchive reads the parts of a workbook, counting the bytes they
+// expand to against workbookMaxReadSize.
+type workbookArchive struct {
+	files map[string]*zip.File
+	read  int
+}
+
+func (a *workbookArchive) part(name string) ([]byte, error) {
+	file := a.files[name]
+	if file == nil {
+		return nil, fmt.Errorf("workbook is missing %s", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s", name)
+	}
+	defer reader.Close()
+	limit := min(workbookMaxPartSize, workbookMaxReadSize-a.read)
+	data, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s", name)
+	}
+	if len(data) > limit {
+		return nil, errWorkbookTooLarge
+	}
+	a.read += len(data)
+	return data, nil
+}
+
+func (a *workbookArchive) decode(name string, dest any) error {
+	data, err := a.part(name)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("unable to parse %s", name)
+	}
+	return nil
+}
+
+// XLSX (Office Open XML)
+
+const (
+	xlsxMainNS      = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	xlsxRelsNS      = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	xlsxPackageNS   = "http://schemas.openxmlformats.org/package/2006/relationships"
+	xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	// xlsxMaxDateSerial is 10000-01-01 in the 1900 date system.
+	xlsxMaxDateSerial = 2958466
+)
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 string `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a shared or inline string: plain text or rich text runs.
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.Text)
+	for _, run := range t.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxRow struct {
+	Index int        `xml:"r,attr"`
+	Cells []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	Ref     string `xml:"r,attr"`
+	Type    string `xml:"t,attr"`
+	Style   int    `xml:"s,attr"`
+	Formula *struct {
+		Text string `xml:",chardata"`
+	} `xml:"f"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// xlsxParts holds what every worksheet of an .xlsx file is read with.
+type xlsxParts struct {
+	names      []string
+	targets    []string
+	shared     []xlsxText
+	dateStyles map[int]bool
+	epoch      time.Time
+}
+
+func openXLSX(archive *workbookArchive) (*xlsxParts, error) {
+	var workbook xlsxWorkbook
+	if err := archive.decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) > workbookMaxSheets {
+		return nil, fmt.Errorf("workbook has more than %d worksheets", workbookMaxSheets)
+	}
+	var rels xlsxRelationships
+	if err := archive.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+	parts := &xlsxParts{dateStyles: make(map[int]bool)}
+	for _, entry := range workbook.Sheets {
+		target, ok := targets[entry.RID]
+		if !ok {
+			return nil, fmt.Errorf("worksheet %q has no part", entry.Name)
+		}
+		parts.names = append(parts.names, entry.Name)
+		parts.targets = append(parts.targets, target)
+	}
+
+	var shared xlsxSharedStrings
+	if archive.files["xl/sharedStrings.xml"] != nil {
+		if err := archive.decode("xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	parts.shared = shared.Items
+	var styles xlsxStyles
+	if archive.files["xl/styles.xml"] != nil {
+		if err := archive.decode("xl/styles.xml", &styles); err != nil {
+			return nil, err
+		}
+	}
+	formats := make(map[int]string, len(styles.NumFmts))
+	for _, format := range styles.NumFmts {
+		formats[format.ID] = format.Code
+	}
+	for i, xf := range styles.CellXfs {
+		if xlsxIsDateFormat(xf.NumFmtID, formats[xf.NumFmtID]) {
+			parts.dateStyles[i] = true
+		}
+	}
+	parts.epoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904, _ := strconv.ParseBool(workbook.Properties.Date1904); date1904 {
+		parts.epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return parts, nil
+}
+
+// worksheet streams one worksheet part row by row, so only the cells that
+// are kept take up memory.
+func (x *xlsxParts) worksheet(archive *workbookArchive, index int, discard bool) (workbookSheet, error) {
+	target := x.targets[index]
+	data, err := archive.part(target)
+	if err != nil {
+		return workbookSheet{}, err
+	}
+	sheet := workbookSheet{Name: x.names[index], discard: discard}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	row := -1
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return workbookSheet{}, fmt.Errorf("unable to parse %s", target)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var xlsxRow xlsxRow
+		if err := decoder.DecodeElement(&xlsxRow, &start); err != nil {
+			return workbookSheet{}, fmt.Errorf("unable to parse %s", target)
+		}
+		if xlsxRow.Index > 0 {
+			row = xlsxRow.Index - 1
+		} else {
+			row++
+		}
+		col := -1
+		for _, cell := range xlsxRow.Cells {
+			if ref, ok := parseSheetCellRef(cell.Ref); ok {
+				col = ref.col
+			} else {
+				col++
+			}
+			value := xlsxCellValue(cell, x.shared, x.dateStyles[cell.Style], x.epoch)
+			if err := sheet.set(row, col, value); err != nil {
+				return workbookSheet{}, err
+			}
+		}
+	}
+	return sheet, nil
+}
+
+// xlsxCellValue converts a cell. Formulas are kept when Scoli can evaluate
+// them; others, such as references to other worksheets, keep their cached
+// result.
+func xlsxCellValue(cell xlsxCell, shared []xlsxText, dateStyle bool, epoch time.Time) workbookCell {
+	if cell.Formula != nil {
+		formula := strings.TrimSpace(cell.Formula.Text)
+		if formula != "" && sheetFormulaSupported(formula) {
+			return workbookCell{Value: "=" + formula}
+		}
+	}
+	switch cell.Type {
+	case "s":
+		index, err := strconv.Atoi(strings.TrimSpace(cell.Value))
+		if err != nil || index < 0 || index >= len(shared) {
+			return workbookCell{}
+		}
+		return workbookCell{Value: shared[index].String(), Type: sheetColumnText}
+	case "inlineStr":
+		return workbookCell{Value: cell.Inline.String(), Type: sheetColumnText}
+	case "str":
+		return workbookCell{Value: cell.Value, Type: sheetColumnText}
+	case "b":
+		return workbookCell{Value: formatSheetValue(strings.TrimSpace(cell.Value) == "1"), Type: sheetColumnBool}
+	case "e":
+		return workbookCell{Value: cell.Value}
+	case "d":
+		value := strings.TrimSpace(cell.Value)
+		if date, rest, _ := strings.Cut(value, "T"); strings.Trim(rest, "0:.Z") == "" {
+			return workbookCell{Value: date, Type: sheetColumnDate}
+		}
+		return workbookCell{Value: value, Type: sheetColumnText}
+	}
+	value := strings.TrimSpace(cell.Value)
+	if value == "" {
+		return workbookCell{}
+	}
+	number, ok := parseSheetNumber(value)
+	if !ok {
+		return workbookCell{Value: value, Type: sheetColumnText}
+	}
+	if dateStyle {
+		return serialDateCell(number, epoch)
+	}
+	return workbookCell{Value: formatSheetValue(number), Type: sheetColumnNumber}
+}
+
+// serialDateCell converts a spreadsheet date serial, the days since the
+// epoch. Serials with a time of day become text. Whole days go through
+// AddDate, since a time.Duration only spans about 292 years; serials outside
+// the range Excel can display stay numbers.
+func serialDateCell(serial float64, epoch time.Time) workbookCell {
+	if serial < 0 || serial >= xlsxMaxDateSerial {
+		return workbookCell{Value: formatSheetValue(serial), Type: sheetColumnNumber}
+	}
+	seconds := math.Round(serial * 86400)
+	days := math.Floor(seconds / 86400)
+	date := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds-days*86400) * time.Second)
+	if math.Mod(seconds, 86400) == 0 {
+		return workbookCell{Value: date.Format(dailyDateLayout), Type: sheetColumnDate}
+	}
+	return workbookCell{Value: date.Format("2006-01-02 15:04"), Type: sheetColumnText}
+}
+
+// xlsxIsDateFormat reports whether a number format shows a date: one of the
+// built-in date formats, or a custom code with day or year parts.
+func xlsxIsDateFormat(id int, code string) bool {
+	switch {
+	case id >= 14 && id <= 17, id == 22, id >= 27 && id <= 36, id >= 50 && id <= 58:
+		return true
+	case id < 164:
+		return false
+	}
+	var b strings.Builder
+	quoted := false
+	bracket := false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '[':
+			bracket = true
+		case c == ']':
+			bracket = false
+		case bracket:
+		case c == '\\' || c == '_' || c == '*':
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	stripped := strings.ToLower(b.String())
+	return strings.ContainsAny(stripped, "dy")
+}
+
+// ODS (OpenDocument Spreadsheet)
+
+const (
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// odsRefPattern matches a same-sheet reference such as [.A1] or [.A1:.B3].
+var odsRefPattern = regexp.MustCompile(`\[\.(\$?[A-Za-z]+\$?[0-9]+)(?::\.(\$?[A-Za-z]+\$?[0-9]+))?\]`)
+
+type odsCell struct {
+	valueType string
+	value     string
+	formula   string
+	repeat    int
+	text      strings.Builder
+	paragraph bool
+}
+
+// readODS streams content.xml. Repeated empty rows and cells, which ODS uses
+// to pad to the full sheet size, are skipped rather than expanded. Only the
+// table at index keeps its cells; the others are measured.
+func readODS(data []byte, index int) ([]workbookSheet, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var sheets []workbookSheet
+	total := 0
+	var sheet *workbookSheet
+	var cell *odsCell
+	row, col, rowRepeat := 0, 0, 1
+	rowStart := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("unable to parse content.xml")
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				if len(sheets) == workbookMaxSheets {
+					return nil, fmt.Errorf("workbook has more than %d worksheets", workbookMaxSheets)
+				}
+				sheets = append(sheets, workbookSheet{Name: odsAttr(t, odsTableNS, "name"), discard: len(sheets) != index})
+				sheet = &sheets[len(sheets)-1]
+				row = 0
+			case sheet == nil:
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				col = 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+				rowStart = sheet.count
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				cell = &odsCell{
+					valueType: odsAttr(t, odsOfficeNS, "value-type"),
+					formula:   odsAttr(t, odsTableNS, "formula"),
+					repeat:    odsRepeat(t, "number-columns-repeated"),
+				}
+				switch cell.valueType {
+				case "date":
+					cell.value = odsAttr(t, odsOfficeNS, "date-value")
+				case "boolean":
+					cell.value = odsAttr(t, odsOfficeNS, "boolean-value")
+				default:
+					cell.value = odsAttr(t, odsOfficeNS, "value")
+				}
+			case cell == nil:
+			case t.Name.Space == odsTextNS && t.Name.Local == "p":
+				if cell.paragraph {
+					cell.text.WriteByte('\n')
+				}
+				cell.paragraph = true
+			case t.Name.Space == odsTextNS && t.Name.Local == "s":
+				spaces, err := strconv.Atoi(odsAttr(t, odsTextNS, "c"))
+				if err != nil || spaces < 1 {
+					spaces = 1
+				}
+				cell.text.WriteString(strings.Repeat(" ", min(spaces, 1024)))
+			case t.Name.Space == odsTextNS && t.Name.Local == "tab":
+				cell.text.WriteByte('\t')
+			case t.Name.Space == odsTextNS && t.Name.Local == "line-break":
+				cell.text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if cell != nil && cell.paragraph {
+				cell.text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case sheet == nil || t.Name.Space != odsTableNS:
+			case t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell":
+				if cell == nil {
+					continue
+				}
+				value := odsCellValue(cell)
+				if value.Value == "" {
+					// Trailing empty cells often repeat to the end of the
+					// row; stop at the column limit rather than overflow.
+					col += min(cell.repeat, workbookMaxColumns-col)
+				} else {
+					for i := 0; i < cell.repeat; i++ {
+						if err := sheet.set(row, col, value); err != nil {
+							return nil, err
+						}
+						col++
+					}
+				}
+				cell = nil
+			case t.Name.Local == "table-row":
+				if sheet.count == rowStart {
+					row += min(rowRepeat, workbookMaxRows-row)
+					continue
+				}
+				// Copy a repeated row that has content.
+				if err := sheet.repeatRow(row, rowRepeat, sheet.count-rowStart); err != nil {
+					return nil, err
+				}
+				row += min(rowRepeat, workbookMaxRows-row)
+			case t.Name.Local == "table":
+				if total += sheet.count; total > workbookMaxTotalCells {
+					return nil, errWorkbookTooLarge
+				}
+				sheet = nil
+			}
+		}
+	}
+	return sheets, nil
+}
+
+func odsAttr(element xml.StartElement, space, local string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func odsRepeat(element xml.StartElement, local string) int {
+	repeat, err := strconv.Atoi(odsAttr(element, odsTableNS, local))
+	if err != nil || repeat < 1 {
+		return 1
+	}
+	return repeat
+}
+
+func odsCellValue(cell *odsCell) workbookCell {
+	if formula, ok := odsFormula(cell.formula); ok {
+		return workbookCell{Value: "=" + formula}
+	}
+	text := cell.text.String()
+	switch cell.valueType {
+	case "float", "currency":
+		if number, ok := parseSheetNumber(cell.value); ok {
+			return workbookCell{Value: formatSheetValue(number), Type: sheetColumnNumber}
+		}
+	case "percentage":
+		if number, ok := parseSheetNumber(cell.value); ok {
+			return workbookCell{Value: formatSheetValue(number*100) + "%", Type: sheetColumnNumber}
+		}
+	case "date":
+		if date, rest, _ := strings.Cut(cell.value, "T"); strings.Trim(rest, "0:.") == "" {
+			return workbookCell{Value: date, Type: sheetColumnDate}
+		}
+	case "boolean":
+		return workbookCell{Value: formatSheetValue(cell.value == "true"), Type: sheetColumnBool}
+	case "":
+		if text == "" {
+			return workbookCell{}
+		}
+	}
+	return workbookCell{Value: text, Type: sheetColumnText}
+}
+
+// odsFormula converts an OpenFormula such as of:=SUM([.A1:.A3]) to A1
+// syntax. Formulas referring to other tables, or that Scoli cannot evaluate,
+// are not converted.
+func odsFormula(formula string) (string, bool) {
+	if formula == "" {
+		return "", false
+	}
+	// Drop the namespace prefix, of: for OpenFormula.
+	if i := strings.Index(formula, ":="); i >= 0 && !strings.ContainsAny(formula[:i], `[("`) {
+		formula = formula[i+2:]
+	} else {
+		formula = strings.TrimPrefix(formula, "=")
+	}
+	formula = odsRefPattern.ReplaceAllStringFunc(formula, func(ref string) string {
+		parts := odsRefPattern.FindStringSubmatch(ref)
+		if parts[2] == "" {
+			return parts[1]
+		}
+		return parts[1] + ":" + parts[2]
+	})
+	if strings.Contains(formula, "[") || !sheetFormulaSupported(formula) {
+		return "", false
+	}
+	return formula, true
+}
+
+// XLSX export
+
+// workbookExport is one worksheet of an exported workbook.
+type workbookExport struct {
+	Name  string
+	Sheet sheetFile
+}
+
+// writeXLSX writes sheets as an .xlsx workbook. Formulas are written with
+// their results cached, or as plain values when values is set. Cells become
+// numbers, booleans and dates following their column type; literal numbers
+// in untyped columns are written as numbers.
+func writeXLSX(w io.Writer, sheets []workbookExport, values bool, today time.Time) error {
+	archive := zip.NewWriter(w)
+	part := func(name, content string) error {
+		writer, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(writer, xml.Header+content)
+		return err
+	}
+
+	var types, workbook, rels strings.Builder
+	types.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(`<workbook xmlns="` + xlsxMainNS + `" xmlns:r="` + xlsxRelsNS + `"><sheets>`)
+	rels.WriteString(`<Relationships xmlns="` + xlsxPackageNS + `">`)
+	for i, sheet := range sheets {
+		n := strconv.Itoa(i + 1)
+		types.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+		workbook.WriteString(`<sheet name="` + xmlEscape(sheet.Name) + `" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + n + `" Type="` + xlsxRelsNS + `/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	rels.WriteString(`<Relationship Id="rId` + strconv.Itoa(len(sheets)+1) + `" Type="` + xlsxRelsNS + `/styles" Target="styles.xml"/></Relationships>`)
+
+	if err := part("[Content_Types].xml", types.String()); err != nil {
+		return err
+	}
+	if err := part("_rels/.rels", `<Relationships xmlns="`+xlsxPackageNS+`">`+
+		`<Relationship Id="rId1" Type="`+xlsxRelsNS+`/officeDocument" Target="xl/workbook.xml"/></Relationships>`); err != nil {
+		return err
+	}
+	if err := part("xl/workbook.xml", workbook.String()); err != nil {
+		return err
+	}
+	if err := part("xl/_rels/workbook.xml.rels", rels.String()); err != nil {
+		return err
+	}
+	// Style 1 is the date format yyyy-mm-dd.
+	if err := part("xl/styles.xml", `<styleSheet xmlns="`+xlsxMainNS+`">`+
+		`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>`+
+		`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>`+
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>`+
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>`+
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`+
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`+
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>`+
+		`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>`+
+		`</styleSheet>`); err != nil {
+		return err
+	}
+	for i, sheet := range sheets {
+		if err := part("xl/worksheets/sheet"+strconv.Itoa(i+1)+".xml", xlsxWorksheetXML(sheet.Sheet, values, today)); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func xlsxWorksheetXML(sheet sheetFile, values bool, today time.Time) string {
+	computed := evaluateSheet(sheet.Data, today)
+	var b strings.Builder
+	b.WriteString(`<worksheet xmlns="` + xlsxMainNS + `">`)
+	if sheet.FrozenHeader {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews>`)
+	}
+	widths := false
+	for i, column := range sheet.Columns {
+		if column.Width <= 0 {
+			continue
+		}
+		if !widths {
+			b.WriteString(`<cols>`)
+			widths = true
+		}
+		// Column widths are in characters; a character is about 7 pixels.
+		n := strconv.Itoa(i + 1)
+		b.WriteString(`<col min="` + n + `" max="` + n + `" width="` +
+			strconv.FormatFloat(math.Round(float64(column.Width)/7*100)/100, 'f', -1, 64) + `" customWidth="1"/>`)
+	}
+	if widths {
+		b.WriteString(`</cols>`)
+	}
+
+	b.WriteString(`<sheetData>`)
+	for row, cells := range sheet.Data {
+		started := false
+		for col, raw := range cells {
+			if raw == "" {
+				continue
+			}
+			if !started {
+				b.WriteString(`<row r="` + strconv.Itoa(row+1) + `">`)
+				started = true
+			}
+			column := SheetColumn{}
+			if col < len(sheet.Columns) && !(row == 0 && sheet.FrozenHeader) {
+				column = sheet.Columns[col]
+			}
+			ref := sheetColumnName(col) + strconv.Itoa(row+1)
+			if isSheetFormula(raw) {
+				b.WriteString(xlsxFormulaCellXML(ref, raw[1:], computed[row][col], values))
+				continue
+			}
+			b.WriteString(xlsxValueCellXML(ref, raw, column))
+		}
+		if started {
+			b.WriteString(`</row>`)
+		}
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+func xlsxFormulaCellXML(ref, formula, result string, valuesOnly bool) string {
+	typeAttr, value := "", result
+	switch {
+	case result == "TRUE":
+		typeAttr, value = ` t="b"`, "1"
+	case result == "FALSE":
+		typeAttr, value = ` t="b"`, "0"
+	case strings.HasPrefix(result, "#"):
+		typeAttr = ` t="e"`
+	default:
+		if _, ok := parseSheetNumber(result); !ok {
+			typeAttr = ` t="str"`
+		}
+	}
+	if !valuesOnly {
+		return `<c r="` + ref + `"` + typeAttr + `><f>` + xmlEscape(formula) + `</f><v>` + xmlEscape(value) + `</v></c>`
+	}
+	if typeAttr == ` t="str"` || typeAttr == ` t="e"` {
+		return xlsxInlineStringXML(ref, result)
+	}
+	return `<c r="` + ref + `"` + typeAttr + `><v>` + value + `</v></c>`
+}
+
+func xlsxValueCellXML(ref, raw string, column SheetColumn) string {
+	value := strings.TrimSpace(raw)
+	switch column.Type {
+	case sheetColumnText, sheetColumnSelect:
+		return xlsxInlineStringXML(ref, raw)
+	case sheetColumnDate:
+		if date, err := time.Parse(dailyDateLayout, value); err == nil {
+			serial := date.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+			return `<c r="` + ref + `" s="1"><v>` + strconv.Itoa(int(math.Round(serial))) + `</v></c>`
+		}
+	case sheetColumnBool:
+		switch strings.ToLower(value) {
+		case "true":
+			return `<c r="` + ref + `" t="b"><v>1</v></c>`
+		case "false":
+			return `<c r="` + ref + `" t="b"><v>0</v></c>`
+		}
+	}
+	// Outside number columns only text that reads back unchanged becomes a
+	// number, so codes such as 00123 or 1e5 keep their spelling.
+	if number, ok := literalSheetValue(raw).(float64); ok && (column.Type == sheetColumnNumber || formatSheetValue(number) == raw) {
+		return `<c r="` + ref + `"><v>` + strconv.FormatFloat(number, 'f', -1, 64) + `</v></c>`
+	}
+	return xlsxInlineStringXML(ref, raw)
+}
+
+func xlsxInlineStringXML(ref, text string) string {
+	return `<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(text) + `</t></is></c>`
+}
+
+func xmlEscape(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// workbookSheetName makes a worksheet name from a sheet path: at most 31
+// characters, without the characters Excel forbids, and unique within used.
+func workbookSheetName(relPath string, used map[string]bool) string {
+	name := strings.TrimSuffix(path.Base(relPath), sheetExtension)
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.TrimSpace(name) == "" {
+		name = "Sheet"
+	}
+	truncate := func(value string, limit int) string {
+		for utf8.RuneCountInString(value) > limit {
+			_, size := utf8.DecodeLastRuneInString(value)
+			value = value[:len(value)-size]
+		}
+		return value
+	}
+	candidate := truncate(name, 31)
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		suffix := " (" + strconv.Itoa(n) + ")"
+		candidate = truncate(name, 31-len(suffix)) + suffix
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
@@ -0,0 +1,432 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Bills" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>Item</t></si><si><t>Cost</t></si><si><r><t>Re</t></r><r><t>nt</t></r></si>` +
+			`<si><t>Due</t></si><si><t>Code</t></si><si><t>00123</t></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="dd/mm/yyyy"/></numFmts>` +
+			`<cellXfs><xf numFmtId="0"/><xf numFmtId="164"/><xf numFmtId="14"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>hello</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>3</v></c><c r="D1" t="s"><v>4</v></c><c r="E1"><v>1</v></c></row>` +
+			`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>1200</v></c><c r="C2" s="1"><v>46327</v></c><c r="D2" t="s"><v>5</v></c><c r="E2" t="b"><v>1</v></c></row>` +
+			`<row r="4"><c r="B4"><f>SUM(B2:B3)</f><v>1200</v></c><c r="C4" s="2"><v>46327.5</v></c><c r="D4"><v>7</v></c>` +
+			`<c r="E4"><f>Summary!A1</f><v>9</v></c><c r="F4"><f>_xlfn.XLOOKUP(1,A1:A2,B1:B2)</f><v>3</v></c></row>` +
+			`</sheetData></worksheet>`,
+	})
+
+	sheets, err := readWorkbook(data)
+	if err != nil {
+		t.Fatalf("read workbook: %v", err)
+	}
+	if len(sheets) != 2 || sheets[0].Name != "Summary" || sheets[1].Name != "Bills" {
+		t.Fatalf("unexpected worksheets %#v", sheets)
+	}
+	sheet, err := sheets[1].sheet()
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := [][]string{
+		{"Item", "Cost", "Due", "Code", "1", ""},
+		{"Rent", "1200", "2026-11-01", "00123", "TRUE", ""},
+		{"", "", "", "", "", ""},
+		{"", "=SUM(B2:B3)", "2026-11-01 12:00", "7", "9", "3"},
+	}
+	if !reflect.DeepEqual(sheet.Data, want) {
+		t.Fatalf("unexpected data\n got %q\nwant %q", sheet.Data, want)
+	}
+	types := make([]string, 0, len(sheet.Columns))
+	for _, column := range sheet.Columns {
+		types = append(types, column.Type)
+	}
+	if !sheet.FrozenHeader || !reflect.DeepEqual(types, []string{"text", "number", "text", "text", "text", "number"}) {
+		t.Fatalf("unexpected settings %#v", sheet.SheetSettings)
+	}
+}
+
+func TestReadODS(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+			`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+			`<office:body><office:spreadsheet><table:table table:name="Budget">` +
+			`<table:table-row><table:table-cell office:value-type="string"><text:p>Item</text:p></table:table-cell>` +
+			`<table:table-cell office:value-type="string"><text:p>Cost</text:p></table:table-cell>` +
+			`<table:table-cell office:value-type="string"><text:p>Paid</text:p></table:table-cell>` +
+			`<table:table-cell table:number-columns-repeated="16380"/></table:table-row>` +
+			`<table:table-row table:number-rows-repeated="2"><table:table-cell office:value-type="string"><text:p>Rent<text:s text:c="2"/>due</text:p></table:table-cell>` +
+			`<table:table-cell office:value-type="float" office:value="1200"><text:p>1,200</text:p></table:table-cell>` +
+			`<table:table-cell office:value-type="boolean" office:boolean-value="false"><text:p>FALSE</text:p></table:table-cell></table:table-row>` +
+			`<table:table-row table:number-rows-repeated="1000000"><table:table-cell table:number-columns-repeated="16384"/></table:table-row>` +
+			`</table:table><table:table table:name="Later"><table:table-row>` +
+			`<table:table-cell office:value-type="date" office:date-value="2026-10-17"><text:p>17/10/26</text:p></table:table-cell>` +
+			`<table:table-cell office:value-type="percentage" office:value="0.15"><text:p>15%</text:p></table:table-cell>` +
+			`<table:table-cell table:formula="of:=SUM([.B1:.B2])+[.B1]" office:value-type="float" office:value="0.15"/>` +
+			`<table:table-cell table:formula="of:=[Budget.B2]" office:value-type="float" office:value="1200"/>` +
+			`</table:table-row></table:table></office:spreadsheet></office:body></office:document-content>`,
+	})
+
+	sheets, err := readWorkbook(data)
+	if err != nil {
+		t.Fatalf("read workbook: %v", err)
+	}
+	if len(sheets) != 2 {
+		t.Fatalf("expected 2 worksheets, got %d", len(sheets))
+	}
+	budget, err := sheets[0].sheet()
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := [][]string{{"Item", "Cost", "Paid"}, {"Rent  due", "1200", "FALSE"}, {"Rent  due", "1200", "FALSE"}}
+	if !reflect.DeepEqual(budget.Data, want) {
+		t.Fatalf("unexpected data %q", budget.Data)
+	}
+	if budget.Columns[1].Type != sheetColumnNumber || budget.Columns[2].Type != sheetColumnBool {
+		t.Fatalf("unexpected columns %#v", budget.Columns)
+	}
+	later, err := sheets[1].sheet()
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if !reflect.DeepEqual(later.Data, [][]string{{"2026-10-17", "15%", "=SUM(B1:B2)+B1", "1200"}}) {
+		t.Fatalf("unexpected data %q", later.Data)
+	}
+}
+
+func TestReadODSHugeRepeats(t *testing.T) {
+	ods := func(rows string) []byte {
+		return buildZip(t, map[string]string{
+			"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+			"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+				`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+				`<office:body><office:spreadsheet><table:table table:name="Big">` + rows +
+				`</table:table></office:spreadsheet></office:body></office:document-content>`,
+		})
+	}
+	value := `<table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell>`
+	maxRepeat := "9223372036854775807"
+
+	sheets, err := readWorkbook(ods(`<table:table-row>` + value + `<table:table-cell table:number-columns-repeated="` + maxRepeat + `"/>` +
+		`<table:table-cell table:number-columns-repeated="` + maxRepeat + `"/></table:table-row>` +
+		`<table:table-row table:number-rows-repeated="` + maxRepeat + `"><table:table-cell/></table:table-row>` +
+		`<table:table-row table:number-rows-repeated="` + maxRepeat + `"><table:table-cell/></table:table-row>`))
+	if err != nil {
+		t.Fatalf("read workbook: %v", err)
+	}
+	if !reflect.DeepEqual(sheets[0].Cells, [][]workbookCell{{{Value: "x", Type: sheetColumnText}}}) {
+		t.Fatalf("unexpected cells %#v", sheets[0].Cells)
+	}
+
+	for _, rows := range []string{
+		`<table:table-row><table:table-cell table:number-columns-repeated="` + maxRepeat + `"/>` + value + `</table:table-row>`,
+		`<table:table-row table:number-rows-repeated="` + maxRepeat + `"><table:table-cell/></table:table-row><table:table-row>` + value + `</table:table-row>`,
+	} {
+		if _, err := readWorkbook(ods(rows)); err != errWorkbookTooLarge {
+			t.Errorf("expected %v, got %v", errWorkbookTooLarge, err)
+		}
+	}
+
+	var ws workbookSheet
+	if err := ws.set(-1, 0, workbookCell{Value: "x"}); err == nil {
+		t.Fatalf("expected a negative row to be rejected")
+	}
+}
+
+// readWorkbook reads every worksheet of data with its cells.
+func readWorkbook(data []byte) ([]workbookSheet, error) {
+	wb, err := openWorkbook(data)
+	if err != nil {
+		return nil, err
+	}
+	sheets := make([]workbookSheet, 0, len(wb.names))
+	for i := range wb.names {
+		sheet, err := wb.worksheet(i)
+		if err != nil {
+			return nil, err
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// readWorkbookAllocs reads data and reports the bytes allocated while doing so.
+func readWorkbookAllocs(t *testing.T, data []byte) (uint64, error) {
+	t.Helper()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := readWorkbook(data)
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc, err
+}
+
+func TestReadWorkbookFarColumns(t *testing.T) {
+	const rows = 5000
+	var sheet strings.Builder
+	sheet.WriteString(`<worksheet><sheetData>`)
+	for row := 1; row <= rows; row++ {
+		n := strconv.Itoa(row)
+		sheet.WriteString(`<row r="` + n + `"><c r="XFD` + n + `"><v>1</v></c></row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	xlsx := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Wide" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": sheet.String(),
+	})
+	ods := buildZip(t, map[string]string{
+		"mimetype": "application/vnd.oasis.opendocument.spreadsheet",
+		"content.xml": `<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+			`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">` +
+			`<office:body><office:spreadsheet><table:table table:name="Wide">` +
+			`<table:table-row table:number-rows-repeated="` + strconv.Itoa(rows) + `">` +
+			`<table:table-cell table:number-columns-repeated="16383"/>` +
+			`<table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell></table:table-row>` +
+			`</table:table></office:spreadsheet></office:body></office:document-content>`,
+	})
+
+	for name, data := range map[string][]byte{"xlsx": xlsx, "ods": ods} {
+		allocated, err := readWorkbookAllocs(t, data)
+		if err != errWorkbookTooLarge {
+			t.Errorf("%s: expected %v, got %v", name, errWorkbookTooLarge, err)
+		}
+		if allocated > 256<<20 {
+			t.Errorf("%s: allocated %d MB before rejecting the sheet", name, allocated>>20)
+		}
+	}
+}
+
+func TestWorkbookSharedWorksheetPart(t *testing.T) {
+	xlsx := func(count int) []byte {
+		var sheets, rels strings.Builder
+		for i := 1; i <= count; i++ {
+			n := strconv.Itoa(i)
+			sheets.WriteString(`<sheet name="S` + n + `" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+			rels.WriteString(`<Relationship Id="rId` + n + `" Target="worksheets/sheet1.xml"/>`)
+		}
+		return buildZip(t, map[string]string{
+			"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+				`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + sheets.String() + `</sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				rels.String() + `</Relationships>`,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="2"><c r="C2"><v>1</v></c></row></sheetData>` +
+				strings.Repeat(" ", 1<<20) + `</worksheet>`,
+		})
+	}
+
+	if _, err := openWorkbook(xlsx(workbookMaxSheets + 1)); err == nil {
+		t.Fatalf("expected too many worksheets to be rejected")
+	}
+
+	wb, err := openWorkbook(xlsx(workbookMaxSheets))
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	index, ok := wb.find("s200")
+	if !ok || index != 199 {
+		t.Fatalf("expected worksheet S200, got %d %v", index, ok)
+	}
+	sheet, err := wb.worksheet(index)
+	if err != nil || len(sheet.Cells) != 2 || sheet.Cells[1][2].Value != "1" {
+		t.Fatalf("unexpected worksheet %#v (%v)", sheet, err)
+	}
+	if _, err := wb.dimensions(); err != errWorkbookTooLarge {
+		t.Fatalf("expected the workbook read limit, got %v", err)
+	}
+
+	wb, err = openWorkbook(xlsx(3))
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+	sheets, err := wb.dimensions()
+	if err != nil || len(sheets) != 3 {
+		t.Fatalf("unexpected dimensions %#v (%v)", sheets, err)
+	}
+	if sheets[2].Name != "S3" || sheets[2].rows != 2 || sheets[2].width != 3 || sheets[2].Cells != nil {
+		t.Fatalf("expected dimensions without cells, got %#v", sheets[2])
+	}
+}
+
+func TestReadWorkbookRejectsOtherFiles(t *testing.T) {
+	if _, err := readWorkbook([]byte("Item,Cost\n")); err != errWorkbookFormat {
+		t.Fatalf("expected format error, got %v", err)
+	}
+	if _, err := readWorkbook(buildZip(t, map[string]string{"word/document.xml": "<w/>"})); err != errWorkbookFormat {
+		t.Fatalf("expected format error, got %v", err)
+	}
+}
+
+func TestWriteXLSXRoundTrip(t *testing.T) {
+	sheet := sheetFile{
+		SheetSettings: SheetSettings{
+			FrozenHeader: true,
+			Columns: []SheetColumn{
+				{Name: "Item", Type: sheetColumnText, Width: 140},
+				{Name: "Cost", Type: sheetColumnNumber},
+				{Name: "Due", Type: sheetColumnDate},
+				{Name: "Paid", Type: sheetColumnBool},
+				{Name: "Code", Type: sheetColumnText},
+			},
+		},
+		Data: [][]string{
+			{"Item", "Cost", "Due", "Paid", "Code"},
+			{"Rent <home>", "1200", "2026-11-01", "true", "00123"},
+			{"Total", "=SUM(B2:B2)", "", "", ""},
+		},
+	}
+	var buf bytes.Buffer
+	today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	exports := []workbookExport{{Name: "Bills", Sheet: sheet}, {Name: "Copy", Sheet: sheet}}
+	if err := writeXLSX(&buf, exports, false, today); err != nil {
+		t.Fatalf("write xlsx: %v", err)
+	}
+	sheets, err := readWorkbook(buf.Bytes())
+	if err != nil {
+		t.Fatalf("read xlsx: %v", err)
+	}
+	if len(sheets) != 2 || sheets[1].Name != "Copy" {
+		t.Fatalf("unexpected worksheets %#v", sheets)
+	}
+	got, err := sheets[0].sheet()
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	want := [][]string{
+		{"Item", "Cost", "Due", "Paid", "Code"},
+		{"Rent <home>", "1200", "2026-11-01", "TRUE", "00123"},
+		{"Total", "=SUM(B2:B2)", "", "", ""},
+	}
+	if !reflect.DeepEqual(got.Data, want) {
+		t.Fatalf("unexpected data %q", got.Data)
+	}
+	for i, typ := range []string{"text", "number", "date", "bool", "text"} {
+		if got.Columns[i].Type != typ {
+			t.Errorf("column %d type = %q, want %q", i, got.Columns[i].Type, typ)
+		}
+	}
+
+	buf.Reset()
+	if err := writeXLSX(&buf, exports[:1], true, today); err != nil {
+		t.Fatalf("write xlsx: %v", err)
+	}
+	sheets, err = readWorkbook(buf.Bytes())
+	if err != nil {
+		t.Fatalf("read xlsx: %v", err)
+	}
+	if value := sheets[0].Cells[2][1].Value; value != "1200" {
+		t.Fatalf("expected the formula's value, got %q", value)
+	}
+
+	// Sheets without column types keep numeric-looking text as written.
+	untyped := sheetFile{Data: [][]string{{"00123", "1e5", "1200", "-0.5", "15%", " 7"}}}
+	buf.Reset()
+	if err := writeXLSX(&buf, []workbookExport{{Name: "Codes", Sheet: untyped}}, false, today); err != nil {
+		t.Fatalf("write xlsx: %v", err)
+	}
+	sheets, err = readWorkbook(buf.Bytes())
+	if err != nil {
+		t.Fatalf("read xlsx: %v", err)
+	}
+	wantCells := []workbookCell{
+		{Value: "00123", Type: sheetColumnText},
+		{Value: "1e5", Type: sheetColumnText},
+		{Value: "1200", Type: sheetColumnNumber},
+		{Value: "-0.5", Type: sheetColumnNumber},
+		{Value: "15%", Type: sheetColumnText},
+		{Value: " 7", Type: sheetColumnText},
+	}
+	if !reflect.DeepEqual(sheets[0].Cells[0], wantCells) {
+		t.Fatalf("unexpected untyped cells %#v", sheets[0].Cells[0])
+	}
+}
+
+func TestXLSXIsDateFormat(t *testing.T) {
+	tests := []struct {
+		id   int
+		code string
+		want bool
+	}{
+		{14, "", true},
+		{2, "", false},
+		{164, "yyyy-mm-dd", true},
+		{165, `[$-409]d\-mmm`, true},
+		{166, "h:mm:ss", false},
+		{167, `0.00" days"`, false},
+		{168, "[Red]0.00", false},
+	}
+	for _, tt := range tests {
+		if got := xlsxIsDateFormat(tt.id, tt.code); got != tt.want {
+			t.Errorf("xlsxIsDateFormat(%d, %q) = %v", tt.id, tt.code, got)
+		}
+	}
+}
+
+func TestSerialDateCell(t *testing.T) {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		serial float64
+		want   workbookCell
+	}{
+		{46000, workbookCell{Value: "2025-12-09", Type: sheetColumnDate}},
+		{46000.5, workbookCell{Value: "2025-12-09 12:00", Type: sheetColumnText}},
+		{200000, workbookCell{Value: "2447-07-30", Type: sheetColumnDate}},
+		{2958465.75, workbookCell{Value: "9999-12-31 18:00", Type: sheetColumnText}},
+		{1e12, workbookCell{Value: "1000000000000", Type: sheetColumnNumber}},
+	}
+	for _, tt := range tests {
+		if got := serialDateCell(tt.serial, epoch); got != tt.want {
+			t.Errorf("serialDateCell(%v) = %#v, want %#v", tt.serial, got, tt.want)
+		}
+	}
+}
+
+func TestWorkbookSheetName(t *testing.T) {
+	used := make(map[string]bool)
+	names := []string{
+		workbookSheetName("Budget.jsh", used),
+		workbookSheetName("archive/budget.jsh", used),
+		workbookSheetName("Q1: costs/totals?.jsh", used),
+		workbookSheetName("A very long sheet name that will not fit.jsh", used),
+	}
+	want := []string{"Budget", "budget (2)", "totals_", "A very long sheet name that wil"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected names %q", names)
+	}
+}
@@ -5,17 +5,22 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// SheetPayload creates or updates a sheet. Settings may be left out of an
+// update to keep the sheet's current settings.
 type SheetPayload struct {
-	Path string     `json:"path"`
-	Data [][]string `json:"data"`
+	Path     string         `json:"path"`
+	Data     [][]string     `json:"data"`
+	Settings *SheetSettings `json:"settings,omitempty"`
 }
 
 type SheetRenamePayload struct {
@@ -23,21 +28,72 @@ type SheetRenamePayload struct {
 	NewPath string `json:"newPath"`
 }
 
+// SheetImportPayload imports CSV text, or a base64-encoded .xlsx or .ods
+// file and one of its worksheets.
 type SheetImportPayload struct {
-	Path string `json:"path"`
-	CSV  string `json:"csv"`
+	Path      string `json:"path"`
+	CSV       string `json:"csv,omitempty"`
+	File      []byte `json:"file,omitempty"`
+	Worksheet string `json:"worksheet,omitempty"`
+}
+
+type SheetWorksheetsPayload struct {
+	File []byte `json:"file"`
+}
+
+type SheetWorksheet struct {
+	Name    string `json:"name"`
+	Rows    int    `json:"rows"`
+	Columns int    `json:"columns"`
+}
+
+type SheetWorksheetsResponse struct {
+	Worksheets []SheetWorksheet `json:"worksheets"`
 }
 
+// SheetResponse carries the raw cells in Data and, in Values, the same grid
+// with every formula replaced by its result.
 type SheetResponse struct {
-	Path     string     `json:"path"`
-	Data     [][]string `json:"data"`
-	Modified time.Time  `json:"modified"`
+	Path     string        `json:"path"`
+	Data     [][]string    `json:"data"`
+	Values   [][]string    `json:"values"`
+	Settings SheetSettings `json:"settings"`
+	Modified time.Time     `json:"modified"`
+	ETag     string        `json:"etag"`
 }
 
+// sheetFileVersion is the current .jsh schema. Version 1 files hold only
+// data and are upgraded when read.
+const sheetFileVersion = 2
+
 type sheetFile struct {
+	Version int `json:"version"`
+	SheetSettings
 	Data [][]string `json:"data"`
 }
 
+// sheetMaxBody caps sheet request bodies. Imports carry a whole workbook, so
+// the cap matches the largest workbook part that is read.
+const sheetMaxBody = workbookMaxPartSize
+
+// decodeSheetJSON reads a sheet request body of at most sheetMaxBody bytes.
+// On failure it writes the error, 413 for an oversized body, and returns
+// false.
+func decodeSheetJSON[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, sheetMaxBody)
+	payload, err := decodeJSON[T](r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeError(w, http.StatusBadRequest, err.Error())
+		}
+		return payload, false
+	}
+	return payload, true
+}
+
 func (s *Server) handleSheetsTree(w http.ResponseWriter, r *http.Request) {
 	root := TreeNode{
 		Name: "Sheets",
@@ -114,15 +170,18 @@ func (s *Server) handleSheetsGet(w http.ResponseWriter, r *http.Request) {
 	resp := SheetResponse{
 		Path:     relPath,
 		Data:     sheet.Data,
+		Values:   evaluateSheet(sheet.Data, dateOnly(timeNow())),
+		Settings: sheet.SheetSettings,
 		Modified: info.ModTime(),
+		ETag:     contentETag(data),
 	}
+	w.Header().Set("ETag", resp.ETag)
 	writeJSON(w, http.StatusOK, resp)
 }
 
 func (s *Server) handleSheetsCreate(w http.ResponseWriter, r *http.Request) {
-	payload, err := decodeJSON[SheetPayload](r.Body)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	payload, ok := decodeSheetJSON[SheetPayload](w, r)
+	if !ok {
 		return
 	}
 	if strings.TrimSpace(payload.Path) == "" {
@@ -130,6 +189,15 @@ func (s *Server) handleSheetsCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sheet := sheetFile{Data: normalizeSheetData(payload.Data)}
+	if payload.Settings != nil {
+		sheet.SheetSettings = *payload.Settings
+	}
+	if err := validateSheet(&sheet); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	pathParam := ensureSheetExtension(strings.TrimSpace(payload.Path))
 	absPath, relPath, err := s.resolveSheetPath(pathParam)
 	if err != nil {
@@ -150,7 +218,7 @@ func (s *Server) handleSheetsCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := writeSheetFile(absPath, payload.Data); err != nil {
+	if err := writeSheetFile(absPath, sheet); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to write sheet")
 		return
 	}
@@ -160,9 +228,8 @@ func (s *Server) handleSheetsCreate(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSheetsUpdate(w http.ResponseWriter, r *http.Request) {
-	payload, err := decodeJSON[SheetPayload](r.Body)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	payload, ok := decodeSheetJSON[SheetPayload](w, r)
+	if !ok {
 		return
 	}
 	if strings.TrimSpace(payload.Path) == "" {
@@ -195,21 +262,150 @@ func (s *Server) handleSheetsUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := writeSheetFile(absPath, payload.Data); err != nil {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read sheet")
+		return
+	}
+	sheet, err := decodeSheetFile(current)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to parse sheet data")
+		return
+	}
+	currentETag := contentETag(current)
+	if !ifMatchSatisfied(r, currentETag) {
+		writeSheetConflict(w, relPath, sheet, info.ModTime(), currentETag)
+		return
+	}
+
+	updated := sheetFile{SheetSettings: sheet.SheetSettings, Data: normalizeSheetData(payload.Data)}
+	if payload.Settings != nil {
+		updated.SheetSettings = *payload.Settings
+	}
+	if err := validateSheet(&updated); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoded, err := encodeSheetFile(updated)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to update sheet")
+		return
+	}
+	if err := os.WriteFile(absPath, encoded, 0o644); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to update sheet")
 		return
 	}
 
+	etag := contentETag(encoded)
 	s.logger.Info("sheet updated", "path", relPath)
-	writeJSON(w, http.StatusOK, map[string]string{"path": relPath})
+	w.Header().Set("ETag", etag)
+	writeJSON(w, http.StatusOK, map[string]string{"path": relPath, "etag": etag})
 }
 
-func (s *Server) handleSheetsRename(w http.ResponseWriter, r *http.Request) {
-	payload, err := decodeJSON[SheetRenamePayload](r.Body)
+// handleSheetsOperations applies a list of operations to the sheet as it is
+// on disk, saving the result only if all of them succeed.
+func (s *Server) handleSheetsOperations(w http.ResponseWriter, r *http.Request) {
+	payload, ok := decodeSheetJSON[SheetOperationsPayload](w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(payload.Path) == "" {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	if len(payload.Operations) == 0 {
+		writeError(w, http.StatusBadRequest, "operations are required")
+		return
+	}
+
+	pathParam := ensureSheetExtension(strings.TrimSpace(payload.Path))
+	absPath, relPath, err := s.resolveSheetPath(pathParam)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "sheet not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "unable to read sheet")
+		return
+	}
+	if info.IsDir() {
+		writeError(w, http.StatusBadRequest, "path is a folder")
+		return
+	}
+	if !isSheetFile(absPath) {
+		writeError(w, http.StatusBadRequest, "not a sheet file")
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to read sheet")
+		return
+	}
+	sheet, err := decodeSheetFile(current)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to parse sheet data")
+		return
+	}
+	currentETag := contentETag(current)
+	if !ifMatchSatisfied(r, currentETag) {
+		writeSheetConflict(w, relPath, sheet, info.ModTime(), currentETag)
+		return
+	}
+
+	updated, err := applySheetOperations(sheet, payload.Operations)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := validateSheet(&updated); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	encoded, err := encodeSheetFile(updated)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to update sheet")
+		return
+	}
+	if err := os.WriteFile(absPath, encoded, 0o644); err != nil {
+		writeError(w, http.StatusInternalServerError, "unable to update sheet")
+		return
+	}
+
+	etag := contentETag(encoded)
+	columns := 0
+	if len(updated.Data) > 0 {
+		columns = len(updated.Data[0])
+	}
+	s.logger.Info("sheet updated", "path", relPath, "operations", len(payload.Operations))
+	w.Header().Set("ETag", etag)
+	writeJSON(w, http.StatusOK, SheetOperationsResponse{
+		Path:    relPath,
+		ETag:    etag,
+		Rows:    len(updated.Data),
+		Columns: columns,
+	})
+}
+
+func (s *Server) handleSheetsRename(w http.ResponseWriter, r *http.Request) {
+	payload, ok := decodeSheetJSON[SheetRenamePayload](w, r)
+	if !ok {
+		return
+	}
 	if strings.TrimSpace(payload.Path) == "" || strings.TrimSpace(payload.NewPath) == "" {
 		writeError(w, http.StatusBadRequest, "path and newPath are required")
 		return
@@ -300,10 +496,11 @@ func (s *Server) handleSheetsDelete(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
+// handleSheetsImport creates a sheet from CSV text or from an .xlsx or .ods
+// file. Workbooks import their first worksheet unless another is named.
 func (s *Server) handleSheetsImport(w http.ResponseWriter, r *http.Request) {
-	payload, err := decodeJSON[SheetImportPayload](r.Body)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	payload, ok := decodeSheetJSON[SheetImportPayload](w, r)
+	if !ok {
 		return
 	}
 	if strings.TrimSpace(payload.Path) == "" {
@@ -311,11 +508,43 @@ func (s *Server) handleSheetsImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reader := csv.NewReader(strings.NewReader(payload.CSV))
-	records, err := reader.ReadAll()
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid CSV data")
-		return
+	var sheet sheetFile
+	if len(payload.File) > 0 {
+		if payload.CSV != "" {
+			writeError(w, http.StatusBadRequest, "csv and file cannot be combined")
+			return
+		}
+		wb, err := openWorkbook(payload.File)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		index, ok := wb.find(payload.Worksheet)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("worksheet %q not found; available: %s", payload.Worksheet, strings.Join(wb.names, ", ")))
+			return
+		}
+		worksheet, err := wb.worksheet(index)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if sheet, err = worksheet.sheet(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		if strings.TrimSpace(payload.Worksheet) != "" {
+			writeError(w, http.StatusBadRequest, "worksheet requires a file")
+			return
+		}
+		reader := csv.NewReader(strings.NewReader(payload.CSV))
+		records, err := reader.ReadAll()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid CSV data")
+			return
+		}
+		sheet = sheetFile{SheetSettings: inferSheetSettings(records), Data: normalizeSheetData(records)}
 	}
 
 	pathParam := ensureSheetExtension(strings.TrimSpace(payload.Path))
@@ -338,7 +567,7 @@ func (s *Server) handleSheetsImport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := writeSheetFile(absPath, records); err != nil {
+	if err := writeSheetFile(absPath, sheet); err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to write sheet")
 		return
 	}
@@ -347,59 +576,184 @@ func (s *Server) handleSheetsImport(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"path": relPath})
 }
 
+// handleSheetsWorksheets lists the worksheets of an .xlsx or .ods file, so a
+// client can choose one to import.
+func (s *Server) handleSheetsWorksheets(w http.ResponseWriter, r *http.Request) {
+	payload, ok := decodeSheetJSON[SheetWorksheetsPayload](w, r)
+	if !ok {
+		return
+	}
+	if len(payload.File) == 0 {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	wb, err := openWorkbook(payload.File)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	worksheets, err := wb.dimensions()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resp := SheetWorksheetsResponse{Worksheets: make([]SheetWorksheet, 0, len(worksheets))}
+	for _, ws := range worksheets {
+		resp.Worksheets = append(resp.Worksheets, SheetWorksheet{Name: ws.Name, Rows: ws.rows, Columns: ws.width})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSheetsExport writes a sheet as CSV, or as .xlsx with format=xlsx.
+// An .xlsx export may repeat path to put several sheets in one workbook.
+// Formulas are exported as written unless ?values=true asks for their
+// results.
 func (s *Server) handleSheetsExport(w http.ResponseWriter, r *http.Request) {
-	pathParam := strings.TrimSpace(r.URL.Query().Get("path"))
+	query := r.URL.Query()
+	var paths []string
+	for _, raw := range query["path"] {
+		if trimmed := strings.TrimSpace(raw); trimmed != "" {
+			paths = append(paths, trimmed)
+		}
+	}
+	if len(paths) == 0 {
+		writeError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	exportValues := false
+	if raw := strings.TrimSpace(query.Get("values")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid values")
+			return
+		}
+		exportValues = parsed
+	}
+	format := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	switch format {
+	case "", "csv":
+		format = "csv"
+		if len(paths) > 1 {
+			writeError(w, http.StatusBadRequest, "csv export takes one path")
+			return
+		}
+	case "xlsx":
+	default:
+		writeError(w, http.StatusBadRequest, "format must be csv or xlsx")
+		return
+	}
+
+	today := dateOnly(timeNow())
+	exports := make([]workbookExport, 0, len(paths))
+	used := make(map[string]bool)
+	filename := "Sheets." + format
+	for _, pathParam := range paths {
+		sheet, relPath, status, msg := s.readSheet(pathParam)
+		if status != 0 {
+			writeError(w, status, msg)
+			return
+		}
+		exports = append(exports, workbookExport{Name: workbookSheetName(relPath, used), Sheet: sheet})
+		if len(paths) == 1 {
+			filename = strings.TrimSuffix(filepath.Base(relPath), sheetExtension) + "." + format
+		}
+	}
+
+	var buf bytes.Buffer
+	contentType := "text/csv"
+	if format == "xlsx" {
+		contentType = xlsxContentType
+		if err := writeXLSX(&buf, exports, exportValues, today); err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to export sheet")
+			return
+		}
+	} else {
+		rows := exports[0].Sheet.Data
+		if exportValues {
+			rows = evaluateSheet(rows, today)
+		}
+		writer := csv.NewWriter(&buf)
+		if err := writer.WriteAll(rows); err != nil {
+			writeError(w, http.StatusInternalServerError, "unable to export sheet")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// handleSheetsQuery filters, groups and sorts a sheet's rows, taking its first
+// row as column names.
+func (s *Server) handleSheetsQuery(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	pathParam := strings.TrimSpace(values.Get("path"))
 	if pathParam == "" {
 		writeError(w, http.StatusBadRequest, "path is required")
 		return
 	}
-	pathParam = ensureSheetExtension(pathParam)
-	absPath, relPath, err := s.resolveSheetPath(pathParam)
+	query, err := sheetQueryFromValues(values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sheet, relPath, status, message := s.readSheet(pathParam)
+	if status != 0 {
+		writeError(w, status, message)
+		return
+	}
+	resp, err := query.run(sheet, dateOnly(timeNow()))
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	resp.Path = relPath
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeSheetConflict reports a failed If-Match with the sheet as stored.
+func writeSheetConflict(w http.ResponseWriter, relPath string, sheet sheetFile, modified time.Time, etag string) {
+	writeConflict(w, "sheet changed on server", etag, SheetResponse{
+		Path:     relPath,
+		Data:     sheet.Data,
+		Values:   evaluateSheet(sheet.Data, dateOnly(timeNow())),
+		Settings: sheet.SheetSettings,
+		Modified: modified,
+		ETag:     etag,
+	})
+}
 
+// readSheet loads a sheet by its path under the Sheets root. On failure it
+// returns the HTTP status and message to report.
+func (s *Server) readSheet(pathParam string) (sheetFile, string, int, string) {
+	absPath, relPath, err := s.resolveSheetPath(ensureSheetExtension(pathParam))
+	if err != nil {
+		return sheetFile{}, "", http.StatusBadRequest, err.Error()
+	}
 	info, err := os.Stat(absPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			writeError(w, http.StatusNotFound, "sheet not found")
-			return
+			return sheetFile{}, "", http.StatusNotFound, "sheet not found"
 		}
-		writeError(w, http.StatusInternalServerError, "unable to read sheet")
-		return
+		return sheetFile{}, "", http.StatusInternalServerError, "unable to read sheet"
 	}
 	if info.IsDir() {
-		writeError(w, http.StatusBadRequest, "path is a folder")
-		return
+		return sheetFile{}, "", http.StatusBadRequest, "path is a folder"
 	}
 	if !isSheetFile(absPath) {
-		writeError(w, http.StatusBadRequest, "not a sheet file")
-		return
+		return sheetFile{}, "", http.StatusBadRequest, "not a sheet file"
 	}
-
 	data, err := os.ReadFile(absPath)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to read sheet")
-		return
+		return sheetFile{}, "", http.StatusInternalServerError, "unable to read sheet"
 	}
 	sheet, err := decodeSheetFile(data)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to parse sheet data")
-		return
-	}
-
-	var buf bytes.Buffer
-	writer := csv.NewWriter(&buf)
-	if err := writer.WriteAll(sheet.Data); err != nil {
-		writeError(w, http.StatusInternalServerError, "unable to export sheet")
-		return
+		return sheetFile{}, "", http.StatusInternalServerError, "unable to parse sheet data"
 	}
-
-	filename := strings.TrimSuffix(filepath.Base(relPath), sheetExtension) + ".csv"
-	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
-	_, _ = w.Write(buf.Bytes())
+	return sheet, relPath, 0, ""
 }
 
 func (s *Server) buildSheetsTree(absPath, relPath string) ([]TreeNode, error) {
@@ -492,28 +846,57 @@ func (s *Server) resolveSheetPath(input string) (string, string, error) {
 	return absPath, filepath.ToSlash(clean), nil
 }
 
-func writeSheetFile(path string, data [][]string) error {
-	payload := sheetFile{Data: normalizeSheetData(data)}
-	encoded, err := json.MarshalIndent(payload, "", "  ")
+func writeSheetFile(path string, sheet sheetFile) error {
+	encoded, err := encodeSheetFile(sheet)
 	if err != nil {
 		return err
 	}
-	encoded = append(encoded, '\n')
 	return os.WriteFile(path, encoded, 0o644)
 }
 
+func encodeSheetFile(sheet sheetFile) ([]byte, error) {
+	sheet.Version = sheetFileVersion
+	sheet.Data = normalizeSheetData(sheet.Data)
+	if sheet.Columns == nil {
+		sheet.Columns = []SheetColumn{}
+	}
+	encoded, err := json.MarshalIndent(sheet, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
 func decodeSheetFile(data []byte) (sheetFile, error) {
 	if len(bytes.TrimSpace(data)) == 0 {
-		return sheetFile{Data: [][]string{}}, nil
+		return sheetFile{
+			Version:       sheetFileVersion,
+			SheetSettings: SheetSettings{Columns: []SheetColumn{}},
+			Data:          [][]string{},
+		}, nil
 	}
 	var parsed sheetFile
 	if err := json.Unmarshal(data, &parsed); err != nil {
 		return sheetFile{}, err
 	}
-	if parsed.Data == nil {
-		parsed.Data = [][]string{}
+	if parsed.Version > sheetFileVersion {
+		return sheetFile{}, fmt.Errorf("unsupported sheet version %d", parsed.Version)
+	}
+	parsed.Version = sheetFileVersion
+	if parsed.Columns == nil {
+		parsed.Columns = []SheetColumn{}
+	}
+	parsed.Data = normalizeSheetData(parsed.Data)
+	return parsed, nil
+}
+
+// validateSheet checks a sheet's settings, normalizing them, and the cells of
+// its typed columns.
+func validateSheet(sheet *sheetFile) error {
+	if err := validateSheetSettings(&sheet.SheetSettings); err != nil {
+		return err
 	}
-	return sheetFile{Data: normalizeSheetData(parsed.Data)}, nil
+	return validateSheetData(sheet.Data, sheet.SheetSettings)
 }
 
 func normalizeSheetData(data [][]string) [][]string {
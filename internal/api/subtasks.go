@@ -0,0 +1,149 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// noteTaskItems builds the API form of a note's tasks and links subtasks to
+// their parents. todos must be one note's tasks in line order.
+func noteTaskItems(relPath string, todos []ParsedTodo, meta *NoteMeta) []TaskItem {
+	tasks := make([]TaskItem, 0, len(todos))
+	indexByLine := make(map[int]int, len(todos))
+	for _, todo := range todos {
+		indexByLine[todo.LineNumber] = len(tasks)
+		tasks = append(tasks, newTaskItem(relPath, todo, meta))
+	}
+	for i, todo := range todos {
+		if todo.Parent == 0 {
+			continue
+		}
+		parent, ok := indexByLine[todo.Parent]
+		if !ok {
+			continue
+		}
+		tasks[i].ParentID = tasks[parent].ID
+		tasks[parent].Children = append(tasks[parent].Children, tasks[i].ID)
+	}
+	for i, todo := range todos {
+		if len(tasks[i].Children) == 0 {
+			continue
+		}
+		progress := subtaskProgress(taskDescendants(todos, todo.LineNumber))
+		tasks[i].Progress = &progress
+	}
+	return tasks
+}
+
+// taskDescendants returns the subtasks nested at any depth under the task on
+// lineNumber.
+func taskDescendants(todos []ParsedTodo, lineNumber int) []ParsedTodo {
+	inside := map[int]bool{lineNumber: true}
+	descendants := make([]ParsedTodo, 0)
+	for _, todo := range todos {
+		if todo.LineNumber <= lineNumber {
+			continue
+		}
+		if !inside[todo.Parent] {
+			break
+		}
+		inside[todo.LineNumber] = true
+		descendants = append(descendants, todo)
+	}
+	return descendants
+}
+
+// subtaskProgress is the percentage of subtasks that are done. Cancelled and
+// migrated subtasks do not count, and a parent whose subtasks are all
+// cancelled is complete.
+func subtaskProgress(subtasks []ParsedTodo) int {
+	total := 0
+	done := 0
+	for _, todo := range subtasks {
+		switch todo.Status {
+		case taskStatusCancelled, taskStatusMigrated:
+			continue
+		case taskStatusDone:
+			done++
+		}
+		total++
+	}
+	if total == 0 {
+		return 100
+	}
+	return done * 100 / total
+}
+
+// buildTaskTree nests subtasks under their parents. Tasks whose parent is not
+// in the list, for example after daily deduplication, become roots.
+func buildTaskTree(tasks []TaskItem) []TaskItem {
+	type taskKey struct {
+		path string
+		id   string
+	}
+	byKey := make(map[taskKey]int, len(tasks))
+	for i, task := range tasks {
+		byKey[taskKey{task.Path, task.ID}] = i
+	}
+
+	var nest func(i int) TaskItem
+	nest = func(i int) TaskItem {
+		task := tasks[i]
+		for _, childID := range task.Children {
+			if child, ok := byKey[taskKey{task.Path, childID}]; ok {
+				task.Subtasks = append(task.Subtasks, nest(child))
+			}
+		}
+		return task
+	}
+
+	roots := make([]TaskItem, 0)
+	for i, task := range tasks {
+		if task.ParentID != "" {
+			if _, ok := byKey[taskKey{task.Path, task.ParentID}]; ok {
+				continue
+			}
+		}
+		roots = append(roots, nest(i))
+	}
+	return roots
+}
+
+// wantTaskTree reports whether a task listing asked for the tree shape with
+// ?tree=true.
+func wantTaskTree(r *http.Request) bool {
+	value := strings.TrimSpace(r.URL.Query().Get("tree"))
+	if value == "" || value == "0" || strings.EqualFold(value, "false") {
+		return false
+	}
+	return true
+}
+
+// completeSubtaskLines marks the open subtasks in lines as done, adding
+// doneDate as a ✓date token when it is set, and returns the subtasks it
+// changed. Closed subtasks keep their status, and recurring subtasks are not
+// rescheduled.
+func completeSubtaskLines(lines []string, subtasks []ParsedTodo, doneDate string) []ParsedTodo {
+	completed := make([]ParsedTodo, 0, len(subtasks))
+	for _, todo := range subtasks {
+		if isClosedTaskStatus(todo.Status) {
+			continue
+		}
+		line := lines[todo.LineNumber-1]
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+			line = strings.TrimSuffix(line, "\r")
+		}
+		updated, ok := setTaskLineStatus(line, taskStatusDone)
+		if !ok {
+			continue
+		}
+		if doneDate != "" {
+			updated, _ = setTaskLineCompletedDate(updated, doneDate)
+		}
+		lines[todo.LineNumber-1] = updated + ending
+		completed = append(completed, todo)
+	}
+	return completed
+}
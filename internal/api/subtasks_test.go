@@ -0,0 +1,69 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubtaskHierarchy(t *testing.T) {
+	content := strings.Join([]string{
+		"- [ ] Launch",
+		"  - [x] Write copy",
+		"    notes about the copy",
+		"  - [ ] Build page",
+		"\t- [-] Old idea",
+		"      - [ ] Pick colours",
+		"  - plain bullet",
+		"    - [ ] Under a bullet",
+		"",
+		"Paragraph",
+		"  - [ ] Loose",
+	}, "\n")
+	todos := parseTodoLines(content)
+	parents := make([]int, 0, len(todos))
+	for _, todo := range todos {
+		parents = append(parents, todo.Parent)
+	}
+	want := []int{0, 1, 1, 4, 5, 1, 0}
+	if len(parents) != len(want) {
+		t.Fatalf("expected %d tasks, got %#v", len(want), todos)
+	}
+	for i := range want {
+		if parents[i] != want[i] {
+			t.Fatalf("expected parents %v, got %v", want, parents)
+		}
+	}
+
+	tasks := noteTaskItems("plan.md", todos, nil)
+	root := tasks[0]
+	if root.Progress == nil || *root.Progress != 25 {
+		t.Fatalf("expected 25%% progress, got %#v", root.Progress)
+	}
+	if strings.Join(root.Children, ",") != "plan.md:2,plan.md:4,plan.md:8" {
+		t.Fatalf("unexpected children %v", root.Children)
+	}
+	if tasks[4].ParentID != "plan.md:5" || tasks[1].Progress != nil {
+		t.Fatalf("unexpected links %#v", tasks[4])
+	}
+
+	descendants := taskDescendants(todos, 1)
+	if len(descendants) != 5 || descendants[len(descendants)-1].LineNumber != 8 {
+		t.Fatalf("unexpected descendants %#v", descendants)
+	}
+
+	tree := buildTaskTree(tasks)
+	if len(tree) != 2 || len(tree[0].Subtasks) != 3 || tree[0].Subtasks[1].Subtasks[0].Subtasks[0].Text != "Pick colours" {
+		t.Fatalf("unexpected tree %#v", tree)
+	}
+}
+
+func TestSubtaskProgress(t *testing.T) {
+	subtasks := []ParsedTodo{{Status: taskStatusCancelled}, {Status: taskStatusCancelled}}
+	if got := subtaskProgress(subtasks); got != 100 {
+		t.Fatalf("expected all-cancelled subtasks to count as complete, got %d", got)
+	}
+	subtasks = append(subtasks, ParsedTodo{Status: taskStatusDone}, ParsedTodo{Status: taskStatusInProgress}, ParsedTodo{Status: taskStatusOpen})
+	if got := subtaskProgress(subtasks); got != 33 {
+		t.Fatalf("expected 33, got %d", got)
+	}
+}
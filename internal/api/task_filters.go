@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 const taskFiltersFileName = "task-sets.json"
@@ -30,8 +32,21 @@ type TaskFilter struct {
 	Due        *TaskFilterDue      `json:"due,omitempty"`
 	Priority   *TaskFilterPriority `json:"priority,omitempty"`
 	Completed  *bool               `json:"completed,omitempty"`
+	Statuses   []string            `json:"statuses,omitempty"`
 	Text       string              `json:"text,omitempty"`
 	PathPrefix string              `json:"pathPrefix,omitempty"`
+	// Meta matches front matter inherited by the task. An empty value or "*"
+	// only requires the key to be present.
+	Meta map[string]string `json:"meta,omitempty"`
+	// Any matches when at least one of its filters does, and Not when none of
+	// its filters does. Nested filters need no id or name.
+	Any []TaskFilter `json:"any,omitempty"`
+	Not []TaskFilter `json:"not,omitempty"`
+	// Sort lists the keys to order matches by, each optionally prefixed with
+	// "-" to reverse it. GroupBy splits the matches into groups. Both are only
+	// used on saved filters and ad-hoc queries, not nested ones.
+	Sort    []string `json:"sort,omitempty"`
+	GroupBy string   `json:"groupBy,omitempty"`
 }
 
 type TaskFilterDue struct {
@@ -142,7 +157,188 @@ func validateTaskFilters(filters TaskFilters) error {
 		}
 		ids[idKey] = true
 		names[nameKey] = true
+		if err := validateTaskFilterCriteria(filter); err != nil {
+			return err
+		}
+		if err := validateTaskFilterOrder(filter); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// taskFilterSortKeys are the keys Sort accepts.
+var taskFilterSortKeys = map[string]bool{
+	"due":      true,
+	"priority": true,
+	"text":     true,
+	"project":  true,
+	"note":     true,
+	"status":   true,
+}
+
+// taskFilterGroupKeys are the values GroupBy accepts.
+var taskFilterGroupKeys = map[string]bool{
+	"project": true,
+	"tag":     true,
+	"due":     true,
+	"note":    true,
+}
+
+func validateTaskFilterCriteria(filter TaskFilter) error {
+	for _, status := range filter.Statuses {
+		if _, ok := taskStatusMarkers[strings.ToLower(strings.TrimSpace(status))]; !ok {
+			return errors.New("filter status must be open, done, in-progress, cancelled, deferred, blocked or migrated")
+		}
+	}
+	for key := range filter.Meta {
+		if strings.TrimSpace(key) == "" {
+			return errors.New("filter meta key is required")
+		}
+	}
+	for _, nested := range append(append([]TaskFilter{}, filter.Any...), filter.Not...) {
+		if len(nested.Sort) > 0 || nested.GroupBy != "" {
+			return errors.New("nested filters cannot sort or group")
+		}
+		if err := validateTaskFilterCriteria(nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTaskFilterOrder(filter TaskFilter) error {
+	for _, key := range filter.Sort {
+		if !taskFilterSortKeys[strings.TrimPrefix(strings.ToLower(strings.TrimSpace(key)), "-")] {
+			return errors.New("filter sort must be due, priority, text, project, note or status")
+		}
+	}
+	if groupBy := strings.ToLower(strings.TrimSpace(filter.GroupBy)); groupBy != "" && !taskFilterGroupKeys[groupBy] {
+		return errors.New("filter groupBy must be project, tag, due or note")
+	}
+	return nil
+}
+
+// findTaskFilter returns the saved filter with the given ID, compared without
+// case.
+func (filters TaskFilters) findTaskFilter(id string) (TaskFilter, bool) {
+	id = strings.TrimSpace(id)
+	for _, filter := range filters.Filters {
+		if strings.EqualFold(strings.TrimSpace(filter.ID), id) {
+			return filter, true
+		}
+	}
+	return TaskFilter{}, false
+}
+
+// taskMatchesFilter reports whether a task matches a filter. Every set
+// criterion must match; tags and mentions must all be present. Due bounds
+// accept the same dates as task due tokens, resolved against today.
+func taskMatchesFilter(task TaskItem, filter TaskFilter, today time.Time) bool {
+	if filter.Completed != nil && task.Completed != *filter.Completed {
+		return false
+	}
+	if statuses := normalizeFilterValues(filter.Statuses); len(statuses) > 0 {
+		status := strings.ToLower(task.Status)
+		if status == "" {
+			status = taskStatusOpen
+			if task.Completed {
+				status = taskStatusDone
+			}
+		}
+		if !slices.Contains(statuses, status) {
+			return false
+		}
+	}
+	if projects := normalizeFilterValues(filter.Projects); len(projects) > 0 {
+		if !slices.Contains(projects, strings.ToLower(task.Project)) {
+			return false
+		}
+	}
+	if !containsAllFilterValues(task.Tags, filter.Tags) || !containsAllFilterValues(task.Mentions, filter.Mentions) {
+		return false
+	}
+	if text := strings.ToLower(strings.TrimSpace(filter.Text)); text != "" {
+		if !strings.Contains(strings.ToLower(task.Text), text) {
+			return false
+		}
+	}
+	if prefix := strings.ToLower(strings.TrimSpace(filter.PathPrefix)); prefix != "" {
+		if !strings.HasPrefix(strings.ToLower(task.Path), prefix) {
+			return false
+		}
+	}
+	for key, value := range filter.Meta {
+		values, ok := task.Meta[strings.ToLower(strings.TrimSpace(key))]
+		if !ok || len(values) == 0 {
+			return false
+		}
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" && value != "*" && !slices.Contains(values, value) {
+			return false
+		}
+	}
+	if filter.Due != nil {
+		from, _ := resolveDueDate(strings.TrimSpace(filter.Due.From), today)
+		to, _ := resolveDueDate(strings.TrimSpace(filter.Due.To), today)
+		if from != "" || to != "" {
+			if task.DueDateISO == "" {
+				return false
+			}
+			if from != "" && task.DueDateISO < from {
+				return false
+			}
+			if to != "" && task.DueDateISO > to {
+				return false
+			}
+		}
+	}
+	if filter.Priority != nil {
+		if filter.Priority.Min != nil && task.Priority < *filter.Priority.Min {
+			return false
+		}
+		if filter.Priority.Max != nil && task.Priority > *filter.Priority.Max {
+			return false
+		}
+	}
+	if len(filter.Any) > 0 {
+		matched := false
+		for _, nested := range filter.Any {
+			if taskMatchesFilter(task, nested, today) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, nested := range filter.Not {
+		if taskMatchesFilter(task, nested, today) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeFilterValues(values []string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value != "" {
+			normalized = append(normalized, value)
+		}
+	}
+	return normalized
+}
+
+func containsAllFilterValues(have, want []string) bool {
+	have = normalizeFilterValues(have)
+	for _, value := range normalizeFilterValues(want) {
+		if !slices.Contains(have, value) {
+			return false
+		}
+	}
+	return true
+}
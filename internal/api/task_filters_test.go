@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskMatchesFilter(t *testing.T) {
+	today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	task := TaskItem{
+		Path:       "Projects/site.md",
+		Text:       "Ship landing page",
+		Status:     taskStatusInProgress,
+		Project:    "Work",
+		Tags:       []string{"web", "launch"},
+		DueDateISO: "2026-10-20",
+		Priority:   2,
+		Meta:       map[string][]string{"area": {"marketing"}},
+	}
+	intPtr := func(value int) *int { return &value }
+	boolPtr := func(value bool) *bool { return &value }
+
+	cases := []struct {
+		name   string
+		filter TaskFilter
+		want   bool
+	}{
+		{"empty", TaskFilter{}, true},
+		{"project", TaskFilter{Projects: []string{"work"}}, true},
+		{"all tags", TaskFilter{Tags: []string{"web", "launch"}}, true},
+		{"missing tag", TaskFilter{Tags: []string{"web", "ops"}}, false},
+		{"status", TaskFilter{Statuses: []string{"in-progress"}}, true},
+		{"completed", TaskFilter{Completed: boolPtr(true)}, false},
+		{"text", TaskFilter{Text: "LANDING"}, true},
+		{"path prefix", TaskFilter{PathPrefix: "projects/"}, true},
+		{"meta any", TaskFilter{Meta: map[string]string{"Area": "*"}}, true},
+		{"meta value", TaskFilter{Meta: map[string]string{"area": "sales"}}, false},
+		{"due relative", TaskFilter{Due: &TaskFilterDue{From: "today", To: "+3d"}}, true},
+		{"due before", TaskFilter{Due: &TaskFilterDue{To: "tomorrow"}}, false},
+		{"priority", TaskFilter{Priority: &TaskFilterPriority{Min: intPtr(1), Max: intPtr(2)}}, true},
+		{"priority max", TaskFilter{Priority: &TaskFilterPriority{Max: intPtr(1)}}, false},
+		{"any", TaskFilter{Any: []TaskFilter{{Projects: []string{"home"}}, {Tags: []string{"web"}}}}, true},
+		{"any none", TaskFilter{Any: []TaskFilter{{Projects: []string{"home"}}, {Tags: []string{"ops"}}}}, false},
+		{"not", TaskFilter{Not: []TaskFilter{{Statuses: []string{"blocked"}}}}, true},
+		{"not matching", TaskFilter{Projects: []string{"work"}, Not: []TaskFilter{{Text: "landing"}}}, false},
+	}
+	for _, tc := range cases {
+		if got := taskMatchesFilter(task, tc.filter, today); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestValidateTaskFilters(t *testing.T) {
+	valid := TaskFilters{Version: 1, Filters: []TaskFilter{{
+		ID:      "work",
+		Name:    "Work",
+		Any:     []TaskFilter{{Projects: []string{"work"}}, {Not: []TaskFilter{{Statuses: []string{"done"}}}}},
+		Sort:    []string{"-priority", "due"},
+		GroupBy: "project",
+	}}}
+	if err := validateTaskFilters(valid); err != nil {
+		t.Fatalf("expected filters to be valid: %v", err)
+	}
+
+	invalid := []TaskFilter{
+		{ID: "a", Name: "A", Sort: []string{"size"}},
+		{ID: "a", Name: "A", GroupBy: "mention"},
+		{ID: "a", Name: "A", Any: []TaskFilter{{Statuses: []string{"waiting"}}}},
+		{ID: "a", Name: "A", Not: []TaskFilter{{GroupBy: "tag"}}},
+	}
+	for i, filter := range invalid {
+		if err := validateTaskFilters(TaskFilters{Version: 1, Filters: []TaskFilter{filter}}); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
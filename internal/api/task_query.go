@@ -0,0 +1,353 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TaskGroup is one group of a task listing split with groupBy. An empty key
+// holds the tasks without a project, tag or due date.
+type TaskGroup struct {
+	Key   string     `json:"key"`
+	Tasks []TaskItem `json:"tasks"`
+}
+
+// Due buckets used by groupBy=due, in display order.
+const (
+	taskDueOverdue  = "overdue"
+	taskDueToday    = "today"
+	taskDueTomorrow = "tomorrow"
+	taskDueThisWeek = "this-week"
+	taskDueLater    = "later"
+)
+
+var taskDueBucketOrder = map[string]int{
+	taskDueOverdue:  0,
+	taskDueToday:    1,
+	taskDueTomorrow: 2,
+	taskDueThisWeek: 3,
+	taskDueLater:    4,
+}
+
+// taskStatusOrder ranks statuses for sort=status: open work first, closed
+// work last.
+var taskStatusOrder = map[string]int{
+	taskStatusOpen:       0,
+	taskStatusInProgress: 1,
+	taskStatusBlocked:    2,
+	taskStatusDeferred:   3,
+	taskStatusDone:       4,
+	taskStatusCancelled:  5,
+	taskStatusMigrated:   6,
+}
+
+// taskQuery is a filtered, sorted or grouped task listing. A task must match
+// every filter.
+type taskQuery struct {
+	Filters []TaskFilter
+	Sort    []string
+	GroupBy string
+}
+
+func (q taskQuery) active() bool {
+	return len(q.Filters) > 0 || len(q.Sort) > 0 || q.GroupBy != ""
+}
+
+// taskQueryFromRequest reads ?filter=<id> and the ad-hoc filter parameters of
+// a task listing. Ad-hoc criteria narrow a saved filter further, and ad-hoc
+// sort and groupBy replace its own. On failure it returns the HTTP status and
+// message to report.
+func (s *Server) taskQueryFromRequest(r *http.Request) (taskQuery, int, string) {
+	values := r.URL.Query()
+	var query taskQuery
+	if id := strings.TrimSpace(values.Get("filter")); id != "" {
+		filters, _, err := s.loadTaskFilters()
+		if err != nil {
+			return taskQuery{}, http.StatusInternalServerError, "unable to load task filters"
+		}
+		saved, ok := filters.findTaskFilter(id)
+		if !ok {
+			return taskQuery{}, http.StatusNotFound, "task filter not found"
+		}
+		query.Filters = append(query.Filters, saved)
+		query.Sort = saved.Sort
+		query.GroupBy = saved.GroupBy
+	}
+
+	adHoc, ok, err := adHocTaskFilter(values)
+	if err != nil {
+		return taskQuery{}, http.StatusBadRequest, err.Error()
+	}
+	if ok {
+		query.Filters = append(query.Filters, adHoc)
+	}
+	if sortKeys := queryList(values, "sort"); len(sortKeys) > 0 {
+		query.Sort = sortKeys
+	}
+	if groupBy := strings.TrimSpace(values.Get("groupBy")); groupBy != "" {
+		query.GroupBy = groupBy
+	}
+	if err := validateTaskFilterOrder(TaskFilter{Sort: query.Sort, GroupBy: query.GroupBy}); err != nil {
+		return taskQuery{}, http.StatusBadRequest, err.Error()
+	}
+	return query, 0, ""
+}
+
+// adHocTaskFilter builds a filter from query parameters. List parameters may
+// repeat or hold comma-separated values; meta takes key or key:value.
+func adHocTaskFilter(values url.Values) (TaskFilter, bool, error) {
+	filter := TaskFilter{
+		Tags:       queryList(values, "tag"),
+		Mentions:   queryList(values, "mention"),
+		Projects:   queryList(values, "project"),
+		Statuses:   queryList(values, "status"),
+		Text:       strings.TrimSpace(values.Get("text")),
+		PathPrefix: strings.TrimSpace(values.Get("pathPrefix")),
+	}
+	set := len(filter.Tags) > 0 || len(filter.Mentions) > 0 || len(filter.Projects) > 0 ||
+		len(filter.Statuses) > 0 || filter.Text != "" || filter.PathPrefix != ""
+
+	if raw := strings.TrimSpace(values.Get("completed")); raw != "" {
+		completed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return TaskFilter{}, false, errors.New("invalid completed")
+		}
+		filter.Completed = &completed
+		set = true
+	}
+	from := strings.TrimSpace(values.Get("dueFrom"))
+	to := strings.TrimSpace(values.Get("dueTo"))
+	if from != "" || to != "" {
+		if _, ok := resolveDueDate(from, dateOnly(timeNow())); from != "" && !ok {
+			return TaskFilter{}, false, errors.New("invalid dueFrom")
+		}
+		if _, ok := resolveDueDate(to, dateOnly(timeNow())); to != "" && !ok {
+			return TaskFilter{}, false, errors.New("invalid dueTo")
+		}
+		filter.Due = &TaskFilterDue{From: from, To: to}
+		set = true
+	}
+	for _, name := range []string{"priorityMin", "priorityMax"} {
+		raw := strings.TrimSpace(values.Get(name))
+		if raw == "" {
+			continue
+		}
+		priority, err := strconv.Atoi(raw)
+		if err != nil {
+			return TaskFilter{}, false, errors.New("invalid " + name)
+		}
+		if filter.Priority == nil {
+			filter.Priority = &TaskFilterPriority{}
+		}
+		if name == "priorityMin" {
+			filter.Priority.Min = &priority
+		} else {
+			filter.Priority.Max = &priority
+		}
+		set = true
+	}
+	for _, entry := range queryList(values, "meta") {
+		key, value, _ := strings.Cut(entry, ":")
+		if filter.Meta == nil {
+			filter.Meta = make(map[string]string)
+		}
+		filter.Meta[key] = value
+		set = true
+	}
+	if err := validateTaskFilterCriteria(filter); err != nil {
+		return TaskFilter{}, false, err
+	}
+	return filter, set, nil
+}
+
+func queryList(values url.Values, name string) []string {
+	var list []string
+	for _, raw := range values[name] {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				list = append(list, value)
+			}
+		}
+	}
+	return list
+}
+
+// apply filters, sorts and groups tasks. Filtered listings without sort keys
+// are ordered by due date, then priority, then text, as the task panel shows
+// them.
+func (q taskQuery) apply(tasks []TaskItem, today time.Time) ([]TaskItem, []TaskGroup) {
+	matched := tasks
+	if len(q.Filters) > 0 {
+		matched = make([]TaskItem, 0, len(tasks))
+		for _, task := range tasks {
+			if taskMatchesAll(task, q.Filters, today) {
+				matched = append(matched, task)
+			}
+		}
+	}
+	if len(q.Filters) > 0 || len(q.Sort) > 0 {
+		sortTasks(matched, q.Sort)
+	}
+	if q.GroupBy == "" {
+		return matched, nil
+	}
+	return matched, groupTasks(matched, strings.ToLower(strings.TrimSpace(q.GroupBy)), today)
+}
+
+func taskMatchesAll(task TaskItem, filters []TaskFilter, today time.Time) bool {
+	for _, filter := range filters {
+		if !taskMatchesFilter(task, filter, today) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortTasks orders tasks by the given keys, stably. Tasks without a due date,
+// priority or project sort last in either direction.
+func sortTasks(tasks []TaskItem, keys []string) {
+	if len(keys) == 0 {
+		keys = []string{"due", "priority", "text"}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, raw := range keys {
+			key := strings.ToLower(strings.TrimSpace(raw))
+			descending := strings.HasPrefix(key, "-")
+			key = strings.TrimPrefix(key, "-")
+			if cmp := compareTasksBy(tasks[i], tasks[j], key, descending); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+func compareTasksBy(a, b TaskItem, key string, descending bool) int {
+	switch key {
+	case "due":
+		return compareOptional(a.DueDateISO, b.DueDateISO, descending)
+	case "priority":
+		switch {
+		case a.Priority == b.Priority:
+			return 0
+		case a.Priority <= 0:
+			return 1
+		case b.Priority <= 0:
+			return -1
+		}
+		return orderedCompare(a.Priority, b.Priority, descending)
+	case "project":
+		return compareOptional(strings.ToLower(a.Project), strings.ToLower(b.Project), descending)
+	case "text":
+		return orderedCompare(strings.ToLower(a.Text), strings.ToLower(b.Text), descending)
+	case "note":
+		if cmp := orderedCompare(strings.ToLower(a.Path), strings.ToLower(b.Path), descending); cmp != 0 {
+			return cmp
+		}
+		return orderedCompare(a.LineNumber, b.LineNumber, descending)
+	case "status":
+		return orderedCompare(taskStatusOrder[a.Status], taskStatusOrder[b.Status], descending)
+	}
+	return 0
+}
+
+// compareOptional compares two values, putting empty ones last.
+func compareOptional(a, b string, descending bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	return orderedCompare(a, b, descending)
+}
+
+func orderedCompare[T int | string](a, b T, descending bool) int {
+	cmp := 0
+	switch {
+	case a < b:
+		cmp = -1
+	case a > b:
+		cmp = 1
+	}
+	if descending {
+		return -cmp
+	}
+	return cmp
+}
+
+// groupTasks splits sorted tasks into groups, keeping their order within each
+// group. A task with several tags appears under each of them.
+func groupTasks(tasks []TaskItem, groupBy string, today time.Time) []TaskGroup {
+	byKey := make(map[string][]TaskItem)
+	for _, task := range tasks {
+		for _, key := range taskGroupKeys(task, groupBy, today) {
+			byKey[key] = append(byKey[key], task)
+		}
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a == "" || b == "" {
+			return b == ""
+		}
+		if groupBy == "due" {
+			return taskDueBucketOrder[a] < taskDueBucketOrder[b]
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	groups := make([]TaskGroup, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, TaskGroup{Key: key, Tasks: byKey[key]})
+	}
+	return groups
+}
+
+func taskGroupKeys(task TaskItem, groupBy string, today time.Time) []string {
+	switch groupBy {
+	case "project":
+		return []string{task.Project}
+	case "note":
+		return []string{task.Path}
+	case "tag":
+		if len(task.Tags) == 0 {
+			return []string{""}
+		}
+		return task.Tags
+	case "due":
+		return []string{taskDueBucket(task.DueDateISO, today)}
+	}
+	return []string{""}
+}
+
+// taskDueBucket places a due date relative to today. This week means within
+// seven days.
+func taskDueBucket(dueISO string, today time.Time) string {
+	due, err := time.ParseInLocation(dailyDateLayout, dueISO, today.Location())
+	if err != nil {
+		return ""
+	}
+	switch days := int(math.Round(due.Sub(dateOnly(today)).Hours() / 24)); {
+	case days < 0:
+		return taskDueOverdue
+	case days == 0:
+		return taskDueToday
+	case days == 1:
+		return taskDueTomorrow
+	case days <= 7:
+		return taskDueThisWeek
+	default:
+		return taskDueLater
+	}
+}
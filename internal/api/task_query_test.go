@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSortTasks(t *testing.T) {
+	tasks := []TaskItem{
+		{Text: "b", DueDateISO: "2026-10-20", Priority: 0},
+		{Text: "a", DueDateISO: "", Priority: 1},
+		{Text: "c", DueDateISO: "2026-10-18", Priority: 3},
+		{Text: "d", DueDateISO: "2026-10-20", Priority: 2},
+	}
+	order := func() string {
+		out := ""
+		for _, task := range tasks {
+			out += task.Text
+		}
+		return out
+	}
+
+	sortTasks(tasks, nil)
+	if got := order(); got != "cdba" {
+		t.Fatalf("schedule order: got %q", got)
+	}
+	sortTasks(tasks, []string{"-due", "text"})
+	if got := order(); got != "bdca" {
+		t.Fatalf("descending due: got %q", got)
+	}
+	sortTasks(tasks, []string{"priority"})
+	if got := order(); got != "adcb" {
+		t.Fatalf("priority: got %q", got)
+	}
+}
+
+func TestGroupTasks(t *testing.T) {
+	today := time.Date(2026, 10, 17, 0, 0, 0, 0, time.Local)
+	tasks := []TaskItem{
+		{Text: "late", DueDateISO: "2026-10-10", Tags: []string{"web", "ops"}},
+		{Text: "someday"},
+		{Text: "soon", DueDateISO: "2026-10-22", Tags: []string{"web"}},
+		{Text: "now", DueDateISO: "2026-10-17"},
+	}
+
+	groups := groupTasks(tasks, "due", today)
+	keys := make([]string, 0, len(groups))
+	for _, group := range groups {
+		keys = append(keys, group.Key)
+	}
+	if len(keys) != 4 || keys[0] != taskDueOverdue || keys[1] != taskDueToday || keys[2] != taskDueThisWeek || keys[3] != "" {
+		t.Fatalf("unexpected due groups %v", keys)
+	}
+
+	groups = groupTasks(tasks, "tag", today)
+	if len(groups) != 3 || groups[0].Key != "ops" || groups[1].Key != "web" || len(groups[1].Tasks) != 2 || groups[2].Key != "" {
+		t.Fatalf("unexpected tag groups %#v", groups)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -17,30 +18,65 @@ type TaskItem struct {
 	LineHash   string   `json:"lineHash"`
 	Text       string   `json:"text"`
 	Completed  bool     `json:"completed"`
+	Status     string   `json:"status"`
 	Project    string   `json:"project"`
 	Tags       []string `json:"tags"`
 	Mentions   []string `json:"mentions"`
 	DueDate    string   `json:"dueDate,omitempty"`
 	DueDateISO string   `json:"dueDateISO,omitempty"`
 	Priority   int      `json:"priority,omitempty"`
+	Recurrence string   `json:"recurrence,omitempty"`
+	// CompletedDate is the day from the task's ✓date token.
+	CompletedDate string `json:"completedDate,omitempty"`
+	// Meta holds the front matter of the task's note, lowercased, so task
+	// filters can match on it.
+	Meta map[string][]string `json:"meta,omitempty"`
+	// ParentID and Children link subtasks, nested by indentation, within a
+	// note. Progress is the percentage of done subtasks and is only set on
+	// parents.
+	ParentID string   `json:"parentId,omitempty"`
+	Children []string `json:"children,omitempty"`
+	Progress *int     `json:"progress,omitempty"`
+	// Subtasks holds the nested children when tasks are listed as a tree.
+	Subtasks []TaskItem `json:"subtasks,omitempty"`
 }
 
 type TaskListResponse struct {
-	Tasks  []TaskItem `json:"tasks"`
-	Notice string     `json:"notice,omitempty"`
+	Tasks  []TaskItem  `json:"tasks"`
+	Groups []TaskGroup `json:"groups,omitempty"`
+	Notice string      `json:"notice,omitempty"`
 }
 
+// Task payloads address a task by path, lineNumber and lineHash, or by a block
+// ID such as t-3f9a, in which case path is only a hint.
 type TaskTogglePayload struct {
 	Path       string `json:"path"`
 	LineNumber int    `json:"lineNumber"`
 	LineHash   string `json:"lineHash"`
+	ID         string `json:"id,omitempty"`
 	Completed  bool   `json:"completed"`
 }
 
+// TaskToggleResponse reports the follow-up task inserted when a recurring task
+// is completed.
+type TaskToggleResponse struct {
+	Status string    `json:"status"`
+	Next   *TaskItem `json:"next,omitempty"`
+}
+
+type TaskStatusPayload struct {
+	Path       string `json:"path"`
+	LineNumber int    `json:"lineNumber"`
+	LineHash   string `json:"lineHash"`
+	ID         string `json:"id,omitempty"`
+	Status     string `json:"status"`
+}
+
 type TaskDuePayload struct {
 	Path       string `json:"path"`
 	LineNumber int    `json:"lineNumber"`
 	LineHash   string `json:"lineHash"`
+	ID         string `json:"id,omitempty"`
 	DueDate    string `json:"dueDate"`
 }
 
@@ -49,14 +85,31 @@ type TaskArchiveResponse struct {
 	Files    int `json:"files"`
 }
 
+type TaskAssignIDsResponse struct {
+	Assigned int `json:"assigned"`
+	Files    int `json:"files"`
+}
+
 func (s *Server) handleTasksList(w http.ResponseWriter, r *http.Request) {
+	query, code, msg := s.taskQueryFromRequest(r)
+	if code != 0 {
+		writeError(w, code, msg)
+		return
+	}
 	tasks, notice, err := s.listTasks()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "unable to load tasks")
 		return
 	}
+	var groups []TaskGroup
+	if query.active() {
+		tasks, groups = query.apply(tasks, dateOnly(timeNow()))
+	}
+	if wantTaskTree(r) {
+		tasks = buildTaskTree(tasks)
+	}
 
-	resp := TaskListResponse{Tasks: tasks}
+	resp := TaskListResponse{Tasks: tasks, Groups: groups}
 	if notice != "" {
 		resp.Notice = notice
 	}
@@ -91,29 +144,19 @@ func (s *Server) handleTasksForNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parsed := parseTodoLines(string(data))
-	tasks := make([]TaskItem, 0, len(parsed))
+	content := string(data)
+	meta := parseNoteMeta(content)
+	parsed := parseTodoLines(maskFrontMatter(content))
+	tasks := noteTaskItems(relPath, parsed, meta)
 	var warnings []string
 	for _, todo := range parsed {
-		task := TaskItem{
-			ID:         fmt.Sprintf("%s:%d", relPath, todo.LineNumber),
-			Path:       relPath,
-			LineNumber: todo.LineNumber,
-			LineHash:   todo.LineHash,
-			Text:       todo.Text,
-			Completed:  todo.Completed,
-			Project:    todo.Project,
-			Tags:       todo.Tags,
-			Mentions:   todo.Mentions,
-			DueDate:    todo.DueDateRaw,
-			DueDateISO: todo.DueDateISO,
-			Priority:   todo.Priority,
-		}
 		if todo.DueDateRaw != "" && !todo.DueDateValid {
 			warnings = append(warnings, fmt.Sprintf("%s:%d (%s)", relPath, todo.LineNumber, todo.DueDateRaw))
 			s.logger.Warn("unrecognized due date", "path", relPath, "line", todo.LineNumber, "value", todo.DueDateRaw)
 		}
-		tasks = append(tasks, task)
+	}
+	if wantTaskTree(r) {
+		tasks = buildTaskTree(tasks)
 	}
 
 	notice := ""
@@ -142,79 +185,160 @@ func (s *Server) handleTasksToggle(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if strings.TrimSpace(payload.Path) == "" {
-		writeError(w, http.StatusBadRequest, "path is required")
+	ref := taskRef{Path: payload.Path, LineNumber: payload.LineNumber, LineHash: payload.LineHash, ID: strings.TrimSpace(payload.ID)}
+	if err := ref.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if payload.LineNumber <= 0 {
-		writeError(w, http.StatusBadRequest, "lineNumber must be positive")
-		return
+
+	status := taskStatusOpen
+	if payload.Completed {
+		status = taskStatusDone
 	}
+	s.writeTaskStatus(w, ref, status)
+}
 
-	absPath, relPath, err := s.resolvePath(payload.Path)
+func (s *Server) handleTasksStatus(w http.ResponseWriter, r *http.Request) {
+	payload, err := decodeJSON[TaskStatusPayload](r.Body)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if !isMarkdown(absPath) {
-		writeError(w, http.StatusBadRequest, "not a note file")
+	ref := taskRef{Path: payload.Path, LineNumber: payload.LineNumber, LineHash: payload.LineHash, ID: strings.TrimSpace(payload.ID)}
+	if err := ref.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	status := strings.ToLower(strings.TrimSpace(payload.Status))
+	if _, ok := taskStatusMarkers[status]; !ok {
+		writeError(w, http.StatusBadRequest, "status must be open, done, in-progress, cancelled, deferred, blocked or migrated")
+		return
+	}
+	s.writeTaskStatus(w, ref, status)
+}
 
-	data, err := os.ReadFile(absPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			writeError(w, http.StatusNotFound, "note not found")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "unable to read note")
+// writeTaskStatus sets the checkbox of a task line and writes the response for
+// the toggle and status endpoints.
+func (s *Server) writeTaskStatus(w http.ResponseWriter, ref taskRef, status string) {
+	resp, code, msg := s.updateTaskStatus(ref, status)
+	if code != 0 {
+		writeError(w, code, msg)
 		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	lines := strings.Split(string(data), "\n")
-	lineIndex := payload.LineNumber - 1
-	if lineIndex < 0 || lineIndex >= len(lines) || !lineHashMatches(lines[lineIndex], payload.LineHash) {
-		if payload.LineHash == "" {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
-		}
-		found := false
-		for i, line := range lines {
-			if lineHashMatches(line, payload.LineHash) {
-				lineIndex = i
-				found = true
-				break
-			}
-		}
-		if !found {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
+// updateTaskStatus sets the checkbox of a task line, completing subtasks,
+// scheduling the next occurrence of a recurring task and logging completions
+// as configured. On failure it returns the HTTP status and message to report.
+func (s *Server) updateTaskStatus(ref taskRef, status string) (TaskToggleResponse, int, string) {
+	return s.updateTaskStatusDue(ref, status, nil)
+}
+
+// updateTaskStatusDue is updateTaskStatus that, when due is not nil, first
+// sets the due date as updateTaskDue does, so both land in one write.
+func (s *Server) updateTaskStatusDue(ref taskRef, status string, due *string) (TaskToggleResponse, int, string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	loc, code, msg := s.findTask(ref)
+	if code != 0 {
+		return TaskToggleResponse{}, code, msg
+	}
+	absPath, relPath, lines, lineIndex := loc.AbsPath, loc.RelPath, loc.Lines, loc.Index
+	originalLine, lineEnding := loc.Line, loc.Ending
+	if due != nil {
+		dueLine, _, code, msg := taskLineWithDue(originalLine, relPath, *due)
+		if code != 0 {
+			return TaskToggleResponse{}, code, msg
 		}
+		originalLine = dueLine
+		lines[lineIndex] = dueLine + lineEnding
 	}
 
-	originalLine := lines[lineIndex]
-	lineEnding := ""
-	if strings.HasSuffix(originalLine, "\r") {
-		lineEnding = "\r"
-		originalLine = strings.TrimSuffix(originalLine, "\r")
+	settings, _, err := s.loadSettings()
+	if err != nil {
+		s.logger.Warn("unable to load settings", "error", err)
+	}
+	todos := parseTodoLines(maskFrontMatter(strings.Join(lines, "\n")))
+	subtasks := taskDescendants(todos, lineIndex+1)
+	wasDone := todoCompletedPattern.MatchString(originalLine)
+	completing := status == taskStatusDone && !wasDone
+	doneDate := ""
+	if completing && settings.CompletionDates {
+		doneDate = timeNow().Format(dailyDateLayout)
 	}
 
-	updatedLine, ok := setTaskLineCompletion(originalLine, payload.Completed)
+	updatedLine, ok := setTaskLineStatus(originalLine, status)
 	if !ok {
-		writeError(w, http.StatusBadRequest, "line is not a task")
-		return
+		return TaskToggleResponse{}, http.StatusBadRequest, "line is not a task"
+	}
+	// Reopening a task drops its completion date.
+	if doneDate != "" || (status != taskStatusDone && taskDoneDatePattern.MatchString(stripInlineCode(updatedLine))) {
+		updatedLine, _ = setTaskLineCompletedDate(updatedLine, doneDate)
 	}
 	lines[lineIndex] = updatedLine + lineEnding
 
+	var completedSubtasks []ParsedTodo
+	if status == taskStatusDone && len(subtasks) > 0 && settings.CompleteSubtasks {
+		completedSubtasks = completeSubtaskLines(lines, subtasks, doneDate)
+	}
+
+	// Completing a recurring task that was not already done keeps the
+	// completed line and adds the next occurrence below it and its subtasks.
+	nextLineIndex := -1
+	if completing {
+		if nextLine, ok := nextRecurringTaskLine(originalLine, dueDateAnchor(relPath)); ok {
+			nextLineIndex = lineIndex + 1
+			if len(subtasks) > 0 {
+				nextLineIndex = subtasks[len(subtasks)-1].LineNumber
+			}
+			lines = slices.Insert(lines, nextLineIndex, nextLine+lineEnding)
+		}
+	}
+
 	updated := strings.Join(lines, "\n")
+	if nextLineIndex >= 0 {
+		updated, _ = s.assignNoteBlockIDs(relPath, updated)
+	}
 	if err := os.WriteFile(absPath, []byte(updated), 0o644); err != nil {
 		s.logger.Error("unable to update task line", "path", relPath, "line", lineIndex+1, "error", err)
-		writeError(w, http.StatusInternalServerError, "unable to update note")
-		return
+		return TaskToggleResponse{}, http.StatusInternalServerError, "unable to update note"
 	}
 
-	s.logger.Info("task toggled", "path", relPath, "line", lineIndex+1, "completed", payload.Completed)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	s.logger.Info("task status updated", "path", relPath, "line", lineIndex+1, "status", status)
+	if len(completedSubtasks) > 0 {
+		s.logger.Info("subtasks completed", "path", relPath, "line", lineIndex+1, "count", len(completedSubtasks))
+	}
+
+	meta := parseNoteMeta(updated)
+	updatedTodos := make(map[int]ParsedTodo)
+	for _, todo := range parseTodoLines(maskFrontMatter(updated)) {
+		updatedTodos[todo.LineNumber] = todo
+	}
+	var logEntries []TaskLogEntry
+	switch {
+	case completing:
+		logEntries = append(logEntries, newTaskLogEntry(taskLogDone, newTaskItem(relPath, updatedTodos[lineIndex+1], meta)))
+		for _, todo := range completedSubtasks {
+			logEntries = append(logEntries, newTaskLogEntry(taskLogDone, newTaskItem(relPath, updatedTodos[todo.LineNumber], meta)))
+		}
+	case wasDone && status != taskStatusDone:
+		logEntries = append(logEntries, newTaskLogEntry(taskLogReopened, newTaskItem(relPath, updatedTodos[lineIndex+1], meta)))
+	}
+	if err := s.appendTaskLog(logEntries...); err != nil {
+		s.logger.Warn("unable to append task log", "path", relPath, "error", err)
+	}
+
+	resp := TaskToggleResponse{Status: "updated"}
+	if nextLineIndex >= 0 {
+		if todo, ok := updatedTodos[nextLineIndex+1]; ok {
+			next := newTaskItem(relPath, todo, meta)
+			resp.Next = &next
+			s.logger.Info("recurring task scheduled", "path", relPath, "line", todo.LineNumber, "due", todo.DueDateISO)
+		}
+	}
+	return resp, 0, ""
 }
 
 func (s *Server) handleTasksDue(w http.ResponseWriter, r *http.Request) {
@@ -223,12 +347,9 @@ func (s *Server) handleTasksDue(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	if strings.TrimSpace(payload.Path) == "" {
-		writeError(w, http.StatusBadRequest, "path is required")
-		return
-	}
-	if payload.LineNumber <= 0 {
-		writeError(w, http.StatusBadRequest, "lineNumber must be positive")
+	ref := taskRef{Path: payload.Path, LineNumber: payload.LineNumber, LineHash: payload.LineHash, ID: strings.TrimSpace(payload.ID)}
+	if err := ref.validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	rawDue := strings.TrimSpace(payload.DueDate)
@@ -236,76 +357,61 @@ func (s *Server) handleTasksDue(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "dueDate is required")
 		return
 	}
-	dueISO, ok := normalizeDueDate(rawDue)
-	if !ok {
-		writeError(w, http.StatusBadRequest, "invalid dueDate")
-		return
-	}
-
-	absPath, relPath, err := s.resolvePath(payload.Path)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
-		return
-	}
-	if !isMarkdown(absPath) {
-		writeError(w, http.StatusBadRequest, "not a note file")
-		return
-	}
 
-	data, err := os.ReadFile(absPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			writeError(w, http.StatusNotFound, "note not found")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "unable to read note")
+	if code, msg := s.updateTaskDue(ref, rawDue); code != 0 {
+		writeError(w, code, msg)
 		return
 	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
 
-	lines := strings.Split(string(data), "\n")
-	lineIndex := payload.LineNumber - 1
-	if lineIndex < 0 || lineIndex >= len(lines) || !lineHashMatches(lines[lineIndex], payload.LineHash) {
-		if payload.LineHash == "" {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
-		}
-		found := false
-		for i, line := range lines {
-			if lineHashMatches(line, payload.LineHash) {
-				lineIndex = i
-				found = true
-				break
-			}
-		}
-		if !found {
-			writeError(w, http.StatusBadRequest, "task not found")
-			return
-		}
-	}
+// updateTaskDue sets the due date of a task line, or removes it when rawDue is
+// empty. Relative dates are resolved against the note's anchor date. On
+// failure it returns the HTTP status and message to report.
+func (s *Server) updateTaskDue(ref taskRef, rawDue string) (int, string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 
-	originalLine := lines[lineIndex]
-	lineEnding := ""
-	if strings.HasSuffix(originalLine, "\r") {
-		lineEnding = "\r"
-		originalLine = strings.TrimSuffix(originalLine, "\r")
+	loc, code, msg := s.findTask(ref)
+	if code != 0 {
+		return code, msg
 	}
-
-	updatedLine, ok := setTaskLineDueDate(originalLine, dueISO)
-	if !ok {
-		writeError(w, http.StatusBadRequest, "line is not a task")
-		return
+	absPath, relPath, lines, lineIndex := loc.AbsPath, loc.RelPath, loc.Lines, loc.Index
+	updatedLine, dueISO, code, msg := taskLineWithDue(loc.Line, relPath, rawDue)
+	if code != 0 {
+		return code, msg
 	}
-	lines[lineIndex] = updatedLine + lineEnding
+	lines[lineIndex] = updatedLine + loc.Ending
 
 	updated := strings.Join(lines, "\n")
 	if err := os.WriteFile(absPath, []byte(updated), 0o644); err != nil {
 		s.logger.Error("unable to update task due date", "path", relPath, "line", lineIndex+1, "error", err)
-		writeError(w, http.StatusInternalServerError, "unable to update note")
-		return
+		return http.StatusInternalServerError, "unable to update note"
 	}
 
 	s.logger.Info("task due date updated", "path", relPath, "line", lineIndex+1, "due", dueISO)
-	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	return 0, ""
+}
+
+// taskLineWithDue sets or, for an empty rawDue, removes the due token of a
+// task line in the note at relPath. It also returns the resolved date.
+func taskLineWithDue(line, relPath, rawDue string) (string, string, int, string) {
+	if rawDue == "" {
+		updated, ok := replaceTaskLineToken(line, taskDuePattern, "")
+		if !ok {
+			return "", "", http.StatusBadRequest, "line is not a task"
+		}
+		return updated, "", 0, ""
+	}
+	dueISO, ok := resolveDueDate(rawDue, dueDateAnchor(relPath))
+	if !ok {
+		return "", "", http.StatusBadRequest, "invalid dueDate"
+	}
+	updated, ok := setTaskLineDueDate(line, dueISO)
+	if !ok {
+		return "", "", http.StatusBadRequest, "line is not a task"
+	}
+	return updated, dueISO, 0, ""
 }
 
 func (s *Server) handleTasksArchive(w http.ResponseWriter, r *http.Request) {
@@ -333,50 +439,19 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 	records := make([]dailyTaskRecord, 0)
 	bestByKey := make(map[string]dailyCandidate)
 
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			if isAiDir(d.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if isIgnoredFile(d.Name()) || !isMarkdown(d.Name()) {
-			return nil
-		}
-
-		rel, err := filepath.Rel(s.notesDir, path)
-		if err != nil {
-			return err
-		}
-		rel = filepath.ToSlash(rel)
-
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		parsed := parseTodoLines(string(data))
-		for _, todo := range parsed {
-			task := TaskItem{
-				ID:         fmt.Sprintf("%s:%d", rel, todo.LineNumber),
-				Path:       rel,
-				LineNumber: todo.LineNumber,
-				LineHash:   todo.LineHash,
-				Text:       todo.Text,
-				Completed:  todo.Completed,
-				Project:    todo.Project,
-				Tags:       todo.Tags,
-				Mentions:   todo.Mentions,
-				DueDate:    todo.DueDateRaw,
-				DueDateISO: todo.DueDateISO,
-				Priority:   todo.Priority,
-			}
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, note := range notes {
+		rel := note.Path
+		items := noteTaskItems(rel, note.Todos, note.Meta)
+		for i, todo := range note.Todos {
+			task := items[i]
 			record := dailyTaskRecord{
 				task: task,
 			}
-			if !todo.Completed {
+			if !isClosedTaskStatus(todo.Status) {
 				if noteDate, ok := parseDailyNoteDate(rel); ok {
 					record.dailyEligible = true
 					record.noteDate = noteDate
@@ -399,10 +474,6 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 			}
 			records = append(records, record)
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, "", err
 	}
 
 	notice := ""
@@ -436,6 +507,40 @@ func (s *Server) listTasks() ([]TaskItem, string, error) {
 	return tasks, notice, nil
 }
 
+// newTaskItem builds the API form of a parsed task. Tasks without a +project
+// take the project from their note's front matter, and relative due dates are
+// resolved against the note's anchor date.
+func newTaskItem(relPath string, todo ParsedTodo, meta *NoteMeta) TaskItem {
+	task := TaskItem{
+		ID:            fmt.Sprintf("%s:%d", relPath, todo.LineNumber),
+		Path:          relPath,
+		LineNumber:    todo.LineNumber,
+		LineHash:      todo.LineHash,
+		Text:          todo.Text,
+		Completed:     todo.Completed,
+		Status:        todo.Status,
+		Project:       todo.Project,
+		Tags:          todo.Tags,
+		Mentions:      todo.Mentions,
+		DueDate:       todo.DueDateRaw,
+		DueDateISO:    todo.DueDateISO,
+		Priority:      todo.Priority,
+		Recurrence:    todo.Recurrence,
+		Meta:          meta.metaValues(),
+		CompletedDate: todo.CompletedDate,
+	}
+	if todo.BlockID != "" {
+		task.ID = todo.BlockID
+	}
+	if todo.DueDateRelative {
+		task.DueDateISO, _ = resolveDueDate(todo.DueDateRaw, dueDateAnchor(relPath))
+	}
+	if task.Project == "" && meta != nil {
+		task.Project = meta.Project
+	}
+	return task
+}
+
 type dailyCandidate struct {
 	id       string
 	noteDate time.Time
@@ -519,6 +624,24 @@ func dateOnly(value time.Time) time.Time {
 	return time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, value.Location())
 }
 
+// findTaskLine returns the index of the task at lineNumber, or of the first
+// line matching lineHash when the note has shifted since it was listed.
+func findTaskLine(lines []string, lineNumber int, lineHash string) (int, bool) {
+	lineIndex := lineNumber - 1
+	if lineIndex >= 0 && lineIndex < len(lines) && lineHashMatches(lines[lineIndex], lineHash) {
+		return lineIndex, true
+	}
+	if lineHash == "" {
+		return 0, false
+	}
+	for i, line := range lines {
+		if lineHashMatches(line, lineHash) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 func lineHashMatches(line, hash string) bool {
 	if hash == "" {
 		return false
@@ -528,26 +651,24 @@ func lineHashMatches(line, hash string) bool {
 }
 
 func (s *Server) archiveCompletedTasks() (int, int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
 	archived := 0
 	filesUpdated := 0
 
-	err := filepath.WalkDir(s.notesDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			if isAiDir(d.Name()) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		if isIgnoredFile(d.Name()) || !isMarkdown(d.Name()) {
-			return nil
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, note := range notes {
+		if !note.Archivable {
+			continue
 		}
-
+		path := filepath.Join(s.notesDir, filepath.FromSlash(note.Path))
 		data, err := os.ReadFile(path)
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 		lines := strings.Split(string(data), "\n")
 		changed := false
@@ -561,17 +682,13 @@ func (s *Server) archiveCompletedTasks() (int, int, error) {
 			changed = true
 		}
 		if !changed {
-			return nil
+			continue
 		}
 		output := strings.Join(lines, "\n")
 		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
-			return err
+			return 0, 0, err
 		}
 		filesUpdated += 1
-		return nil
-	})
-	if err != nil {
-		return 0, 0, err
 	}
 	if archived > 0 {
 		s.logger.Info("archived completed tasks", "count", archived, "files", filesUpdated)
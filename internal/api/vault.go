@@ -0,0 +1,140 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultCache holds the parsed form of every note so the task, tag, mention,
+// search and graph endpoints answer from memory. A note is re-read only when
+// its modification time or size changes.
+type vaultCache struct {
+	mu    sync.Mutex
+	notes map[string]*vaultNote
+}
+
+// vaultNote is what the API derives from a single note. Entries are never
+// modified once built, so snapshots can be shared without copying.
+type vaultNote struct {
+	Path     string
+	Modified time.Time
+	Size     int64
+	Todos    []ParsedTodo
+	Tags     []string
+	Mentions []string
+	Headings []vaultHeading
+	Links    []parsedWikiLink
+	Meta     *NoteMeta
+	// Archivable is set when a line would be rewritten by the archive
+	// endpoint, which also matches closed tasks inside code blocks.
+	Archivable bool
+}
+
+type vaultHeading struct {
+	Level int
+	Text  string
+	Line  int
+}
+
+// vaultNotes refreshes the cache against the notes on disk and returns every
+// note in walk order.
+func (s *Server) vaultNotes() ([]*vaultNote, error) {
+	infos, err := listMarkdownNotes(s.notesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.vault.mu.Lock()
+	defer s.vault.mu.Unlock()
+	if s.vault.notes == nil {
+		s.vault.notes = make(map[string]*vaultNote)
+	}
+
+	notes := make([]*vaultNote, 0, len(infos))
+	current := make(map[string]*vaultNote, len(infos))
+	for _, info := range infos {
+		note, ok := s.vault.notes[info.Path]
+		if !ok || !note.Modified.Equal(info.Modified) || note.Size != info.Size {
+			data, err := os.ReadFile(filepath.Join(s.notesDir, filepath.FromSlash(info.Path)))
+			if err != nil {
+				continue
+			}
+			note = newVaultNote(info, string(data))
+		}
+		current[info.Path] = note
+		notes = append(notes, note)
+	}
+	s.vault.notes = current
+	return notes, nil
+}
+
+// noteInfos lists the notes with their front matter aliases, for link
+// resolution.
+func noteInfos(notes []*vaultNote) []noteInfo {
+	infos := make([]noteInfo, 0, len(notes))
+	for _, note := range notes {
+		info := noteInfo{Path: note.Path, Modified: note.Modified, Size: note.Size}
+		if note.Meta != nil {
+			info.Aliases = note.Meta.Aliases
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// vaultNoteMap is vaultNotes keyed by path.
+func (s *Server) vaultNoteMap() (map[string]*vaultNote, error) {
+	notes, err := s.vaultNotes()
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*vaultNote, len(notes))
+	for _, note := range notes {
+		byPath[note.Path] = note
+	}
+	return byPath, nil
+}
+
+// newVaultNote parses a note. Front matter is read into Meta and hidden from
+// the inline parsers; its tags are added after the inline ones.
+func newVaultNote(info noteInfo, content string) *vaultNote {
+	meta := parseNoteMeta(content)
+	content = maskFrontMatter(content)
+	cleaned := stripCodeBlocksAndInline(content)
+	note := &vaultNote{
+		Path:     info.Path,
+		Modified: info.Modified,
+		Size:     info.Size,
+		Todos:    parseTodoLines(content),
+		Tags:     extractNoteTags(cleaned),
+		Mentions: extractNoteMentions(cleaned),
+		Headings: make([]vaultHeading, 0),
+		Links:    parseWikiLinks(content),
+		Meta:     meta,
+	}
+	if meta != nil {
+		note.Tags = append(note.Tags, meta.Tags...)
+	}
+	tracker := &codeBlockTracker{}
+	for i, line := range strings.Split(content, "\n") {
+		if todoClosedPattern.MatchString(line) {
+			note.Archivable = true
+		}
+		if tracker.isCodeLine(line) {
+			continue
+		}
+		match := headingPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		note.Headings = append(note.Headings, vaultHeading{
+			Level: len(match[1]),
+			Text:  strings.TrimSpace(match[2]),
+			Line:  i + 1,
+		})
+	}
+	return note
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultNotesCache(t *testing.T) {
+	dir := t.TempDir()
+	s := &Server{notesDir: dir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	writeFile(t, filepath.Join(dir, "Work", "plan.md"), "# Plan\n- [ ] ship +launch #work\n```\n# not a heading #code\n```\nSee [[Budget]] @alice\n")
+	writeFile(t, filepath.Join(dir, "Home.md"), "## Home\n")
+	writeFile(t, filepath.Join(dir, ".history", "Work", "plan.md", "old.md"), "# Old\n")
+
+	notes, err := s.vaultNoteMap()
+	if err != nil {
+		t.Fatalf("vault: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %d", len(notes))
+	}
+	plan := notes["Work/plan.md"]
+	if plan == nil {
+		t.Fatalf("expected Work/plan.md in vault")
+	}
+	if len(plan.Todos) != 1 || plan.Todos[0].Project != "launch" {
+		t.Fatalf("unexpected todos %#v", plan.Todos)
+	}
+	if len(plan.Headings) != 1 || plan.Headings[0].Text != "Plan" || plan.Headings[0].Level != 1 {
+		t.Fatalf("unexpected headings %#v", plan.Headings)
+	}
+	if len(plan.Tags) != 1 || plan.Tags[0] != "work" {
+		t.Fatalf("unexpected tags %#v", plan.Tags)
+	}
+	if len(plan.Mentions) != 1 || plan.Mentions[0] != "alice" {
+		t.Fatalf("unexpected mentions %#v", plan.Mentions)
+	}
+	if len(plan.Links) != 1 || plan.Links[0].Target != "Budget" {
+		t.Fatalf("unexpected links %#v", plan.Links)
+	}
+	if plan.Archivable {
+		t.Fatalf("expected no archivable tasks")
+	}
+
+	writeFile(t, filepath.Join(dir, "Work", "plan.md"), "- [x] shipped\n")
+	again, err := s.vaultNoteMap()
+	if err != nil {
+		t.Fatalf("vault: %v", err)
+	}
+	if again["Home.md"] != notes["Home.md"] {
+		t.Fatalf("expected unchanged note to be reused")
+	}
+	if again["Work/plan.md"] == plan || !again["Work/plan.md"].Archivable {
+		t.Fatalf("expected changed note to be re-read")
+	}
+}
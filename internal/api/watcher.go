@@ -0,0 +1,387 @@
+package api
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	watcherDebounce = 250 * time.Millisecond
+	// watcherMaxWait bounds how long a steady stream of notifications can
+	// hold back a flush.
+	watcherMaxWait = 2 * time.Second
+)
+
+const (
+	watchedNote  = "note"
+	watchedSheet = "sheet"
+)
+
+// noteWatcher turns filesystem notifications for the notes tree into change
+// events. Notifications are debounced and then compared against the last
+// known state of each file, so editors that write through temp files or
+// delete-and-recreate still produce a single update.
+type noteWatcher struct {
+	server  *Server
+	root    string
+	fs      *fsnotify.Watcher
+	pending map[string]fsnotify.Op
+	files   map[string]watchedFile
+	dirs    map[string]bool
+}
+
+type watchedFile struct {
+	kind     string
+	modified int64
+	size     int64
+	tasks    string
+}
+
+// startWatcher watches the notes tree until ctx is done; watcherDone is
+// closed once the watcher has stopped.
+func (s *Server) startWatcher(ctx context.Context) {
+	s.watcherOnce.Do(func() {
+		fsWatcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			s.logger.Error("watcher init failed", "error", err)
+			return
+		}
+		w := &noteWatcher{
+			server:  s,
+			root:    filepath.Clean(s.notesDir),
+			fs:      fsWatcher,
+			pending: make(map[string]fsnotify.Op),
+			files:   make(map[string]watchedFile),
+			dirs:    make(map[string]bool),
+		}
+		if err := fsWatcher.Add(w.root); err != nil {
+			s.logger.Error("watcher init failed", "error", err)
+			fsWatcher.Close()
+			return
+		}
+		s.watcherDone = make(chan struct{})
+		go func() {
+			defer close(s.watcherDone)
+			w.run(ctx)
+		}()
+	})
+}
+
+func (w *noteWatcher) run(ctx context.Context) {
+	defer w.fs.Close()
+	w.addTree(w.root, nil)
+
+	timer := time.NewTimer(watcherDebounce)
+	timer.Stop()
+	var firstPending time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == w.root && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+				w.server.logger.Warn("notes dir removed, watcher stopped", "path", w.root)
+				return
+			}
+			now := time.Now()
+			if len(w.pending) == 0 {
+				firstPending = now
+			}
+			w.pending[event.Name] |= event.Op
+			timer.Reset(watcherDelay(firstPending, now))
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			w.server.logger.Warn("watcher error", "error", err)
+		case <-timer.C:
+			w.server.events.publish(w.flush()...)
+		}
+	}
+}
+
+// watcherDelay is the debounce before flushing a batch whose first pending
+// notification arrived at first. It never runs past watcherMaxWait.
+func watcherDelay(first, now time.Time) time.Duration {
+	remaining := watcherMaxWait - now.Sub(first)
+	if remaining < 0 {
+		return 0
+	}
+	if remaining < watcherDebounce {
+		return remaining
+	}
+	return watcherDebounce
+}
+
+// addTree watches absDir and every directory below it and records the files
+// it contains. Each newly seen file is passed to found when it is non-nil.
+func (w *noteWatcher) addTree(absDir string, found func(rel string, file watchedFile)) {
+	_ = filepath.WalkDir(absDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, ok := w.relPath(path)
+		if !ok {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if rel != "" {
+				if w.dirs[rel] {
+					return nil
+				}
+				w.dirs[rel] = true
+			}
+			if err := w.fs.Add(path); err != nil {
+				w.server.logger.Warn("watcher add failed", "path", rel, "error", err)
+			}
+			return nil
+		}
+		if _, known := w.files[rel]; known {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if file, ok := w.readFile(path, rel, info); ok {
+			w.files[rel] = file
+			if found != nil {
+				found(rel, file)
+			}
+		}
+		return nil
+	})
+}
+
+// relPath maps an absolute path to its slash-separated path below the notes
+// root. Server data, version-control and other dot directories are skipped.
+func (w *noteWatcher) relPath(absPath string) (string, bool) {
+	rel, err := filepath.Rel(w.root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	rel = filepath.ToSlash(rel)
+	for _, part := range strings.Split(rel, "/") {
+		if isHiddenDir(part) || isIgnoredFile(part) || strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	return rel, true
+}
+
+func (w *noteWatcher) readFile(absPath, rel string, info os.FileInfo) (watchedFile, bool) {
+	file := watchedFile{modified: info.ModTime().UnixNano(), size: info.Size()}
+	inSheets := strings.HasPrefix(strings.ToLower(rel), strings.ToLower(sheetsFolderName)+"/")
+	switch {
+	case inSheets && isSheetFile(rel):
+		file.kind = watchedSheet
+	case !inSheets && isNoteFile(rel):
+		file.kind = watchedNote
+		data, err := os.ReadFile(absPath)
+		if err != nil {
+			return watchedFile{}, false
+		}
+		file.tasks = taskSignature(string(data))
+	default:
+		return watchedFile{}, false
+	}
+	return file, true
+}
+
+// taskSignature summarises the task lines of a note and the project they
+// inherit, so content edits that leave tasks alone do not announce task
+// changes.
+func taskSignature(content string) string {
+	todos := parseTodoLines(maskFrontMatter(content))
+	if len(todos) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	if meta := parseNoteMeta(content); meta != nil {
+		b.WriteString(meta.Project)
+		b.WriteByte('\n')
+	}
+	for _, todo := range todos {
+		b.WriteString(strconv.Itoa(todo.LineNumber))
+		b.WriteByte(':')
+		b.WriteString(todo.LineHash)
+		b.WriteByte('\n')
+	}
+	return hashLine(b.String())
+}
+
+// flush classifies the pending notifications against the known state and
+// returns the resulting events. A deleted and a created file of the same kind,
+// size and modification time in one batch are reported as a rename.
+func (w *noteWatcher) flush() []ChangeEvent {
+	paths := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	w.pending = make(map[string]fsnotify.Op)
+
+	created := make(map[string]watchedFile)
+	deleted := make(map[string]watchedFile)
+	updated := make(map[string]watchedFile)
+	tasksChanged := make(map[string]bool)
+	var dirsCreated, dirsDeleted []string
+
+	for _, absPath := range paths {
+		rel, ok := w.relPath(absPath)
+		if !ok || rel == "" {
+			continue
+		}
+		info, err := os.Stat(absPath)
+		if err != nil {
+			if w.dirs[rel] {
+				dirsDeleted = append(dirsDeleted, rel)
+				for dir := range w.dirs {
+					if dir == rel || strings.HasPrefix(dir, rel+"/") {
+						delete(w.dirs, dir)
+						// A moved directory keeps its watch under the old
+						// name; drop it so the new location is watched afresh.
+						_ = w.fs.Remove(filepath.Join(w.root, filepath.FromSlash(dir)))
+					}
+				}
+				for filePath, file := range w.files {
+					if strings.HasPrefix(filePath, rel+"/") {
+						deleted[filePath] = file
+						delete(w.files, filePath)
+					}
+				}
+			} else if file, known := w.files[rel]; known {
+				deleted[rel] = file
+				delete(w.files, rel)
+			}
+			continue
+		}
+		if info.IsDir() {
+			if !w.dirs[rel] {
+				dirsCreated = append(dirsCreated, rel)
+				w.addTree(absPath, func(filePath string, file watchedFile) {
+					created[filePath] = file
+				})
+			}
+			continue
+		}
+		file, ok := w.readFile(absPath, rel, info)
+		if !ok {
+			continue
+		}
+		previous, known := w.files[rel]
+		w.files[rel] = file
+		switch {
+		case !known:
+			created[rel] = file
+		case previous.modified != file.modified || previous.size != file.size:
+			updated[rel] = file
+			if previous.tasks != file.tasks {
+				tasksChanged[rel] = true
+			}
+		}
+	}
+
+	now := timeNow()
+	events := make([]ChangeEvent, 0)
+	emit := func(eventType, path, oldPath string) {
+		events = append(events, ChangeEvent{Type: eventType, Path: path, OldPath: oldPath, Time: now})
+	}
+	fileEvent := func(kind, noteType, path, oldPath string) {
+		if kind == watchedSheet {
+			emit(EventSheetChanged, sheetEventPath(path), sheetEventPath(oldPath))
+			return
+		}
+		emit(noteType, path, oldPath)
+	}
+
+	dirsRenamed := make(map[string]string)
+	if len(dirsCreated) == 1 && len(dirsDeleted) == 1 {
+		dirsRenamed[dirsCreated[0]] = dirsDeleted[0]
+		dirsCreated, dirsDeleted = nil, nil
+	}
+	for _, dir := range dirsCreated {
+		emit(EventFolderCreated, dir, "")
+	}
+	for dir, oldDir := range dirsRenamed {
+		emit(EventFolderRenamed, dir, oldDir)
+	}
+
+	for _, oldPath := range sortedWatchedPaths(deleted) {
+		old := deleted[oldPath]
+		for _, newPath := range sortedWatchedPaths(created) {
+			file := created[newPath]
+			if file.kind != old.kind || file.modified != old.modified || file.size != old.size {
+				continue
+			}
+			fileEvent(file.kind, EventNoteRenamed, newPath, oldPath)
+			if file.tasks != "" {
+				tasksChanged[newPath] = true
+			}
+			delete(created, newPath)
+			delete(deleted, oldPath)
+			break
+		}
+	}
+	for _, path := range sortedWatchedPaths(created) {
+		fileEvent(created[path].kind, EventNoteCreated, path, "")
+		if created[path].tasks != "" {
+			tasksChanged[path] = true
+		}
+	}
+	for _, path := range sortedWatchedPaths(updated) {
+		fileEvent(updated[path].kind, EventNoteUpdated, path, "")
+	}
+	for _, path := range sortedWatchedPaths(deleted) {
+		fileEvent(deleted[path].kind, EventNoteDeleted, path, "")
+		if deleted[path].tasks != "" {
+			tasksChanged[path] = true
+		}
+	}
+
+	taskPaths := make([]string, 0, len(tasksChanged))
+	for path := range tasksChanged {
+		taskPaths = append(taskPaths, path)
+	}
+	sort.Strings(taskPaths)
+	for _, path := range taskPaths {
+		emit(EventTasksChanged, path, "")
+	}
+	for _, dir := range dirsDeleted {
+		emit(EventFolderDeleted, dir, "")
+	}
+	return events
+}
+
+func sortedWatchedPaths(files map[string]watchedFile) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func sheetEventPath(rel string) string {
+	if rel == "" {
+		return ""
+	}
+	return rel[len(sheetsFolderName)+1:]
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func newTestWatcher(t *testing.T) (string, *noteWatcher) {
+	t.Helper()
+	dir := t.TempDir()
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("watcher: %v", err)
+	}
+	t.Cleanup(func() { fsWatcher.Close() })
+	w := &noteWatcher{
+		server:  &Server{notesDir: dir, logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+		root:    dir,
+		fs:      fsWatcher,
+		pending: make(map[string]fsnotify.Op),
+		files:   make(map[string]watchedFile),
+		dirs:    make(map[string]bool),
+	}
+	return dir, w
+}
+
+func flushPaths(w *noteWatcher, paths ...string) []string {
+	for _, path := range paths {
+		w.pending[filepath.Join(w.root, filepath.FromSlash(path))] |= fsnotify.Write
+	}
+	events := w.flush()
+	summary := make([]string, 0, len(events))
+	for _, event := range events {
+		line := event.Type + " " + event.Path
+		if event.OldPath != "" {
+			line += " <- " + event.OldPath
+		}
+		summary = append(summary, line)
+	}
+	return summary
+}
+
+func TestWatcherClassifiesChanges(t *testing.T) {
+	dir, w := newTestWatcher(t)
+	writeFile(t, filepath.Join(dir, "Notes", "plan.md"), "# Plan\n- [ ] ship\n")
+	writeFile(t, filepath.Join(dir, "Notes", "plain.md"), "text\n")
+	w.addTree(dir, nil)
+
+	writeFile(t, filepath.Join(dir, "Notes", "plain.md"), "more text\n")
+	writeFile(t, filepath.Join(dir, "Notes", "new.md"), "- [ ] new task\n")
+	writeFile(t, filepath.Join(dir, "Sheets", "budget.jsh"), "{}\n")
+	writeFile(t, filepath.Join(dir, ".history", "Notes", "plan.md", "v.md"), "old\n")
+	got := flushPaths(w, "Notes/plain.md", "Notes/new.md", "Sheets", ".history/Notes/plan.md")
+	expected := []string{
+		"folder.created Sheets",
+		"note.created Notes/new.md",
+		"sheet.changed budget.jsh",
+		"note.updated Notes/plain.md",
+		"tasks.changed Notes/new.md",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(got, "\n"))
+	}
+
+	if err := os.Rename(filepath.Join(dir, "Notes", "plan.md"), filepath.Join(dir, "Notes", "renamed.md")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "Notes", "plain.md")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	got = flushPaths(w, "Notes/plan.md", "Notes/renamed.md", "Notes/plain.md")
+	expected = []string{
+		"note.renamed Notes/renamed.md <- Notes/plan.md",
+		"note.deleted Notes/plain.md",
+		"tasks.changed Notes/renamed.md",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(got, "\n"))
+	}
+
+	if err := os.Rename(filepath.Join(dir, "Notes"), filepath.Join(dir, "Archive")); err != nil {
+		t.Fatalf("rename folder: %v", err)
+	}
+	got = flushPaths(w, "Notes", "Archive")
+	expected = []string{
+		"folder.renamed Archive <- Notes",
+		"note.renamed Archive/new.md <- Notes/new.md",
+		"note.renamed Archive/renamed.md <- Notes/renamed.md",
+		"tasks.changed Archive/new.md",
+		"tasks.changed Archive/renamed.md",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestWatcherStopsWithContext(t *testing.T) {
+	s := &Server{notesDir: t.TempDir(), logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.startWatcher(ctx)
+	if s.watcherDone == nil {
+		t.Fatalf("watcher did not start")
+	}
+	cancel()
+	select {
+	case <-s.watcherDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher still running after cancel")
+	}
+}
+
+func TestWatcherDelayIsBounded(t *testing.T) {
+	first := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		elapsed time.Duration
+		delay   time.Duration
+	}{
+		{elapsed: 0, delay: watcherDebounce},
+		{elapsed: watcherMaxWait - watcherDebounce, delay: watcherDebounce},
+		{elapsed: watcherMaxWait - 100*time.Millisecond, delay: 100 * time.Millisecond},
+		{elapsed: watcherMaxWait, delay: 0},
+		{elapsed: time.Minute, delay: 0},
+	}
+	for _, tc := range cases {
+		if got := watcherDelay(first, first.Add(tc.elapsed)); got != tc.delay {
+			t.Fatalf("after %s: expected %s, got %s", tc.elapsed, tc.delay, got)
+		}
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	dir, router := setupTestRouter(t)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	resp, err := http.Get(server.URL + "/events?types=note.created")
+	if err != nil {
+		t.Fatalf("events request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	writeFile(t, filepath.Join(dir, "Notes", "outside.md"), "edited elsewhere\n")
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed before event")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var event ChangeEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			if event.Type != EventNoteCreated || event.Path != "Notes/outside.md" {
+				t.Fatalf("unexpected event %#v", event)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("timed out waiting for event")
+		}
+	}
+}
@@ -18,6 +18,12 @@ func (sr *statusRecorder) WriteHeader(status int) {
 	sr.ResponseWriter.WriteHeader(status)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, which
+// streaming endpoints need for flushing.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
 func (sr *statusRecorder) Write(data []byte) (int, error) {
 	if sr.status == 0 {
 		sr.status = http.StatusOK
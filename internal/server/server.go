@@ -1,11 +1,16 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -59,13 +64,43 @@ func Run(cfg Config) error {
 
 	logger.Info("server starting", "notesDir", notesDir, "port", cfg.Port)
 
+	// The API's background work, such as the notes watcher, lives as long
+	// as the server: it stops on SIGINT/SIGTERM or when serving fails.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	r := chi.NewRouter()
 	r.Use(requestLogger)
-	r.Mount("/api/v1", api.NewRouter(notesDir))
+	r.Mount("/api/v1", api.NewRouterContext(ctx, notesDir))
+	r.Handle("/.well-known/caldav", http.RedirectHandler("/api/v1/caldav/", http.StatusMovedPermanently))
 	r.Mount("/", ui.NewRouter())
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
-	return listenAndServe(addr, r)
+	return listenAndServe(ctx, addr, r)
 }
 
-var listenAndServe = http.ListenAndServe
+const shutdownTimeout = 10 * time.Second
+
+// listenAndServe serves handler on addr until ctx is done, then shuts the
+// server down gracefully.
+var listenAndServe = func(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/sottey/scoli/internal/api"
 )
 
 func TestRunRejectsInvalidPort(t *testing.T) {
@@ -23,7 +28,9 @@ func TestRunMountsRouters(t *testing.T) {
 	originalListen := listenAndServe
 	var gotAddr string
 	var gotHandler http.Handler
-	listenAndServe = func(addr string, handler http.Handler) error {
+	var gotCtx context.Context
+	listenAndServe = func(ctx context.Context, addr string, handler http.Handler) error {
+		gotCtx = ctx
 		gotAddr = addr
 		gotHandler = handler
 		return nil
@@ -40,6 +47,9 @@ func TestRunMountsRouters(t *testing.T) {
 	if gotHandler == nil {
 		t.Fatalf("expected handler to be set")
 	}
+	if gotCtx == nil || gotCtx.Err() == nil {
+		t.Fatalf("expected the server context to be done once Run returns")
+	}
 
 	if _, err := os.Stat(notesDir); err != nil {
 		t.Fatalf("expected notes dir to exist: %v", err)
@@ -52,6 +62,21 @@ func TestRunMountsRouters(t *testing.T) {
 		t.Fatalf("expected /api/v1/health 200, got %d", rec.Code)
 	}
 
+	req = httptest.NewRequest("PROPFIND", "/.well-known/caldav", nil)
+	rec = httptest.NewRecorder()
+	gotHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/api/v1/caldav/" {
+		t.Fatalf("expected caldav redirect, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	req = httptest.NewRequest("PROPFIND", "/api/v1/caldav/", nil)
+	req.Header.Set("Depth", "0")
+	rec = httptest.NewRecorder()
+	gotHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "<D:href>/api/v1/caldav/</D:href>") {
+		t.Fatalf("expected /api/v1/caldav/ 207, got %d", rec.Code)
+	}
+
 	req = httptest.NewRequest(http.MethodGet, "/", nil)
 	rec = httptest.NewRecorder()
 	gotHandler.ServeHTTP(rec, req)
@@ -62,3 +87,42 @@ func TestRunMountsRouters(t *testing.T) {
 		t.Fatalf("expected HTML content type, got %q", rec.Header().Get("Content-Type"))
 	}
 }
+
+func TestListenAndServeShutsDownOpenEventStreams(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- listenAndServe(ctx, addr, api.NewRouterContext(ctx, t.TempDir()))
+	}()
+
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		resp, err = http.Get("http://" + addr + "/events")
+		if err == nil {
+			break
+		}
+		if attempt == 50 {
+			t.Fatalf("events request: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	defer resp.Body.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(shutdownTimeout / 2):
+		t.Fatalf("shutdown waited on the open event stream")
+	}
+}
@@ -136,6 +136,9 @@ const commandClose = document.getElementById("command-close");
 const commandBackdrop = commandPalette ? commandPalette.querySelector(".modal-backdrop") : null;
 
 let currentNotePath = "";
+// ETags of notes as last loaded or saved, sent as If-Match so a save cannot
+// overwrite changes made elsewhere.
+const noteETags = new Map();
 let currentActivePath = "";
 let currentTree = null;
 let currentSheetsTree = null;
@@ -155,6 +158,9 @@ let aiViewMode = "active";
 let currentMode = "note";
 let currentSheetPath = "";
 let currentSheetData = [];
+let currentSheetSettings = null;
+const sheetQueryCache = new Map();
+const sheetQueryCacheMs = 30000;
 let sheetDirty = false;
 let sheetInstance = null;
 let lastNoteView = "preview";
@@ -614,6 +620,13 @@ function handleStartupShortcut() {
   }
   startupShortcutHandled = true;
   const url = new URL(window.location.href);
+  const notePath = url.searchParams.get("note");
+  if (notePath) {
+    openNote(notePath);
+    url.searchParams.delete("note");
+    window.history.replaceState({}, "", url.pathname + url.search);
+    return true;
+  }
   const shortcut = url.searchParams.get("shortcut");
   if (!shortcut) {
     if (currentSettings.startOnToday && !currentActivePath) {
@@ -679,6 +692,7 @@ function updatePreviewFromMarkdown(text) {
   preview.innerHTML = renderMarkdown(text);
   previewSyncing = false;
   applyHighlighting();
+  renderSheetQueryBlocks();
 }
 
 function syncEditorFromPreview() {
@@ -722,6 +736,9 @@ function serializeBlock(node, ctx) {
   if (node.nodeType !== Node.ELEMENT_NODE) {
     return "";
   }
+  if (node.classList.contains("sheet-query-result")) {
+    return "";
+  }
   const tag = node.tagName.toLowerCase();
   if (tag === "p" || tag === "div") {
     return serializeInline(node).trim();
@@ -1631,148 +1648,57 @@ function renderTaskFiltersPanel(selectedId = "") {
     return;
   }
 
-  const filtered = sortTasksForFilter(applyTaskFilter(currentTasks || [], activeFilter));
   const summary = document.createElement("div");
   summary.className = "task-list-summary";
-  summary.textContent = `${filtered.length} task${filtered.length === 1 ? "" : "s"}`;
+  summary.textContent = "Loading...";
   header.appendChild(summary);
 
   const list = document.createElement("div");
   list.className = "task-items";
-  if (filtered.length === 0) {
+  taskList.appendChild(list);
+  taskList.scrollTop = 0;
+  loadTaskFilterResults(activeFilter.id, summary, list);
+}
+
+async function loadTaskFilterResults(filterId, summary, list) {
+  let response;
+  try {
+    response = await apiFetch(`/tasks?filter=${encodeURIComponent(filterId)}`);
+  } catch (err) {
+    if (list.isConnected) {
+      summary.textContent = err.message;
+    }
+    return;
+  }
+  if (!list.isConnected || currentTaskFilterId !== filterId) {
+    return;
+  }
+  const tasks = response && Array.isArray(response.tasks) ? response.tasks : [];
+  const groups = response && Array.isArray(response.groups) ? response.groups : [];
+  summary.textContent = `${tasks.length} task${tasks.length === 1 ? "" : "s"}`;
+  if (tasks.length === 0) {
     const empty = document.createElement("div");
     empty.className = "search-empty";
     empty.textContent = "No tasks to show.";
     list.appendChild(empty);
+  } else if (groups.length > 0) {
+    groups.forEach((group) => {
+      const heading = document.createElement("div");
+      heading.className = "task-filter-group";
+      heading.textContent = group.key || "None";
+      list.appendChild(heading);
+      (group.tasks || []).forEach((task) => {
+        list.appendChild(buildTaskListItem(task));
+      });
+    });
   } else {
-    filtered.forEach((task) => {
+    tasks.forEach((task) => {
       list.appendChild(buildTaskListItem(task));
     });
   }
-  taskList.appendChild(list);
-  taskList.scrollTop = 0;
   requestAnimationFrame(() => updateTaskMetaOverflow());
 }
 
-function normalizeFilterValues(values) {
-  return (values || [])
-    .map((value) => String(value || "").trim().toLowerCase())
-    .filter((value) => value);
-}
-
-function resolveFilterDate(value) {
-  const raw = String(value || "").trim().toLowerCase();
-  if (!raw) {
-    return "";
-  }
-  if (raw === "today") {
-    return formatDailyDate(new Date());
-  }
-  const relativeMatch = raw.match(/^\+(\d+)d$/);
-  if (relativeMatch) {
-    const days = Number(relativeMatch[1] || 0);
-    if (Number.isFinite(days)) {
-      const base = new Date();
-      base.setDate(base.getDate() + days);
-      return formatDailyDate(base);
-    }
-  }
-  if (/^\d{4}-\d{2}-\d{2}$/.test(raw)) {
-    return raw;
-  }
-  return "";
-}
-
-function applyTaskFilter(tasks, filter) {
-  if (!filter) {
-    return tasks || [];
-  }
-  const tags = normalizeFilterValues(filter.tags);
-  const mentions = normalizeFilterValues(filter.mentions);
-  const projects = normalizeFilterValues(filter.projects);
-  const text = String(filter.text || "").trim().toLowerCase();
-  const pathPrefix = String(filter.pathPrefix || "").trim().toLowerCase();
-  const completed = filter.completed;
-  const fromKey = filter.due ? resolveFilterDate(filter.due.from) : "";
-  const toKey = filter.due ? resolveFilterDate(filter.due.to) : "";
-  const minPriority = filter.priority && Number.isFinite(filter.priority.min) ? Number(filter.priority.min) : null;
-  const maxPriority = filter.priority && Number.isFinite(filter.priority.max) ? Number(filter.priority.max) : null;
-
-  return (tasks || []).filter((task) => {
-    if (typeof completed === "boolean") {
-      if (!!task.completed !== completed) {
-        return false;
-      }
-    }
-
-    if (projects.length > 0) {
-      const project = String(task.project || "").toLowerCase();
-      if (!projects.includes(project)) {
-        return false;
-      }
-    }
-
-    if (tags.length > 0) {
-      const taskTags = normalizeFilterValues(task.tags);
-      if (!tags.every((tag) => taskTags.includes(tag))) {
-        return false;
-      }
-    }
-
-    if (mentions.length > 0) {
-      const taskMentions = normalizeFilterValues(task.mentions);
-      if (!mentions.every((mention) => taskMentions.includes(mention))) {
-        return false;
-      }
-    }
-
-    if (text) {
-      const target = String(task.text || "").toLowerCase();
-      if (!target.includes(text)) {
-        return false;
-      }
-    }
-
-    if (pathPrefix) {
-      const path = String(task.path || "").toLowerCase();
-      if (!path.startsWith(pathPrefix)) {
-        return false;
-      }
-    }
-
-    if (fromKey || toKey) {
-      const due = task.dueDateISO;
-      if (!due) {
-        return false;
-      }
-      if (fromKey && due < fromKey) {
-        return false;
-      }
-      if (toKey && due > toKey) {
-        return false;
-      }
-    }
-
-    if (minPriority !== null || maxPriority !== null) {
-      const priority = Number(task.priority) || 0;
-      if (minPriority !== null && priority < minPriority) {
-        return false;
-      }
-      if (maxPriority !== null && priority > maxPriority) {
-        return false;
-      }
-    }
-
-    return true;
-  });
-}
-
-function sortTasksForFilter(tasks) {
-  const sorted = [...(tasks || [])];
-  sorted.sort(compareTasksBySchedule);
-  return sorted;
-}
-
 function showNoteEditor() {
   currentMode = "note";
   summaryPanel.classList.add("hidden");
@@ -3329,11 +3255,90 @@ function applyHighlighting() {
   if (!window.hljs) {
     return;
   }
-  preview.querySelectorAll("pre code").forEach((block) => {
+  preview.querySelectorAll("pre code:not(.language-sheet-query)").forEach((block) => {
     hljs.highlightElement(block);
   });
 }
 
+// sheet-query blocks hold one "key: value" query parameter per line, such as
+// "path: Invoices" or "where: Amount > 500". Results are shown below the block
+// and kept briefly so that typing elsewhere in the note does not refetch them.
+function renderSheetQueryBlocks() {
+  preview.querySelectorAll("pre > code.language-sheet-query").forEach(async (code) => {
+    const block = code.parentElement;
+    const result = document.createElement("div");
+    result.className = "sheet-query-result";
+    result.setAttribute("contenteditable", "false");
+    try {
+      const data = await fetchSheetQuery(sheetQueryParams(code.textContent || ""));
+      result.appendChild(buildSheetQueryTable(data));
+    } catch (err) {
+      result.classList.add("error");
+      result.textContent = err.message;
+    }
+    if (block.isConnected) {
+      block.after(result);
+    }
+  });
+}
+
+function sheetQueryParams(source) {
+  const params = new URLSearchParams();
+  source.split("\n").forEach((line) => {
+    const trimmed = line.trim();
+    const index = trimmed.indexOf(":");
+    if (index <= 0) {
+      return;
+    }
+    params.append(trimmed.slice(0, index).trim(), trimmed.slice(index + 1).trim());
+  });
+  return params;
+}
+
+async function fetchSheetQuery(params) {
+  const key = params.toString();
+  const cached = sheetQueryCache.get(key);
+  if (cached && Date.now() - cached.at < sheetQueryCacheMs) {
+    return cached.result;
+  }
+  const result = apiFetch(`/sheets/query?${key}`);
+  sheetQueryCache.set(key, { at: Date.now(), result });
+  result.catch(() => sheetQueryCache.delete(key));
+  return result;
+}
+
+function buildSheetQueryTable(data) {
+  const wrapper = document.createDocumentFragment();
+  const table = document.createElement("table");
+  const head = document.createElement("tr");
+  data.columns.forEach((column) => {
+    const th = document.createElement("th");
+    th.textContent = column;
+    head.appendChild(th);
+  });
+  table.createTHead().appendChild(head);
+  const body = table.createTBody();
+  data.rows.forEach((row) => {
+    const tr = document.createElement("tr");
+    row.forEach((value) => {
+      const td = document.createElement("td");
+      td.textContent = value === null ? "" : String(value);
+      if (typeof value === "number") {
+        td.classList.add("number");
+      }
+      tr.appendChild(td);
+    });
+    body.appendChild(tr);
+  });
+  const caption = document.createElement("div");
+  caption.className = "sheet-query-caption";
+  const shown = data.rows.length < data.total ? `${data.rows.length} of ${data.total}` : `${data.total}`;
+  caption.textContent = `${data.path} · ${shown} ${data.total === 1 ? "row" : "rows"}`;
+  wrapper.appendChild(table);
+  wrapper.appendChild(caption);
+  return wrapper;
+}
+
 async function apiFetch(path, options = {}) {
   let response;
   try {
@@ -3355,7 +3360,10 @@ async function apiFetch(path, options = {}) {
 
   if (!response.ok) {
     const error = await response.json().catch(() => ({ error: "Request failed" }));
-    throw new Error(error.error || "Request failed");
+    const failure = new Error(error.error || "Request failed");
+    failure.status = response.status;
+    failure.data = error;
+    throw failure;
   }
 
   if (response.status === 204) {
@@ -3699,15 +3707,21 @@ function hasTaskTag(task, tag) {
   return (task.tags || []).some((value) => String(value || "").toLowerCase() === target);
 }
 
+// isTaskActive leaves out migrated tasks: daily rollover already carried them
+// into a later note, where their copy is listed.
+function isTaskActive(task) {
+  return !task.completed && task.status !== "migrated";
+}
+
 function getTodayTasks(tasks) {
   const todayKey = formatDateKey(new Date());
   return (tasks || []).filter(
-    (task) => !task.completed && !hasTaskTag(task, "someday") && isTaskDueTodayOrPast(task, todayKey)
+    (task) => isTaskActive(task) && !hasTaskTag(task, "someday") && isTaskDueTodayOrPast(task, todayKey)
   );
 }
 
 function getSomedayTasks(tasks) {
-  return (tasks || []).filter((task) => !task.completed && hasTaskTag(task, "someday"));
+  return (tasks || []).filter((task) => isTaskActive(task) && hasTaskTag(task, "someday"));
 }
 
 function showToast(message) {
@@ -4194,7 +4208,7 @@ function buildTasksRoot(tasks, taskFilters) {
   caret.className = "folder-icon";
   row.appendChild(caret);
 
-  const activeTasks = (tasks || []).filter((task) => !task.completed);
+  const activeTasks = (tasks || []).filter((task) => isTaskActive(task));
   const completedTasks = (tasks || []).filter((task) => task.completed);
   const todayTasks = getTodayTasks(tasks || []);
   const somedayTasks = getSomedayTasks(tasks || []);
@@ -4356,7 +4370,7 @@ function buildSheetsNode(node, depth) {
           action: () => createSheet(node.path || ""),
         },
         {
-          label: "Import CSV",
+          label: "Import CSV / Excel / ODS",
           action: () => promptSheetImport(node.path || ""),
         },
         {
@@ -4390,6 +4404,10 @@ function buildSheetsNode(node, depth) {
         label: "Export CSV",
         action: () => exportSheet(node.path),
       },
+      {
+        label: "Export XLSX",
+        action: () => exportSheet(node.path, "xlsx"),
+      },
     ]);
   });
 
@@ -4416,7 +4434,7 @@ function buildSheetsRoot(tree) {
           action: () => createSheet(""),
         },
         {
-          label: "Import CSV",
+          label: "Import CSV / Excel / ODS",
           action: () => promptSheetImport(""),
         },
         {
@@ -4430,7 +4448,7 @@ function buildSheetsRoot(tree) {
 }
 
 function splitTasksByProject(tasks) {
-  const activeTasks = (tasks || []).filter((task) => !task.completed);
+  const activeTasks = (tasks || []).filter((task) => isTaskActive(task));
   const completedTasks = (tasks || []).filter((task) => task.completed);
   const projectMap = new Map();
   const noProject = [];
@@ -4600,6 +4618,9 @@ function buildTaskListItem(task) {
   const meta = document.createElement("div");
   meta.className = "task-meta";
 
+  if (task.status && task.status !== "open" && task.status !== "done") {
+    meta.appendChild(buildTaskChip(task.status));
+  }
   if (task.project) {
     meta.appendChild(buildTaskChip(`+${task.project}`));
   }
@@ -4611,6 +4632,15 @@ function buildTaskListItem(task) {
   if (task.priority) {
     meta.appendChild(buildTaskChip(`^${task.priority}`));
   }
+  if (task.recurrence) {
+    meta.appendChild(buildTaskChip(`*every:${task.recurrence}`));
+  }
+  if (task.completedDate) {
+    meta.appendChild(buildTaskChip(`✓${task.completedDate}`));
+  }
+  if (typeof task.progress === "number") {
+    meta.appendChild(buildTaskChip(`${task.progress}% subtasks done`));
+  }
   (task.tags || []).forEach((tag) => meta.appendChild(buildTaskChip(`#${tag}`)));
   (task.mentions || []).forEach((mention) => meta.appendChild(buildTaskChip(`@${mention}`)));
 
@@ -4678,14 +4708,26 @@ function updateTaskMetaOverflow() {
   });
 }
 
+// Tasks with a block ID (^t-…) are addressed by it, so the update still lands
+// after the line moves; path, line number and hash are sent as a fallback.
+function taskAddress(task) {
+  const address = {
+    path: task.path,
+    lineNumber: task.lineNumber,
+    lineHash: task.lineHash,
+  };
+  if (typeof task.id === "string" && task.id.startsWith("t-")) {
+    address.id = task.id;
+  }
+  return address;
+}
+
 async function toggleTaskCompletion(task, completed) {
   try {
     await apiFetch("/tasks/toggle", {
       method: "PATCH",
       body: JSON.stringify({
-        path: task.path,
-        lineNumber: task.lineNumber,
-        lineHash: task.lineHash,
+        ...taskAddress(task),
         completed,
       }),
     });
@@ -4713,6 +4755,10 @@ function buildTaskContextMenu(task) {
         }
       },
     },
+    ...taskStatusOptions.filter((option) => option.status !== (task.status || "open")).map((option) => ({
+      label: option.label,
+      action: () => setTaskStatus(task, option.status),
+    })),
   ];
 }
 
@@ -4763,9 +4809,7 @@ async function setTaskDueDate(task, dueDate) {
     await apiFetch("/tasks/due", {
       method: "PATCH",
       body: JSON.stringify({
-        path: task.path,
-        lineNumber: task.lineNumber,
-        lineHash: task.lineHash,
+        ...taskAddress(task),
         dueDate,
       }),
     });
@@ -4775,6 +4819,29 @@ async function setTaskDueDate(task, dueDate) {
   }
 }
 
+const taskStatusOptions = [
+  { status: "open", label: "Mark Open" },
+  { status: "in-progress", label: "Mark In Progress" },
+  { status: "blocked", label: "Mark Blocked" },
+  { status: "deferred", label: "Mark Deferred" },
+  { status: "cancelled", label: "Mark Cancelled" },
+];
+
+async function setTaskStatus(task, status) {
+  try {
+    await apiFetch("/tasks/status", {
+      method: "PATCH",
+      body: JSON.stringify({
+        ...taskAddress(task),
+        status,
+      }),
+    });
+    await loadTree();
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
 async function archiveCompletedTasks() {
   try {
     await apiFetch("/tasks/archive", { method: "PATCH" });
@@ -5840,6 +5907,7 @@ async function openNote(path) {
     currentNotePath = data.path;
     currentSheetPath = "";
     currentActivePath = data.path;
+    noteETags.set(data.path, data.etag);
     notePath.textContent = data.path;
     editor.value = data.content;
     updatePreviewFromMarkdown(data.content);
@@ -6468,10 +6536,12 @@ async function appendToInbox(text) {
     return;
   }
   let existing = "";
+  let existingETag = "";
   let found = false;
   try {
     const data = await apiFetch(`/notes?path=${encodeURIComponent(inboxNotePath)}`);
     existing = data.content || "";
+    existingETag = data.etag || "";
     found = true;
   } catch (err) {
     if (err.message !== "note not found") {
@@ -6481,10 +6551,18 @@ async function appendToInbox(text) {
   const separator = existing && !existing.endsWith("\n") ? "\n" : "";
   const nextContent = found ? `${existing}${separator}${content}` : content;
   const method = found ? "PATCH" : "POST";
-  await apiFetch("/notes", {
+  const headers = { "Content-Type": "application/json" };
+  if (existingETag) {
+    headers["If-Match"] = existingETag;
+  }
+  const saved = await apiFetch("/notes", {
     method,
+    headers,
     body: JSON.stringify({ path: inboxNotePath, content: nextContent }),
   });
+  if (saved && saved.etag) {
+    noteETags.set(inboxNotePath, saved.etag);
+  }
   if (currentNotePath === inboxNotePath) {
     editor.value = nextContent;
     updatePreviewFromMarkdown(nextContent);
@@ -6936,11 +7014,28 @@ function renderSheetGrid(data) {
           tableWidth: width,
           tableHeight: height,
           minDimensions: [Math.max(cols, defaultSheetCols), Math.max(rows, defaultSheetRows)],
+          columns: getSheetColumnOptions(),
         },
       ],
       onchange: () => {
         markSheetDirty();
       },
+      onresizecolumn: (worksheet, column, width) => {
+        recordSheetColumnWidths(column, width);
+        markSheetDirty();
+      },
+      oninsertcolumn: (worksheet, inserted) => {
+        (inserted || []).forEach((entry) => shiftSheetSettingsColumns(entry.column, 1));
+        markSheetDirty();
+      },
+      ondeletecolumn: (worksheet, removed) => {
+        [...(removed || [])].sort((a, b) => b - a).forEach((column) => shiftSheetSettingsColumns(column, -1));
+        markSheetDirty();
+      },
+      onmovecolumn: (worksheet, from, to) => {
+        moveSheetSettingsColumn(from, to);
+        markSheetDirty();
+      },
       onafterchanges: () => {
         markSheetDirty();
       },
@@ -6949,6 +7044,83 @@ function renderSheetGrid(data) {
   }
 }
 
+function getSheetColumnOptions() {
+  const columns = currentSheetSettings && Array.isArray(currentSheetSettings.columns) ? currentSheetSettings.columns : [];
+  return columns.map((column) => (column.width > 0 ? { width: column.width } : {}));
+}
+
+function recordSheetColumnWidths(column, width) {
+  if (!currentSheetSettings) {
+    currentSheetSettings = { columns: [], frozenHeader: false };
+  }
+  const columnsList = Array.isArray(column) ? column : [column];
+  const widths = Array.isArray(width) ? width : [width];
+  const columns = currentSheetSettings.columns || [];
+  columnsList.forEach((index, i) => {
+    const value = Math.round(Number(widths[i]));
+    if (!Number.isInteger(index) || index < 0 || !(value > 0)) {
+      return;
+    }
+    while (columns.length <= index) {
+      columns.push({});
+    }
+    columns[index] = { ...columns[index], width: value };
+  });
+  currentSheetSettings.columns = columns;
+}
+
+// Column settings are positional, so structural edits in the grid shift them
+// the way the sheet operations endpoint does: an inserted column gets no
+// settings and a deleted column takes its settings, and the sort, with it.
+function shiftSheetSettingsColumns(column, delta) {
+  if (!currentSheetSettings || !Number.isInteger(column) || column < 0) {
+    return;
+  }
+  const columns = currentSheetSettings.columns || [];
+  if (column < columns.length) {
+    if (delta > 0) {
+      columns.splice(column, 0, {});
+    } else {
+      columns.splice(column, 1);
+    }
+  }
+  currentSheetSettings.columns = columns;
+  const sort = currentSheetSettings.sort;
+  if (sort && delta < 0 && sort.column === column) {
+    delete currentSheetSettings.sort;
+  } else if (sort && sort.column >= column) {
+    sort.column += delta;
+  }
+}
+
+function moveSheetSettingsColumn(from, to) {
+  from = Number(from);
+  to = Number(to);
+  if (!currentSheetSettings || !Number.isInteger(from) || !Number.isInteger(to) || from === to) {
+    return;
+  }
+  const columns = currentSheetSettings.columns || [];
+  if (from < columns.length || to < columns.length) {
+    while (columns.length <= Math.max(from, to)) {
+      columns.push({});
+    }
+    const [moved] = columns.splice(from, 1);
+    columns.splice(to, 0, moved);
+  }
+  currentSheetSettings.columns = columns;
+  const sort = currentSheetSettings.sort;
+  if (!sort) {
+    return;
+  }
+  if (sort.column === from) {
+    sort.column = to;
+  } else if (from < sort.column && sort.column <= to) {
+    sort.column -= 1;
+  } else if (to <= sort.column && sort.column < from) {
+    sort.column += 1;
+  }
+}
+
 async function openSheet(path) {
   if (!path) {
     return;
@@ -6961,6 +7133,7 @@ async function openSheet(path) {
     currentActivePath = `sheets:${data.path}`;
     notePath.textContent = data.path;
     sheetDirty = false;
+    currentSheetSettings = data.settings || null;
     renderSheetGrid(data.data || []);
     setActiveNode(currentActivePath);
     saveBtn.disabled = true;
@@ -6993,6 +7166,7 @@ async function saveSheet() {
       body: JSON.stringify({
         path: currentSheetPath,
         data: currentSheetData,
+        ...(currentSheetSettings ? { settings: currentSheetSettings } : {}),
       }),
     });
     sheetDirty = false;
@@ -7001,7 +7175,9 @@ async function saveSheet() {
   } catch (err) {
     saveBtn.textContent = "Save";
     saveBtn.disabled = false;
-    alert(err.message);
+    // A 400 is a cell that does not fit its column type; the edits stay in
+    // the grid so they can be fixed and saved again.
+    alert(err.status === 400 ? `Sheet not saved: ${err.message}` : err.message);
   }
 }
 
@@ -7099,13 +7275,27 @@ async function handleSheetImportFile() {
   const sheetName = ensureSheetName(name);
   const path = pendingSheetImportParent ? `${pendingSheetImportParent}/${sheetName}` : sheetName;
   try {
-    const csvText = await file.text();
+    const payload = { path };
+    if (/\.(xlsx|ods)$/i.test(file.name)) {
+      payload.file = await readFileAsBase64(file);
+      const listing = await apiFetch("/sheets/import/worksheets", {
+        method: "POST",
+        body: JSON.stringify({ file: payload.file }),
+      });
+      const names = (listing.worksheets || []).map((worksheet) => worksheet.name);
+      if (names.length > 1) {
+        const choice = window.prompt(`Worksheet to import (${names.join(", ")})`, names[0]);
+        if (!choice) {
+          return;
+        }
+        payload.worksheet = choice.trim();
+      }
+    } else {
+      payload.csv = await file.text();
+    }
     const response = await apiFetch("/sheets/import", {
       method: "POST",
-      body: JSON.stringify({
-        path,
-        csv: csvText,
-      }),
+      body: JSON.stringify(payload),
     });
     await loadTree();
     await openSheet(response.path || path);
@@ -7114,12 +7304,24 @@ async function handleSheetImportFile() {
   }
 }
 
-async function exportSheet(path) {
+async function readFileAsBase64(file) {
+  const bytes = new Uint8Array(await file.arrayBuffer());
+  let binary = "";
+  const chunkSize = 0x8000;
+  for (let i = 0; i < bytes.length; i += chunkSize) {
+    binary += String.fromCharCode(...bytes.subarray(i, i + chunkSize));
+  }
+  return btoa(binary);
+}
+
+async function exportSheet(path, format = "csv") {
   if (!path) {
     return;
   }
   try {
-    const response = await fetch(`${apiBase}/sheets/export?path=${encodeURIComponent(path)}`);
+    const response = await fetch(
+      `${apiBase}/sheets/export?path=${encodeURIComponent(path)}&format=${encodeURIComponent(format)}`
+    );
     if (!response.ok) {
       const error = await response.json().catch(() => ({ error: "Unable to export sheet" }));
       throw new Error(error.error || "Unable to export sheet");
@@ -7128,7 +7330,7 @@ async function exportSheet(path) {
     const url = URL.createObjectURL(blob);
     const anchor = document.createElement("a");
     anchor.href = url;
-    anchor.download = `${displaySheetName(path.split("/").pop()) || "sheet"}.csv`;
+    anchor.download = `${displaySheetName(path.split("/").pop()) || "sheet"}.${format}`;
     document.body.appendChild(anchor);
     anchor.click();
     anchor.remove();
@@ -7186,13 +7388,21 @@ async function saveNoteContent(path, content, options = {}) {
       saveBtn.disabled = true;
       saveBtn.textContent = "Saving...";
     }
-    await apiFetch("/notes", {
-      method: "PATCH",
-      body: JSON.stringify({
-        path,
-        content,
-      }),
-    });
+    const data = await patchNoteContent(path, content);
+    if (!data) {
+      // The user kept the server's version instead.
+      if (showStatus && path === currentNotePath) {
+        saveBtn.textContent = "Save";
+        saveBtn.disabled = false;
+      }
+      return;
+    }
+    if (typeof data.content === "string" && path === currentNotePath && editor.value === content) {
+      // The server rewrote relative due dates; show what was saved.
+      editor.value = data.content;
+      updatePreviewFromMarkdown(data.content);
+      content = data.content;
+    }
     if (path === currentNotePath) {
       await refreshTasksAndTagsPreserveView({ suppressNotice });
     } else {
@@ -7214,6 +7424,48 @@ async function saveNoteContent(path, content, options = {}) {
   }
 }
 
+// patchNoteContent saves a note with If-Match. When the note changed on the
+// server, the user either overwrites it or takes the server's version, in
+// which case null is returned.
+async function patchNoteContent(path, content) {
+  const request = (etag) => {
+    const headers = { "Content-Type": "application/json" };
+    if (etag) {
+      headers["If-Match"] = etag;
+    }
+    return apiFetch("/notes", {
+      method: "PATCH",
+      headers,
+      body: JSON.stringify({ path, content }),
+    });
+  };
+  let data;
+  try {
+    data = await request(noteETags.get(path));
+  } catch (err) {
+    const current = err.status === 409 && err.data ? err.data.current : null;
+    if (!current) {
+      throw err;
+    }
+    if (window.confirm(`${path} changed since it was opened. Overwrite it with your version?`)) {
+      data = await request(current.etag);
+    } else {
+      noteETags.set(path, current.etag);
+      if (path === currentNotePath) {
+        editor.value = current.content;
+        updatePreviewFromMarkdown(current.content);
+        renderTagBarFromContent(current.content);
+        isDirty = false;
+      }
+      return null;
+    }
+  }
+  if (data && data.etag) {
+    noteETags.set(path, data.etag);
+  }
+  return data;
+}
+
 async function saveNote() {
   if (!currentNotePath) {
     return;
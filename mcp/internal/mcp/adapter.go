@@ -67,14 +67,16 @@ func (a *Adapter) Tools() []ToolSpec {
 			InputSchema: schemaObject(map[string]any{
 				"path":    schemaString("Note path, relative to the notes root."),
 				"content": schemaString("Full note content."),
+				"etag":    schemaString("ETag from note.read; the update fails if the note changed since."),
 			}, []string{"path", "content"}),
 		},
 		{
 			Name:        "note.rename",
-			Description: "Rename a note.",
+			Description: "Rename or move a note, rewriting links that point at it.",
 			InputSchema: schemaObject(map[string]any{
 				"path":    schemaString("Existing note path."),
 				"newPath": schemaString("New note path, relative to notes root."),
+				"dryRun":  schemaBoolean("Preview the rewritten lines without renaming."),
 			}, []string{"path", "newPath"}),
 		},
 		{
@@ -106,11 +108,12 @@ func (a *Adapter) Tools() []ToolSpec {
 						},
 					},
 				},
+				"settings": schemaSheetSettings(),
 			}, []string{"path", "data"}),
 		},
 		{
 			Name:        "sheet.update",
-			Description: "Update an existing sheet's data.",
+			Description: "Update an existing sheet's data, keeping its settings unless new ones are given.",
 			InputSchema: schemaObject(map[string]any{
 				"path": schemaString("Sheet path, relative to the Sheets root."),
 				"data": map[string]any{
@@ -123,8 +126,38 @@ func (a *Adapter) Tools() []ToolSpec {
 						},
 					},
 				},
+				"settings": schemaSheetSettings(),
+				"etag":     schemaString("ETag from sheet.read; the update fails if the sheet changed since."),
 			}, []string{"path", "data"}),
 		},
+		{
+			Name:        "sheet.operations",
+			Description: "Apply cell, row and column operations to a sheet as it is on the server; all of them or none are saved.",
+			InputSchema: schemaObject(map[string]any{
+				"path": schemaString("Sheet path, relative to the Sheets root."),
+				"operations": map[string]any{
+					"type":        "array",
+					"description": "Operations to apply in order.",
+					"items": schemaObject(map[string]any{
+						"op":     schemaString("set-cell, insert-row, delete-row, insert-column, delete-column, append-rows or move-row."),
+						"row":    schemaInteger("0-based row index."),
+						"column": schemaInteger("0-based column index."),
+						"value":  schemaString("Cell value for set-cell."),
+						"count":  schemaInteger("Rows or columns to insert or delete; defaults to 1."),
+						"to":     schemaInteger("0-based index a move-row ends up at."),
+						"rows": map[string]any{
+							"type":        "array",
+							"description": "Rows for append-rows.",
+							"items": map[string]any{
+								"type":  "array",
+								"items": map[string]any{"type": "string"},
+							},
+						},
+					}, []string{"op"}),
+				},
+				"etag": schemaString("ETag from sheet.read; the operations fail if the sheet changed since."),
+			}, []string{"path", "operations"}),
+		},
 		{
 			Name:        "sheet.rename",
 			Description: "Rename a sheet.",
@@ -142,17 +175,59 @@ func (a *Adapter) Tools() []ToolSpec {
 		},
 		{
 			Name:        "sheet.import",
-			Description: "Import CSV into a new sheet.",
+			Description: "Import CSV, or an .xlsx or .ods workbook, into a new sheet.",
 			InputSchema: schemaObject(map[string]any{
-				"path": schemaString("Target sheet path, relative to the Sheets root."),
-				"csv":  schemaString("CSV contents to import."),
-			}, []string{"path", "csv"}),
+				"path":      schemaString("Target sheet path, relative to the Sheets root."),
+				"csv":       schemaString("CSV contents to import."),
+				"file":      schemaString("Base64-encoded .xlsx or .ods file to import instead of csv."),
+				"worksheet": schemaString("Worksheet of the file to import; defaults to the first."),
+			}, []string{"path"}),
 		},
 		{
 			Name:        "sheet.export",
-			Description: "Export a sheet as CSV.",
+			Description: "Export a sheet as CSV, or sheets as a base64-encoded .xlsx workbook.",
 			InputSchema: schemaObject(map[string]any{
 				"path": schemaString("Sheet path, relative to the Sheets root."),
+				"paths": map[string]any{
+					"type":        "array",
+					"description": "More sheet paths to export as worksheets of one .xlsx workbook.",
+					"items":       map[string]any{"type": "string"},
+				},
+				"format": schemaString("csv (default) or xlsx."),
+				"values": schemaBoolean("Export formula results instead of the formulas."),
+			}, []string{"path"}),
+		},
+		{
+			Name:        "sheet.query",
+			Description: "Query a sheet whose first row names its columns: filter, select, group, aggregate and sort rows.",
+			InputSchema: schemaObject(map[string]any{
+				"path": schemaString("Sheet path, relative to the Sheets root."),
+				"where": map[string]any{
+					"type":        "array",
+					"description": "Conditions rows must all match, such as \"Status = open\" or \"Amount > 500\"; operators are =, !=, <, <=, >, >=, ~ (contains) and !~.",
+					"items":       map[string]any{"type": "string"},
+				},
+				"select": map[string]any{
+					"type":        "array",
+					"description": "Columns to return, by header or letter; defaults to all.",
+					"items":       map[string]any{"type": "string"},
+				},
+				"groupBy": map[string]any{
+					"type":        "array",
+					"description": "Columns to group rows by.",
+					"items":       map[string]any{"type": "string"},
+				},
+				"aggregate": map[string]any{
+					"type":        "array",
+					"description": "Aggregates per group: count, or count, sum, avg, min or max of a column, such as sum(Amount).",
+					"items":       map[string]any{"type": "string"},
+				},
+				"sort": map[string]any{
+					"type":        "array",
+					"description": "Columns or aggregates to sort by; prefix with - to sort descending.",
+					"items":       map[string]any{"type": "string"},
+				},
+				"limit": schemaInteger("Maximum rows to return."),
 			}, []string{"path"}),
 		},
 		{
@@ -164,10 +239,11 @@ func (a *Adapter) Tools() []ToolSpec {
 		},
 		{
 			Name:        "folder.rename",
-			Description: "Rename a folder.",
+			Description: "Rename or move a folder, rewriting links into it.",
 			InputSchema: schemaObject(map[string]any{
 				"path":    schemaString("Existing folder path."),
 				"newPath": schemaString("New folder path, relative to notes root."),
+				"dryRun":  schemaBoolean("Preview the rewritten lines without renaming."),
 			}, []string{"path", "newPath"}),
 		},
 		{
@@ -179,9 +255,10 @@ func (a *Adapter) Tools() []ToolSpec {
 		},
 		{
 			Name:        "search",
-			Description: "Search notes by query string.",
+			Description: "Full-text search over notes, ranked by relevance with line snippets.",
 			InputSchema: schemaObject(map[string]any{
-				"query": schemaString("Search query string."),
+				"query": schemaString("Search query. Supports words, \"phrases\", prefix*, AND/OR/NOT, -exclusions, and filters: tag:work (#work), mention:bob (@bob), project:x (+x), path:Folder/, modified:>2026-09-01."),
+				"limit": schemaInteger("Maximum number of results (default 50, max 200)."),
 			}, []string{"query"}),
 		},
 		{
@@ -191,24 +268,59 @@ func (a *Adapter) Tools() []ToolSpec {
 		},
 		{
 			Name:        "tasks.list",
-			Description: "List tasks, optionally scoped to a note path.",
+			Description: "List tasks, optionally scoped to a note path or narrowed by a saved task filter and ad-hoc criteria. Subtasks carry parentId; parents carry children and progress. Grouped listings also return groups.",
 			InputSchema: schemaObject(map[string]any{
-				"path": schemaString("Optional note path to scope tasks."),
+				"path":    schemaString("Optional note path to scope tasks. Cannot be combined with filters."),
+				"tree":    schemaBoolean("Nest subtasks under their parents as subtasks."),
+				"filter":  schemaString("Saved task filter id from task-sets.json."),
+				"project": schemaString("Comma-separated projects; a task must be in one."),
+				"tag":     schemaString("Comma-separated tags; a task must have all."),
+				"status":  schemaString("Comma-separated statuses: open, done, in-progress, cancelled, deferred, blocked."),
+				"text":    schemaString("Text the task must contain."),
+				"dueFrom": schemaString("Earliest due date, absolute or relative such as today or +3d."),
+				"dueTo":   schemaString("Latest due date, absolute or relative."),
+				"sort":    schemaString("Comma-separated sort keys: due, priority, text, project, note, status; prefix - to reverse."),
+				"groupBy": schemaString("Group by project, tag, due or note."),
 			}, nil),
 		},
 		{
 			Name:        "tasks.toggle",
-			Description: "Toggle a task's completion state.",
+			Description: "Toggle a task's completion state. Address the task by its block id, or by path, lineNumber and lineHash. Completing a recurring task (*every:) also adds its next occurrence, returned as next.",
 			InputSchema: schemaObject(map[string]any{
+				"id":         schemaString("Task block id such as t-3f9a, from task listing."),
 				"path":       schemaString("Note path containing the task."),
 				"lineNumber": schemaInteger("Task line number (1-based)."),
 				"lineHash":   schemaString("Task line hash from task listing."),
 				"completed":  schemaBoolean("New completed value."),
-			}, []string{"path", "lineNumber", "lineHash", "completed"}),
+			}, []string{"completed"}),
+		},
+		{
+			Name:        "tasks.status",
+			Description: "Set a task's status. Address the task by its block id, or by path, lineNumber and lineHash. Setting done on a recurring task (*every:) also adds its next occurrence, returned as next.",
+			InputSchema: schemaObject(map[string]any{
+				"id":         schemaString("Task block id such as t-3f9a, from task listing."),
+				"path":       schemaString("Note path containing the task."),
+				"lineNumber": schemaInteger("Task line number (1-based)."),
+				"lineHash":   schemaString("Task line hash from task listing."),
+				"status":     schemaString("open, done, in-progress, cancelled, deferred or blocked."),
+			}, []string{"status"}),
 		},
 		{
 			Name:        "tasks.archive",
-			Description: "Archive completed tasks by prefixing them with '~ '.",
+			Description: "Archive done and cancelled tasks by prefixing them with '~ '.",
+			InputSchema: schemaObject(map[string]any{}, nil),
+		},
+		{
+			Name:        "tasks.log",
+			Description: "Read the task completion log. Each entry records a task marked done or reopened, and when.",
+			InputSchema: schemaObject(map[string]any{
+				"from": schemaString("Optional first day (YYYY-MM-DD or a relative date such as -7d)."),
+				"to":   schemaString("Optional last day (YYYY-MM-DD or a relative date such as today)."),
+			}, nil),
+		},
+		{
+			Name:        "tasks.assignIds",
+			Description: "Add a block id (^t-…) to every task that lacks one, giving each task a stable id.",
 			InputSchema: schemaObject(map[string]any{}, nil),
 		},
 		{
@@ -273,14 +385,22 @@ func (a *Adapter) CallTool(ctx context.Context, name string, args any) (any, err
 		}
 		return a.client.CreateNote(ctx, payload)
 	case "note.update":
-		var payload scoli.UpdateNoteRequest
+		var payload struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+			ETag    string `json:"etag"`
+		}
 		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
 		}
 		if err := validatePath(payload.Path); err != nil {
 			return nil, err
 		}
-		return a.client.UpdateNote(ctx, payload)
+		return a.client.UpdateNote(ctx, scoli.UpdateNoteRequest{
+			Path:    payload.Path,
+			Content: payload.Content,
+			IfMatch: payload.ETag,
+		})
 	case "note.rename":
 		var payload scoli.RenameNoteRequest
 		if err := decodeInput(args, &payload); err != nil {
@@ -318,14 +438,44 @@ func (a *Adapter) CallTool(ctx context.Context, name string, args any) (any, err
 		}
 		return a.client.CreateSheet(ctx, payload)
 	case "sheet.update":
-		var payload scoli.UpdateSheetRequest
+		var payload struct {
+			Path     string               `json:"path"`
+			Data     [][]string           `json:"data"`
+			Settings *scoli.SheetSettings `json:"settings"`
+			ETag     string               `json:"etag"`
+		}
 		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
 		}
 		if err := validatePath(payload.Path); err != nil {
 			return nil, err
 		}
-		return a.client.UpdateSheet(ctx, payload)
+		return a.client.UpdateSheet(ctx, scoli.UpdateSheetRequest{
+			Path:     payload.Path,
+			Data:     payload.Data,
+			Settings: payload.Settings,
+			IfMatch:  payload.ETag,
+		})
+	case "sheet.operations":
+		var payload struct {
+			Path       string                 `json:"path"`
+			Operations []scoli.SheetOperation `json:"operations"`
+			ETag       string                 `json:"etag"`
+		}
+		if err := decodeInput(args, &payload); err != nil {
+			return nil, err
+		}
+		if err := validatePath(payload.Path); err != nil {
+			return nil, err
+		}
+		if len(payload.Operations) == 0 {
+			return nil, fmt.Errorf("operations are required")
+		}
+		return a.client.ApplySheetOperations(ctx, scoli.SheetOperationsRequest{
+			Path:       payload.Path,
+			Operations: payload.Operations,
+			IfMatch:    payload.ETag,
+		})
 	case "sheet.rename":
 		var payload scoli.RenameSheetRequest
 		if err := decodeInput(args, &payload); err != nil {
@@ -355,16 +505,30 @@ func (a *Adapter) CallTool(ctx context.Context, name string, args any) (any, err
 		if err := validatePath(payload.Path); err != nil {
 			return nil, err
 		}
+		if (payload.CSV == "") == (len(payload.File) == 0) {
+			return nil, fmt.Errorf("one of csv or file is required")
+		}
 		return a.client.ImportSheet(ctx, payload)
 	case "sheet.export":
-		payload, err := decodePath(args)
-		if err != nil {
+		var payload scoli.ExportSheetRequest
+		if err := decodeInput(args, &payload); err != nil {
+			return nil, err
+		}
+		for _, sheetPath := range append([]string{payload.Path}, payload.Paths...) {
+			if err := validatePath(sheetPath); err != nil {
+				return nil, err
+			}
+		}
+		return a.client.ExportSheet(ctx, payload)
+	case "sheet.query":
+		var payload scoli.SheetQueryRequest
+		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
 		}
 		if err := validatePath(payload.Path); err != nil {
 			return nil, err
 		}
-		return a.client.ExportSheet(ctx, payload.Path)
+		return a.client.QuerySheet(ctx, payload)
 	case "folder.create":
 		var payload scoli.FolderRequest
 		if err := decodeInput(args, &payload); err != nil {
@@ -395,6 +559,7 @@ func (a *Adapter) CallTool(ctx context.Context, name string, args any) (any, err
 	case "search":
 		var payload struct {
 			Query string `json:"query"`
+			Limit int    `json:"limit"`
 		}
 		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
@@ -402,34 +567,58 @@ func (a *Adapter) CallTool(ctx context.Context, name string, args any) (any, err
 		if strings.TrimSpace(payload.Query) == "" {
 			return nil, fmt.Errorf("query is required")
 		}
-		return a.client.Search(ctx, payload.Query)
+		return a.client.Search(ctx, payload.Query, payload.Limit)
 	case "tags.list":
 		return a.client.ListTags(ctx)
 	case "tasks.list":
 		var payload struct {
 			Path string `json:"path"`
+			scoli.TaskQuery
 		}
 		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
 		}
 		if payload.Path == "" {
-			return a.client.ListTasks(ctx)
+			return a.client.ListTasks(ctx, payload.TaskQuery)
+		}
+		if payload.TaskQuery != (scoli.TaskQuery{Tree: payload.Tree}) {
+			return nil, fmt.Errorf("path cannot be combined with filters")
 		}
 		if err := validatePath(payload.Path); err != nil {
 			return nil, err
 		}
-		return a.client.ListTasksForNote(ctx, payload.Path)
+		return a.client.ListTasksForNote(ctx, payload.Path, payload.Tree)
 	case "tasks.toggle":
 		var payload scoli.ToggleTaskRequest
 		if err := decodeInput(args, &payload); err != nil {
 			return nil, err
 		}
-		if err := validatePath(payload.Path); err != nil {
+		if err := validateTaskAddress(payload.ID, payload.Path); err != nil {
 			return nil, err
 		}
 		return a.client.ToggleTask(ctx, payload)
+	case "tasks.status":
+		var payload scoli.SetTaskStatusRequest
+		if err := decodeInput(args, &payload); err != nil {
+			return nil, err
+		}
+		if err := validateTaskAddress(payload.ID, payload.Path); err != nil {
+			return nil, err
+		}
+		return a.client.SetTaskStatus(ctx, payload)
 	case "tasks.archive":
 		return a.client.ArchiveTasks(ctx)
+	case "tasks.log":
+		var payload struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := decodeInput(args, &payload); err != nil {
+			return nil, err
+		}
+		return a.client.TaskLog(ctx, payload.From, payload.To)
+	case "tasks.assignIds":
+		return a.client.AssignTaskIDs(ctx)
 	case "settings.get":
 		return a.client.GetSettings(ctx)
 	case "settings.update":
@@ -504,6 +693,31 @@ func schemaBoolean(description string) map[string]any {
 	}
 }
 
+func schemaSheetSettings() map[string]any {
+	return schemaObject(map[string]any{
+		"columns": map[string]any{
+			"type":        "array",
+			"description": "Column definitions, by position.",
+			"items": schemaObject(map[string]any{
+				"name":   schemaString("Column name."),
+				"type":   schemaString("number, date, bool, select or text (default)."),
+				"width":  schemaInteger("Display width in pixels."),
+				"format": schemaString("Display format hint, such as 0.00."),
+				"options": map[string]any{
+					"type":        "array",
+					"description": "Allowed values of a select column.",
+					"items":       map[string]any{"type": "string"},
+				},
+			}, nil),
+		},
+		"frozenHeader": schemaBoolean("The first row holds column names and is not type checked."),
+		"sort": schemaObject(map[string]any{
+			"column":     schemaInteger("0-based column index."),
+			"descending": schemaBoolean("Sort in descending order."),
+		}, []string{"column"}),
+	}, nil)
+}
+
 func decodeInput(args any, dest any) error {
 	if args == nil {
 		return nil
@@ -540,6 +754,14 @@ func decodePath(args any) (struct {
 	return payload, nil
 }
 
+// validateTaskAddress accepts a task block id on its own, or a note path.
+func validateTaskAddress(id, rawPath string) error {
+	if strings.TrimSpace(id) != "" && rawPath == "" {
+		return nil
+	}
+	return validatePath(rawPath)
+}
+
 func validatePath(raw string) error {
 	if strings.HasPrefix(raw, "/") {
 		return fmt.Errorf("path must be relative")
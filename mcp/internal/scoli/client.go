@@ -3,11 +3,13 @@ package scoli
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -86,7 +88,7 @@ func (c *Client) CreateSheet(ctx context.Context, req CreateSheetRequest) (*Crea
 
 func (c *Client) UpdateNote(ctx context.Context, req UpdateNoteRequest) (*UpdateNoteResponse, error) {
 	var out UpdateNoteResponse
-	if err := c.doJSON(ctx, http.MethodPatch, "/notes", nil, req, &out); err != nil {
+	if err := c.doJSONWithHeaders(ctx, http.MethodPatch, "/notes", nil, ifMatchHeader(req.IfMatch), req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
@@ -94,14 +96,22 @@ func (c *Client) UpdateNote(ctx context.Context, req UpdateNoteRequest) (*Update
 
 func (c *Client) UpdateSheet(ctx context.Context, req UpdateSheetRequest) (*UpdateSheetResponse, error) {
 	var out UpdateSheetResponse
-	if err := c.doJSON(ctx, http.MethodPatch, "/sheets", nil, req, &out); err != nil {
+	if err := c.doJSONWithHeaders(ctx, http.MethodPatch, "/sheets", nil, ifMatchHeader(req.IfMatch), req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
-func (c *Client) RenameNote(ctx context.Context, req RenameNoteRequest) (*RenameNoteResponse, error) {
-	var out RenameNoteResponse
+func (c *Client) ApplySheetOperations(ctx context.Context, req SheetOperationsRequest) (*SheetOperationsResponse, error) {
+	var out SheetOperationsResponse
+	if err := c.doJSONWithHeaders(ctx, http.MethodPost, "/sheets/operations", nil, ifMatchHeader(req.IfMatch), req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) RenameNote(ctx context.Context, req RenameNoteRequest) (*RenameResponse, error) {
+	var out RenameResponse
 	if err := c.doJSON(ctx, http.MethodPatch, "/notes/rename", nil, req, &out); err != nil {
 		return nil, err
 	}
@@ -144,10 +154,52 @@ func (c *Client) ImportSheet(ctx context.Context, req ImportSheetRequest) (*Crea
 	return &out, nil
 }
 
-func (c *Client) ExportSheet(ctx context.Context, path string) (string, error) {
+func (c *Client) ExportSheet(ctx context.Context, req ExportSheetRequest) (string, error) {
 	query := url.Values{}
-	query.Set("path", path)
-	return c.doText(ctx, http.MethodGet, "/sheets/export", query, nil)
+	if req.Path != "" {
+		query.Add("path", req.Path)
+	}
+	for _, path := range req.Paths {
+		query.Add("path", path)
+	}
+	if req.Format != "" {
+		query.Set("format", req.Format)
+	}
+	if req.Values {
+		query.Set("values", "true")
+	}
+	content, err := c.doText(ctx, http.MethodGet, "/sheets/export", query, nil)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(req.Format, "xlsx") {
+		return base64.StdEncoding.EncodeToString([]byte(content)), nil
+	}
+	return content, nil
+}
+
+func (c *Client) QuerySheet(ctx context.Context, req SheetQueryRequest) (*SheetQueryResponse, error) {
+	query := url.Values{}
+	query.Set("path", req.Path)
+	for name, list := range map[string][]string{
+		"where":     req.Where,
+		"select":    req.Select,
+		"groupBy":   req.GroupBy,
+		"aggregate": req.Aggregate,
+		"sort":      req.Sort,
+	} {
+		for _, value := range list {
+			query.Add(name, value)
+		}
+	}
+	if req.Limit > 0 {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	}
+	var out SheetQueryResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/sheets/query", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
 func (c *Client) CreateFolder(ctx context.Context, req FolderRequest) (*FolderResponse, error) {
@@ -158,8 +210,8 @@ func (c *Client) CreateFolder(ctx context.Context, req FolderRequest) (*FolderRe
 	return &out, nil
 }
 
-func (c *Client) RenameFolder(ctx context.Context, req RenameFolderRequest) (*FolderResponse, error) {
-	var out FolderResponse
+func (c *Client) RenameFolder(ctx context.Context, req RenameFolderRequest) (*RenameResponse, error) {
+	var out RenameResponse
 	if err := c.doJSON(ctx, http.MethodPatch, "/folders", nil, req, &out); err != nil {
 		return nil, err
 	}
@@ -176,9 +228,12 @@ func (c *Client) DeleteFolder(ctx context.Context, path string) (*DeleteResponse
 	return &out, nil
 }
 
-func (c *Client) Search(ctx context.Context, queryText string) ([]SearchResult, error) {
+func (c *Client) Search(ctx context.Context, queryText string, limit int) ([]SearchResult, error) {
 	query := url.Values{}
 	query.Set("query", queryText)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
 	var out []SearchResult
 	if err := c.doJSON(ctx, http.MethodGet, "/search", query, nil, &out); err != nil {
 		return nil, err
@@ -194,17 +249,39 @@ func (c *Client) ListTags(ctx context.Context) ([]TagGroup, error) {
 	return out, nil
 }
 
-func (c *Client) ListTasks(ctx context.Context) (*TaskList, error) {
+func (c *Client) ListTasks(ctx context.Context, params TaskQuery) (*TaskList, error) {
+	query := url.Values{}
+	if params.Tree {
+		query.Set("tree", "true")
+	}
+	for key, value := range map[string]string{
+		"filter":  params.Filter,
+		"project": params.Project,
+		"tag":     params.Tag,
+		"status":  params.Status,
+		"text":    params.Text,
+		"dueFrom": params.DueFrom,
+		"dueTo":   params.DueTo,
+		"sort":    params.Sort,
+		"groupBy": params.GroupBy,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
 	var out TaskList
-	if err := c.doJSON(ctx, http.MethodGet, "/tasks", nil, nil, &out); err != nil {
+	if err := c.doJSON(ctx, http.MethodGet, "/tasks", query, nil, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
-func (c *Client) ListTasksForNote(ctx context.Context, path string) (*TaskList, error) {
+func (c *Client) ListTasksForNote(ctx context.Context, path string, tree bool) (*TaskList, error) {
 	query := url.Values{}
 	query.Set("path", path)
+	if tree {
+		query.Set("tree", "true")
+	}
 	var out TaskList
 	if err := c.doJSON(ctx, http.MethodGet, "/tasks/for-note", query, nil, &out); err != nil {
 		return nil, err
@@ -212,14 +289,22 @@ func (c *Client) ListTasksForNote(ctx context.Context, path string) (*TaskList,
 	return &out, nil
 }
 
-func (c *Client) ToggleTask(ctx context.Context, req ToggleTaskRequest) (*StatusResponse, error) {
-	var out StatusResponse
+func (c *Client) ToggleTask(ctx context.Context, req ToggleTaskRequest) (*ToggleTaskResponse, error) {
+	var out ToggleTaskResponse
 	if err := c.doJSON(ctx, http.MethodPatch, "/tasks/toggle", nil, req, &out); err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
+func (c *Client) SetTaskStatus(ctx context.Context, req SetTaskStatusRequest) (*ToggleTaskResponse, error) {
+	var out ToggleTaskResponse
+	if err := c.doJSON(ctx, http.MethodPatch, "/tasks/status", nil, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 func (c *Client) ArchiveTasks(ctx context.Context) (*ArchiveTasksResponse, error) {
 	var out ArchiveTasksResponse
 	if err := c.doJSON(ctx, http.MethodPatch, "/tasks/archive", nil, nil, &out); err != nil {
@@ -228,6 +313,29 @@ func (c *Client) ArchiveTasks(ctx context.Context) (*ArchiveTasksResponse, error
 	return &out, nil
 }
 
+func (c *Client) TaskLog(ctx context.Context, from, to string) (*TaskLogResponse, error) {
+	query := url.Values{}
+	if from != "" {
+		query.Set("from", from)
+	}
+	if to != "" {
+		query.Set("to", to)
+	}
+	var out TaskLogResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/tasks/log", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) AssignTaskIDs(ctx context.Context) (*AssignTaskIDsResponse, error) {
+	var out AssignTaskIDsResponse
+	if err := c.doJSON(ctx, http.MethodPatch, "/tasks/ids", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 func (c *Client) GetSettings(ctx context.Context) (*SettingsResponse, error) {
 	var out SettingsResponse
 	if err := c.doJSON(ctx, http.MethodGet, "/settings", nil, nil, &out); err != nil {
@@ -284,6 +392,10 @@ func (c *Client) doText(ctx context.Context, method, endpoint string, query url.
 }
 
 func (c *Client) doJSON(ctx context.Context, method, endpoint string, query url.Values, body any, out any) error {
+	return c.doJSONWithHeaders(ctx, method, endpoint, query, nil, body, out)
+}
+
+func (c *Client) doJSONWithHeaders(ctx context.Context, method, endpoint string, query url.Values, headers http.Header, body any, out any) error {
 	fullURL := c.BaseURL + endpoint
 	if len(query) > 0 {
 		fullURL += "?" + query.Encode()
@@ -305,6 +417,11 @@ func (c *Client) doJSON(ctx context.Context, method, endpoint string, query url.
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
 	resp, err := c.HTTP.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
@@ -327,6 +444,13 @@ func (c *Client) doJSON(ctx context.Context, method, endpoint string, query url.
 	return nil
 }
 
+func ifMatchHeader(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{"If-Match": []string{etag}}
+}
+
 func decodeAPIError(resp *http.Response) error {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
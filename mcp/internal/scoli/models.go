@@ -12,15 +12,49 @@ type TreeNode struct {
 }
 
 type Note struct {
-	Path     string `json:"path"`
-	Content  string `json:"content"`
-	Modified string `json:"modified"`
+	Path        string    `json:"path"`
+	Content     string    `json:"content"`
+	Modified    string    `json:"modified"`
+	ETag        string    `json:"etag"`
+	FrontMatter *NoteMeta `json:"frontMatter,omitempty"`
+}
+
+type NoteMeta struct {
+	Fields  map[string]any `json:"fields"`
+	Tags    []string       `json:"tags,omitempty"`
+	Aliases []string       `json:"aliases,omitempty"`
+	Created string         `json:"created,omitempty"`
+	Updated string         `json:"updated,omitempty"`
+	Project string         `json:"project,omitempty"`
+	Error   string         `json:"error,omitempty"`
 }
 
 type Sheet struct {
-	Path     string     `json:"path"`
-	Data     [][]string `json:"data"`
-	Modified string     `json:"modified"`
+	Path     string        `json:"path"`
+	Data     [][]string    `json:"data"`
+	Values   [][]string    `json:"values"`
+	Settings SheetSettings `json:"settings"`
+	Modified string        `json:"modified"`
+	ETag     string        `json:"etag"`
+}
+
+type SheetSettings struct {
+	Columns      []SheetColumn `json:"columns"`
+	FrozenHeader bool          `json:"frozenHeader"`
+	Sort         *SheetSort    `json:"sort,omitempty"`
+}
+
+type SheetColumn struct {
+	Name    string   `json:"name,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Width   int      `json:"width,omitempty"`
+	Format  string   `json:"format,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+type SheetSort struct {
+	Column     int  `json:"column"`
+	Descending bool `json:"descending,omitempty"`
 }
 
 type CreateNoteRequest struct {
@@ -33,28 +67,46 @@ type CreateNoteResponse struct {
 	Notice string `json:"notice,omitempty"`
 }
 
+// UpdateNoteRequest is sent as the request body. IfMatch, when set, is sent
+// as the If-Match header so the write fails if the note changed since it was
+// read.
 type UpdateNoteRequest struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
+	IfMatch string `json:"-"`
 }
 
 type UpdateNoteResponse struct {
-	Path string `json:"path"`
+	Path    string `json:"path"`
+	ETag    string `json:"etag"`
+	Content string `json:"content,omitempty"`
 }
 
 type RenameNoteRequest struct {
 	Path    string `json:"path"`
 	NewPath string `json:"newPath"`
+	DryRun  bool   `json:"dryRun,omitempty"`
 }
 
-type RenameNoteResponse struct {
-	Path    string `json:"path"`
-	NewPath string `json:"newPath"`
+type RenameResponse struct {
+	Path    string         `json:"path"`
+	NewPath string         `json:"newPath"`
+	DryRun  bool           `json:"dryRun,omitempty"`
+	Changes []RenameChange `json:"changes"`
+	Filters []string       `json:"filters,omitempty"`
+}
+
+type RenameChange struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
 }
 
 type CreateSheetRequest struct {
-	Path string     `json:"path"`
-	Data [][]string `json:"data"`
+	Path     string         `json:"path"`
+	Data     [][]string     `json:"data"`
+	Settings *SheetSettings `json:"settings,omitempty"`
 }
 
 type CreateSheetResponse struct {
@@ -62,12 +114,38 @@ type CreateSheetResponse struct {
 }
 
 type UpdateSheetRequest struct {
-	Path string     `json:"path"`
-	Data [][]string `json:"data"`
+	Path     string         `json:"path"`
+	Data     [][]string     `json:"data"`
+	Settings *SheetSettings `json:"settings,omitempty"`
+	IfMatch  string         `json:"-"`
 }
 
 type UpdateSheetResponse struct {
 	Path string `json:"path"`
+	ETag string `json:"etag"`
+}
+
+type SheetOperation struct {
+	Op     string     `json:"op"`
+	Row    *int       `json:"row,omitempty"`
+	Column *int       `json:"column,omitempty"`
+	Value  string     `json:"value,omitempty"`
+	Count  int        `json:"count,omitempty"`
+	To     *int       `json:"to,omitempty"`
+	Rows   [][]string `json:"rows,omitempty"`
+}
+
+type SheetOperationsRequest struct {
+	Path       string           `json:"path"`
+	Operations []SheetOperation `json:"operations"`
+	IfMatch    string           `json:"-"`
+}
+
+type SheetOperationsResponse struct {
+	Path    string `json:"path"`
+	ETag    string `json:"etag"`
+	Rows    int    `json:"rows"`
+	Columns int    `json:"columns"`
 }
 
 type RenameSheetRequest struct {
@@ -81,8 +159,35 @@ type RenameSheetResponse struct {
 }
 
 type ImportSheetRequest struct {
-	Path string `json:"path"`
-	CSV  string `json:"csv"`
+	Path      string `json:"path"`
+	CSV       string `json:"csv,omitempty"`
+	File      []byte `json:"file,omitempty"`
+	Worksheet string `json:"worksheet,omitempty"`
+}
+
+type ExportSheetRequest struct {
+	Path   string   `json:"path"`
+	Paths  []string `json:"paths"`
+	Format string   `json:"format"`
+	Values bool     `json:"values"`
+}
+
+type SheetQueryRequest struct {
+	Path      string   `json:"path"`
+	Where     []string `json:"where"`
+	Select    []string `json:"select"`
+	GroupBy   []string `json:"groupBy"`
+	Aggregate []string `json:"aggregate"`
+	Sort      []string `json:"sort"`
+	Limit     int      `json:"limit"`
+}
+
+type SheetQueryResponse struct {
+	Path       string   `json:"path"`
+	Columns    []string `json:"columns"`
+	Rows       [][]any  `json:"rows"`
+	SourceRows []int    `json:"sourceRows,omitempty"`
+	Total      int      `json:"total"`
 }
 
 type DeleteResponse struct {
@@ -96,6 +201,7 @@ type FolderRequest struct {
 type RenameFolderRequest struct {
 	Path    string `json:"path"`
 	NewPath string `json:"newPath"`
+	DryRun  bool   `json:"dryRun,omitempty"`
 }
 
 type FolderResponse struct {
@@ -104,9 +210,19 @@ type FolderResponse struct {
 }
 
 type SearchResult struct {
-	Path string `json:"path"`
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Path       string            `json:"path"`
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	ID         string            `json:"id,omitempty"`
+	Line       int               `json:"line,omitempty"`
+	Snippet    string            `json:"snippet,omitempty"`
+	Highlights []SearchHighlight `json:"highlights,omitempty"`
+	Score      float64           `json:"score,omitempty"`
+}
+
+type SearchHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
 type TagNote struct {
@@ -120,34 +236,71 @@ type TagGroup struct {
 }
 
 type Task struct {
-	ID         string   `json:"id"`
-	Path       string   `json:"path"`
-	LineNumber int      `json:"lineNumber"`
-	LineHash   string   `json:"lineHash"`
-	Text       string   `json:"text"`
-	Completed  bool     `json:"completed"`
-	Project    string   `json:"project"`
-	Tags       []string `json:"tags"`
-	Mentions   []string `json:"mentions"`
-	DueDate    string   `json:"dueDate"`
-	DueDateISO string   `json:"dueDateISO"`
-	Priority   int      `json:"priority"`
+	ID            string              `json:"id"`
+	Path          string              `json:"path"`
+	LineNumber    int                 `json:"lineNumber"`
+	LineHash      string              `json:"lineHash"`
+	Text          string              `json:"text"`
+	Completed     bool                `json:"completed"`
+	Status        string              `json:"status"`
+	Project       string              `json:"project"`
+	Tags          []string            `json:"tags"`
+	Mentions      []string            `json:"mentions"`
+	DueDate       string              `json:"dueDate"`
+	DueDateISO    string              `json:"dueDateISO"`
+	Priority      int                 `json:"priority"`
+	Recurrence    string              `json:"recurrence,omitempty"`
+	CompletedDate string              `json:"completedDate,omitempty"`
+	Meta          map[string][]string `json:"meta,omitempty"`
+	ParentID      string              `json:"parentId,omitempty"`
+	Children      []string            `json:"children,omitempty"`
+	Progress      *int                `json:"progress,omitempty"`
+	Subtasks      []Task              `json:"subtasks,omitempty"`
 }
 
 type TaskList struct {
-	Tasks  []Task `json:"tasks"`
-	Notice string `json:"notice,omitempty"`
+	Tasks  []Task      `json:"tasks"`
+	Groups []TaskGroup `json:"groups,omitempty"`
+	Notice string      `json:"notice,omitempty"`
+}
+
+type TaskGroup struct {
+	Key   string `json:"key"`
+	Tasks []Task `json:"tasks"`
+}
+
+type TaskQuery struct {
+	Tree    bool   `json:"tree,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+	Project string `json:"project,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Text    string `json:"text,omitempty"`
+	DueFrom string `json:"dueFrom,omitempty"`
+	DueTo   string `json:"dueTo,omitempty"`
+	Sort    string `json:"sort,omitempty"`
+	GroupBy string `json:"groupBy,omitempty"`
 }
 
 type ToggleTaskRequest struct {
-	Path       string `json:"path"`
-	LineNumber int    `json:"lineNumber"`
-	LineHash   string `json:"lineHash"`
+	Path       string `json:"path,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+	LineHash   string `json:"lineHash,omitempty"`
+	ID         string `json:"id,omitempty"`
 	Completed  bool   `json:"completed"`
 }
 
-type StatusResponse struct {
+type SetTaskStatusRequest struct {
+	Path       string `json:"path,omitempty"`
+	LineNumber int    `json:"lineNumber,omitempty"`
+	LineHash   string `json:"lineHash,omitempty"`
+	ID         string `json:"id,omitempty"`
+	Status     string `json:"status"`
+}
+
+type ToggleTaskResponse struct {
 	Status string `json:"status"`
+	Next   *Task  `json:"next,omitempty"`
 }
 
 type ArchiveTasksResponse struct {
@@ -155,6 +308,24 @@ type ArchiveTasksResponse struct {
 	Files    int `json:"files"`
 }
 
+type TaskLogEntry struct {
+	Time    string `json:"time"`
+	Event   string `json:"event"`
+	ID      string `json:"id"`
+	Path    string `json:"path"`
+	Text    string `json:"text"`
+	Project string `json:"project,omitempty"`
+}
+
+type TaskLogResponse struct {
+	Entries []TaskLogEntry `json:"entries"`
+}
+
+type AssignTaskIDsResponse struct {
+	Assigned int `json:"assigned"`
+	Files    int `json:"files"`
+}
+
 type SettingsResponse struct {
 	Settings Settings `json:"settings"`
 	Notice   string   `json:"notice,omitempty"`